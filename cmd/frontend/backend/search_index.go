@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+// SearchIndex backend.
+var SearchIndex = &searchIndex{}
+
+type searchIndex struct{}
+
+// BranchPatterns returns the patterns of the branches of repo (other than its
+// default branch, which is always indexed) that are configured to be indexed
+// for search. They come from the "search.index.branches" site configuration
+// property, and the "indexedBranches" property of the code host connection or
+// "repos.list" entry of repo.
+func (searchIndex) BranchPatterns(repo *types.Repo) []string {
+	return branchPatterns(conf.Get(), repo)
+}
+
+func branchPatterns(c *schema.SiteConfiguration, repo *types.Repo) []string {
+	patterns := append([]string(nil), c.SearchIndexBranches[string(repo.URI)]...)
+	for _, r := range c.ReposList {
+		if r.Path == string(repo.URI) {
+			patterns = append(patterns, r.IndexedBranches...)
+		}
+	}
+
+	if ext := repo.ExternalRepo; ext != nil {
+		// The ServiceID of repositories from code host connections is
+		// the normalized URL of the connection.
+		matchesService := func(connURL string) bool {
+			u, err := url.Parse(connURL)
+			return err == nil && reposource.NormalizeBaseURL(u).String() == ext.ServiceID
+		}
+		switch ext.ServiceType {
+		case "github":
+			for _, c := range c.Github {
+				if matchesService(c.Url) {
+					patterns = append(patterns, c.IndexedBranches...)
+				}
+			}
+		case "gitlab":
+			for _, c := range c.Gitlab {
+				if matchesService(c.Url) {
+					patterns = append(patterns, c.IndexedBranches...)
+				}
+			}
+		case "bitbucketServer":
+			for _, c := range c.BitbucketServer {
+				if matchesService(c.Url) {
+					patterns = append(patterns, c.IndexedBranches...)
+				}
+			}
+		}
+	}
+	return patterns
+}
+
+// Branches returns the branches of repo matching its BranchPatterns, as full
+// ref names (such as "refs/heads/release/1.0").
+func (s searchIndex) Branches(ctx context.Context, repo *types.Repo) ([]*git.Ref, error) {
+	patterns := s.BranchPatterns(repo)
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	// Unlike a ref glob in a search query, a branch name without glob
+	// characters only matches itself.
+	names := map[string]bool{}
+	var globs []search.RevisionSpecifier
+	for _, pattern := range patterns {
+		ref := "refs/heads/" + strings.TrimPrefix(pattern, "refs/heads/")
+		if strings.ContainsAny(pattern, "*?[") {
+			globs = append(globs, search.RevisionSpecifier{RefGlob: ref})
+		} else {
+			names[ref] = true
+		}
+	}
+	m, err := search.NewRefGlobMatcher(globs)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := git.ListRefs(ctx, gitserver.Repo{Name: repo.URI})
+	if err != nil {
+		return nil, err
+	}
+	var branches []*git.Ref
+	for _, ref := range refs {
+		if strings.HasPrefix(ref.Name, "refs/heads/") && (names[ref.Name] || m.Match(ref.Name)) {
+			branches = append(branches, ref)
+		}
+	}
+	return branches, nil
+}
@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestBranchPatterns(t *testing.T) {
+	c := &schema.SiteConfiguration{
+		SearchIndexBranches: map[string][]string{
+			"github.com/a/b": {"dev"},
+		},
+		ReposList: []*schema.Repository{
+			{Path: "example.com/c/d", IndexedBranches: []string{"release/*"}},
+		},
+		Github: []*schema.GitHubConnection{
+			{Url: "https://github.com/", IndexedBranches: []string{"stable"}},
+			{Url: "https://ghe.example.com", IndexedBranches: []string{"other"}},
+		},
+	}
+	github := func(uri string) *types.Repo {
+		return &types.Repo{
+			URI: api.RepoURI(uri),
+			ExternalRepo: &api.ExternalRepoSpec{
+				ID:          uri,
+				ServiceType: "github",
+				ServiceID:   "https://github.com/",
+			},
+		}
+	}
+	tests := []struct {
+		repo *types.Repo
+		want []string
+	}{
+		{repo: github("github.com/a/b"), want: []string{"dev", "stable"}},
+		{repo: github("github.com/x/y"), want: []string{"stable"}},
+		{repo: &types.Repo{URI: "example.com/c/d"}, want: []string{"release/*"}},
+		{repo: &types.Repo{URI: "example.com/e/f"}, want: nil},
+	}
+	for _, test := range tests {
+		if got := branchPatterns(c, test.repo); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.repo.URI, got, test.want)
+		}
+	}
+}
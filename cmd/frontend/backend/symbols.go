@@ -40,7 +40,22 @@ func (symbols) ListTags(ctx context.Context, args protocol.SearchArgs) ([]protoc
 	return result.Symbols, err
 }
 
+// Outline returns the symbols of a file from ctags, nested in their parents (such as the
+// methods of a class in the class).
+func (symbols) Outline(ctx context.Context, args protocol.OutlineArgs) ([]*protocol.OutlineSymbol, error) {
+	if Mocks.Symbols.Outline != nil {
+		return Mocks.Symbols.Outline(ctx, args)
+	}
+
+	result, err := symbolsclient.DefaultClient.Outline(ctx, args)
+	if result == nil {
+		return nil, err
+	}
+	return result.Symbols, err
+}
+
 // MockSymbols is used by tests to mock Symbols backend methods.
 type MockSymbols struct {
-	List func(ctx context.Context, repo api.RepoURI, commitID api.CommitID, mode string, params lspext.WorkspaceSymbolParams) ([]lsp.SymbolInformation, error)
+	List    func(ctx context.Context, repo api.RepoURI, commitID api.CommitID, mode string, params lspext.WorkspaceSymbolParams) ([]lsp.SymbolInformation, error)
+	Outline func(ctx context.Context, args protocol.OutlineArgs) ([]*protocol.OutlineSymbol, error)
 }
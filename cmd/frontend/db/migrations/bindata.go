@@ -176,6 +176,10 @@
 // ../../../../migrations/1528395555_.up.sql (710B)
 // ../../../../migrations/1528395556_.down.sql (63B)
 // ../../../../migrations/1528395556_.up.sql (64B)
+// ../../../../migrations/1528395557_.down.sql (33B)
+// ../../../../migrations/1528395557_.up.sql (344B)
+// ../../../../migrations/1528395558_.down.sql (75B)
+// ../../../../migrations/1528395558_.up.sql (178B)
 
 package migrations
 
@@ -3764,6 +3768,86 @@ func _1528395556_UpSql() (*asset, error) {
 	return a, nil
 }
 
+var __1528395557_DownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x72\x09\xf2\x0f\x50\x08\x71\x74\xf2\x71\x55\x28\x4a\x2d\xc8\x8f\x2f\x2d\x48\x49\x2c\x49\x8d\x2f\x4e\xce\x48\x4d\x29\xcd\x49\xb5\xe6\x02\x0c\x00\xe3\xe7\x33\xf9\x21\x00\x00\x00")
+
+func _1528395557_DownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395557_DownSql,
+		"1528395557_.down.sql",
+	)
+}
+
+func _1528395557_DownSql() (*asset, error) {
+	bytes, err := _1528395557_DownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395557_.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0xc8, 0x9f, 0x40, 0x97, 0x7a, 0xf3, 0xbe, 0xb9, 0x6e, 0xdd, 0xd8, 0x10, 0xe0, 0x43, 0x88, 0x8d, 0x68, 0x44, 0x86, 0x42, 0xe6, 0xa8, 0xa6, 0xa6, 0x26, 0xb6, 0x36, 0x10, 0x62, 0x1e, 0x5, 0x15}}
+	return a, nil
+}
+
+var __1528395557_UpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x8e\xc1\x4a\xc3\x40\x10\x86\xef\x79\x8a\xff\x98\x80\x6f\xe0\x69\x4d\xa6\x20\xae\xa9\x6c\xb7\x87\x9e\xc2\xda\x1d\x92\x81\x74\x13\x92\x89\x05\x9f\x5e\x4c\x14\x44\xd0\x1e\x87\xf9\xf8\xbf\xaf\x74\x64\x3c\xc1\x9b\x07\x4b\x98\x78\x1c\x9a\x65\x8c\x41\xb9\x99\xcf\x1d\xc7\xa5\x67\xe4\x19\x80\xed\x25\x11\x92\x94\x5b\x9e\xf0\xe2\x1e\x9f\x8d\x3b\xe1\x89\x4e\x70\xb4\x23\x47\x75\x49\x87\x15\xcb\x25\x16\xd8\xd7\xa8\xc8\x92\x27\x94\xe6\x50\x9a\x8a\xee\xd6\x99\xb8\x30\x54\x2e\x3c\x6b\xb8\x8c\xb8\x8a\x76\xeb\x89\xf7\x21\x31\xea\xbd\x47\x7d\xb4\x76\x43\xbf\x3a\x3e\x85\xd3\x5b\xe8\x9b\x34\xe3\x55\x5a\x49\xfa\x8b\xeb\xc3\xac\xdf\xd1\x71\x99\x82\xca\x90\xfe\x87\xcf\x5d\x48\x2d\xc7\x3f\x43\x7e\xfa\x63\x13\xf4\x76\x31\x2a\xda\x99\xa3\xf5\x48\xc3\x35\x2f\xb2\xe2\x3e\xfb\x18\x00\x0d\x94\xa2\x14\x58\x01\x00\x00")
+
+func _1528395557_UpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395557_UpSql,
+		"1528395557_.up.sql",
+	)
+}
+
+func _1528395557_UpSql() (*asset, error) {
+	bytes, err := _1528395557_UpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395557_.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x4b, 0x2b, 0xc9, 0x42, 0x0, 0x12, 0xe9, 0x49, 0x1, 0xe0, 0x2e, 0xb6, 0x97, 0x4d, 0x8, 0xce, 0xe6, 0x9, 0xd8, 0xce, 0x86, 0xd5, 0x52, 0xa5, 0xc2, 0x57, 0xa5, 0xa8, 0xe8, 0xa6, 0x74, 0xa9}}
+	return a, nil
+}
+
+var __1528395558_DownSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x72\x09\xf2\x0f\x50\xf0\xf4\x73\x71\x8d\x50\x28\x4a\x2d\xc8\x8f\x4f\xad\x28\x49\x2d\xca\x4b\xcc\x89\x2f\x4e\x2d\x2a\xcb\x4c\x4e\xb5\xe6\x72\xf4\x09\x71\x0d\x52\x08\x71\x74\xf2\x71\x05\x2b\x51\x00\x6b\x71\xf6\xf7\x09\xf5\xf5\x53\x48\x49\xcd\x49\x2d\x49\x4d\x89\x4f\x2c\xb1\xe6\x02\x0c\x00\xa7\x89\xfd\xfd\x4b\x00\x00\x00")
+
+func _1528395558_DownSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395558_DownSql,
+		"1528395558_.down.sql",
+	)
+}
+
+func _1528395558_DownSql() (*asset, error) {
+	bytes, err := _1528395558_DownSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395558_.down.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x68, 0x9d, 0xd1, 0x48, 0x5d, 0x10, 0x47, 0x17, 0x7b, 0x1c, 0xf3, 0xec, 0x46, 0x5f, 0x5d, 0xc3, 0xc8, 0x62, 0xca, 0x0, 0x8a, 0x33, 0x49, 0xa6, 0x9e, 0x27, 0xcf, 0xb, 0xd8, 0x80, 0x50, 0xcf}}
+	return a, nil
+}
+
+var __1528395558_UpSql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x64\xce\x4d\x0a\xc2\x30\x14\x04\xe0\x7d\x4f\x31\x4b\x05\x6f\xd0\x55\x6c\x1f\x58\x88\x29\xd4\x16\xdd\x85\x60\x1e\x18\xe8\x4f\x48\x1f\xfe\x9d\x5e\xc8\x4a\x74\x39\xf3\xc1\x30\x4a\xf7\xd4\xa1\x57\x7b\x4d\x48\x1c\x17\xa8\xba\x46\xd5\xea\xe1\x68\xe0\x79\x64\x61\x6f\x9d\x40\xc2\xc4\xab\xb8\x29\xe2\x11\xe4\x96\x23\xde\xcb\xcc\x65\x51\x75\xa4\x7a\x42\x63\x6a\xba\xe4\x05\xcb\x4f\xe1\x34\xbb\xd1\xae\x9c\xee\xe1\xca\x68\x4d\x86\xcd\x2f\x58\x79\x45\xde\xe1\xaf\x0e\x7e\x8b\xf3\x81\x3a\xfa\x7e\xd0\x9c\x60\x06\xad\xcb\xe2\x33\x00\xc1\xc7\xd2\x8f\xb2\x00\x00\x00")
+
+func _1528395558_UpSqlBytes() ([]byte, error) {
+	return bindataRead(
+		__1528395558_UpSql,
+		"1528395558_.up.sql",
+	)
+}
+
+func _1528395558_UpSql() (*asset, error) {
+	bytes, err := _1528395558_UpSqlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "1528395558_.up.sql", size: 0, mode: os.FileMode(0), modTime: time.Unix(0, 0)}
+	a := &asset{bytes: bytes, info: info, digest: [32]uint8{0x8f, 0x53, 0xdb, 0xf9, 0x5, 0xbd, 0x5f, 0xd7, 0xf2, 0x72, 0x34, 0x77, 0xbf, 0x2c, 0xaf, 0x52, 0x7f, 0xce, 0x1e, 0x42, 0x49, 0x8f, 0x44, 0xf7, 0x21, 0x1, 0xbc, 0x21, 0x61, 0x31, 0x88, 0xbe}}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -4205,18 +4289,24 @@ var _bindata = map[string]func() (*asset, error){
 
 	"1528395556_.down.sql": _1528395556_DownSql,
 
-	"1528395556_.up.sql": _1528395556_UpSql,
+	"1528395556_.up.sql":   _1528395556_UpSql,
+	"1528395557_.down.sql": _1528395557_DownSql,
+	"1528395557_.up.sql":   _1528395557_UpSql,
+	"1528395558_.down.sql": _1528395558_DownSql,
+	"1528395558_.up.sql":   _1528395558_UpSql,
 }
 
 // AssetDir returns the file names below a certain
 // directory embedded in the file by go-bindata.
 // For example if you run go-bindata on data/... and data contains the
 // following hierarchy:
-//     data/
-//       foo.txt
-//       img/
-//         a.png
-//         b.png
+//
+//	data/
+//	  foo.txt
+//	  img/
+//	    a.png
+//	    b.png
+//
 // then AssetDir("data") would return []string{"foo.txt", "img"},
 // AssetDir("data/img") would return []string{"a.png", "b.png"},
 // AssetDir("foo.txt") and AssetDir("notexist") would return an error, and
@@ -4425,6 +4515,10 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"1528395555_.up.sql":                                          &bintree{_1528395555_UpSql, map[string]*bintree{}},
 	"1528395556_.down.sql":                                        &bintree{_1528395556_DownSql, map[string]*bintree{}},
 	"1528395556_.up.sql":                                          &bintree{_1528395556_UpSql, map[string]*bintree{}},
+	"1528395557_.down.sql":                                        &bintree{_1528395557_DownSql, map[string]*bintree{}},
+	"1528395557_.up.sql":                                          &bintree{_1528395557_UpSql, map[string]*bintree{}},
+	"1528395558_.down.sql":                                        &bintree{_1528395558_DownSql, map[string]*bintree{}},
+	"1528395558_.up.sql":                                          &bintree{_1528395558_UpSql, map[string]*bintree{}},
 }}
 
 // RestoreAsset restores an asset under the given directory.
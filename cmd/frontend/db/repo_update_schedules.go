@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// repoUpdateSchedules stores the repo-updater scheduler state of repositories,
+// so that repo-updater can resume its schedule after it restarts.
+type repoUpdateSchedules struct{}
+
+// List returns the saved scheduler state of all repositories.
+func (s *repoUpdateSchedules) List(ctx context.Context) ([]*api.RepoUpdateSchedule, error) {
+	rows, err := dbconn.Global.QueryContext(ctx, `
+SELECT repo.uri, s.due, s.update_interval_ns, s.last_update_duration_ns, s.last_changed
+FROM repo_update_schedule s
+JOIN repo ON repo.id = s.repo_id
+WHERE repo.deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*api.RepoUpdateSchedule
+	for rows.Next() {
+		var (
+			sched                                  api.RepoUpdateSchedule
+			updateIntervalNs, lastUpdateDurationNs int64
+		)
+		if err := rows.Scan(&sched.Repo, &sched.Due, &updateIntervalNs, &lastUpdateDurationNs, &sched.LastChanged); err != nil {
+			return nil, err
+		}
+		sched.UpdateInterval = time.Duration(updateIntervalNs)
+		sched.LastUpdateDuration = time.Duration(lastUpdateDurationNs)
+		schedules = append(schedules, &sched)
+	}
+	return schedules, rows.Err()
+}
+
+const upsertRepoUpdateScheduleSQL = `
+INSERT INTO repo_update_schedule(repo_id, due, update_interval_ns, last_update_duration_ns, last_changed)
+SELECT id, $2, $3, $4, $5 FROM repo WHERE uri=$1
+ON CONFLICT (repo_id) DO UPDATE SET
+	due=excluded.due,
+	update_interval_ns=excluded.update_interval_ns,
+	last_update_duration_ns=excluded.last_update_duration_ns,
+	last_changed=excluded.last_changed,
+	updated_at=now()`
+
+// Upsert saves the scheduler state of the given repositories. Repositories
+// that don't exist are ignored.
+func (s *repoUpdateSchedules) Upsert(ctx context.Context, schedules []*api.RepoUpdateSchedule) error {
+	return Transaction(ctx, dbconn.Global, func(tx *sql.Tx) error {
+		for _, sched := range schedules {
+			_, err := tx.ExecContext(ctx, upsertRepoUpdateScheduleSQL,
+				sched.Repo, sched.Due, int64(sched.UpdateInterval), int64(sched.LastUpdateDuration), sched.LastChanged)
+			if err != nil {
+				return errors.Wrapf(err, "saving update schedule of %s", sched.Repo)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	dbtesting "github.com/sourcegraph/sourcegraph/cmd/frontend/db/testing"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+func TestRepoUpdateSchedules(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	ctx := dbtesting.TestContext(t)
+	mustCreate(ctx, t, &types.Repo{URI: "a"}, &types.Repo{URI: "b"})
+
+	due := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	changed := due.Add(-time.Hour)
+	schedules := []*api.RepoUpdateSchedule{
+		{Repo: "a", Due: due, UpdateInterval: time.Minute, LastUpdateDuration: time.Second, LastChanged: &changed},
+		{Repo: "b", Due: due, UpdateInterval: time.Hour, LastUpdateDuration: 2 * time.Second},
+		// Repositories that don't exist are ignored.
+		{Repo: "c", Due: due, UpdateInterval: time.Hour},
+	}
+	if err := RepoUpdateSchedules.Upsert(ctx, schedules); err != nil {
+		t.Fatal(err)
+	}
+	// Saving again updates the existing schedule.
+	schedules[1].UpdateInterval = 2 * time.Hour
+	if err := RepoUpdateSchedules.Upsert(ctx, schedules[1:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := RepoUpdateSchedules.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byRepo := map[api.RepoURI]*api.RepoUpdateSchedule{}
+	for _, sched := range got {
+		sched.Due = sched.Due.UTC()
+		if sched.LastChanged != nil {
+			c := sched.LastChanged.UTC()
+			sched.LastChanged = &c
+		}
+		byRepo[sched.Repo] = sched
+	}
+	want := map[api.RepoURI]*api.RepoUpdateSchedule{"a": schedules[0], "b": schedules[1]}
+	if !reflect.DeepEqual(byRepo, want) {
+		t.Errorf("got %+v, want %+v", byRepo, want)
+	}
+}
@@ -47,7 +47,7 @@ func (s *repos) Get(ctx context.Context, id api.RepoID) (*types.Repo, error) {
 		return Mocks.Repos.Get(ctx, id)
 	}
 
-	repos, err := s.getBySQL(ctx, sqlf.Sprintf("WHERE id=%d LIMIT 1", id))
+	repos, err := s.getBySQL(ctx, sqlf.Sprintf("WHERE id=%d AND deleted_at IS NULL LIMIT 1", id))
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +67,7 @@ func (s *repos) GetByURI(ctx context.Context, uri api.RepoURI) (*types.Repo, err
 		return Mocks.Repos.GetByURI(ctx, uri)
 	}
 
-	repos, err := s.getBySQL(ctx, sqlf.Sprintf("WHERE uri=%s LIMIT 1", uri))
+	repos, err := s.getBySQL(ctx, sqlf.Sprintf("WHERE uri=%s AND deleted_at IS NULL LIMIT 1", uri))
 	if err != nil {
 		return nil, err
 	}
@@ -258,7 +258,7 @@ func (s *repos) List(ctx context.Context, opt ReposListOptions) (results []*type
 // indexed-search). We special case just returning enabled names so that we
 // read much less data into memory.
 func (s *repos) ListEnabledNames(ctx context.Context) ([]string, error) {
-	q := sqlf.Sprintf("SELECT uri FROM repo WHERE enabled = true")
+	q := sqlf.Sprintf("SELECT uri FROM repo WHERE enabled = true AND deleted_at IS NULL")
 	rows, err := dbconn.Global.QueryContext(ctx, q.Query(sqlf.PostgresBindVar), q.Args()...)
 	if err != nil {
 		return nil, err
@@ -281,7 +281,7 @@ func (s *repos) ListEnabledNames(ctx context.Context) ([]string, error) {
 }
 
 func (*repos) listSQL(opt ReposListOptions) (conds []*sqlf.Query, err error) {
-	conds = []*sqlf.Query{sqlf.Sprintf("TRUE")}
+	conds = []*sqlf.Query{sqlf.Sprintf("deleted_at IS NULL")}
 	if opt.Query != "" && (len(opt.IncludePatterns) > 0 || opt.ExcludePattern != "") {
 		return nil, errors.New("Repos.List: Query and IncludePatterns/ExcludePattern options are mutually exclusive")
 	}
@@ -0,0 +1,248 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/keegancsmith/sqlf"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db/dbconn"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// ListByExternalService returns the repositories of the given external service, that is, the repositories whose
+// ExternalRepo has the given service type and ID.
+func (s *repos) ListByExternalService(ctx context.Context, serviceType, serviceID string) ([]*types.Repo, error) {
+	return s.getBySQL(ctx, sqlf.Sprintf("WHERE external_service_type=%s AND external_service_id=%s AND deleted_at IS NULL", serviceType, serviceID))
+}
+
+// syncRepo is a stored repository considered by Sync.
+type syncRepo struct {
+	id                       api.RepoID
+	uri                      api.RepoURI
+	enabled                  bool
+	spec                     *api.ExternalRepoSpec
+	fromService, stays, gone bool
+}
+
+// Sync updates the stored repositories of an external service to match the repositories on it, in a single
+// transaction (see api.ReposSyncRequest).
+//
+// The repositories in op.Deleted are soft-deleted. Their URI is prefixed with "DELETED-", so that another
+// repository can use it. The repositories in op.Repos that match a stored repository of the external service are
+// renamed if their URI changed, and their metadata is updated. The other repositories are created, unless a
+// repository without an ExternalRepo has the same URI (such as one added before external services were recorded),
+// in which case it is adopted instead.
+//
+// A stored repository of the external service that is not in op.Repos, or one without an ExternalRepo, is
+// soft-deleted if a repository is renamed to (or created with) its URI, since op.Repos lists all repositories on
+// the external service. Renames and creations are skipped if they conflict with a repository of another external
+// service, or with a repository in op.Repos that keeps its URI.
+func (s *repos) Sync(ctx context.Context, op api.ReposSyncRequest) (*api.ReposSyncResponse, error) {
+	resp := &api.ReposSyncResponse{Repos: make([]*api.Repo, len(op.Repos))}
+	err := Transaction(ctx, dbconn.Global, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE repo SET uri='DELETED-' || id || '-' || uri, deleted_at=now() WHERE id=ANY($1) AND external_service_type=$2 AND external_service_id=$3 AND deleted_at IS NULL",
+			pq.Array(op.Deleted), op.ServiceType, op.ServiceID)
+		if err != nil {
+			return errors.Wrap(err, "deleting repositories")
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		resp.Deleted = int(deleted)
+
+		// Load the stored repositories of the external service, and those with the URIs of op.Repos.
+		uris := make([]string, len(op.Repos))
+		for i, r := range op.Repos {
+			uris[i] = string(r.RepoURI)
+		}
+		rows, err := tx.QueryContext(ctx, `
+SELECT id, uri, enabled, external_id, external_service_type, external_service_id FROM repo
+WHERE ((external_service_type=$1 AND external_service_id=$2) OR uri=ANY($3::citext[])) AND deleted_at IS NULL
+FOR UPDATE`,
+			op.ServiceType, op.ServiceID, pq.Array(uris))
+		if err != nil {
+			return errors.Wrap(err, "listing repositories")
+		}
+		byExternalID := make(map[string]*syncRepo)
+		byURI := make(map[string]*syncRepo) // keyed by lowercase URI, since uri is citext
+		for rows.Next() {
+			var (
+				r    syncRepo
+				spec dbExternalRepoSpec
+			)
+			if err := rows.Scan(&r.id, &r.uri, &r.enabled, &spec.id, &spec.serviceType, &spec.serviceID); err != nil {
+				rows.Close()
+				return err
+			}
+			r.spec = spec.toAPISpec()
+			if r.spec != nil && r.spec.ServiceType == op.ServiceType && r.spec.ServiceID == op.ServiceID {
+				r.fromService = true
+				byExternalID[r.spec.ID] = &r
+			}
+			byURI[strings.ToLower(string(r.uri))] = &r
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// Match op.Repos to stored repositories, ignoring duplicates.
+		matched := make([]*syncRepo, len(op.Repos))
+		renames := make(map[int]bool) // indexes of op.Repos
+		duplicates := make(map[int]bool)
+		seenIDs := make(map[string]bool)
+		seenURIs := make(map[string]bool)
+		for i, r := range op.Repos {
+			if r.ExternalRepo == nil || r.ExternalRepo.ServiceType != op.ServiceType || r.ExternalRepo.ServiceID != op.ServiceID {
+				return errors.Errorf("repository %q is not from external service %s %s", r.RepoURI, op.ServiceType, op.ServiceID)
+			}
+			if seenIDs[r.ExternalRepo.ID] || seenURIs[strings.ToLower(string(r.RepoURI))] {
+				duplicates[i] = true
+				continue
+			}
+			seenIDs[r.ExternalRepo.ID] = true
+			seenURIs[strings.ToLower(string(r.RepoURI))] = true
+			if m := byExternalID[r.ExternalRepo.ID]; m != nil {
+				matched[i] = m
+				m.stays = true
+				if m.uri != r.RepoURI {
+					renames[i] = true
+				}
+			}
+		}
+
+		// conflict returns the stored repository with the URI of op.Repos[i] that must be soft-deleted, or
+		// whether op.Repos[i] must be skipped.
+		conflict := func(i int) (other *syncRepo, skip bool) {
+			other = byURI[strings.ToLower(string(op.Repos[i].RepoURI))]
+			switch {
+			case other == nil || other == matched[i] || other.gone:
+				return nil, false
+			case other.stays:
+				return nil, true
+			case other.fromService || other.spec == nil:
+				return other, false
+			default:
+				return nil, true
+			}
+		}
+
+		// Renamed repositories vacate their URI, unless the rename is skipped, so repeat until no more renames
+		// are skipped.
+		for changed := true; changed; {
+			changed = false
+			for i := range renames {
+				matched[i].gone = true
+			}
+			for i := range renames {
+				if _, skip := conflict(i); skip {
+					delete(renames, i)
+					matched[i].gone = false
+					changed = true
+				}
+			}
+		}
+
+		// Soft-delete conflicting repositories.
+		var conflicting []api.RepoID
+		creates := make(map[int]bool)
+		adopts := make(map[int]*syncRepo)
+		for i := range op.Repos {
+			if duplicates[i] || (matched[i] != nil && !renames[i]) {
+				continue
+			}
+			other, skip := conflict(i)
+			if skip {
+				continue
+			}
+			if other != nil && matched[i] == nil && other.spec == nil {
+				adopts[i] = other
+				other.stays = true
+				continue
+			}
+			if other != nil {
+				conflicting = append(conflicting, other.id)
+				other.gone = true
+			}
+			if matched[i] == nil {
+				creates[i] = true
+			}
+		}
+		if len(conflicting) > 0 {
+			res, err := tx.ExecContext(ctx, "UPDATE repo SET uri='DELETED-' || id || '-' || uri, deleted_at=now() WHERE id=ANY($1)", pq.Array(conflicting))
+			if err != nil {
+				return errors.Wrap(err, "deleting conflicting repositories")
+			}
+			deleted, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			resp.Deleted += int(deleted)
+		}
+
+		// Rename in two steps, so that repositories can swap URIs.
+		for i := range renames {
+			if _, err := tx.ExecContext(ctx, "UPDATE repo SET uri='RENAMING-' || id WHERE id=$1", matched[i].id); err != nil {
+				return errors.Wrapf(err, "renaming %s", matched[i].uri)
+			}
+		}
+		for i := range renames {
+			if _, err := tx.ExecContext(ctx, "UPDATE repo SET uri=$2 WHERE id=$1", matched[i].id, op.Repos[i].RepoURI); err != nil {
+				return errors.Wrapf(err, "renaming %s to %s", matched[i].uri, op.Repos[i].RepoURI)
+			}
+			matched[i].uri = op.Repos[i].RepoURI
+			resp.Renamed++
+		}
+
+		for i, r := range op.Repos {
+			spec := (&dbExternalRepoSpec{}).fromAPISpec(r.ExternalRepo)
+			switch {
+			case matched[i] != nil:
+				res, err := tx.ExecContext(ctx, "UPDATE repo SET description=$2, fork=$3, archived=$4 WHERE id=$1 AND (description IS DISTINCT FROM $2 OR fork IS DISTINCT FROM $3 OR archived <> $4)",
+					matched[i].id, r.Description, r.Fork, r.Archived)
+				if err != nil {
+					return errors.Wrapf(err, "updating %s", r.RepoURI)
+				}
+				if updated, err := res.RowsAffected(); err != nil {
+					return err
+				} else if updated > 0 {
+					resp.Updated++
+				}
+
+			case adopts[i] != nil:
+				_, err := tx.ExecContext(ctx, "UPDATE repo SET description=$2, fork=$3, archived=$4, external_id=$5, external_service_type=$6, external_service_id=$7 WHERE id=$1",
+					adopts[i].id, r.Description, r.Fork, r.Archived, spec.id, spec.serviceType, spec.serviceID)
+				if err != nil {
+					return errors.Wrapf(err, "updating %s", r.RepoURI)
+				}
+				matched[i] = adopts[i]
+				matched[i].spec = r.ExternalRepo
+				resp.Updated++
+
+			case creates[i]:
+				m := &syncRepo{uri: r.RepoURI, enabled: r.Enabled, spec: r.ExternalRepo}
+				err := tx.QueryRowContext(ctx, "INSERT INTO repo(uri, description, fork, language, enabled, external_id, external_service_type, external_service_id, archived) VALUES($1, $2, $3, '', $4, $5, $6, $7, $8) RETURNING id",
+					r.RepoURI, r.Description, r.Fork, r.Enabled, spec.id, spec.serviceType, spec.serviceID, r.Archived).Scan(&m.id)
+				if err != nil {
+					return errors.Wrapf(err, "creating %s", r.RepoURI)
+				}
+				matched[i] = m
+				resp.Created++
+
+			default:
+				continue // skipped
+			}
+			resp.Repos[i] = &api.Repo{ID: matched[i].id, ExternalRepo: r.ExternalRepo, URI: matched[i].uri, Enabled: matched[i].enabled}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
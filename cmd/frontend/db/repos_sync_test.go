@@ -0,0 +1,90 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	dbtesting "github.com/sourcegraph/sourcegraph/cmd/frontend/db/testing"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+func TestRepos_Sync(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	ctx := dbtesting.TestContext(t)
+
+	spec := func(serviceID, id string) *api.ExternalRepoSpec {
+		return &api.ExternalRepoSpec{ID: id, ServiceType: "t", ServiceID: serviceID}
+	}
+	for _, op := range []api.InsertRepoOp{
+		{URI: "a", ExternalRepo: spec("s", "1"), Enabled: true},
+		{URI: "b", ExternalRepo: spec("s", "2"), Enabled: true},
+		{URI: "c", Enabled: true},
+		{URI: "d", ExternalRepo: spec("other", "1"), Enabled: true},
+		{URI: "e", ExternalRepo: spec("s", "5"), Enabled: true},
+	} {
+		if err := Repos.Upsert(ctx, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, err := Repos.GetByURI(ctx, "e")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := Repos.Sync(ctx, api.ReposSyncRequest{
+		ServiceType: "t",
+		ServiceID:   "s",
+		Repos: []api.RepoCreateOrUpdateRequest{
+			// a and b swap URIs.
+			{RepoURI: "b", ExternalRepo: spec("s", "1")},
+			{RepoURI: "a", ExternalRepo: spec("s", "2"), Description: "x"},
+			// c has no external service, so it is adopted.
+			{RepoURI: "c", ExternalRepo: spec("s", "3")},
+			// d is from another external service, so it is skipped.
+			{RepoURI: "d", ExternalRepo: spec("s", "4")},
+			{RepoURI: "f", ExternalRepo: spec("s", "6"), Enabled: false},
+		},
+		Deleted: []api.RepoID{e.ID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Created != 1 || resp.Updated != 2 || resp.Renamed != 2 || resp.Deleted != 1 {
+		t.Errorf("got %+v, want 1 created, 2 updated, 2 renamed and 1 deleted", resp)
+	}
+	var uris []api.RepoURI
+	for _, r := range resp.Repos {
+		if r == nil {
+			uris = append(uris, "")
+			continue
+		}
+		uris = append(uris, r.URI)
+	}
+	if want := []api.RepoURI{"b", "a", "c", "", "f"}; !reflect.DeepEqual(uris, want) {
+		t.Errorf("got repos %v, want %v", uris, want)
+	}
+	if resp.Repos[4].Enabled {
+		t.Error("created repository f is enabled")
+	}
+
+	stored, err := Repos.ListByExternalService(ctx, "t", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedRepoURIs(stored), []api.RepoURI{"a", "b", "c", "f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got stored repos %v, want %v", got, want)
+	}
+	a, err := Repos.GetByURI(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ExternalRepo.ID != "2" || a.Description != "x" {
+		t.Errorf("got %+v, want the renamed repository with ID 2", a)
+	}
+	if _, err := Repos.Get(ctx, e.ID); err == nil {
+		t.Error("deleted repository e still exists")
+	}
+}
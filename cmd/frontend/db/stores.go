@@ -6,6 +6,7 @@ var (
 	DiscussionComments        = &discussionComments{}
 	DiscussionMailReplyTokens = &discussionMailReplyTokens{}
 	Repos                     = &repos{}
+	RepoUpdateSchedules       = &repoUpdateSchedules{}
 	Phabricator               = &phabricator{}
 	SavedQueries              = &savedQueries{}
 	Orgs                      = &orgs{}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/zoekt"
 	zoektquery "github.com/google/zoekt/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 )
 
@@ -79,8 +80,6 @@ func (r *repositoryTextSearchIndexStatus) IndexShardsCount() int32 {
 
 func (r *repositoryTextSearchIndexResolver) Refs(ctx context.Context) ([]*repositoryTextSearchIndexedRef, error) {
 	// We assume that the default branch for enabled repositories is always configured to be indexed.
-	//
-	// TODO(sqs): support configuring which branches should be indexed (add'l branches, not default branch, etc.).
 	defaultBranchRef, err := r.repo.DefaultBranch(ctx)
 	if err != nil {
 		return nil, err
@@ -90,6 +89,17 @@ func (r *repositoryTextSearchIndexResolver) Refs(ctx context.Context) ([]*reposi
 	}
 	refNames := []string{defaultBranchRef.name}
 
+	// Add the other branches that are configured to be indexed.
+	branches, err := backend.SearchIndex.Branches(ctx, r.repo.repo)
+	if err != nil {
+		return nil, err
+	}
+	for _, branch := range branches {
+		if branch.Name != defaultBranchRef.name {
+			refNames = append(refNames, branch.Name)
+		}
+	}
+
 	refs := make([]*repositoryTextSearchIndexedRef, len(refNames))
 	for i, refName := range refNames {
 		refs[i] = &repositoryTextSearchIndexedRef{ref: &gitRefResolver{name: refName, repo: r.repo}}
@@ -0,0 +1,83 @@
+package graphqlbackend
+
+import (
+	"context"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+	repoupdaterprotocol "github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+)
+
+func (r *siteResolver) RepositoryUpdateQueue(ctx context.Context, args *struct {
+	First *int32
+}) (*repositoryUpdateQueueResolver, error) {
+	// 🚨 SECURITY: Only site admins can view the update queue, since it lists all repositories.
+	if err := backend.CheckCurrentUserIsSiteAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	var req repoupdaterprotocol.UpdateQueueRequest
+	if args.First != nil {
+		req.First = int(*args.First)
+	}
+	queue, err := repoupdater.DefaultClient.UpdateQueue(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &repositoryUpdateQueueResolver{queue: queue}, nil
+}
+
+type repositoryUpdateQueueResolver struct {
+	queue *repoupdaterprotocol.UpdateQueue
+}
+
+func (r *repositoryUpdateQueueResolver) HighPriority() []*repositoryUpdateQueueItemResolver {
+	return toRepositoryUpdateQueueItemResolvers(r.queue.HighPriority)
+}
+
+func (r *repositoryUpdateQueueResolver) New() []*repositoryUpdateQueueItemResolver {
+	return toRepositoryUpdateQueueItemResolvers(r.queue.New)
+}
+
+func (r *repositoryUpdateQueueResolver) Scheduled() []*repositoryUpdateQueueItemResolver {
+	return toRepositoryUpdateQueueItemResolvers(r.queue.Scheduled)
+}
+
+func (r *repositoryUpdateQueueResolver) Unscheduled() []*repositoryUpdateQueueItemResolver {
+	return toRepositoryUpdateQueueItemResolvers(r.queue.Unscheduled)
+}
+
+func toRepositoryUpdateQueueItemResolvers(items []*repoupdaterprotocol.UpdateQueueItem) []*repositoryUpdateQueueItemResolver {
+	resolvers := make([]*repositoryUpdateQueueItemResolver, len(items))
+	for i, item := range items {
+		resolvers[i] = &repositoryUpdateQueueItemResolver{item: item}
+	}
+	return resolvers
+}
+
+type repositoryUpdateQueueItemResolver struct {
+	item *repoupdaterprotocol.UpdateQueueItem
+}
+
+func (r *repositoryUpdateQueueItemResolver) Name() string { return string(r.item.Repo) }
+
+func (r *repositoryUpdateQueueItemResolver) Due() string { return r.item.Due.Format(time.RFC3339) }
+
+func (r *repositoryUpdateQueueItemResolver) UpdateIntervalSeconds() int32 {
+	return int32(r.item.UpdateInterval / time.Second)
+}
+
+func (r *repositoryUpdateQueueItemResolver) LastUpdateDurationMilliseconds() int32 {
+	return int32(r.item.LastUpdateDuration / time.Millisecond)
+}
+
+func (r *repositoryUpdateQueueItemResolver) LastChanged() *string {
+	if r.item.LastChanged == nil {
+		return nil
+	}
+	s := r.item.LastChanged.Format(time.RFC3339)
+	return &s
+}
+
+func (r *repositoryUpdateQueueItemResolver) IsUpdating() bool { return r.item.IsUpdating }
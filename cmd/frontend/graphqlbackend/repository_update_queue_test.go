@@ -0,0 +1,73 @@
+package graphqlbackend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/graph-gophers/graphql-go/gqltesting"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+)
+
+func TestRepositoryUpdateQueue(t *testing.T) {
+	resetMocks()
+	db.Mocks.Users.GetByCurrentAuthUser = func(context.Context) (*types.User, error) {
+		return &types.User{SiteAdmin: true}, nil
+	}
+
+	due := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	repoupdater.MockUpdateQueue = func(ctx context.Context, args protocol.UpdateQueueRequest) (*protocol.UpdateQueue, error) {
+		if args.First != 1 {
+			t.Errorf("got first %d, want 1", args.First)
+		}
+		return &protocol.UpdateQueue{
+			HighPriority: []*protocol.UpdateQueueItem{{Repo: "a", Due: due, UpdateInterval: time.Minute, LastUpdateDuration: 1500 * time.Millisecond, IsUpdating: true}},
+			Scheduled:    []*protocol.UpdateQueueItem{{Repo: "b", Due: due, UpdateInterval: time.Hour, LastChanged: &due}},
+		}, nil
+	}
+	defer func() { repoupdater.MockUpdateQueue = nil }()
+
+	gqltesting.RunTests(t, []*gqltesting.Test{
+		{
+			Schema: GraphQLSchema,
+			Query: `
+				{
+					site {
+						repositoryUpdateQueue(first: 1) {
+							highPriority { name due updateIntervalSeconds lastUpdateDurationMilliseconds lastChanged isUpdating }
+							new { name }
+							scheduled { name updateIntervalSeconds lastChanged }
+							unscheduled { name }
+						}
+					}
+				}
+			`,
+			ExpectedResult: `
+				{
+					"site": {
+						"repositoryUpdateQueue": {
+							"highPriority": [{
+								"name": "a",
+								"due": "2018-10-01T12:00:00Z",
+								"updateIntervalSeconds": 60,
+								"lastUpdateDurationMilliseconds": 1500,
+								"lastChanged": null,
+								"isUpdating": true
+							}],
+							"new": [],
+							"scheduled": [{
+								"name": "b",
+								"updateIntervalSeconds": 3600,
+								"lastChanged": "2018-10-01T12:00:00Z"
+							}],
+							"unscheduled": []
+						}
+					}
+				}
+			`,
+		},
+	})
+}
@@ -237,6 +237,22 @@ type Mutation {
         # The URL to the phabricator instance (e.g. http://phabricator.sgdev.org).
         url: String!
     ): EmptyResponse
+    # EXPERIMENTAL: Creates a commit by applying a patch (such as the concatenated replacementDiff
+    # fields of a search with a replacement) to a base commit, and creates the ref
+    # "sourcegraph/replace/NAME" pointing at it. The commit is authored by the current user.
+    createCommitFromPatch(
+        # The repository to create the commit in.
+        repository: ID!
+        # The full commit ID that the patch is based on.
+        baseCommit: String!
+        # The patch, in unified diff format.
+        patch: String!
+        # The name of the ref to create (without the "sourcegraph/replace/" prefix). It must not
+        # already exist.
+        name: String!
+        # The commit message.
+        message: String!
+    ): GitCommit!
     # Resolves a revision for a given diff from Phabricator.
     resolvePhabricatorDiff(
         # The name of the repository that the diff is based on.
@@ -720,6 +736,10 @@ type Query {
     search(
         # The search query (such as "foo" or "repo:myrepo foo").
         query: String = ""
+        # EXPERIMENTAL: If set, each match of the query's pattern is replaced with this template, and
+        # file matches have a replacementDiff instead of line matches. The template may refer to
+        # capture groups in the pattern (such as "$1" or "${name}").
+        replacement: String
     ): Search
     # All saved queries configured for the current user, merged from all configurations.
     savedQueries: [SavedQuery!]!
@@ -770,6 +790,98 @@ type Search {
     # cached and thus quicker to query. Useful for e.g. querying sparkline
     # data.
     stats: SearchResultsStats!
+    # EXPERIMENTAL: Counts the results of the search, grouped by the given
+    # property. Unlike the results field, this searches (nearly) the entire
+    # result set, and only the counts are returned. The counts are
+    # approximate: at most 10,000 results (e.g. file matches) are counted, and
+    # SearchAggregation.limitHit is true if there were more.
+    aggregate(by: SearchAggregationBy!): SearchAggregation!
+    # EXPERIMENTAL: How the search would be run, for debugging searches that are slow or return no
+    # results. Only the repositories are resolved; the search itself is not run.
+    plan: SearchPlan!
+}
+
+# EXPERIMENTAL: How a search query would be run.
+type SearchPlan {
+    # The parsed query, as a normalized query string. For a boolean query, this shows how the
+    # operators are grouped.
+    parseTree: String!
+    # The field values of the query after type checking. The pattern is the field with an empty
+    # name.
+    fields: [SearchPlanField!]!
+    # For a boolean query, the plans for each of its branches (the query in disjunctive normal
+    # form). Each branch is run as a separate search, and the results are combined. The other
+    # fields are not set for a boolean query.
+    branches: [SearchPlan!]
+    # The types of results that would be searched for, such as "file" or "repo".
+    resultTypes: [String!]!
+    # The number of repositories that would be searched.
+    repositoryCount: Int!
+    # The number of repositories that would be searched with the index.
+    indexedRepositoryCount: Int!
+    # The number of repositories that would be searched without the index.
+    unindexedRepositoryCount: Int!
+    # The number of repository revisions that don't exist or would not be searched (e.g. because
+    # of index:only).
+    missingRepositoryRevisionCount: Int!
+    # Whether there are more matching repositories than the maximum number that is searched.
+    repositoryLimitHit: Boolean!
+    # Whether the index could not be reached to find out which repositories are indexed.
+    indexUnavailable: Boolean!
+    # The pattern that would be sent to the search backends.
+    patternInfo: JSONValue
+    # The query that would be sent to the index, if any repositories are searched with it.
+    zoektQuery: String
+    # The error that the search would fail with, if any.
+    error: String
+}
+
+# A field value in a search query plan.
+type SearchPlanField {
+    # The field name (after resolving aliases), or the empty string for the pattern.
+    field: String!
+    # The value.
+    value: String!
+    # The type of the value: STRING, REGEXP or BOOL.
+    type: String!
+    # Whether the value is negated (e.g. -file:foo).
+    negated: Boolean!
+}
+
+# The property that search results are grouped by in an aggregation.
+enum SearchAggregationBy {
+    # The repository of the result.
+    REPO
+    # The language of the file, detected from its name. Only file matches are
+    # counted.
+    LANG
+    # The top-level directory of the file. Only file matches are counted.
+    PATH_PREFIX
+    # The author of the commit. Only commit results (type:commit or
+    # type:diff) are counted.
+    AUTHOR
+}
+
+# Counts of search results, grouped by some property.
+type SearchAggregation {
+    # The groups, in descending order of count.
+    groups: [SearchAggregationGroup!]!
+    # Whether the counts may be incomplete, e.g. because the result limit was
+    # hit or some repositories could not be searched.
+    limitHit: Boolean!
+}
+
+# A group of search results in an aggregation.
+type SearchAggregationGroup {
+    # The value of the property that the results are grouped by, e.g. the
+    # repository name.
+    label: String!
+    # The number of results in the group. Like SearchResults.resultCount, a
+    # file match counts as its number of line matches.
+    count: Int!
+    # Whether the count may be incomplete because a file had too many matches
+    # or a search limit was hit in the repository.
+    limitHit: Boolean!
 }
 
 # A search result.
@@ -1441,6 +1553,14 @@ type SymbolConnection {
     pageInfo: PageInfo!
 }
 
+# A symbol in the outline of a file.
+type OutlineSymbol {
+    # The symbol.
+    symbol: Symbol!
+    # The symbols contained in this symbol (such as the methods of a class), in the order they are defined.
+    children: [OutlineSymbol!]!
+}
+
 # A Git object ID (SHA-1 hash, 40 hexadecimal characters).
 scalar GitObjectID
 
@@ -1894,6 +2014,9 @@ type GitBlob implements TreeEntry & File2 {
         # Return symbols matching the query.
         query: String
     ): SymbolConnection!
+    # The symbols defined in this blob, with the symbols they contain (such as the methods of a class) nested in
+    # them. The hierarchy comes from ctags, so it is available in all languages that ctags supports.
+    outline: [OutlineSymbol!]!
     # Always false, since a blob is a file, not directory.
     isSingleChild(
         # Returns the first n files in the tree.
@@ -1931,6 +2054,13 @@ type FileMatch {
     lineMatches: [LineMatch!]!
     # Whether or not the limit was hit.
     limitHit: Boolean!
+    # EXPERIMENTAL: A unified diff of the file with each match replaced, if the search has a
+    # replacement. It can be passed to the createCommitFromPatch mutation.
+    replacementDiff: String
+    # The refs on which this file was found, if the search used a ref glob (such as
+    # repo:foo@*refs/heads/release/*). A file with the same contents on several refs is only
+    # returned once.
+    sourceRefs: [GitRef!]!
 }
 
 # A line match.
@@ -1943,6 +2073,12 @@ type LineMatch {
     offsetAndLengths: [[Int!]!]!
     # Whether or not the limit was hit.
     limitHit: Boolean!
+    # The lines preceding the matched line, in file order. Empty unless the search query requests context
+    # lines (e.g. "context:3").
+    beforeContext: [String!]!
+    # The lines following the matched line, in file order. Empty unless the search query requests context
+    # lines (e.g. "context:3").
+    afterContext: [String!]!
 }
 
 # Dependency references.
@@ -2671,6 +2807,14 @@ type Site implements ConfigurationSubject {
     canReloadSite: Boolean!
     # Whether the viewer can modify the subject's configuration.
     viewerCanAdminister: Boolean!
+    # The queues of repositories that are updated from their code hosts, as scheduled by
+    # repo-updater.
+    #
+    # Only site admins may view this field.
+    repositoryUpdateQueue(
+        # Returns the first n repositories from each queue.
+        first: Int
+    ): RepositoryUpdateQueue!
     # Lists all language servers.
     langServers: [LangServer!]!
     # The language server for a given language (if exists, otherwise null)
@@ -2735,6 +2879,36 @@ type Site implements ConfigurationSubject {
     ): SiteActivity!
 }
 
+# The queues of repositories that are updated from their code hosts, ordered by priority.
+type RepositoryUpdateQueue {
+    # Repositories that were requested to be updated next, such as because a user viewed them or
+    # they were pushed to.
+    highPriority: [RepositoryUpdateQueueItem!]!
+    # Repositories that were never updated. They are updated when there is spare capacity.
+    new: [RepositoryUpdateQueueItem!]!
+    # Repositories that are updated periodically, ordered by when they are next due.
+    scheduled: [RepositoryUpdateQueueItem!]!
+    # Repositories that are not updated periodically.
+    unscheduled: [RepositoryUpdateQueueItem!]!
+}
+
+# A repository in the queues of repositories that are updated from their code hosts.
+type RepositoryUpdateQueueItem {
+    # The name of the repository.
+    name: String!
+    # When the repository is next due to be updated.
+    due: String!
+    # How often the repository is updated, in seconds. Repositories that changed recently are
+    # updated more often.
+    updateIntervalSeconds: Int!
+    # How long the last update of the repository took, in milliseconds.
+    lastUpdateDurationMilliseconds: Int!
+    # When the repository last changed, if known.
+    lastChanged: String
+    # Whether the repository is being updated.
+    isUpdating: Boolean!
+}
+
 # The configuration for a site.
 type SiteConfiguration {
     # The effective configuration JSON. This will lag behind the pendingContents
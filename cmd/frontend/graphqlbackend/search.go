@@ -48,16 +48,20 @@ func maxReposToSearch() int {
 }
 
 // Search provides search results and suggestions.
-func (r *schemaResolver) Search(args *struct {
-	Query string
-}) (*searchResolver, error) {
+type searchArgs struct {
+	Query       string
+	Replacement *string
+}
+
+func (r *schemaResolver) Search(args *searchArgs) (*searchResolver, error) {
 	query, err := query.ParseAndCheck(args.Query)
 	if err != nil {
 		return nil, err
 	}
 	return &searchResolver{
-		root:  r,
-		query: query,
+		root:        r,
+		query:       query,
+		replacement: args.Replacement,
 	}, nil
 }
 
@@ -78,6 +82,18 @@ type searchResolver struct {
 
 	query *query.Query // the parsed search query
 
+	// replacement, if non-nil, is the template that each match is replaced
+	// with. File matches then have a diff instead of line matches.
+	replacement *string
+
+	// maxResultsOverride, if non-zero, is used instead of the count: or max:
+	// value. Aggregations use it to search (nearly) the entire result set.
+	maxResultsOverride int32
+
+	// countOnly is set by aggregations, which only count the results. See
+	// search.PatternInfo.CountOnly.
+	countOnly bool
+
 	// Cached resolveRepositories results.
 	reposMu                   sync.Mutex
 	repoRevs, missingRepoRevs []*search.RepositoryRevisions
@@ -92,6 +108,9 @@ func (r *searchResolver) rawQuery() string {
 }
 
 func (r *searchResolver) countIsSet() bool {
+	if r.maxResultsOverride > 0 {
+		return true
+	}
 	count, _ := r.query.StringValues(query.FieldCount)
 	max, _ := r.query.StringValues(query.FieldMax)
 	return len(count) > 0 || len(max) > 0
@@ -100,6 +119,9 @@ func (r *searchResolver) countIsSet() bool {
 const defaultMaxSearchResults = 30
 
 func (r *searchResolver) maxResults() int32 {
+	if r.maxResultsOverride > 0 {
+		return r.maxResultsOverride
+	}
 	count, _ := r.query.StringValues(query.FieldCount)
 	if len(count) > 0 {
 		n, _ := strconv.Atoi(count[0])
@@ -229,6 +251,9 @@ func (r *searchResolver) resolveRepositories(ctx context.Context, effectiveRepoF
 		noArchived:       archived == No || archived == False,
 	})
 	tr.LazyPrintf("resolveRepositories - done")
+	if err == nil {
+		repoRevs, repoResults, err = r.filterRepositoriesByPredicates(ctx, repoRevs, repoResults)
+	}
 	if effectiveRepoFieldValues == nil {
 		r.repoRevs = repoRevs
 		r.missingRepoRevs = missingRepoRevs
@@ -239,6 +264,44 @@ func (r *searchResolver) resolveRepositories(ctx context.Context, effectiveRepoF
 	return repoRevs, missingRepoRevs, repoResults, overLimit, err
 }
 
+// filterRepositoriesByPredicates removes the repositories which don't satisfy
+// the repohasfile: and repohascommitafter: fields from repoRevs and
+// repoResults.
+func (r *searchResolver) filterRepositoriesByPredicates(ctx context.Context, repoRevs []*search.RepositoryRevisions, repoResults []*searchSuggestionResolver) ([]*search.RepositoryRevisions, []*searchSuggestionResolver, error) {
+	hasFile, minusHasFile := r.query.RegexpPatterns(query.FieldRepoHasFile)
+	commitAfter, _ := r.query.StringValue(query.FieldRepoHasCommitAfter)
+	if len(hasFile) == 0 && len(minusHasFile) == 0 && commitAfter == "" {
+		return repoRevs, repoResults, nil
+	}
+
+	var err error
+	if len(hasFile) > 0 || len(minusHasFile) > 0 {
+		repoRevs, err = filterRepoHasFile(ctx, repoRevs, hasFile, minusHasFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if commitAfter != "" {
+		repoRevs, err = filterRepoHasCommitAfter(ctx, repoRevs, commitAfter)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	kept := make(map[api.RepoURI]bool, len(repoRevs))
+	for _, repoRev := range repoRevs {
+		kept[repoRev.Repo.URI] = true
+	}
+	filteredResults := repoResults[:0:0]
+	for _, result := range repoResults {
+		if repo, ok := result.result.(*repositoryResolver); ok && !kept[repo.repo.URI] {
+			continue
+		}
+		filteredResults = append(filteredResults, result)
+	}
+	return repoRevs, filteredResults, nil
+}
+
 // a patternRevspec maps an include pattern to a list of revisions
 // for repos matching that pattern. "map" in this case does not mean
 // an actual map, because we want regexp matches, not identity matches.
@@ -620,12 +683,8 @@ func searchTree(ctx context.Context, matcher matcher, repos []*search.Repository
 	)
 	done := make(chan error, len(repos))
 	for _, repoRev := range repos {
-		if len(repoRev.Revs) >= 2 {
-			return nil, errMultipleRevsNotSupported
-		}
-
-		go func(repoRev search.RepositoryRevisions) {
-			fileResults, err := searchTreeForRepo(ctx, matcher, repoRev, limit, true)
+		go func(repoRev *search.RepositoryRevisions) {
+			fileResults, err := searchTreeForRepoRevs(ctx, matcher, repoRev, limit, true)
 			if err != nil {
 				done <- err
 				return
@@ -634,7 +693,7 @@ func searchTree(ctx context.Context, matcher matcher, repos []*search.Repository
 			res = append(res, fileResults...)
 			resMu.Unlock()
 			done <- nil
-		}(*repoRev)
+		}(repoRev)
 	}
 	for range repos {
 		if err := <-done; err != nil {
@@ -647,6 +706,45 @@ func searchTree(ctx context.Context, matcher matcher, repos []*search.Repository
 	return res, nil
 }
 
+// searchTreeForRepoRevs is like searchTreeForRepo, except that it searches
+// all revisions of repoRev, including the refs matching its ref globs. A path
+// found in several revisions is only included once.
+func searchTreeForRepoRevs(ctx context.Context, matcher matcher, repoRev *search.RepositoryRevisions, limit int, includeDirs bool) ([]*searchSuggestionResolver, error) {
+	if len(repoRev.Revs) <= 1 && !repoRev.HasRefGlobs() {
+		return searchTreeForRepo(ctx, matcher, *repoRev, limit, includeDirs)
+	}
+
+	revs, err := expandRevisions(ctx, repoRev)
+	if err != nil {
+		return nil, err
+	}
+	resultsByRev := make([][]*searchSuggestionResolver, len(revs))
+	err = forEachRevision(ctx, revs, func(ctx context.Context, i int, rev searchRevision) error {
+		var err error
+		resultsByRev[i], err = searchTreeForRepo(ctx, matcher, *singleRevision(repoRev, rev), limit, includeDirs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*searchSuggestionResolver
+	seen := map[string]bool{}
+	for _, results := range resultsByRev {
+		for _, r := range results {
+			if !seen[r.label] {
+				seen[r.label] = true
+				res = append(res, r)
+			}
+		}
+	}
+	sortSearchSuggestions(res)
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
 var mockSearchFilesForRepo func(matcher matcher, repoRevs search.RepositoryRevisions, limit int, includeDirs bool) ([]*searchSuggestionResolver, error)
 
 // searchTreeForRepo searches the specified repository for files whose name matches
@@ -881,24 +979,9 @@ func sortSearchSuggestions(s []*searchSuggestionResolver) {
 // and -lang: filter values in a search query. For example, a query containing "lang:go" should
 // include files whose paths match /\.go$/.
 func langIncludeExcludePatterns(values, negatedValues []string) (includePatterns, excludePatterns []string, err error) {
-	lookup := func(value string) *filelang.Language {
-		value = strings.ToLower(value)
-		for _, lang := range filelang.Langs {
-			if strings.ToLower(lang.Name) == value {
-				return lang
-			}
-			for _, alias := range lang.Aliases {
-				if alias == value {
-					return lang
-				}
-			}
-		}
-		return nil
-	}
-
 	do := func(values []string, patterns *[]string) error {
 		for _, value := range values {
-			lang := lookup(value)
+			lang := lookupLanguage(value)
 			if lang == nil {
 				return fmt.Errorf("unknown language: %q", value)
 			}
@@ -921,6 +1004,23 @@ func langIncludeExcludePatterns(values, negatedValues []string) (includePatterns
 	return includePatterns, excludePatterns, nil
 }
 
+// lookupLanguage returns the language with the name or alias value, or nil if
+// there is none.
+func lookupLanguage(value string) *filelang.Language {
+	value = strings.ToLower(value)
+	for _, lang := range filelang.Langs {
+		if strings.ToLower(lang.Name) == value {
+			return lang
+		}
+		for _, alias := range lang.Aliases {
+			if alias == value {
+				return lang
+			}
+		}
+	}
+	return nil
+}
+
 // handleRepoSearchResult handles the limitHit and searchErr returned by a search function,
 // updating common as to reflect that new information. If searchErr is a fatal error,
 // it returns a non-nil error; otherwise, if searchErr == nil or a non-fatal error, it returns a
@@ -948,5 +1048,3 @@ func handleRepoSearchResult(common *searchResultsCommon, repoRev search.Reposito
 	}
 	return nil
 }
-
-var errMultipleRevsNotSupported = errors.New("not yet supported: searching multiple revs in the same repo")
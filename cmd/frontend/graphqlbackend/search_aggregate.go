@@ -0,0 +1,172 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/pkg/inventory/filelang"
+)
+
+// maxAggregationResults is the result limit used when computing
+// aggregations. It is much higher than the limit for a page of results, since
+// the results are only counted and never returned to the client. Indexed
+// search and searcher count the matches in each file without returning them
+// (see search.PatternInfo.CountOnly), so a result is cheap, but the number of
+// results is still capped. The schema documents that the counts are
+// approximate.
+const maxAggregationResults = 10000
+
+var langsByFilename = filelang.Langs.CompileByFilename()
+
+func (r *searchResolver) Aggregate(ctx context.Context, args *struct{ By string }) (*searchAggregationResolver, error) {
+	if args.By == "AUTHOR" {
+		resultTypes, _ := r.query.StringValues(query.FieldType)
+		if !containsString(resultTypes, "commit") && !containsString(resultTypes, "diff") {
+			return nil, errors.New("aggregating by AUTHOR requires a type:commit or type:diff query")
+		}
+	}
+
+	// Use a new resolver so we don't clobber the cached repository
+	// resolution of r, which uses the normal result limit.
+	ar := &searchResolver{
+		root:               r.root,
+		query:              r.query,
+		replacement:        r.replacement,
+		maxResultsOverride: maxAggregationResults,
+		countOnly:          true,
+	}
+	results, err := ar.doResults(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return aggregateResults(results, args.By)
+}
+
+// aggregateResults counts the results by the given grouping (one of the
+// SearchAggregationBy enum values). Results which don't belong to any group
+// (e.g. repository results when grouping by language) are not counted.
+func aggregateResults(results *searchResultsResolver, by string) (*searchAggregationResolver, error) {
+	var keyFunc func(*searchResultResolver) (string, bool)
+	switch by {
+	case "REPO":
+		keyFunc = func(result *searchResultResolver) (string, bool) {
+			switch {
+			case result.fileMatch != nil:
+				return string(result.fileMatch.repo.URI), true
+			case result.diff != nil:
+				return result.diff.commit.repo.URI(), true
+			case result.repo != nil:
+				return result.repo.URI(), true
+			}
+			return "", false
+		}
+	case "LANG":
+		keyFunc = func(result *searchResultResolver) (string, bool) {
+			if result.fileMatch == nil {
+				return "", false
+			}
+			if langs := langsByFilename(path.Base(result.fileMatch.JPath)); len(langs) > 0 {
+				return langs[0].Name, true
+			}
+			return "Unknown", true
+		}
+	case "PATH_PREFIX":
+		keyFunc = func(result *searchResultResolver) (string, bool) {
+			if result.fileMatch == nil {
+				return "", false
+			}
+			return pathPrefix(result.fileMatch.JPath), true
+		}
+	case "AUTHOR":
+		keyFunc = func(result *searchResultResolver) (string, bool) {
+			if result.diff == nil {
+				return "", false
+			}
+			person := result.diff.commit.author.person
+			return fmt.Sprintf("%s <%s>", person.name, person.email), true
+		}
+	default:
+		return nil, fmt.Errorf("unknown aggregation %q", by)
+	}
+
+	groups := map[string]*searchAggregationGroupResolver{}
+	for _, result := range results.results {
+		key, ok := keyFunc(result)
+		if !ok {
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &searchAggregationGroupResolver{label: key}
+			groups[key] = g
+		}
+		g.count += result.resultCount()
+		if fm := result.fileMatch; fm != nil {
+			if fm.JLimitHit {
+				// Some of the file's matches were not counted.
+				g.limitHit = true
+			}
+			if _, ok := results.partial[fm.repo.URI]; ok {
+				// Some of the repository's matches were not counted,
+				// e.g. because a search limit was hit in it.
+				g.limitHit = true
+			}
+		}
+	}
+
+	agg := &searchAggregationResolver{
+		groups:   make([]*searchAggregationGroupResolver, 0, len(groups)),
+		limitHit: results.LimitHit() || len(results.cloning) > 0 || len(results.timedout) > 0 || len(results.missing) > 0,
+	}
+	for _, g := range groups {
+		agg.groups = append(agg.groups, g)
+	}
+	sort.Slice(agg.groups, func(i, j int) bool {
+		if agg.groups[i].count != agg.groups[j].count {
+			return agg.groups[i].count > agg.groups[j].count
+		}
+		return agg.groups[i].label < agg.groups[j].label
+	})
+	return agg, nil
+}
+
+// pathPrefix returns the top-level directory of p (with a trailing slash), or
+// "/" if p is at the root.
+func pathPrefix(p string) string {
+	if i := strings.Index(p, "/"); i >= 0 {
+		return p[:i+1]
+	}
+	return "/"
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+type searchAggregationResolver struct {
+	groups   []*searchAggregationGroupResolver
+	limitHit bool
+}
+
+func (r *searchAggregationResolver) Groups() []*searchAggregationGroupResolver { return r.groups }
+func (r *searchAggregationResolver) LimitHit() bool                            { return r.limitHit }
+
+type searchAggregationGroupResolver struct {
+	label    string
+	count    int32
+	limitHit bool
+}
+
+func (r *searchAggregationGroupResolver) Label() string  { return r.label }
+func (r *searchAggregationGroupResolver) Count() int32   { return r.count }
+func (r *searchAggregationGroupResolver) LimitHit() bool { return r.limitHit }
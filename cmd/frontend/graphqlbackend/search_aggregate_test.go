@@ -0,0 +1,91 @@
+package graphqlbackend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestAggregateResults(t *testing.T) {
+	repoA := &types.Repo{URI: "a"}
+	repoB := &types.Repo{URI: "b"}
+	repoC := &types.Repo{URI: "c"}
+	lineMatches := func(n int) []*lineMatch {
+		var lms []*lineMatch
+		for i := 0; i < n; i++ {
+			lms = append(lms, &lineMatch{JLineNumber: int32(i)})
+		}
+		return lms
+	}
+	commit := func(repo *types.Repo, author string) *searchResultResolver {
+		return &searchResultResolver{diff: &commitSearchResultResolver{
+			commit: toGitCommitResolver(&repositoryResolver{repo: repo}, &git.Commit{
+				Author: git.Signature{Name: author, Email: author + "@example.com"},
+			}),
+		}}
+	}
+	results := &searchResultsResolver{
+		results: []*searchResultResolver{
+			{fileMatch: &fileMatchResolver{repo: repoA, JPath: "cmd/main.go", JLineMatches: lineMatches(3)}},
+			{fileMatch: &fileMatchResolver{repo: repoA, JPath: "README.md", JLineMatches: lineMatches(1)}},
+			{fileMatch: &fileMatchResolver{repo: repoB, JPath: "cmd/x/x.go", JLineMatches: lineMatches(2), JLimitHit: true}},
+			{repo: &repositoryResolver{repo: repoB}},
+			commit(repoA, "alice"),
+			commit(repoB, "bob"),
+			commit(repoB, "alice"),
+			// Counted by the backend, in a repository with uncounted matches.
+			{fileMatch: &fileMatchResolver{repo: repoC, JPath: "lib/c.go", JMatchCount: 7}},
+		},
+		searchResultsCommon: searchResultsCommon{
+			partial: map[api.RepoURI]struct{}{"c": {}},
+		},
+	}
+
+	type group struct {
+		label    string
+		count    int32
+		limitHit bool
+	}
+	tests := map[string][]group{
+		"REPO": {
+			{label: "c", count: 7, limitHit: true},
+			{label: "a", count: 5},
+			{label: "b", count: 5, limitHit: true},
+		},
+		"LANG": {
+			{label: "Go", count: 12, limitHit: true},
+			{label: "Markdown", count: 1},
+		},
+		"PATH_PREFIX": {
+			{label: "lib/", count: 7, limitHit: true},
+			{label: "cmd/", count: 5, limitHit: true},
+			{label: "/", count: 1},
+		},
+		"AUTHOR": {
+			{label: "alice <alice@example.com>", count: 2},
+			{label: "bob <bob@example.com>", count: 1},
+		},
+	}
+	for by, want := range tests {
+		t.Run(by, func(t *testing.T) {
+			agg, err := aggregateResults(results, by)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []group
+			for _, g := range agg.Groups() {
+				got = append(got, group{label: g.Label(), count: g.Count(), limitHit: g.LimitHit()})
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := aggregateResults(results, "BOGUS"); err == nil {
+		t.Error("expected error for unknown aggregation")
+	}
+}
@@ -45,6 +45,17 @@ func (r *searchResolver) alertForNoResolvedRepos(ctx context.Context) (*searchAl
 	fork, _ := r.query.StringValue(query.FieldFork)
 	onlyForks, noForks := fork == "only", fork == "no"
 
+	hasFile, minusHasFile := r.query.RegexpPatterns(query.FieldRepoHasFile)
+	commitAfter, _ := r.query.StringValue(query.FieldRepoHasCommitAfter)
+	if len(hasFile) > 0 || len(minusHasFile) > 0 || commitAfter != "" {
+		// We don't know which of the repos was removed by the
+		// predicates, so don't bother proposing queries.
+		return &searchAlert{
+			title:       "No repositories satisfied your repohasfile: or repohascommitafter: filters",
+			description: "Remove or relax the repohasfile: and repohascommitafter: filters to see results.",
+		}, nil
+	}
+
 	// Handle repogroup-only scenarios.
 	if len(repoFilters) == 0 && len(repoGroupFilters) == 0 {
 		return &searchAlert{
@@ -0,0 +1,143 @@
+package graphqlbackend
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/goroutine"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
+)
+
+// branchResolver returns a resolver for the branch q of r's boolean query.
+// It does not inherit countOnly, since the union of the branches' results
+// needs their line matches to avoid counting a line twice.
+func (r *searchResolver) branchResolver(q *query.Query) *searchResolver {
+	return &searchResolver{
+		root:               r.root,
+		query:              q,
+		replacement:        r.replacement,
+		maxResultsOverride: r.maxResultsOverride,
+	}
+}
+
+// doBranchResults runs each branch of a boolean query (see
+// query.Query.Branches) as a separate search and returns the union of their
+// results.
+func (r *searchResolver) doBranchResults(ctx context.Context, branches []*query.Query, forceOnlyResultType string) (res *searchResultsResolver, err error) {
+	tr, ctx := trace.New(ctx, "graphql.SearchResults.branches", r.rawQuery())
+	defer func() {
+		tr.SetError(err)
+		tr.Finish()
+	}()
+	tr.LazyPrintf("%d branches", len(branches))
+
+	start := time.Now()
+
+	var (
+		wg            sync.WaitGroup
+		branchResults = make([]*searchResultsResolver, len(branches))
+		branchErrs    = make([]error, len(branches))
+	)
+	for i, branch := range branches {
+		i, branch := i, branch
+		wg.Add(1)
+		goroutine.Go(func() {
+			defer wg.Done()
+			branchResults[i], branchErrs[i] = r.branchResolver(branch).doResults(ctx, forceOnlyResultType)
+		})
+	}
+	wg.Wait()
+
+	var (
+		merged      = searchResultsResolver{start: start}
+		multiErr    *multierror.Error
+		fileMatches = map[string]*fileMatchResolver{}
+		repos       = map[api.RepoID]struct{}{}
+	)
+	merged.maxResultsCount = r.maxResults()
+	for i, branchResult := range branchResults {
+		if branchErrs[i] != nil {
+			multiErr = multierror.Append(multiErr, branchErrs[i])
+		}
+		if branchResult == nil {
+			continue
+		}
+		if merged.alert == nil {
+			merged.alert = branchResult.alert
+		}
+		merged.update(branchResult.searchResultsCommon)
+		for _, result := range branchResult.results {
+			switch {
+			case result.fileMatch != nil:
+				if fm, ok := fileMatches[result.fileMatch.uri]; ok {
+					mergeFileMatch(fm, result.fileMatch)
+					continue
+				}
+				fileMatches[result.fileMatch.uri] = result.fileMatch
+			case result.repo != nil:
+				if _, ok := repos[result.repo.repo.ID]; ok {
+					continue
+				}
+				repos[result.repo.repo.ID] = struct{}{}
+			}
+			merged.results = append(merged.results, result)
+		}
+	}
+	tr.LazyPrintf("results=%d limitHit=%v", len(merged.results), merged.limitHit)
+
+	// As in doResults, return partial results instead of an error.
+	if len(merged.results) > 0 && multiErr != nil {
+		log15.Error("Errors during search", "error", multiErr)
+		multiErr = nil
+	}
+
+	sortResults(merged.results)
+	return &merged, multiErr.ErrorOrNil()
+}
+
+// mergeFileMatch merges src into dst, which are matches in the same file
+// from different branches of a boolean query.
+func mergeFileMatch(dst, src *fileMatchResolver) {
+	dst.JLimitHit = dst.JLimitHit || src.JLimitHit
+	if len(dst.symbols) == 0 {
+		dst.symbols = src.symbols
+	}
+
+	byLine := make(map[int32]*lineMatch, len(dst.JLineMatches))
+	for _, lm := range dst.JLineMatches {
+		byLine[lm.JLineNumber] = lm
+	}
+	for _, lm := range src.JLineMatches {
+		existing, ok := byLine[lm.JLineNumber]
+		if !ok {
+			byLine[lm.JLineNumber] = lm
+			dst.JLineMatches = append(dst.JLineMatches, lm)
+			continue
+		}
+		existing.JLimitHit = existing.JLimitHit || lm.JLimitHit
+		offsets := append(existing.JOffsetAndLengths, lm.JOffsetAndLengths...)
+		sort.Slice(offsets, func(i, j int) bool {
+			if offsets[i][0] != offsets[j][0] {
+				return offsets[i][0] < offsets[j][0]
+			}
+			return offsets[i][1] < offsets[j][1]
+		})
+		existing.JOffsetAndLengths = offsets[:0]
+		for _, o := range offsets {
+			// Both branches may match the same text.
+			if n := len(existing.JOffsetAndLengths); n == 0 || o != existing.JOffsetAndLengths[n-1] {
+				existing.JOffsetAndLengths = append(existing.JOffsetAndLengths, o)
+			}
+		}
+	}
+	sort.Slice(dst.JLineMatches, func(i, j int) bool {
+		return dst.JLineMatches[i].JLineNumber < dst.JLineMatches[j].JLineNumber
+	})
+}
@@ -0,0 +1,208 @@
+package graphqlbackend
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
+)
+
+// Plan describes how the search would be run, without running it. It is
+// meant for debugging searches that are slow or return no results.
+func (r *searchResolver) Plan(ctx context.Context) (res *searchPlanResolver, err error) {
+	tr, ctx := trace.New(ctx, "graphql.Search.plan", r.rawQuery())
+	defer func() {
+		tr.SetError(err)
+		tr.Finish()
+	}()
+
+	ctx, cancel, err := r.withTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	return r.plan(ctx)
+}
+
+func (r *searchResolver) plan(ctx context.Context) (*searchPlanResolver, error) {
+	p := &searchPlanResolver{query: r.query}
+
+	if branches := r.query.Branches(); branches != nil {
+		for _, branch := range branches {
+			branchPlan, err := r.branchResolver(branch).plan(ctx)
+			if err != nil {
+				return nil, err
+			}
+			p.branches = append(p.branches, branchPlan)
+		}
+		return p, nil
+	}
+
+	// Errors from here on are part of the plan, since they are what the
+	// user is trying to debug.
+	repos, missingRepoRevs, _, overLimit, err := r.resolveRepositories(ctx, nil)
+	if err != nil {
+		p.err = err
+		return p, nil
+	}
+	p.repositoryCount = len(repos)
+	p.missingRepositoryRevisionCount = len(missingRepoRevs)
+	p.repositoryLimitHit = overLimit
+
+	pattern, err := r.getPatternInfo()
+	if err != nil {
+		p.err = err
+		return p, nil
+	}
+	args := search.Args{
+		Pattern:         pattern,
+		Repos:           repos,
+		Query:           r.query,
+		UseFullDeadline: r.searchTimeoutFieldSet(),
+	}
+	p.patternInfo = pattern
+	if err := args.Pattern.Validate(); err != nil {
+		p.err = &badRequestError{err}
+		return p, nil
+	}
+	p.resultTypes, err = r.determineResultTypes(args, "")
+	if err != nil {
+		p.err = err
+		return p, nil
+	}
+
+	indexed, unindexed, err := zoektIndexedRepos(ctx, repos)
+	if err != nil {
+		// Like searchFilesInRepos, this is not fatal.
+		p.indexUnavailable = true
+	}
+	zoektRepos, searcherRepos, missing, err := splitIndexedRepos(&args, indexed, unindexed)
+	if err != nil {
+		p.err = err
+		return p, nil
+	}
+	p.indexedRepositoryCount = len(zoektRepos)
+	p.unindexedRepositoryCount = len(searcherRepos)
+	p.missingRepositoryRevisionCount += len(missing)
+
+	if len(zoektRepos) > 0 {
+		q, err := queryToZoektQuery(pattern)
+		if err != nil {
+			p.err = err
+			return p, nil
+		}
+		s := q.String()
+		p.zoektQuery = &s
+	}
+	return p, nil
+}
+
+type searchPlanResolver struct {
+	query    *query.Query
+	branches []*searchPlanResolver
+
+	repositoryCount                int
+	indexedRepositoryCount         int
+	unindexedRepositoryCount       int
+	missingRepositoryRevisionCount int
+	repositoryLimitHit             bool
+	indexUnavailable               bool
+
+	resultTypes []string
+	patternInfo *search.PatternInfo
+	zoektQuery  *string
+	err         error
+}
+
+func (p *searchPlanResolver) ParseTree() string {
+	if p.query.Syntax.Tree != nil {
+		return p.query.Syntax.Tree.String()
+	}
+	return syntax.ExprString(p.query.Syntax.Expr)
+}
+
+func (p *searchPlanResolver) Fields() []*searchPlanFieldResolver {
+	fields := []*searchPlanFieldResolver{}
+	for field, values := range p.query.Fields {
+		for _, v := range values {
+			f := &searchPlanFieldResolver{field: field, negated: v.Not()}
+			switch {
+			case v.String != nil:
+				f.value, f.typ = *v.String, "STRING"
+			case v.Regexp != nil:
+				f.value, f.typ = v.Regexp.String(), "REGEXP"
+			case v.Bool != nil:
+				f.value, f.typ = "no", "BOOL"
+				if *v.Bool {
+					f.value = "yes"
+				}
+			}
+			fields = append(fields, f)
+		}
+	}
+	// The values of each field are already in query order.
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].field < fields[j].field })
+	return fields
+}
+
+func (p *searchPlanResolver) Branches() *[]*searchPlanResolver {
+	if p.branches == nil {
+		return nil
+	}
+	return &p.branches
+}
+
+func (p *searchPlanResolver) ResultTypes() []string {
+	if p.resultTypes == nil {
+		return []string{}
+	}
+	return p.resultTypes
+}
+
+func (p *searchPlanResolver) RepositoryCount() int32 { return int32(p.repositoryCount) }
+
+func (p *searchPlanResolver) IndexedRepositoryCount() int32 { return int32(p.indexedRepositoryCount) }
+
+func (p *searchPlanResolver) UnindexedRepositoryCount() int32 {
+	return int32(p.unindexedRepositoryCount)
+}
+
+func (p *searchPlanResolver) MissingRepositoryRevisionCount() int32 {
+	return int32(p.missingRepositoryRevisionCount)
+}
+
+func (p *searchPlanResolver) RepositoryLimitHit() bool { return p.repositoryLimitHit }
+
+func (p *searchPlanResolver) IndexUnavailable() bool { return p.indexUnavailable }
+
+func (p *searchPlanResolver) PatternInfo() *jsonValue {
+	if p.patternInfo == nil {
+		return nil
+	}
+	return &jsonValue{value: p.patternInfo}
+}
+
+func (p *searchPlanResolver) ZoektQuery() *string { return p.zoektQuery }
+
+func (p *searchPlanResolver) Error() *string {
+	if p.err == nil {
+		return nil
+	}
+	s := p.err.Error()
+	return &s
+}
+
+type searchPlanFieldResolver struct {
+	field   string
+	value   string
+	typ     string
+	negated bool
+}
+
+func (f *searchPlanFieldResolver) Field() string { return f.field }
+func (f *searchPlanFieldResolver) Value() string { return f.value }
+func (f *searchPlanFieldResolver) Type() string  { return f.typ }
+func (f *searchPlanFieldResolver) Negated() bool { return f.negated }
@@ -0,0 +1,45 @@
+package graphqlbackend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+)
+
+func TestSearchPlan_Fields(t *testing.T) {
+	q, err := query.ParseAndCheck(`foo -file:\.go$ repo:b repo:a case:yes`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []searchPlanFieldResolver
+	for _, f := range (&searchPlanResolver{query: q}).Fields() {
+		got = append(got, *f)
+	}
+	want := []searchPlanFieldResolver{
+		{field: "", value: "foo", typ: "REGEXP"},
+		{field: "case", value: "yes", typ: "BOOL"},
+		{field: "file", value: `\.go$`, typ: "REGEXP", negated: true},
+		{field: "repo", value: "b", typ: "REGEXP"},
+		{field: "repo", value: "a", typ: "REGEXP"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchPlan_ParseTree(t *testing.T) {
+	tests := map[string]string{
+		"foo  repo:bar": "foo repo:bar",
+		"(a OR b) c":    "(a OR b) AND c",
+	}
+	for input, want := range tests {
+		q, err := query.ParseAndCheck(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := (&searchPlanResolver{query: q}).ParseTree(); got != want {
+			t.Errorf("%q: got %q, want %q", input, got, want)
+		}
+	}
+}
@@ -143,15 +143,11 @@ func searchReferencesInRepos(ctx context.Context, args *search.Args) (res []*sea
 		if len(repoRev.Revs) == 0 {
 			return nil, common, nil // no revs to search
 		}
-		if len(repoRev.Revs) >= 2 {
-			return nil, common, errMultipleRevsNotSupported
-		}
 
 		wg.Add(1)
 		go func(repoRev search.RepositoryRevisions) {
 			defer wg.Done()
-			rev := repoRev.RevSpecs()[0] // TODO(sqs): search multiple revs
-			matches, repoLimitHit, searchErr := searchReferencesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo, rev, language, symbol, hints, args.Pattern)
+			matches, repoLimitHit, searchErr := searchReferencesInRepoRevs(ctx, &repoRev, language, symbol, hints, args.Pattern)
 			if searchErr != nil {
 				tr.LogFields(otlog.String("repo", string(repoRev.Repo.URI)), otlog.String("searchErr", searchErr.Error()), otlog.Bool("timeout", errcode.IsTimeout(searchErr)), otlog.Bool("temporary", errcode.IsTemporary(searchErr)))
 			}
@@ -186,6 +182,41 @@ func searchReferencesInRepos(ctx context.Context, args *search.Args) (res []*sea
 	return fileMatchesToSearchResults(flattened), common, nil
 }
 
+// searchReferencesInRepoRevs is like searchReferencesInRepo, except that it
+// searches all revisions of repoRev, including the refs matching its ref
+// globs. A file with the same contents in several revisions is only included
+// once, with the refs of all of those revisions as its sourceRefs.
+func searchReferencesInRepoRevs(ctx context.Context, repoRev *search.RepositoryRevisions, language string, symbol lspext.SymbolDescriptor, hints map[string]interface{}, query *search.PatternInfo) (matches []*fileMatchResolver, limitHit bool, err error) {
+	if len(repoRev.Revs) == 1 && !repoRev.HasRefGlobs() {
+		return searchReferencesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo, repoRev.Revs[0].RevSpec, language, symbol, hints, query)
+	}
+
+	revs, err := expandRevisions(ctx, repoRev)
+	if err != nil {
+		return nil, false, err
+	}
+	var mu sync.Mutex
+	matchesByRev := make([][]*fileMatchResolver, len(revs))
+	err = forEachRevision(ctx, revs, func(ctx context.Context, i int, rev searchRevision) error {
+		revMatches, revLimitHit, err := searchReferencesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo, rev.rev, language, symbol, hints, query)
+		if err != nil {
+			return err
+		}
+		for _, fm := range revMatches {
+			fm.sourceRefs = rev.refs
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		matchesByRev[i] = revMatches
+		limitHit = limitHit || revLimitHit
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return mergeRevisionFileMatches(matchesByRev, revisionBlobIDs(ctx, repoRev, matchesByRev)), limitHit, nil
+}
+
 var mockSearchReferencesInRepo func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev, language string, symbol lspext.SymbolDescriptor, hints map[string]interface{}, query *search.PatternInfo) (matches []*fileMatchResolver, limitHit bool, err error)
 
 func searchReferencesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev, language string, symbol lspext.SymbolDescriptor, hints map[string]interface{}, query *search.PatternInfo) (matches []*fileMatchResolver, limitHit bool, err error) {
@@ -0,0 +1,111 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+// replaceRefPrefix is the namespace of the refs created by
+// createCommitFromPatch, so they never clash with the repository's own
+// branches and tags.
+const replaceRefPrefix = "sourcegraph/replace/"
+
+// validReplaceRefName matches the names accepted by createCommitFromPatch. It
+// is stricter than git check-ref-format.
+var validReplaceRefName = regexp.MustCompile(`^[\w-]+(\.[\w-]+)*(/[\w-]+(\.[\w-]+)*)*$`)
+
+// CreateCommitFromPatch applies a patch (usually the concatenated
+// replacementDiff fields of a search with a replacement) to a commit, and
+// creates a new ref pointing at the resulting commit.
+func (*schemaResolver) CreateCommitFromPatch(ctx context.Context, args *struct {
+	Repository graphql.ID
+	BaseCommit string
+	Patch      string
+	Name       string
+	Message    string
+}) (*gitCommitResolver, error) {
+	user, err := CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, backend.ErrNotAuthenticated
+	}
+
+	repo, err := repositoryByID(ctx, args.Repository)
+	if err != nil {
+		return nil, err
+	}
+	if len(args.BaseCommit) != 40 {
+		return nil, fmt.Errorf("invalid baseCommit %q (must be a full commit ID)", args.BaseCommit)
+	}
+	if !validReplaceRefName.MatchString(args.Name) {
+		return nil, fmt.Errorf("invalid name %q (must be a valid git ref name)", args.Name)
+	}
+	if strings.TrimSpace(args.Patch) == "" {
+		return nil, errors.New("patch must not be empty")
+	}
+	if strings.TrimSpace(args.Message) == "" {
+		return nil, errors.New("message must not be empty")
+	}
+
+	targetRef := replaceRefPrefix + args.Name
+	getCommit := func() (*gitCommitResolver, error) {
+		// We check via the vcsrepo api so that we can toggle NoEnsureRevision,
+		// since the ref does not exist on the remote host. See
+		// ResolvePhabricatorDiff.
+		_, err := git.ResolveRevision(ctx, backend.CachedGitRepo(repo.repo), nil, targetRef, &git.ResolveRevisionOptions{
+			NoEnsureRevision: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return repo.Commit(ctx, &repositoryCommitArgs{Rev: targetRef})
+	}
+
+	// Refuse to overwrite an existing ref, since it may be in use.
+	if _, err := getCommit(); err == nil {
+		return nil, fmt.Errorf("ref %q already exists", targetRef)
+	} else if !git.IsRevisionNotFound(err) {
+		return nil, err
+	}
+
+	authorName := user.user.Username
+	if user.user.DisplayName != "" {
+		authorName = user.user.DisplayName
+	}
+	authorEmail, _, err := db.UserEmails.GetPrimaryEmail(ctx, user.user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting author email")
+	}
+
+	_, err = gitserver.DefaultClient.CreateCommitFromPatch(ctx, protocol.CreateCommitFromPatchRequest{
+		Repo:       repo.repo.URI,
+		BaseCommit: api.CommitID(args.BaseCommit),
+		TargetRef:  targetRef,
+		Patch:      args.Patch,
+		CommitInfo: protocol.PatchCommitInfo{
+			AuthorName:  authorName,
+			AuthorEmail: authorEmail,
+			Message:     args.Message,
+			Date:        time.Now(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return getCommit()
+}
@@ -0,0 +1,276 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"sync"
+
+	zoekt "github.com/google/zoekt"
+	zoektquery "github.com/google/zoekt/query"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/trace"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// maxConcurrentRepoPredicates is the maximum number of concurrent gitserver
+// requests made when evaluating repohasfile: and repohascommitafter:.
+const maxConcurrentRepoPredicates = 16
+
+// filterRepoHasFile returns the repositories which contain a file matching
+// every include pattern, and no file matching any exclude pattern (from
+// repohasfile: and -repohasfile:). Patterns are case-insensitive.
+//
+// Repositories indexed by zoekt are checked with zoekt, and the others by
+// listing their tree on gitserver. Repositories which can't be checked (e.g.
+// because they are still cloning) are kept, so that the search reports them.
+func filterRepoHasFile(ctx context.Context, repos []*search.RepositoryRevisions, include, exclude []string) (filtered []*search.RepositoryRevisions, err error) {
+	tr, ctx := trace.New(ctx, "filterRepoHasFile", fmt.Sprintf("include: %v, exclude: %v, numRepos: %d", include, exclude, len(repos)))
+	defer func() {
+		tr.SetError(err)
+		tr.Finish()
+	}()
+
+	patterns := append(append([]string{}, include...), exclude...)
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		res[i], err = regexp.Compile("(?i:" + p + ")")
+		if err != nil {
+			return nil, &badRequestError{err}
+		}
+	}
+
+	// hasFile[i] is the set of repositories which contain a file matching
+	// patterns[i].
+	var mu sync.Mutex
+	hasFile := make([]map[api.RepoURI]bool, len(patterns))
+	for i := range hasFile {
+		hasFile[i] = map[api.RepoURI]bool{}
+	}
+
+	indexed, unindexed, err := zoektIndexedRepos(ctx, repos)
+	if err != nil {
+		log15.Warn("repohasfile: failed to list indexed repositories, falling back to gitserver", "error", err)
+		indexed, unindexed = nil, repos
+	}
+	for i, p := range patterns {
+		if len(indexed) == 0 {
+			break
+		}
+		found, complete, err := zoektReposWithFile(ctx, indexed, p)
+		if err != nil {
+			return nil, err
+		}
+		if !complete {
+			// We can't tell which repositories zoekt skipped, so
+			// check all of them on gitserver instead.
+			tr.LazyPrintf("zoekt results incomplete for %q", p)
+			unindexed = append(unindexed, indexed...)
+			indexed = nil
+			break
+		}
+		for repo := range found {
+			hasFile[i][repo] = true
+		}
+	}
+	tr.LazyPrintf("%d indexed, %d unindexed repos", len(indexed), len(unindexed))
+
+	unknown := map[api.RepoURI]bool{}
+	err = forEachRepoConcurrently(ctx, unindexed, func(ctx context.Context, repoRev *search.RepositoryRevisions) error {
+		matched, err := repoHasFiles(ctx, repoRev, res)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if vcs.IsRepoNotExist(err) || git.IsRevisionNotFound(err) {
+				unknown[repoRev.Repo.URI] = true
+				return nil
+			}
+			return err
+		}
+		for i := range matched {
+			if matched[i] {
+				hasFile[i][repoRev.Repo.URI] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filtered = repos[:0:0]
+	for _, repoRev := range repos {
+		keep := true
+		for i := range patterns {
+			if hasFile[i][repoRev.Repo.URI] != (i < len(include)) {
+				keep = false
+				break
+			}
+		}
+		if keep || unknown[repoRev.Repo.URI] {
+			filtered = append(filtered, repoRev)
+		}
+	}
+	return filtered, nil
+}
+
+// zoektReposWithFile returns the set of repositories (which must be indexed,
+// see zoektIndexedRepos) containing a file matching pattern. complete is false if zoekt
+// skipped some shards, in which case found may be missing repositories.
+func zoektReposWithFile(ctx context.Context, repos []*search.RepositoryRevisions, pattern string) (found map[api.RepoURI]bool, complete bool, err error) {
+	re, err := syntax.Parse(pattern, syntax.ClassNL|syntax.PerlX|syntax.UnicodeGroups)
+	if err != nil {
+		return nil, false, &badRequestError{err}
+	}
+	q := zoektquery.NewAnd(zoektReposQuery(repos), &zoektquery.Regexp{Regexp: re, FileName: true})
+
+	// We only need to know whether each repository has a match, so stop
+	// searching a shard as soon as it has one.
+	resp, err := zoektCl.Search(ctx, q, &zoekt.SearchOptions{
+		ShardMaxMatchCount: 1,
+		TotalMaxMatchCount: math.MaxInt32,
+		MaxDocDisplayCount: math.MaxInt32,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	found = map[api.RepoURI]bool{}
+	for _, file := range resp.Files {
+		found[api.RepoURI(file.Repository)] = true
+	}
+	return found, resp.ShardsSkipped == 0, nil
+}
+
+// repoHasFiles reports for each of res whether any revision of repoRev
+// contains a file whose path matches it.
+func repoHasFiles(ctx context.Context, repoRev *search.RepositoryRevisions, res []*regexp.Regexp) ([]bool, error) {
+	matched := make([]bool, len(res))
+	for _, rev := range repoRevSpecs(repoRev) {
+		commit, err := git.ResolveRevision(ctx, repoRev.GitserverRepo, nil, rev, &git.ResolveRevisionOptions{NoEnsureRevision: true})
+		if err != nil {
+			return nil, err
+		}
+		entries, err := git.ReadDir(ctx, repoRev.GitserverRepo, commit, "", true)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			for i, re := range res {
+				if !matched[i] && re.MatchString(entry.Name()) {
+					matched[i] = true
+				}
+			}
+		}
+	}
+	return matched, nil
+}
+
+// filterRepoHasCommitAfter returns the repositories with a commit after the
+// given date (from repohascommitafter:), which is anything accepted by git
+// log --after, such as "2018-01-01" or "1 month ago".
+//
+// zoekt does not record commit dates, so this always asks gitserver.
+// Repositories which can't be checked (e.g. because they are still cloning)
+// are kept, so that the search reports them.
+func filterRepoHasCommitAfter(ctx context.Context, repos []*search.RepositoryRevisions, after string) (filtered []*search.RepositoryRevisions, err error) {
+	tr, ctx := trace.New(ctx, "filterRepoHasCommitAfter", fmt.Sprintf("after: %q, numRepos: %d", after, len(repos)))
+	defer func() {
+		tr.SetError(err)
+		tr.Finish()
+	}()
+
+	var mu sync.Mutex
+	keep := map[api.RepoURI]bool{}
+	err = forEachRepoConcurrently(ctx, repos, func(ctx context.Context, repoRev *search.RepositoryRevisions) error {
+		for _, rev := range repoRevSpecs(repoRev) {
+			if rev == "" {
+				rev = "HEAD"
+			}
+			commits, err := git.Commits(ctx, repoRev.GitserverRepo, git.CommitsOptions{Range: rev, After: after, N: 1})
+			if err != nil && !vcs.IsRepoNotExist(err) && !git.IsRevisionNotFound(err) {
+				return err
+			}
+			if err != nil || len(commits) > 0 {
+				mu.Lock()
+				keep[repoRev.Repo.URI] = true
+				mu.Unlock()
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filtered = repos[:0:0]
+	for _, repoRev := range repos {
+		if keep[repoRev.Repo.URI] {
+			filtered = append(filtered, repoRev)
+		}
+	}
+	return filtered, nil
+}
+
+// repoRevSpecs returns the revisions of repoRev to evaluate repository
+// predicates against. Ref globs are not expanded, so if repoRev only has ref
+// globs the default branch is used.
+func repoRevSpecs(repoRev *search.RepositoryRevisions) []string {
+	var revs []string
+	for _, rev := range repoRev.Revs {
+		if rev.RefGlob == "" && rev.ExcludeRefGlob == "" {
+			revs = append(revs, rev.RevSpec)
+		}
+	}
+	if len(revs) == 0 {
+		revs = []string{""}
+	}
+	return revs
+}
+
+// forEachRepoConcurrently calls f for each repository, with at most
+// maxConcurrentRepoPredicates calls running at once. It returns the first
+// error returned by f.
+func forEachRepoConcurrently(ctx context.Context, repos []*search.RepositoryRevisions, f func(context.Context, *search.RepositoryRevisions) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+		sem      = make(semaphore, maxConcurrentRepoPredicates)
+	)
+	setErr := func(err error) {
+		errMu.Lock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+		errMu.Unlock()
+	}
+	for _, repoRev := range repos {
+		if err := sem.Acquire(ctx); err != nil {
+			setErr(err)
+			break
+		}
+		wg.Add(1)
+		go func(repoRev *search.RepositoryRevisions) {
+			defer wg.Done()
+			defer sem.Release()
+			if err := f(ctx, repoRev); err != nil {
+				setErr(err)
+			}
+		}(repoRev)
+	}
+	wg.Wait()
+	return firstErr
+}
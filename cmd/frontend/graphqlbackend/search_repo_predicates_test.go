@@ -0,0 +1,79 @@
+package graphqlbackend
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/util"
+)
+
+func TestFilterRepoHasFile(t *testing.T) {
+	// The git mocks don't know which repository they are called for, so
+	// each repository is searched at a different revision.
+	trees := map[string][]string{
+		"node":  {"package.json", "src/index.js"},
+		"go":    {"main.go", "vendor/github.com/x/package.json"},
+		"empty": {},
+	}
+	git.Mocks.ResolveRevision = func(spec string, opt *git.ResolveRevisionOptions) (api.CommitID, error) {
+		if _, ok := trees[spec]; !ok {
+			return "", &git.RevisionNotFoundError{Spec: spec}
+		}
+		return api.CommitID(strings.Repeat("a", 40-len(spec)) + spec), nil
+	}
+	git.Mocks.ReadDir = func(commit api.CommitID, name string, recurse bool) ([]os.FileInfo, error) {
+		var entries []os.FileInfo
+		for _, path := range trees[strings.TrimLeft(string(commit), "a")] {
+			entries = append(entries, &util.FileInfo{Name_: path})
+		}
+		entries = append(entries, &util.FileInfo{Name_: "src", Mode_: os.ModeDir})
+		return entries, nil
+	}
+	defer git.ResetMocks()
+
+	var repos []*search.RepositoryRevisions
+	for _, rev := range []string{"node", "go", "empty", "cloning"} {
+		repos = append(repos, &search.RepositoryRevisions{
+			Repo:          &types.Repo{URI: api.RepoURI(rev)},
+			GitserverRepo: gitserver.Repo{Name: api.RepoURI(rev)},
+			Revs:          []search.RevisionSpecifier{{RevSpec: rev}},
+		})
+	}
+
+	tests := []struct {
+		include, exclude []string
+		want             []string
+	}{
+		{include: []string{`(^|/)package\.json$`}, want: []string{"node", "go", "cloning"}},
+		{include: []string{`^package\.json$`}, want: []string{"node", "cloning"}},
+		{include: []string{`^PACKAGE\.json$`, `\.js$`}, want: []string{"node", "cloning"}},
+		{exclude: []string{`\.go$`}, want: []string{"node", "empty", "cloning"}},
+		{include: []string{`package\.json`}, exclude: []string{`^vendor/`}, want: []string{"node", "cloning"}},
+		{include: []string{`^src$`}, want: []string{"cloning"}},
+	}
+	for _, test := range tests {
+		filtered, err := filterRepoHasFile(context.Background(), repos, test.include, test.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, repoRev := range filtered {
+			got = append(got, string(repoRev.Repo.URI))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("include %q exclude %q: got %q, want %q", test.include, test.exclude, got, test.want)
+		}
+	}
+
+	if _, err := filterRepoHasFile(context.Background(), repos, []string{"("}, nil); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
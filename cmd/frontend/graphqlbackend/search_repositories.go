@@ -28,6 +28,7 @@ func searchRepositories(ctx context.Context, args *search.Args, limit int32) (re
 		query.FieldCount:     struct{}{},
 		query.FieldMax:       struct{}{},
 		query.FieldTimeout:   struct{}{},
+		query.FieldContext:   struct{}{},
 		query.FieldFork:      struct{}{},
 		query.FieldArchived:  struct{}{},
 	}
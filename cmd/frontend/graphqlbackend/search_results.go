@@ -543,7 +543,7 @@ func (r *searchResolver) Stats(ctx context.Context) (stats *searchResultsStats,
 }
 
 func (r *searchResolver) getPatternInfo() (*search.PatternInfo, error) {
-	var patternsToCombine []string
+	var patternsToCombine, andPatterns, notPatterns []string
 	for _, v := range r.query.Values(query.FieldDefault) {
 		// Treat quoted strings as literal strings to match, not regexps.
 		var pattern string
@@ -556,6 +556,20 @@ func (r *searchResolver) getPatternInfo() (*search.PatternInfo, error) {
 		if pattern == "" {
 			continue
 		}
+		if r.query.IsBranch {
+			// In a branch of a boolean query, each value is a whole
+			// pattern. The first is the main pattern, and the others
+			// must also match (or not match) the file.
+			switch {
+			case v.Not():
+				notPatterns = append(notPatterns, pattern)
+			case len(patternsToCombine) == 0:
+				patternsToCombine = append(patternsToCombine, pattern)
+			default:
+				andPatterns = append(andPatterns, pattern)
+			}
+			continue
+		}
 		patternsToCombine = append(patternsToCombine, pattern)
 	}
 
@@ -573,18 +587,52 @@ func (r *searchResolver) getPatternInfo() (*search.PatternInfo, error) {
 	patternInfo := &search.PatternInfo{
 		IsRegExp:                     true,
 		IsCaseSensitive:              r.query.IsCaseSensitive(),
+		IsMultiline:                  r.query.BoolValue(query.FieldMultiline),
 		FileMatchLimit:               r.maxResults(),
 		Pattern:                      regexpPatternMatchingExprsInOrder(patternsToCombine),
 		IncludePatterns:              includePatterns,
 		PathPatternsAreRegExps:       true,
 		PathPatternsAreCaseSensitive: r.query.IsCaseSensitive(),
+		AndPatterns:                  andPatterns,
+		NotPatterns:                  notPatterns,
+		CountOnly:                    r.countOnly,
+	}
+	if len(andPatterns) > 0 || len(notPatterns) > 0 {
+		if patternInfo.IsMultiline {
+			return nil, errors.New("multiline:yes is not supported with patterns combined by \"and\" or \"not\"")
+		}
+		if r.replacement != nil {
+			return nil, errors.New("replacing is not supported with patterns combined by \"and\" or \"not\"")
+		}
 	}
 	if len(excludePatterns) > 0 {
 		patternInfo.ExcludePattern = unionRegExps(excludePatterns)
 	}
+	if context, _ := r.query.StringValue(query.FieldContext); context != "" {
+		n, err := strconv.Atoi(context)
+		if err != nil || n < 0 || n > maxContextLines {
+			return nil, fmt.Errorf("invalid context:%q (must be a number between 0 and %d)", context, maxContextLines)
+		}
+		if n > 0 && patternInfo.IsMultiline {
+			return nil, errors.New("context: is not supported with multiline:yes")
+		}
+		patternInfo.BeforeContextLines = int32(n)
+		patternInfo.AfterContextLines = int32(n)
+	}
+	if r.replacement != nil {
+		if patternInfo.Pattern == "" {
+			return nil, errors.New("a search pattern is required to replace")
+		}
+		patternInfo.IsReplace = true
+		patternInfo.Replacement = *r.replacement
+	}
 	return patternInfo, nil
 }
 
+// maxContextLines is the maximum value of the context: field. It matches the
+// limit enforced by searcher.
+const maxContextLines = 10
+
 var (
 	// The default timeout to use for queries.
 	defaultTimeout = 10 * time.Second
@@ -618,7 +666,50 @@ func (r *searchResolver) withTimeout(ctx context.Context) (context.Context, cont
 	return ctx, cancel, nil
 }
 
+// determineResultTypes returns the types of results to search for, and sets
+// the fields of args.Pattern that depend on them.
+func (r *searchResolver) determineResultTypes(args search.Args, forceOnlyResultType string) ([]string, error) {
+	var resultTypes []string
+	if forceOnlyResultType != "" {
+		resultTypes = []string{forceOnlyResultType}
+	} else {
+		resultTypes, _ = r.query.StringValues(query.FieldType)
+		if len(resultTypes) == 0 {
+			resultTypes = []string{"file", "path", "repo", "ref"}
+		}
+	}
+	if args.Pattern.IsReplace {
+		// Only content matches can be replaced.
+		resultTypes = []string{"file"}
+	}
+	if len(args.Pattern.AndPatterns) > 0 || len(args.Pattern.NotPatterns) > 0 {
+		// Patterns combined with AND or NOT only match file content.
+		var fileResultTypes []string
+		for _, resultType := range resultTypes {
+			if resultType == "file" {
+				fileResultTypes = append(fileResultTypes, resultType)
+			}
+		}
+		if len(fileResultTypes) == 0 {
+			return nil, fmt.Errorf("patterns combined with \"and\" or \"not\" only match file content, not type:%s", strings.Join(resultTypes, ","))
+		}
+		resultTypes = fileResultTypes
+	}
+	for _, resultType := range resultTypes {
+		if resultType == "file" {
+			args.Pattern.PatternMatchesContent = true
+		} else if resultType == "path" {
+			args.Pattern.PatternMatchesPath = true
+		}
+	}
+	return resultTypes, nil
+}
+
 func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType string) (res *searchResultsResolver, err error) {
+	if branches := r.query.Branches(); branches != nil {
+		return r.doBranchResults(ctx, branches, forceOnlyResultType)
+	}
+
 	tr, ctx := trace.New(ctx, "graphql.SearchResults", r.rawQuery())
 	defer func() {
 		tr.SetError(err)
@@ -667,24 +758,11 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 		return nil, &badRequestError{err}
 	}
 
-	// Determine which types of results to return.
-	var resultTypes []string
-	if forceOnlyResultType != "" {
-		resultTypes = []string{forceOnlyResultType}
-	} else {
-		resultTypes, _ = r.query.StringValues(query.FieldType)
-		if len(resultTypes) == 0 {
-			resultTypes = []string{"file", "path", "repo", "ref"}
-		}
+	resultTypes, err := r.determineResultTypes(args, forceOnlyResultType)
+	if err != nil {
+		return nil, err
 	}
 	seenResultTypes := make(map[string]struct{}, len(resultTypes))
-	for _, resultType := range resultTypes {
-		if resultType == "file" {
-			args.Pattern.PatternMatchesContent = true
-		} else if resultType == "path" {
-			args.Pattern.PatternMatchesPath = true
-		}
-	}
 	tr.LazyPrintf("resultTypes: %v", resultTypes)
 
 	var (
@@ -804,6 +882,7 @@ func (r *searchResolver) doResults(ctx context.Context, forceOnlyResultType stri
 						// merge line match results with an existing symbol result
 						m.JLimitHit = m.JLimitHit || r.JLimitHit
 						m.JLineMatches = r.JLineMatches
+						m.JMatchCount = r.JMatchCount
 					} else {
 						fileMatches[key] = r
 						resultsMu.Lock()
@@ -996,6 +1075,9 @@ func (g *searchResultResolver) ToCommitSearchResult() (*commitSearchResultResolv
 func (g *searchResultResolver) resultCount() int32 {
 	switch {
 	case g.fileMatch != nil:
+		if g.fileMatch.JMatchCount > 0 {
+			return g.fileMatch.JMatchCount
+		}
 		if l := len(g.fileMatch.LineMatches()); l > 0 {
 			return int32(l)
 		}
@@ -17,7 +17,7 @@ func TestSearchResults(t *testing.T) {
 	limitOffset := &db.LimitOffset{Limit: maxReposToSearch() + 1}
 
 	createSearchResolver := func(t *testing.T, query string) *searchResolver {
-		r, err := (&schemaResolver{}).Search(&struct{ Query string }{Query: query})
+		r, err := (&schemaResolver{}).Search(&searchArgs{Query: query})
 		if err != nil {
 			t.Fatal("Search:", err)
 		}
@@ -245,6 +245,98 @@ func TestSearchResolver_getPatternInfo(t *testing.T) {
 	}
 }
 
+func TestSearchResolver_getPatternInfo_branches(t *testing.T) {
+	q, err := query.ParseAndCheck(`(foo OR bar) AND "a.b" c AND NOT baz file:\.go$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []search.PatternInfo{{
+		Pattern:                "foo",
+		AndPatterns:            []string{`(a\.b).*?(c)`},
+		NotPatterns:            []string{"baz"},
+		IncludePatterns:        []string{`\.go$`},
+		IsRegExp:               true,
+		PathPatternsAreRegExps: true,
+		FileMatchLimit:         defaultMaxSearchResults,
+	}, {
+		Pattern:                "bar",
+		AndPatterns:            []string{`(a\.b).*?(c)`},
+		NotPatterns:            []string{"baz"},
+		IncludePatterns:        []string{`\.go$`},
+		IsRegExp:               true,
+		PathPatternsAreRegExps: true,
+		FileMatchLimit:         defaultMaxSearchResults,
+	}}
+	branches := q.Branches()
+	if len(branches) != len(want) {
+		t.Fatalf("got %d branches, want %d", len(branches), len(want))
+	}
+	for i, b := range branches {
+		sr := searchResolver{query: b}
+		p, err := sr.getPatternInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*p, want[i]) {
+			t.Errorf("branch %d:\ngot  %+v\nwant %+v", i, *p, want[i])
+		}
+	}
+}
+
+func TestMergeFileMatch(t *testing.T) {
+	dst := &fileMatchResolver{
+		JLineMatches: []*lineMatch{
+			{JLineNumber: 1, JOffsetAndLengths: [][2]int32{{0, 3}}},
+			{JLineNumber: 5, JOffsetAndLengths: [][2]int32{{2, 3}}},
+		},
+	}
+	src := &fileMatchResolver{
+		JLimitHit: true,
+		JLineMatches: []*lineMatch{
+			{JLineNumber: 3, JOffsetAndLengths: [][2]int32{{1, 1}}},
+			{JLineNumber: 5, JOffsetAndLengths: [][2]int32{{0, 1}, {2, 3}}},
+		},
+	}
+	mergeFileMatch(dst, src)
+	want := &fileMatchResolver{
+		JLimitHit: true,
+		JLineMatches: []*lineMatch{
+			{JLineNumber: 1, JOffsetAndLengths: [][2]int32{{0, 3}}},
+			{JLineNumber: 3, JOffsetAndLengths: [][2]int32{{1, 1}}},
+			{JLineNumber: 5, JOffsetAndLengths: [][2]int32{{0, 1}, {2, 3}}},
+		},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v, want %+v", dst, want)
+	}
+}
+
+func TestSearchResolver_getPatternInfo_replacement(t *testing.T) {
+	q, err := query.ParseAndCheck(`f(\w+) file:\.go$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement := "g$1"
+	sr := searchResolver{query: q, replacement: &replacement}
+	p, err := sr.getPatternInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsReplace || p.Replacement != replacement {
+		t.Errorf("got IsReplace=%v Replacement=%q, want IsReplace=true Replacement=%q", p.IsReplace, p.Replacement, replacement)
+	}
+
+	// Replacing requires a pattern to match.
+	q, err = query.ParseAndCheck(`file:\.go$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr = searchResolver{query: q, replacement: &replacement}
+	if _, err := sr.getPatternInfo(); err == nil {
+		t.Error("expected error for replacement without a pattern")
+	}
+}
+
 func TestSearchResolver_DynamicFilters(t *testing.T) {
 	repo := &types.Repo{
 		URI: "testRepo",
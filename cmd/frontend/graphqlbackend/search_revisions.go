@@ -0,0 +1,367 @@
+package graphqlbackend
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/neelance/parallel"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+const (
+	// maxSearchRevisions is the maximum number of distinct revisions of a
+	// single repository that a search (e.g. with a ref glob) may search.
+	maxSearchRevisions = 100
+
+	// maxConcurrentRevisionSearches is the maximum number of revisions of a
+	// single repository that are searched concurrently.
+	maxConcurrentRevisionSearches = 8
+)
+
+// A searchRevision is a revision of a repository to search.
+type searchRevision struct {
+	rev string
+
+	// refs are the refs that matched a ref glob and point to rev (which is
+	// then the first of them). It is empty for a plain revspec.
+	refs []string
+}
+
+// expandRevisions returns the revisions of repoRev to search. Ref globs are
+// expanded to the matching refs, and refs pointing to the same commit are
+// only searched once.
+func expandRevisions(ctx context.Context, repoRev *search.RepositoryRevisions) ([]searchRevision, error) {
+	var revs []searchRevision
+	for _, rev := range repoRev.Revs {
+		if rev.RefGlob == "" && rev.ExcludeRefGlob == "" {
+			revs = append(revs, searchRevision{rev: rev.RevSpec})
+		}
+	}
+	if !repoRev.HasRefGlobs() {
+		return revs, nil
+	}
+
+	m, err := search.NewRefGlobMatcher(repoRev.Revs)
+	if err != nil {
+		return nil, &badRequestError{err}
+	}
+	refs, err := git.ListRefs(ctx, repoRev.GitserverRepo)
+	if err != nil {
+		return nil, err
+	}
+	byCommit := map[api.CommitID]int{} // index into revs
+	for _, ref := range refs {
+		if !m.Match(ref.Name) {
+			continue
+		}
+		if i, ok := byCommit[ref.CommitID]; ok {
+			revs[i].refs = append(revs[i].refs, ref.Name)
+			continue
+		}
+		byCommit[ref.CommitID] = len(revs)
+		revs = append(revs, searchRevision{rev: ref.Name, refs: []string{ref.Name}})
+	}
+	if len(revs) > maxSearchRevisions {
+		return nil, &badRequestError{fmt.Errorf("%s: too many revisions to search (%d, the limit is %d). Use a more specific ref glob.", repoRev, len(revs), maxSearchRevisions)}
+	}
+	return revs, nil
+}
+
+// forEachRevision calls search for each of revs, concurrently for up to
+// maxConcurrentRevisionSearches of them. If a call fails, the context of the
+// others is canceled and its error is returned. If ctx is done before all
+// revisions were searched, its error is returned.
+func forEachRevision(parent context.Context, revs []searchRevision, search func(ctx context.Context, i int, rev searchRevision) error) (err error) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(semaphore, maxConcurrentRevisionSearches)
+	)
+	setErr := func(e error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			err = e
+			cancel()
+		}
+	}
+	for i, rev := range revs {
+		if err := sem.Acquire(ctx); err != nil {
+			setErr(err)
+			break
+		}
+		wg.Add(1)
+		go func(i int, rev searchRevision) {
+			defer wg.Done()
+			defer sem.Release()
+			if err := search(ctx, i, rev); err != nil {
+				setErr(err)
+			}
+		}(i, rev)
+	}
+	wg.Wait()
+	if err == nil {
+		err = parent.Err()
+	}
+	return err
+}
+
+// singleRevision returns repoRev with only the revision rev.
+func singleRevision(repoRev *search.RepositoryRevisions, rev searchRevision) *search.RepositoryRevisions {
+	return &search.RepositoryRevisions{
+		Repo:          repoRev.Repo,
+		Revs:          []search.RevisionSpecifier{{RevSpec: rev.rev}},
+		GitserverRepo: repoRev.GitserverRepo,
+	}
+}
+
+// searchFilesInRepoRevs is like searchFilesInRepo, except that it searches
+// all revisions of repoRev, including the refs matching its ref globs. A file
+// with the same contents in several revisions is only passed to onMatch
+// once, with the refs of all of those revisions as its sourceRefs. When there
+// is more than one revision the matches of each revision are passed on when
+// its search completes, and the sourceRefs of a match that was already
+// passed on may be extended until searchFilesInRepoRevs returns.
+//
+// If only some of the revisions could be searched, the matches found in the
+// others are still passed on and limitHit is true. The error is then only
+// returned if it was a timeout, so that the repository is reported as timed
+// out.
+func searchFilesInRepoRevs(ctx context.Context, repoRev *search.RepositoryRevisions, info *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (limitHit bool, err error) {
+	if len(repoRev.Revs) == 1 && !repoRev.HasRefGlobs() {
+		return searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo, repoRev.Revs[0].RevSpec, info, fetchTimeout, onMatch)
+	}
+
+	revs, err := expandRevisions(ctx, repoRev)
+	if err != nil {
+		return false, err
+	}
+
+	merger := newRevisionMatchMerger(gitBlobID(ctx, repoRev), onMatch)
+	var (
+		mu   sync.Mutex
+		errs []error // of the revisions that could not be searched
+	)
+	ctxErr := forEachRevision(ctx, revs, func(ctx context.Context, i int, rev searchRevision) error {
+		var revMatches []*fileMatchResolver
+		revLimitHit, err := searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo, rev.rev, info, fetchTimeout, func(fm *fileMatchResolver) {
+			fm.sourceRefs = rev.refs
+			revMatches = append(revMatches, fm)
+		})
+		// A revision whose search failed (e.g. because it timed out) may
+		// still have found some matches.
+		merger.add(revMatches)
+		mu.Lock()
+		defer mu.Unlock()
+		limitHit = limitHit || revLimitHit
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if len(errs) == len(revs) && len(revs) > 0 {
+		// No revision could be searched.
+		return limitHit, errs[0]
+	}
+	if ctxErr != nil {
+		// Some revisions were not searched before ctx was done.
+		errs = append(errs, ctxErr)
+	}
+	if len(errs) == 0 {
+		return limitHit, nil
+	}
+	for _, err := range errs {
+		if errcode.IsTimeout(err) || errcode.IsTemporary(err) {
+			return true, err
+		}
+	}
+	log15.Warn("Failed to search some revisions of a repository.", "repo", repoRev.Repo.URI, "errors", errs)
+	return true, nil
+}
+
+// gitBlobID returns a func that looks up the blob ID of a file match in
+// repoRev, for revisionMatchMerger.
+func gitBlobID(ctx context.Context, repoRev *search.RepositoryRevisions) func(*fileMatchResolver) (string, error) {
+	return func(fm *fileMatchResolver) (string, error) {
+		oid, _, err := git.GetObject(ctx, repoRev.GitserverRepo, string(fm.commitID)+":"+fm.JPath)
+		if err != nil {
+			return "", err
+		}
+		return oid.String(), nil
+	}
+}
+
+// revisionBlobIDs looks up the blob IDs of the file matches that
+// mergeRevisionFileMatches compares (those whose path matched in more than
+// one revision), concurrently for up to maxConcurrentRevisionSearches of
+// them. It returns a blobID func for mergeRevisionFileMatches.
+func revisionBlobIDs(ctx context.Context, repoRev *search.RepositoryRevisions, matchesByRev [][]*fileMatchResolver) func(*fileMatchResolver) (string, error) {
+	revsByPath := map[string]int{}
+	for _, matches := range matchesByRev {
+		for _, fm := range matches {
+			revsByPath[fm.JPath]++
+		}
+	}
+
+	type result struct {
+		id  string
+		err error
+	}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = map[*fileMatchResolver]result{}
+		sem     = make(semaphore, maxConcurrentRevisionSearches)
+	)
+lookups:
+	for _, matches := range matchesByRev {
+		for _, fm := range matches {
+			if revsByPath[fm.JPath] < 2 {
+				continue
+			}
+			if sem.Acquire(ctx) != nil {
+				break lookups
+			}
+			wg.Add(1)
+			go func(fm *fileMatchResolver) {
+				defer wg.Done()
+				defer sem.Release()
+				oid, _, err := git.GetObject(ctx, repoRev.GitserverRepo, string(fm.commitID)+":"+fm.JPath)
+				mu.Lock()
+				results[fm] = result{id: oid.String(), err: err}
+				mu.Unlock()
+			}(fm)
+		}
+	}
+	wg.Wait()
+
+	return func(fm *fileMatchResolver) (string, error) {
+		r, ok := results[fm]
+		if !ok {
+			return "", errors.New("blob ID not looked up")
+		}
+		return r.id, r.err
+	}
+}
+
+// mergeRevisionFileMatches flattens the file matches found in several
+// revisions of a repository. When a file has the same blob ID in more than
+// one revision only the first match is kept, and the sourceRefs of the
+// others are added to it.
+func mergeRevisionFileMatches(matchesByRev [][]*fileMatchResolver, blobID func(*fileMatchResolver) (string, error)) []*fileMatchResolver {
+	var merged []*fileMatchResolver
+	merger := newRevisionMatchMerger(blobID, func(fm *fileMatchResolver) {
+		merged = append(merged, fm)
+	})
+	for _, matches := range matchesByRev {
+		merger.add(matches)
+	}
+	return merged
+}
+
+// revisionMatchMerger merges the file matches found in several revisions of
+// a repository as the search of each revision completes. A match is passed
+// to onMatch unless a match that was already passed on has the same path
+// and blob ID, in which case its sourceRefs are added to that match.
+type revisionMatchMerger struct {
+	blobID  func(*fileMatchResolver) (string, error)
+	onMatch func(*fileMatchResolver)
+
+	mu      sync.Mutex
+	byPath  map[string][]*fileMatchResolver // the matches passed on
+	blobIDs map[*fileMatchResolver]string   // "" if the lookup failed
+}
+
+func newRevisionMatchMerger(blobID func(*fileMatchResolver) (string, error), onMatch func(*fileMatchResolver)) *revisionMatchMerger {
+	return &revisionMatchMerger{
+		blobID:  blobID,
+		onMatch: onMatch,
+		byPath:  map[string][]*fileMatchResolver{},
+		blobIDs: map[*fileMatchResolver]string{},
+	}
+}
+
+// add merges the matches found in a revision. It is safe to call
+// concurrently.
+func (m *revisionMatchMerger) add(matches []*fileMatchResolver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lookupBlobIDs(matches)
+
+nextMatch:
+	for _, fm := range matches {
+		if id := m.blobIDs[fm]; id != "" {
+			for _, other := range m.byPath[fm.JPath] {
+				if m.blobIDs[other] == id {
+					other.sourceRefs = append(other.sourceRefs[:len(other.sourceRefs):len(other.sourceRefs)], fm.sourceRefs...)
+					continue nextMatch
+				}
+			}
+		}
+		m.byPath[fm.JPath] = append(m.byPath[fm.JPath], fm)
+		m.onMatch(fm)
+	}
+}
+
+// lookupBlobIDs looks up the blob IDs that add compares (those of matches
+// with the same path as a match that was already passed on), concurrently
+// for up to maxConcurrentRevisionSearches of them. A match whose blob ID
+// can't be looked up is treated as distinct from all others. The caller
+// must hold m.mu.
+func (m *revisionMatchMerger) lookupBlobIDs(matches []*fileMatchResolver) {
+	var lookups []*fileMatchResolver
+	needed := func(fm *fileMatchResolver) {
+		if _, ok := m.blobIDs[fm]; !ok {
+			m.blobIDs[fm] = ""
+			lookups = append(lookups, fm)
+		}
+	}
+	for _, fm := range matches {
+		if others := m.byPath[fm.JPath]; len(others) > 0 {
+			needed(fm)
+			for _, other := range others {
+				needed(other)
+			}
+		}
+	}
+
+	var (
+		mu  sync.Mutex // protects m.blobIDs
+		run = parallel.NewRun(maxConcurrentRevisionSearches)
+	)
+	for _, fm := range lookups {
+		run.Acquire()
+		go func(fm *fileMatchResolver) {
+			defer run.Release()
+			id, err := m.blobID(fm)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			m.blobIDs[fm] = id
+			mu.Unlock()
+		}(fm)
+	}
+	run.Wait()
+}
+
+// SourceRefs returns the refs (matched by a ref glob) on which the file
+// match was found.
+func (fm *fileMatchResolver) SourceRefs() []*gitRefResolver {
+	repo := &repositoryResolver{repo: fm.repo}
+	refs := make([]*gitRefResolver, len(fm.sourceRefs))
+	for i, name := range fm.sourceRefs {
+		refs[i] = &gitRefResolver{repo: repo, name: name}
+	}
+	return refs
+}
@@ -0,0 +1,225 @@
+package graphqlbackend
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestExpandRevisions(t *testing.T) {
+	git.Mocks.ListRefs = func() ([]*git.Ref, error) {
+		return []*git.Ref{
+			{Name: "refs/heads/master", CommitID: "a"},
+			{Name: "refs/heads/release/1", CommitID: "b"},
+			{Name: "refs/heads/release/2", CommitID: "c"},
+			{Name: "refs/heads/release/2-fix", CommitID: "c"},
+			{Name: "refs/tags/v1", CommitID: "b"},
+		}, nil
+	}
+	defer git.ResetMocks()
+
+	tests := map[string][]searchRevision{
+		"repo@rev": {{rev: "rev"}},
+		"repo@*": {
+			{rev: "refs/heads/master", refs: []string{"refs/heads/master"}},
+			{rev: "refs/heads/release/1", refs: []string{"refs/heads/release/1"}},
+			{rev: "refs/heads/release/2", refs: []string{"refs/heads/release/2", "refs/heads/release/2-fix"}},
+		},
+		"repo@v0:*refs/heads/release/*:*!refs/heads/release/1": {
+			{rev: "v0"},
+			{rev: "refs/heads/release/2", refs: []string{"refs/heads/release/2", "refs/heads/release/2-fix"}},
+		},
+		"repo@*nomatch": nil,
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			repo, revs := search.ParseRepositoryRevisions(input)
+			got, err := expandRevisions(context.Background(), &search.RepositoryRevisions{
+				Repo: &types.Repo{URI: repo},
+				Revs: revs,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMergeRevisionFileMatches(t *testing.T) {
+	fm := func(path, blob string, refs ...string) *fileMatchResolver {
+		// Abuse uri to store the blob ID.
+		return &fileMatchResolver{JPath: path, uri: blob, sourceRefs: refs}
+	}
+	blobID := func(fm *fileMatchResolver) (string, error) {
+		if fm.uri == "" {
+			return "", errors.New("not found")
+		}
+		return fm.uri, nil
+	}
+
+	merged := mergeRevisionFileMatches([][]*fileMatchResolver{
+		{fm("a", "1", "r1"), fm("b", "2", "r1"), fm("c", "", "r1")},
+		{fm("a", "1", "r2"), fm("b", "3", "r2"), fm("c", "", "r2")},
+		{fm("a", "4", "r3"), fm("b", "3", "r3", "r4")},
+	}, blobID)
+
+	type result struct {
+		path string
+		refs []string
+	}
+	var got []result
+	for _, fm := range merged {
+		got = append(got, result{path: fm.JPath, refs: fm.sourceRefs})
+	}
+	want := []result{
+		{path: "a", refs: []string{"r1", "r2"}},
+		{path: "b", refs: []string{"r1"}},
+		{path: "c", refs: []string{"r1"}},
+		{path: "b", refs: []string{"r2", "r3", "r4"}},
+		{path: "c", refs: []string{"r2"}},
+		{path: "a", refs: []string{"r3"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestForEachRevision(t *testing.T) {
+	revs := []searchRevision{{rev: "a"}, {rev: "b"}}
+
+	// An error of one revision is returned.
+	err := forEachRevision(context.Background(), revs, func(ctx context.Context, i int, rev searchRevision) error {
+		if rev.rev == "b" {
+			return errors.New("x")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "x" {
+		t.Errorf("got error %v, want x", err)
+	}
+
+	// The error of a context that is done is returned.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var searched int32
+	err = forEachRevision(ctx, revs, func(ctx context.Context, i int, rev searchRevision) error {
+		atomic.AddInt32(&searched, 1)
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("got error %v after %d searches, want %v", err, searched, context.Canceled)
+	}
+}
+
+func TestSearchFilesInRepoRevs(t *testing.T) {
+	git.Mocks.ListRefs = func() ([]*git.Ref, error) {
+		return []*git.Ref{
+			{Name: "refs/heads/a", CommitID: "1"},
+			{Name: "refs/heads/b", CommitID: "2"},
+			{Name: "refs/heads/c", CommitID: "3"},
+		}, nil
+	}
+	defer git.ResetMocks()
+	defer func() { mockSearchFilesInRepo = nil }()
+
+	repoRev := &search.RepositoryRevisions{
+		Repo: &types.Repo{URI: "repo"},
+		Revs: []search.RevisionSpecifier{{RefGlob: "refs/heads/*"}},
+	}
+	searchRevs := func(revErrs map[string]error, onMatch func(*fileMatchResolver)) (bool, error) {
+		mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) ([]*fileMatchResolver, bool, error) {
+			return []*fileMatchResolver{{JPath: rev}}, false, revErrs[rev]
+		}
+		return searchFilesInRepoRevs(context.Background(), repoRev, &search.PatternInfo{}, time.Second, onMatch)
+	}
+
+	t.Run("partial", func(t *testing.T) {
+		// The matches of the other revisions are kept, and the repository
+		// is reported as having hit a limit.
+		var paths []string
+		limitHit, err := searchRevs(map[string]error{"refs/heads/b": errors.New("x")}, func(fm *fileMatchResolver) {
+			paths = append(paths, fm.JPath)
+		})
+		sort.Strings(paths)
+		if want := []string{"refs/heads/a", "refs/heads/b", "refs/heads/c"}; err != nil || !limitHit || !reflect.DeepEqual(paths, want) {
+			t.Errorf("got %v (limitHit=%v, error %v), want %v (limitHit=true)", paths, limitHit, err, want)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		limitHit, err := searchRevs(map[string]error{"refs/heads/b": context.DeadlineExceeded}, func(*fileMatchResolver) {})
+		if err != context.DeadlineExceeded || !limitHit {
+			t.Errorf("got limitHit=%v, error %v, want limitHit=true, error %v", limitHit, err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("all failed", func(t *testing.T) {
+		x := errors.New("x")
+		_, err := searchRevs(map[string]error{"refs/heads/a": x, "refs/heads/b": x, "refs/heads/c": x}, func(*fileMatchResolver) {})
+		if err != x {
+			t.Errorf("got error %v, want %v", err, x)
+		}
+	})
+
+	t.Run("incremental", func(t *testing.T) {
+		// The matches of a revision are passed on before the search of the
+		// other revisions completes.
+		found := make(chan struct{})
+		mockSearchFilesInRepo = func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) ([]*fileMatchResolver, bool, error) {
+			if rev != "refs/heads/a" {
+				select {
+				case <-found:
+				case <-time.After(5 * time.Second):
+					return nil, false, errors.New("matches of refs/heads/a were not passed on")
+				}
+			}
+			return []*fileMatchResolver{{JPath: rev}}, false, nil
+		}
+		var once sync.Once
+		_, err := searchFilesInRepoRevs(context.Background(), repoRev, &search.PatternInfo{}, time.Second, func(fm *fileMatchResolver) {
+			once.Do(func() { close(found) })
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestSearchTreeForRepoRevs(t *testing.T) {
+	mockSearchFilesForRepo = func(matcher matcher, repoRevs search.RepositoryRevisions, limit int, includeDirs bool) ([]*searchSuggestionResolver, error) {
+		var res []*searchSuggestionResolver
+		for _, path := range map[string][]string{"r1": {"a", "b"}, "r2": {"b", "c"}}[repoRevs.Revs[0].RevSpec] {
+			res = append(res, &searchSuggestionResolver{score: 1, length: len(path), label: path})
+		}
+		return res, nil
+	}
+	defer func() { mockSearchFilesForRepo = nil }()
+
+	res, err := searchTreeForRepoRevs(context.Background(), matcher{}, &search.RepositoryRevisions{
+		Repo: &types.Repo{URI: "repo"},
+		Revs: []search.RevisionSpecifier{{RevSpec: "r1"}, {RevSpec: "r2"}},
+	}, 10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range res {
+		got = append(got, r.label)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
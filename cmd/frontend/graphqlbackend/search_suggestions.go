@@ -40,6 +40,12 @@ func (r *searchResolver) Suggestions(ctx context.Context, args *searchSuggestion
 		return nil, nil
 	}
 
+	// Boolean queries are not just a few terms, so none of the
+	// suggestions below are a good fit.
+	if r.query.Branches() != nil {
+		return nil, nil
+	}
+
 	// Only suggest for type:file.
 	typeValues, _ := r.query.StringValues(query.FieldType)
 	for _, resultType := range typeValues {
@@ -19,7 +19,7 @@ func TestSearchSuggestions(t *testing.T) {
 
 	createSearchResolver := func(t *testing.T, query string) *searchResolver {
 		t.Helper()
-		r, err := (&schemaResolver{}).Search(&struct{ Query string }{Query: query})
+		r, err := (&schemaResolver{}).Search(&searchArgs{Query: query})
 		if err != nil {
 			t.Fatal("Search:", err)
 		}
@@ -99,7 +99,7 @@ func TestSearchSuggestions(t *testing.T) {
 	})
 
 	t.Run("single term invalid regex", func(t *testing.T) {
-		_, err := (&schemaResolver{}).Search(&struct{ Query string }{Query: "foo("})
+		_, err := (&schemaResolver{}).Search(&searchArgs{Query: "foo("})
 		if err == nil {
 			t.Fatal("err == nil")
 		} else if want := "error parsing regexp"; !strings.Contains(err.Error(), want) {
@@ -43,7 +43,11 @@ func searchSymbols(ctx context.Context, args *search.Args, limit int) (res []*fi
 		tr.Finish()
 	}()
 
-	if args.Pattern.Pattern == "" {
+	filters, err := symbolFilters(args.Query)
+	if err != nil {
+		return nil, nil, err
+	}
+	if args.Pattern.Pattern == "" && len(filters.Kinds) == 0 && filters.Parent == "" {
 		return nil, nil, nil
 	}
 
@@ -66,7 +70,7 @@ func searchSymbols(ctx context.Context, args *search.Args, limit int) (res []*fi
 		run.Acquire()
 		goroutine.Go(func() {
 			defer run.Release()
-			repoSymbols, repoErr := searchSymbolsInRepo(ctx, repoRevs, args.Pattern, args.Query, limit)
+			repoSymbols, repoErr := searchSymbolsInRepo(ctx, repoRevs, args.Pattern, filters, limit)
 			if repoErr != nil {
 				tr.LogFields(otlog.String("repo", string(repoRevs.Repo.URI)), otlog.String("repoErr", repoErr.Error()), otlog.Bool("timeout", errcode.IsTimeout(repoErr)), otlog.Bool("temporary", errcode.IsTemporary(repoErr)))
 			}
@@ -99,7 +103,42 @@ func searchSymbols(ctx context.Context, args *search.Args, limit int) (res []*fi
 	return res, common, err
 }
 
-func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisions, patternInfo *search.PatternInfo, query *query.Query, limit int) (res []*fileMatchResolver, err error) {
+// symbolFilters returns the kind:, lang: and parent: filters of q, as
+// arguments to the symbols service. lang: (and -lang:) also apply as path
+// patterns like they do for text search (see langIncludeExcludePatterns),
+// which lets the symbols service skip files before it checks the language
+// that ctags reports for each symbol.
+func symbolFilters(q *query.Query) (protocol.SearchArgs, error) {
+	var args protocol.SearchArgs
+	if q == nil {
+		return args, nil
+	}
+	args.Kinds, _ = q.StringValues(query.FieldKind)
+	args.Parent, _ = q.StringValue(query.FieldParent)
+	langs, _ := q.StringValues(query.FieldLang)
+	for _, value := range langs {
+		lang := lookupLanguage(value)
+		if lang == nil {
+			return args, fmt.Errorf("unknown language: %q", value)
+		}
+		name := lang.Name
+		if ctagsName, ok := ctagsLanguageNames[strings.ToLower(name)]; ok {
+			name = ctagsName
+		}
+		args.Languages = append(args.Languages, name)
+	}
+	return args, nil
+}
+
+// ctagsLanguageNames maps the (lowercase) names of languages that ctags
+// names differently to the ctags names. Other names only differ in case,
+// spaces and dashes, which the symbols service ignores.
+var ctagsLanguageNames = map[string]string{
+	"shell":      "Sh",
+	"vim script": "Vim",
+}
+
+func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisions, patternInfo *search.PatternInfo, filters protocol.SearchArgs, limit int) (res []*fileMatchResolver, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Search symbols in repo")
 	defer func() {
 		if err != nil {
@@ -134,6 +173,9 @@ func searchSymbolsInRepo(ctx context.Context, repoRevs *search.RepositoryRevisio
 		IsRegExp:        patternInfo.IsRegExp,
 		IncludePatterns: patternInfo.IncludePatterns,
 		ExcludePattern:  patternInfo.ExcludePattern,
+		Kinds:           filters.Kinds,
+		Languages:       filters.Languages,
+		Parent:          filters.Parent,
 		First:           limit,
 	})
 	fileMatchesByURI := make(map[string]*fileMatchResolver)
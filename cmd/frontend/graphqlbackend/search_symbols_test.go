@@ -0,0 +1,39 @@
+package graphqlbackend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+func TestSymbolFilters(t *testing.T) {
+	tests := map[string]protocol.SearchArgs{
+		"foo": {},
+		"type:symbol kind:class kind:struct Config":    {Kinds: []string{"class", "struct"}},
+		"type:symbol parent:Config lang:go":            {Parent: "Config", Languages: []string{"Go"}},
+		"type:symbol lang:sh lang:objective-c -lang:c": {Languages: []string{"Sh", "Objective-C"}},
+	}
+	for q, want := range tests {
+		parsed, err := query.ParseAndCheck(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := symbolFilters(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %+v, want %+v", q, got, want)
+		}
+	}
+
+	parsed, err := query.ParseAndCheck("lang:notalanguage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := symbolFilters(parsed); err == nil {
+		t.Error("expected an error for an unknown language")
+	}
+}
@@ -168,3 +168,48 @@ func (r *symbolResolver) urlPath(prefix string) string {
 	}
 	return url
 }
+
+func (r *gitTreeEntryResolver) Outline(ctx context.Context) ([]*outlineSymbolResolver, error) {
+	ctx, done := context.WithTimeout(ctx, 5*time.Second)
+	defer done()
+	symbols, err := backend.Symbols.Outline(ctx, protocol.OutlineArgs{
+		Repo:     r.commit.repo.repo.URI,
+		CommitID: api.CommitID(r.commit.oid),
+		Path:     r.path,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.New("processing symbols is taking longer than expected. Try again in a while")
+		}
+		return nil, err
+	}
+	baseURI, err := uri.Parse("git://" + string(r.commit.repo.repo.URI) + "?" + string(r.commit.oid))
+	if err != nil {
+		return nil, err
+	}
+	return toOutlineSymbolResolvers(symbols, baseURI, r.commit), nil
+}
+
+func toOutlineSymbolResolvers(symbols []*protocol.OutlineSymbol, baseURI *uri.URI, commit *gitCommitResolver) []*outlineSymbolResolver {
+	resolvers := make([]*outlineSymbolResolver, 0, len(symbols))
+	for _, symbol := range symbols {
+		resolver := toSymbolResolver(symbolToLSPSymbolInformation(symbol.Symbol, baseURI), strings.ToLower(symbol.Language), commit)
+		if resolver == nil {
+			continue
+		}
+		resolvers = append(resolvers, &outlineSymbolResolver{
+			symbol:   resolver,
+			children: toOutlineSymbolResolvers(symbol.Children, baseURI, commit),
+		})
+	}
+	return resolvers
+}
+
+type outlineSymbolResolver struct {
+	symbol   *symbolResolver
+	children []*outlineSymbolResolver
+}
+
+func (r *outlineSymbolResolver) Symbol() *symbolResolver { return r.symbol }
+
+func (r *outlineSymbolResolver) Children() []*outlineSymbolResolver { return r.children }
@@ -0,0 +1,106 @@
+package graphqlbackend
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/graph-gophers/graphql-go/gqltesting"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/backend"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/db"
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/util"
+)
+
+func TestGitBlobOutline(t *testing.T) {
+	resetMocks()
+	db.Mocks.Repos.MockGetByURI(t, "github.com/gorilla/mux", 2)
+	backend.Mocks.Repos.ResolveRev = func(ctx context.Context, repo *types.Repo, rev string) (api.CommitID, error) {
+		return exampleCommitSHA1, nil
+	}
+	backend.Mocks.Repos.MockGetCommit_Return_NoCheck(t, &git.Commit{ID: exampleCommitSHA1})
+	git.Mocks.Stat = func(commit api.CommitID, path string) (os.FileInfo, error) {
+		return &util.FileInfo{Name_: path, Mode_: 0}, nil
+	}
+	defer git.ResetMocks()
+	backend.Mocks.Symbols.Outline = func(ctx context.Context, args protocol.OutlineArgs) ([]*protocol.OutlineSymbol, error) {
+		if args.Repo != "github.com/gorilla/mux" || args.CommitID != exampleCommitSHA1 || args.Path != "a.py" {
+			t.Errorf("wrong arguments to Symbols.Outline: %+v", args)
+		}
+		return []*protocol.OutlineSymbol{
+			{
+				Symbol: protocol.Symbol{Name: "A", Path: "a.py", Line: 1, Kind: "class", Language: "Python"},
+				Children: []*protocol.OutlineSymbol{
+					{Symbol: protocol.Symbol{Name: "m", Path: "a.py", Line: 2, Kind: "member", Language: "Python", Parent: "A", ParentKind: "class"}},
+				},
+			},
+			{Symbol: protocol.Symbol{Name: "f", Path: "a.py", Line: 5, Kind: "function", Language: "Python"}},
+		}, nil
+	}
+
+	gqltesting.RunTests(t, []*gqltesting.Test{
+		{
+			Schema: GraphQLSchema,
+			Query: `
+				{
+					repository(name: "github.com/gorilla/mux") {
+						commit(rev: "` + exampleCommitSHA1 + `") {
+							blob(path: "a.py") {
+								outline {
+									symbol {
+										name
+										kind
+										containerName
+										location {
+											range {
+												start { line }
+											}
+										}
+									}
+									children {
+										symbol {
+											name
+											kind
+											containerName
+										}
+										children {
+											symbol {
+												name
+											}
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			`,
+			ExpectedResult: `
+				{
+					"repository": {
+						"commit": {
+							"blob": {
+								"outline": [
+									{
+										"symbol": {"name": "A", "kind": "CLASS", "containerName": null, "location": {"range": {"start": {"line": 0}}}},
+										"children": [
+											{"symbol": {"name": "m", "kind": "FIELD", "containerName": "A"}, "children": []}
+										]
+									},
+									{
+										"symbol": {"name": "f", "kind": "FUNCTION", "containerName": null, "location": {"range": {"start": {"line": 4}}}},
+										"children": []
+									}
+								]
+							}
+						}
+					}
+				}
+			`,
+		},
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -32,6 +33,8 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/env"
 	"github.com/sourcegraph/sourcegraph/pkg/errcode"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/contextlines"
+	searcherprotocol "github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/trace"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 	zoektpkg "github.com/sourcegraph/sourcegraph/pkg/zoekt"
@@ -60,15 +63,32 @@ type fileMatchResolver struct {
 	JPath        string       `json:"Path"`
 	JLineMatches []*lineMatch `json:"LineMatches"`
 	JLimitHit    bool         `json:"LimitHit"`
-	symbols      []*symbolResolver
-	uri          string
-	repo         *types.Repo
-	commitID     api.CommitID // or empty for default branch
+
+	// JMultilineMatches is only set by searcher for multiline searches. It is
+	// folded into JLineMatches by foldMultilineMatches.
+	JMultilineMatches []searcherprotocol.MultilineMatch `json:"MultilineMatches"`
+
+	// JDiff is only set by searcher for replace searches.
+	JDiff string `json:"Diff"`
+
+	// JMatchCount is the number of matches in the file when it was found by
+	// a search with search.PatternInfo.CountOnly that counted them instead
+	// of returning them in JLineMatches.
+	JMatchCount int32 `json:"MatchCount"`
+
+	symbols  []*symbolResolver
+	uri      string
+	repo     *types.Repo
+	commitID api.CommitID // or empty for default branch
 
 	// inputRev is the Git revspec that the user originally requested to search. It is used to
 	// preserve the original revision specifier from the user instead of navigating them to the
 	// absolute commit ID when they select a result.
 	inputRev *string
+
+	// sourceRefs are the refs matching a ref glob on which the file was
+	// found. See searchFilesInRepoRevs.
+	sourceRefs []string
 }
 
 func (fm *fileMatchResolver) Key() string {
@@ -107,6 +127,13 @@ func (fm *fileMatchResolver) LimitHit() bool {
 	return fm.JLimitHit
 }
 
+func (fm *fileMatchResolver) ReplacementDiff() *string {
+	if fm.JDiff == "" {
+		return nil
+	}
+	return &fm.JDiff
+}
+
 func fileMatchesToSearchResults(fms []*fileMatchResolver) []*searchResultResolver {
 	results := make([]*searchResultResolver, len(fms))
 	for i, fm := range fms {
@@ -121,6 +148,8 @@ type lineMatch struct {
 	JOffsetAndLengths [][2]int32 `json:"OffsetAndLengths"`
 	JLineNumber       int32      `json:"LineNumber"`
 	JLimitHit         bool       `json:"LimitHit"`
+	JBeforeContext    []string   `json:"BeforeContext"`
+	JAfterContext     []string   `json:"AfterContext"`
 }
 
 func (lm *lineMatch) Preview() string {
@@ -143,11 +172,73 @@ func (lm *lineMatch) LimitHit() bool {
 	return lm.JLimitHit
 }
 
-// textSearch searches repo@commit with p.
-// Note: the returned matches do not set fileMatch.uri
-func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+func (lm *lineMatch) BeforeContext() []string {
+	if lm.JBeforeContext == nil {
+		return []string{}
+	}
+	return lm.JBeforeContext
+}
+
+func (lm *lineMatch) AfterContext() []string {
+	if lm.JAfterContext == nil {
+		return []string{}
+	}
+	return lm.JAfterContext
+}
+
+// multilineMatchesToLineMatches converts matches which may span multiple
+// lines into one lineMatch per line, so that they can be rendered by clients
+// of the GraphQL API which only understand single-line matches. Each line of
+// a multiline match is highlighted from the start of the match (or the start
+// of the line) to the end of the match (or the end of the line).
+func multilineMatchesToLineMatches(mms []searcherprotocol.MultilineMatch) []*lineMatch {
+	byLine := map[int]*lineMatch{}
+	for _, mm := range mms {
+		for i, line := range strings.Split(mm.Preview, "\n") {
+			lineNumber := mm.Start.Line + i
+			if lineNumber > mm.End.Line {
+				break
+			}
+			start, end := 0, utf8.RuneCountInString(line)
+			if lineNumber == mm.Start.Line {
+				start = mm.Start.Column
+			}
+			if lineNumber == mm.End.Line {
+				end = mm.End.Column
+			}
+			if end <= start && mm.Start.Line != mm.End.Line {
+				// The match ends with a newline, so nothing on this line is
+				// part of the match.
+				continue
+			}
+			lm, ok := byLine[lineNumber]
+			if !ok {
+				lm = &lineMatch{
+					JPreview:    line,
+					JLineNumber: int32(lineNumber),
+				}
+				byLine[lineNumber] = lm
+			}
+			lm.JOffsetAndLengths = append(lm.JOffsetAndLengths, [2]int32{int32(start), int32(end - start)})
+		}
+	}
+
+	lms := make([]*lineMatch, 0, len(byLine))
+	for _, lm := range byLine {
+		lms = append(lms, lm)
+	}
+	sort.Slice(lms, func(i, j int) bool {
+		return lms[i].JLineNumber < lms[j].JLineNumber
+	})
+	return lms
+}
+
+// textSearch searches repo@commit with p. Each match is passed to onMatch as
+// soon as searcher finds it.
+// Note: the matches do not set fileMatch.uri
+func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (limitHit bool, err error) {
 	if searcherURLs == nil {
-		return nil, false, errors.New("a searcher service has not been configured")
+		return false, errors.New("a searcher service has not been configured")
 	}
 
 	tr, ctx := trace.New(ctx, "searcher.client", fmt.Sprintf("%s@%s", repo.Name, commit))
@@ -175,13 +266,15 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 		"Pattern":         []string{p.Pattern},
 		"ExcludePattern":  []string{p.ExcludePattern},
 		"IncludePatterns": includePatterns,
+		"AndPatterns":     p.AndPatterns,
+		"NotPatterns":     p.NotPatterns,
 		"IncludePattern":  []string{p.IncludePattern},
 		"FetchTimeout":    []string{fetchTimeout.String()},
 	}
 	if deadline, ok := ctx.Deadline(); ok {
 		t, err := deadline.MarshalText()
 		if err != nil {
-			return nil, false, err
+			return false, err
 		}
 		q.Set("Deadline", string(t))
 	}
@@ -195,6 +288,22 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	if p.IsCaseSensitive {
 		q.Set("IsCaseSensitive", "true")
 	}
+	if p.IsMultiline {
+		q.Set("IsMultiline", "true")
+	}
+	if p.BeforeContextLines > 0 {
+		q.Set("BeforeContextLines", strconv.FormatInt(int64(p.BeforeContextLines), 10))
+	}
+	if p.AfterContextLines > 0 {
+		q.Set("AfterContextLines", strconv.FormatInt(int64(p.AfterContextLines), 10))
+	}
+	if p.IsReplace {
+		q.Set("IsReplace", "true")
+		q.Set("Replacement", p.Replacement)
+	}
+	if p.CountOnly {
+		q.Set("CountOnly", "true")
+	}
 	if p.PathPatternsAreRegExps {
 		q.Set("PathPatternsAreRegExps", "true")
 	}
@@ -205,6 +314,9 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	// these fields from old frontends that do not (and provide a default in the latter case).
 	q.Set("PatternMatchesContent", strconv.FormatBool(p.PatternMatchesContent))
 	q.Set("PatternMatchesPath", strconv.FormatBool(p.PatternMatchesPath))
+	// Ask for a streaming response so that the matches found so far are not
+	// lost if we hit our deadline while searcher is still searching.
+	q.Set("Stream", "true")
 	rawQuery := q.Encode()
 
 	// Searcher caches the file contents for repo@commit since it is
@@ -218,13 +330,21 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 		excludedSearchURLs = map[string]bool{}
 		attempt            = 0
 		maxAttempts        = 2
+
+		// forwarded is the number of matches passed to onMatch. We can't
+		// retry once there are any, since they would be passed again.
+		forwarded int
 	)
+	countingOnMatch := func(fm *fileMatchResolver) {
+		forwarded++
+		onMatch(fm)
+	}
 	for {
 		attempt++
 
 		searcherURL, err := searcherURLs.Get(consistentHashKey, excludedSearchURLs)
 		if err != nil {
-			return nil, false, err
+			return false, err
 		}
 
 		// Fallback to a bad host if nothing is left
@@ -232,28 +352,29 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 			tr.LazyPrintf("failed to find endpoint, trying again without excludes")
 			searcherURL, err = searcherURLs.Get(consistentHashKey, nil)
 			if err != nil {
-				return nil, false, err
+				return false, err
 			}
 		}
 
 		url := searcherURL + "?" + rawQuery
 		tr.LazyPrintf("attempt %d: %s", attempt, url)
-		matches, limitHit, err = textSearchURL(ctx, url)
+		limitHit, err = textSearchURL(ctx, url, countingOnMatch)
 		// Useful trace for debugging:
 		//
-		// tr.LazyPrintf("%d matches, limitHit=%v, err=%v, ctx.Err()=%v", len(matches), limitHit, err, ctx.Err())
+		// tr.LazyPrintf("%d matches, limitHit=%v, err=%v, ctx.Err()=%v", forwarded, limitHit, err, ctx.Err())
 		if err == nil || errcode.IsTimeout(err) {
-			return matches, limitHit, err
+			return limitHit, err
 		}
 
 		// If we are canceled, return that error.
 		if err := ctx.Err(); err != nil {
-			return nil, false, err
+			return false, err
 		}
 
-		// If not temporary or our last attempt then don't try again.
-		if !errcode.IsTemporary(err) || attempt == maxAttempts {
-			return nil, false, err
+		// If not temporary, our last attempt or some matches were already
+		// passed on then don't try again.
+		if !errcode.IsTemporary(err) || attempt == maxAttempts || forwarded > 0 {
+			return false, err
 		}
 
 		tr.LazyPrintf("transient error %s", err.Error())
@@ -262,10 +383,10 @@ func textSearch(ctx context.Context, repo gitserver.Repo, commit api.CommitID, p
 	}
 }
 
-func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool, error) {
+func textSearchURL(ctx context.Context, url string, onMatch func(*fileMatchResolver)) (bool, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, false, err
+		return false, err
 	}
 	req = req.WithContext(ctx)
 
@@ -276,7 +397,7 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 
 	// Limit number of outstanding searcher requests
 	if err := textSearchLimiter.Acquire(ctx); err != nil {
-		return nil, false, err
+		return false, err
 	}
 	defer textSearchLimiter.Release()
 
@@ -287,15 +408,20 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 		if ctx.Err() != nil {
 			err = ctx.Err()
 		}
-		return nil, false, errors.Wrap(err, "searcher request failed")
+		return false, errors.Wrap(err, "searcher request failed")
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return nil, false, err
+			return false, err
 		}
-		return nil, false, errors.WithStack(&searcherError{StatusCode: resp.StatusCode, Message: string(body)})
+		return false, errors.WithStack(&searcherError{StatusCode: resp.StatusCode, Message: string(body)})
+	}
+
+	// TEMP BACKCOMPAT: older searchers ignore the Stream parameter.
+	if resp.Header.Get("Content-Type") == searcherprotocol.StreamContentType {
+		return textSearchStream(ctx, resp.Body, onMatch)
 	}
 
 	r := struct {
@@ -305,12 +431,59 @@ func textSearchURL(ctx context.Context, url string) ([]*fileMatchResolver, bool,
 	}{}
 	err = json.NewDecoder(resp.Body).Decode(&r)
 	if err != nil {
-		return nil, false, errors.Wrap(err, "searcher response invalid")
+		return false, errors.Wrap(err, "searcher response invalid")
 	}
 	if r.DeadlineHit {
 		err = context.DeadlineExceeded
 	}
-	return r.Matches, r.LimitHit, err
+	for _, fm := range r.Matches {
+		foldMultilineMatches(fm)
+		onMatch(fm)
+	}
+	return r.LimitHit, err
+}
+
+// textSearchStream reads a streaming searcher response (see
+// searcherprotocol.StreamEvent), passing each match to onMatch as soon as it
+// is read. If the stream is interrupted, for example because ctx is done,
+// the matches read so far have already been passed on, so partial results
+// are not lost.
+func textSearchStream(ctx context.Context, body io.Reader, onMatch func(*fileMatchResolver)) (limitHit bool, err error) {
+	dec := json.NewDecoder(body)
+	for {
+		var ev struct {
+			Match *fileMatchResolver
+			Done  *searcherprotocol.StreamDone
+		}
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			return false, errors.Wrap(err, "searcher response invalid")
+		}
+		if ev.Match != nil {
+			foldMultilineMatches(ev.Match)
+			onMatch(ev.Match)
+		}
+		if ev.Done != nil {
+			if ev.Done.Error != "" {
+				return ev.Done.LimitHit, errors.New(ev.Done.Error)
+			}
+			if ev.Done.DeadlineHit {
+				err = context.DeadlineExceeded
+			}
+			return ev.Done.LimitHit, err
+		}
+	}
+}
+
+// foldMultilineMatches converts the multiline matches returned by searcher
+// into line matches.
+func foldMultilineMatches(fm *fileMatchResolver) {
+	if len(fm.JMultilineMatches) > 0 {
+		fm.JLineMatches = append(fm.JLineMatches, multilineMatchesToLineMatches(fm.JMultilineMatches)...)
+		fm.JMultilineMatches = nil
+	}
 }
 
 type searcherError struct {
@@ -332,9 +505,15 @@ func (e *searcherError) Error() string {
 
 var mockSearchFilesInRepo func(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error)
 
-func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration) (matches []*fileMatchResolver, limitHit bool, err error) {
+// searchFilesInRepo searches repo@rev, passing each match to onMatch as soon
+// as it is found.
+func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gitserver.Repo, rev string, info *search.PatternInfo, fetchTimeout time.Duration, onMatch func(*fileMatchResolver)) (limitHit bool, err error) {
 	if mockSearchFilesInRepo != nil {
-		return mockSearchFilesInRepo(ctx, repo, gitserverRepo, rev, info, fetchTimeout)
+		matches, limitHit, err := mockSearchFilesInRepo(ctx, repo, gitserverRepo, rev, info, fetchTimeout)
+		for _, fm := range matches {
+			onMatch(fm)
+		}
+		return limitHit, err
 	}
 
 	// Do not trigger a repo-updater lookup (e.g.,
@@ -343,47 +522,44 @@ func searchFilesInRepo(ctx context.Context, repo *types.Repo, gitserverRepo gits
 	// repo is not on gitserver.
 	commit, err := git.ResolveRevision(ctx, gitserverRepo, nil, rev, nil)
 	if err != nil {
-		return nil, false, err
+		return false, err
 	}
 
-	matches, limitHit, err = textSearch(ctx, gitserverRepo, commit, info, fetchTimeout)
-
 	var workspace string
 	if rev != "" {
 		workspace = "git://" + string(repo.URI) + "?" + url.QueryEscape(rev) + "#"
 	} else {
 		workspace = "git://" + string(repo.URI) + "#"
 	}
-	for _, fm := range matches {
+	return textSearch(ctx, gitserverRepo, commit, info, fetchTimeout, func(fm *fileMatchResolver) {
 		fm.uri = workspace + fm.JPath
 		fm.repo = repo
 		fm.commitID = commit
 		fm.inputRev = &rev
-	}
-
-	return matches, limitHit, err
+		onMatch(fm)
+	})
 }
 
-func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*search.RepositoryRevisions, useFullDeadline bool) (fm []*fileMatchResolver, limitHit bool, reposLimitHit map[string]struct{}, err error) {
+// zoektSearch searches repos, which must be indexed (see zoektIndexedRepos),
+// with zoekt.
+func zoektSearch(ctx context.Context, query *search.PatternInfo, repos []*search.RepositoryRevisions, useFullDeadline bool) (fm []*fileMatchResolver, limitHit bool, reposLimitHit map[string]struct{}, err error) {
 	if len(repos) == 0 {
 		return nil, false, nil, nil
 	}
 
-	// Tell zoekt which repos to search
-	repoSet := &zoektquery.RepoSet{Set: make(map[string]bool, len(repos))}
-	repoMap := make(map[api.RepoURI]*types.Repo, len(repos))
+	repoMap := make(map[api.RepoURI]*search.RepositoryRevisions, len(repos))
 	for _, repoRev := range repos {
-		repoSet.Set[string(repoRev.Repo.URI)] = true
-		repoMap[api.RepoURI(strings.ToLower(string(repoRev.Repo.URI)))] = repoRev.Repo
+		repoMap[api.RepoURI(strings.ToLower(string(repoRev.Repo.URI)))] = repoRev
 	}
 
 	queryExceptRepos, err := queryToZoektQuery(query)
 	if err != nil {
 		return nil, false, nil, err
 	}
-	finalQuery := zoektquery.NewAnd(repoSet, queryExceptRepos)
+	// Tell zoekt which repos (and branches) to search
+	finalQuery := zoektquery.NewAnd(zoektReposQuery(repos), queryExceptRepos)
 
-	tr, ctx := trace.New(ctx, "zoekt.Search", fmt.Sprintf("%d %+v", len(repoSet.Set), finalQuery.String()))
+	tr, ctx := trace.New(ctx, "zoekt.Search", fmt.Sprintf("%d %+v", len(repos), finalQuery.String()))
 	defer func() {
 		tr.SetError(err)
 		if len(fm) > 0 {
@@ -432,6 +608,12 @@ func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*se
 		searchOpts.MaxDocDisplayCount = 2000
 	}
 
+	// zoekt does not return context lines, so we ask for the whole file and
+	// find them ourselves.
+	if (query.BeforeContextLines > 0 || query.AfterContextLines > 0) && !query.CountOnly {
+		searchOpts.Whole = true
+	}
+
 	if userProbablyWantsToWaitLonger := query.FileMatchLimit > defaultMaxSearchResults; userProbablyWantsToWaitLonger {
 		searchOpts.MaxWallTime *= time.Duration(3 * float64(query.FileMatchLimit) / float64(defaultMaxSearchResults))
 	}
@@ -479,6 +661,7 @@ func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*se
 		}
 	}
 
+	resp.Files = zoektFilesOnBranches(resp.Files, repoMap)
 	if len(resp.Files) == 0 {
 		return nil, false, nil, nil
 	}
@@ -507,44 +690,84 @@ func zoektSearchHEAD(ctx context.Context, query *search.PatternInfo, repos []*se
 	}
 	matches := make([]*fileMatchResolver, len(resp.Files))
 	for i, file := range resp.Files {
-		fileLimitHit := false
-		if len(file.LineMatches) > maxLineMatches {
-			file.LineMatches = file.LineMatches[:maxLineMatches]
-			fileLimitHit = true
-			limitHit = true
-		}
-		lines := make([]*lineMatch, 0, len(file.LineMatches))
-		for _, l := range file.LineMatches {
-			if !l.FileName {
-				if len(l.LineFragments) > maxLineFragmentMatches {
-					l.LineFragments = l.LineFragments[:maxLineFragmentMatches]
-				}
-				offsets := make([][2]int32, len(l.LineFragments))
-				for k, m := range l.LineFragments {
-					offset := utf8.RuneCount(l.Line[:m.LineOffset])
-					length := utf8.RuneCount(l.Line[m.LineOffset : m.LineOffset+m.MatchLength])
-					offsets[k] = [2]int32{int32(offset), int32(length)}
-				}
-				lines = append(lines, &lineMatch{
-					JPreview:          string(l.Line),
-					JLineNumber:       int32(l.LineNumber - 1),
-					JOffsetAndLengths: offsets,
-				})
+		repoRev := repoMap[api.RepoURI(strings.ToLower(string(file.Repository)))]
+		matches[i] = &fileMatchResolver{
+			JPath:    file.FileName,
+			uri:      fmt.Sprintf("git://%s#%s", file.Repository, file.FileName),
+			repo:     repoRev.Repo,
+			commitID: "", // zoekt doesn't return commit IDs, so use the default branch or inputRev
+		}
+		if query.CountOnly {
+			matches[i].JMatchCount = zoektMatchCount(file)
+		} else {
+			matches[i].JLineMatches, matches[i].JLimitHit = zoektLineMatches(file, query, maxLineMatches, maxLineFragmentMatches)
+			if matches[i].JLimitHit {
+				limitHit = true
 			}
 		}
-		matches[i] = &fileMatchResolver{
-			JPath:        file.FileName,
-			JLineMatches: lines,
-			JLimitHit:    fileLimitHit,
-			uri:          fmt.Sprintf("git://%s#%s", file.Repository, file.FileName),
-			repo:         repoMap[api.RepoURI(strings.ToLower(string(file.Repository)))],
-			commitID:     "", // zoekt only searches default branch
+		if branch, _ := zoektBranch(repoRev); branch != "HEAD" {
+			rev := repoRev.Revs[0].RevSpec
+			matches[i].uri = fmt.Sprintf("git://%s?%s#%s", file.Repository, url.QueryEscape(rev), file.FileName)
+			matches[i].inputRev = &rev
 		}
 	}
 
 	return matches, limitHit, reposLimitHit, nil
 }
 
+// zoektLineMatches converts the line matches of file, keeping at most
+// maxLineMatches lines and maxLineFragmentMatches matches per line.
+// limitHit is whether any lines were dropped.
+func zoektLineMatches(file zoekt.FileMatch, query *search.PatternInfo, maxLineMatches, maxLineFragmentMatches int) (lines []*lineMatch, limitHit bool) {
+	if len(file.LineMatches) > maxLineMatches {
+		file.LineMatches = file.LineMatches[:maxLineMatches]
+		limitHit = true
+	}
+	wantContext := query.BeforeContextLines > 0 || query.AfterContextLines > 0
+	lines = make([]*lineMatch, 0, len(file.LineMatches))
+	for _, l := range file.LineMatches {
+		if l.FileName {
+			continue
+		}
+		if len(l.LineFragments) > maxLineFragmentMatches {
+			l.LineFragments = l.LineFragments[:maxLineFragmentMatches]
+		}
+		offsets := make([][2]int32, len(l.LineFragments))
+		for k, m := range l.LineFragments {
+			offset := utf8.RuneCount(l.Line[:m.LineOffset])
+			length := utf8.RuneCount(l.Line[m.LineOffset : m.LineOffset+m.MatchLength])
+			offsets[k] = [2]int32{int32(offset), int32(length)}
+		}
+		lm := &lineMatch{
+			JPreview:          string(l.Line),
+			JLineNumber:       int32(l.LineNumber - 1),
+			JOffsetAndLengths: offsets,
+		}
+		if wantContext {
+			// l.LineEnd is the offset of the line's newline.
+			end := l.LineEnd
+			if end < len(file.Content) {
+				end++
+			}
+			lm.JBeforeContext, lm.JAfterContext = contextlines.Around(file.Content, l.LineStart, end, int(query.BeforeContextLines), int(query.AfterContextLines))
+		}
+		lines = append(lines, lm)
+	}
+	return lines, limitHit
+}
+
+// zoektMatchCount returns the number of matches in file, counted like the
+// line matches of a fileMatchResolver.
+func zoektMatchCount(file zoekt.FileMatch) int32 {
+	var n int32
+	for _, l := range file.LineMatches {
+		if !l.FileName {
+			n++
+		}
+	}
+	return n
+}
+
 func noOpAnyChar(re *syntax.Regexp) {
 	if re.Op == syntax.OpAnyChar {
 		re.Op = syntax.OpAnyCharNotNL
@@ -601,6 +824,28 @@ func queryToZoektQuery(query *search.PatternInfo) (zoektquery.Q, error) {
 		})
 	}
 
+	// zoekt matches And and Not of content queries at the file level, like
+	// searcher does for AndPatterns and NotPatterns.
+	if len(query.AndPatterns) > 0 || len(query.NotPatterns) > 0 {
+		if !query.IsRegExp {
+			return nil, errors.New("zoekt only supports regex and/not patterns")
+		}
+		for _, p := range query.AndPatterns {
+			q, err := parseRe(p, false)
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, q)
+		}
+		for _, p := range query.NotPatterns {
+			q, err := parseRe(p, false)
+			if err != nil {
+				return nil, err
+			}
+			and = append(and, &zoektquery.Not{Child: q})
+		}
+	}
+
 	// zoekt also uses regular expressions for file paths
 	// TODO PathPatternsAreCaseSensitive
 	// TODO whitespace in file path patterns?
@@ -630,14 +875,16 @@ func zoektIndexedRepos(ctx context.Context, repos []*search.RepositoryRevisions)
 		return nil, repos, nil
 	}
 	for _, repoRev := range repos {
-		// We search HEAD using zoekt
-		if revspecs := repoRev.RevSpecs(); len(revspecs) > 0 {
-			// TODO(sqs): search all revspecs
-			if revspecs[0] == "" {
-				indexed = append(indexed, repoRev)
-			} else {
-				unindexed = append(unindexed, repoRev)
-			}
+		// We search HEAD and the branches configured to be indexed (see
+		// backend.SearchIndex) using zoekt. Other revisions, including
+		// those matching ref globs, are searched with searcher.
+		if len(repoRev.Revs) == 0 {
+			continue
+		}
+		if _, ok := zoektBranch(repoRev); ok {
+			indexed = append(indexed, repoRev)
+		} else {
+			unindexed = append(unindexed, repoRev)
 		}
 	}
 
@@ -653,16 +900,22 @@ func zoektIndexedRepos(ctx context.Context, repos []*search.RepositoryRevisions)
 		return nil, repos, err
 	}
 
-	// Everything currently in indexed is at HEAD. Filter out repos which
-	// zoekt hasn't indexed yet.
-	set := map[string]bool{}
+	// Filter out repos (or branches of them) which zoekt hasn't indexed
+	// yet.
+	branches := map[string][]string{}
 	for _, repo := range resp.Repos {
-		set[repo.Repository.Name] = true
+		names := []string{}
+		for _, b := range repo.Repository.Branches {
+			names = append(names, b.Name)
+		}
+		branches[repo.Repository.Name] = names
 	}
-	head := indexed
+	candidates := indexed
 	indexed = indexed[:0]
-	for _, repoRev := range head {
-		if set[string(repoRev.Repo.URI)] {
+	for _, repoRev := range candidates {
+		names, ok := branches[string(repoRev.Repo.URI)]
+		branch, _ := zoektBranch(repoRev)
+		if ok && (branch == "HEAD" || zoektHasBranch(names, branch)) {
 			indexed = append(indexed, repoRev)
 		} else {
 			unindexed = append(unindexed, repoRev)
@@ -672,9 +925,137 @@ func zoektIndexedRepos(ctx context.Context, repos []*search.RepositoryRevisions)
 	return indexed, unindexed, nil
 }
 
+// zoektBranch returns the name of the branch zoekt indexes for the revision
+// of repoRev ("HEAD" for the default branch). ok is false if repoRev is not a
+// single revision that zoekt can index.
+func zoektBranch(repoRev *search.RepositoryRevisions) (branch string, ok bool) {
+	if len(repoRev.Revs) != 1 {
+		return "", false
+	}
+	rev := repoRev.Revs[0]
+	if rev.RefGlob != "" || rev.ExcludeRefGlob != "" {
+		return "", false
+	}
+	if rev.RevSpec == "" || rev.RevSpec == "HEAD" {
+		return "HEAD", true
+	}
+	return strings.TrimPrefix(rev.RevSpec, "refs/heads/"), true
+}
+
+// zoektHasBranch reports whether branch is one of the indexed branch names,
+// and can be selected with a zoekt branch query. zoekt matches branch queries
+// by substring, so branch must not be part of another indexed branch name.
+func zoektHasBranch(names []string, branch string) bool {
+	found := false
+	for _, name := range names {
+		if name == branch {
+			found = true
+		} else if strings.Contains(name, branch) {
+			return false
+		}
+	}
+	return found
+}
+
+// zoektFilesOnBranches returns the files that are on the branch searched in
+// their repository (see zoektBranch).
+//
+// zoekt matches branch queries by substring, so a query for branch "dev" also
+// matches a file that is only on "dev2". zoektHasBranch avoids such queries,
+// but it uses a cached list of indexed branches. A file matched by a branch
+// query is returned with the name of the branch it matched, or "" if the
+// query matched several branches, so comparing it to the searched branch
+// drops the files that are not on that exact branch.
+func zoektFilesOnBranches(files []zoekt.FileMatch, repoMap map[api.RepoURI]*search.RepositoryRevisions) []zoekt.FileMatch {
+	filtered := files[:0]
+	for _, file := range files {
+		repoRev, ok := repoMap[api.RepoURI(strings.ToLower(string(file.Repository)))]
+		if !ok {
+			continue
+		}
+		branch, _ := zoektBranch(repoRev)
+		for _, b := range file.Branches {
+			if b == branch {
+				filtered = append(filtered, file)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// zoektReposQuery returns a zoekt query that restricts a search to repos, at
+// the branches returned by zoektBranch.
+func zoektReposQuery(repos []*search.RepositoryRevisions) zoektquery.Q {
+	sets := map[string]*zoektquery.RepoSet{}
+	var branches []string
+	for _, repoRev := range repos {
+		branch, _ := zoektBranch(repoRev)
+		set, ok := sets[branch]
+		if !ok {
+			set = &zoektquery.RepoSet{Set: map[string]bool{}}
+			sets[branch] = set
+			branches = append(branches, branch)
+		}
+		set.Set[string(repoRev.Repo.URI)] = true
+	}
+	or := make([]zoektquery.Q, 0, len(branches))
+	for _, branch := range branches {
+		or = append(or, zoektquery.NewAnd(sets[branch], &zoektquery.Branch{Pattern: branch}))
+	}
+	return zoektquery.NewOr(or...)
+}
+
+// splitIndexedRepos decides which repositories are searched with zoekt and
+// which with searcher, given the indexed and unindexed repositories (see
+// zoektIndexedRepos). It follows the index: field, and uses searcher for
+// patterns that zoekt does not support. Unindexed repositories are not
+// searched at all with index:only, and are returned as missing.
+func splitIndexedRepos(args *search.Args, indexed, unindexed []*search.RepositoryRevisions) (zoektRepos, searcherRepos, missing []*search.RepositoryRevisions, err error) {
+	zoektRepos, searcherRepos = indexed, unindexed
+
+	// Support index:yes (default), index:only, and index:no in search query.
+	index, _ := args.Query.StringValues(query.FieldIndex)
+	if len(index) > 0 {
+		index := index[len(index)-1]
+		switch parseYesNoOnly(index) {
+		case Yes, True:
+			// default
+		case Only:
+			if zoektCache == nil {
+				return nil, nil, nil, fmt.Errorf("invalid index:%q (indexed search is not enabled)", index)
+			}
+			if args.Pattern.IsMultiline {
+				return nil, nil, nil, fmt.Errorf("invalid index:%q (indexed search does not support multiline patterns)", index)
+			}
+			if args.Pattern.IsReplace {
+				return nil, nil, nil, fmt.Errorf("invalid index:%q (indexed search does not support replacements)", index)
+			}
+			missing = searcherRepos
+			searcherRepos = nil
+		case No, False:
+			searcherRepos = append(searcherRepos, zoektRepos...)
+			zoektRepos = nil
+		default:
+			return nil, nil, nil, fmt.Errorf("invalid index:%q (valid values are: yes, only, no)", index)
+		}
+	}
+
+	if args.Pattern.IsMultiline || args.Pattern.IsReplace {
+		// zoekt matches line by line and does not compute replacements, so
+		// multiline and replace searches always use searcher.
+		searcherRepos = append(searcherRepos, zoektRepos...)
+		zoektRepos = nil
+	}
+	return zoektRepos, searcherRepos, missing, nil
+}
+
 var mockSearchFilesInRepos func(args *search.Args) ([]*fileMatchResolver, *searchResultsCommon, error)
 
-// searchFilesInRepos searches a set of repos for a pattern.
+// searchFilesInRepos searches a set of repos for a pattern. It returns when
+// the search is done. Matches streamed from searcher are added to the results
+// as they are read, so the matches found before a deadline or the result
+// limit is hit are returned.
 func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatchResolver, common *searchResultsCommon, err error) {
 	if mockSearchFilesInRepos != nil {
 		return mockSearchFilesInRepos(args)
@@ -709,33 +1090,15 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		return nil, common, nil
 	}
 
-	// Support index:yes (default), index:only, and index:no in search query.
-	index, _ := args.Query.StringValues(query.FieldIndex)
-	if len(index) > 0 {
-		index := index[len(index)-1]
-		switch parseYesNoOnly(index) {
-		case Yes, True:
-			// default
-			if zoektCache != nil {
-				tr.LazyPrintf("%d indexed repos, %d unindexed repos", len(zoektRepos), len(searcherRepos))
-			}
-		case Only:
-			if zoektCache == nil {
-				return nil, common, fmt.Errorf("invalid index:%q (indexed search is not enabled)", index)
-			}
-			common.missing = make([]*types.Repo, len(searcherRepos))
-			for i, r := range searcherRepos {
-				common.missing[i] = r.Repo
-			}
-			tr.LazyPrintf("index:only, ignoring %d unindexed repos", len(searcherRepos))
-			searcherRepos = nil
-		case No, False:
-			tr.LazyPrintf("index:no, bypassing zoekt (using searcher) for %d indexed repos", len(zoektRepos))
-			searcherRepos = append(searcherRepos, zoektRepos...)
-			zoektRepos = nil
-		default:
-			return nil, common, fmt.Errorf("invalid index:%q (valid values are: yes, only, no)", index)
-		}
+	zoektRepos, searcherRepos, missing, err := splitIndexedRepos(args, zoektRepos, searcherRepos)
+	if err != nil {
+		return nil, common, err
+	}
+	for _, repoRev := range missing {
+		common.missing = append(common.missing, repoRev.Repo)
+	}
+	if zoektCache != nil {
+		tr.LazyPrintf("%d indexed repos, %d unindexed repos, %d missing repos", len(zoektRepos), len(searcherRepos), len(missing))
 	}
 
 	var (
@@ -746,26 +1109,44 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		overLimitCanceled bool // canceled because we were over the limit
 	)
 
+	// countMatches assumes the caller holds mu.
+	countMatches := func(n int) {
+		common.resultCount += int32(n)
+		flattenedSize += n
+
+		// Stop searching once we have found enough matches. This does
+		// lead to potentially unstable result ordering, but is worth
+		// it for the performance benefit.
+		if flattenedSize > int(args.Pattern.FileMatchLimit) && !overLimitCanceled {
+			tr.LazyPrintf("cancel due to result size: %d > %d", flattenedSize, args.Pattern.FileMatchLimit)
+			overLimitCanceled = true
+			common.limitHit = true
+			cancel()
+		}
+	}
+
 	// addMatches assumes the caller holds mu.
 	addMatches := func(matches []*fileMatchResolver) {
 		if len(matches) > 0 {
-			common.resultCount += int32(len(matches))
-			sort.Slice(matches, func(i, j int) bool {
-				a, b := matches[i].uri, matches[j].uri
-				return a > b
-			})
 			unflattened = append(unflattened, matches)
-			flattenedSize += len(matches)
-
-			// Stop searching once we have found enough matches. This does
-			// lead to potentially unstable result ordering, but is worth
-			// it for the performance benefit.
-			if flattenedSize > int(args.Pattern.FileMatchLimit) {
-				tr.LazyPrintf("cancel due to result size: %d > %d", flattenedSize, args.Pattern.FileMatchLimit)
-				overLimitCanceled = true
-				common.limitHit = true
-				cancel()
+			countMatches(len(matches))
+		}
+	}
+
+	// newRepoMatches returns a func that adds a match found in a repository
+	// to the results (in a group of its own for that repository) as soon as
+	// it is found, so that we stop searching as soon as there are enough.
+	newRepoMatches := func() func(*fileMatchResolver) {
+		group := -1 // index in unflattened
+		return func(fm *fileMatchResolver) {
+			mu.Lock()
+			defer mu.Unlock()
+			if group < 0 {
+				group = len(unflattened)
+				unflattened = append(unflattened, nil)
 			}
+			unflattened[group] = append(unflattened[group], fm)
+			countMatches(1)
 		}
 	}
 
@@ -789,15 +1170,11 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		if len(repoRev.Revs) == 0 {
 			continue
 		}
-		if len(repoRev.Revs) >= 2 {
-			return nil, common, errMultipleRevsNotSupported
-		}
 
 		wg.Add(1)
 		go func(repoRev search.RepositoryRevisions) {
 			defer wg.Done()
-			rev := repoRev.RevSpecs()[0] // TODO(sqs): search multiple revs
-			matches, repoLimitHit, searchErr := searchFilesInRepo(ctx, repoRev.Repo, repoRev.GitserverRepo, rev, args.Pattern, fetchTimeout)
+			repoLimitHit, searchErr := searchFilesInRepoRevs(ctx, &repoRev, args.Pattern, fetchTimeout, newRepoMatches())
 			if searchErr != nil {
 				tr.LogFields(otlog.String("repo", string(repoRev.Repo.URI)), otlog.String("searchErr", searchErr.Error()), otlog.Bool("timeout", errcode.IsTimeout(searchErr)), otlog.Bool("temporary", errcode.IsTemporary(searchErr)))
 			}
@@ -824,7 +1201,6 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 				tr.LazyPrintf("cancel due to error: %v", err)
 				cancel()
 			}
-			addMatches(matches)
 		}(*repoRev)
 	}
 
@@ -832,7 +1208,7 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 	go func() {
 		// TODO limitHit, handleRepoSearchResult
 		defer wg.Done()
-		matches, limitHit, reposLimitHit, searchErr := zoektSearchHEAD(ctx, args.Pattern, zoektRepos, args.UseFullDeadline)
+		matches, limitHit, reposLimitHit, searchErr := zoektSearch(ctx, args.Pattern, zoektRepos, args.UseFullDeadline)
 		mu.Lock()
 		defer mu.Unlock()
 		if ctx.Err() == nil {
@@ -864,6 +1240,12 @@ func searchFilesInRepos(ctx context.Context, args *search.Args) (res []*fileMatc
 		return nil, common, err
 	}
 
+	for _, matches := range unflattened {
+		sort.Slice(matches, func(i, j int) bool {
+			a, b := matches[i].uri, matches[j].uri
+			return a > b
+		})
+	}
 	flattened := flattenFileMatches(unflattened, int(args.Pattern.FileMatchLimit))
 	return flattened, common, nil
 }
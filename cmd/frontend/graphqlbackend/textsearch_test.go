@@ -4,9 +4,11 @@ import (
 	"context"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/zoekt"
 	zoektquery "github.com/google/zoekt/query"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search"
@@ -15,6 +17,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/errcode"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	searcherprotocol "github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 )
@@ -64,6 +67,18 @@ func TestQueryToZoektQuery(t *testing.T) {
 			},
 			Query: `foo case:no f:\.go$ f:\.yaml$ -f:\bvendor\b`,
 		},
+		{
+			Name: "and not",
+			Pattern: &search.PatternInfo{
+				IsRegExp:               true,
+				IsCaseSensitive:        false,
+				Pattern:                "foo",
+				AndPatterns:            []string{"bar"},
+				NotPatterns:            []string{"baz"},
+				PathPatternsAreRegExps: true,
+			},
+			Query: "foo bar -baz case:no",
+		},
 		{
 			Name: "case",
 			Pattern: &search.PatternInfo{
@@ -205,6 +220,172 @@ func TestSearchFilesInRepos(t *testing.T) {
 	}
 }
 
+func TestMultilineMatchesToLineMatches(t *testing.T) {
+	mms := []searcherprotocol.MultilineMatch{{
+		Preview: "func a() {\n} else {",
+		Start:   searcherprotocol.Location{Line: 2, Column: 0},
+		End:     searcherprotocol.Location{Line: 3, Column: 1},
+	}, {
+		Preview: "} else {\n\tfoo()",
+		Start:   searcherprotocol.Location{Line: 3, Column: 2},
+		End:     searcherprotocol.Location{Line: 4, Column: 4},
+	}, {
+		Preview: "x\n",
+		Start:   searcherprotocol.Location{Line: 7, Column: 0},
+		End:     searcherprotocol.Location{Line: 8, Column: 0},
+	}}
+	want := []*lineMatch{
+		{JPreview: "func a() {", JLineNumber: 2, JOffsetAndLengths: [][2]int32{{0, 10}}},
+		{JPreview: "} else {", JLineNumber: 3, JOffsetAndLengths: [][2]int32{{0, 1}, {2, 6}}},
+		{JPreview: "\tfoo()", JLineNumber: 4, JOffsetAndLengths: [][2]int32{{0, 4}}},
+		{JPreview: "x", JLineNumber: 7, JOffsetAndLengths: [][2]int32{{0, 1}}},
+	}
+	got := multilineMatchesToLineMatches(mms)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestTextSearchStream(t *testing.T) {
+	const (
+		match1 = `{"Match":{"Path":"a.go","LineMatches":[{"Preview":"foo","LineNumber":1,"OffsetAndLengths":[[0,3]]}]}}` + "\n"
+		match2 = `{"Match":{"Path":"b.go","LineMatches":[{"Preview":"foo","LineNumber":2,"OffsetAndLengths":[[0,3]]}]}}` + "\n"
+	)
+	cases := []struct {
+		name         string
+		body         string
+		wantPaths    []string
+		wantLimitHit bool
+		wantErr      string
+	}{
+		{
+			name:      "done",
+			body:      match1 + match2 + `{"Done":{}}` + "\n",
+			wantPaths: []string{"a.go", "b.go"},
+		},
+		{
+			name:         "limit hit",
+			body:         match1 + `{"Done":{"LimitHit":true}}` + "\n",
+			wantPaths:    []string{"a.go"},
+			wantLimitHit: true,
+		},
+		{
+			name:      "deadline hit",
+			body:      match1 + `{"Done":{"DeadlineHit":true}}` + "\n",
+			wantPaths: []string{"a.go"},
+			wantErr:   context.DeadlineExceeded.Error(),
+		},
+		{
+			name:      "error",
+			body:      match1 + `{"Done":{"Error":"boom"}}` + "\n",
+			wantPaths: []string{"a.go"},
+			wantErr:   "boom",
+		},
+		{
+			name:      "interrupted",
+			body:      match1 + match2,
+			wantPaths: []string{"a.go", "b.go"},
+			wantErr:   "searcher response invalid: EOF",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var paths []string
+			limitHit, err := textSearchStream(context.Background(), strings.NewReader(c.body), func(m *fileMatchResolver) {
+				paths = append(paths, m.JPath)
+			})
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != c.wantErr {
+				t.Errorf("got error %q, want %q", gotErr, c.wantErr)
+			}
+			if limitHit != c.wantLimitHit {
+				t.Errorf("got limitHit %v, want %v", limitHit, c.wantLimitHit)
+			}
+			if !reflect.DeepEqual(paths, c.wantPaths) {
+				t.Errorf("got paths %v, want %v", paths, c.wantPaths)
+			}
+		})
+	}
+}
+
+func TestZoektBranch(t *testing.T) {
+	cases := map[string]struct {
+		branch string
+		ok     bool
+	}{
+		"foo/bar":                      {branch: "HEAD", ok: true},
+		"foo/bar@HEAD":                 {branch: "HEAD", ok: true},
+		"foo/bar@release/1":            {branch: "release/1", ok: true},
+		"foo/bar@refs/heads/release/1": {branch: "release/1", ok: true},
+		"foo/bar@a:b":                  {},
+		"foo/bar@*refs/heads/*":        {},
+	}
+	for spec, want := range cases {
+		branch, ok := zoektBranch(makeRepositoryRevisions(spec)[0])
+		if branch != want.branch || ok != want.ok {
+			t.Errorf("%s: got %q (ok=%v), want %q (ok=%v)", spec, branch, ok, want.branch, want.ok)
+		}
+	}
+}
+
+func TestZoektHasBranch(t *testing.T) {
+	names := []string{"HEAD", "release/1", "release/10", "dev"}
+	cases := map[string]bool{
+		"HEAD":       true,
+		"release/10": true,
+		"dev":        true,
+		"release/1":  false, // also matches release/10
+		"release":    false,
+		"missing":    false,
+	}
+	for branch, want := range cases {
+		if got := zoektHasBranch(names, branch); got != want {
+			t.Errorf("%s: got %v, want %v", branch, got, want)
+		}
+	}
+}
+
+func TestZoektFilesOnBranches(t *testing.T) {
+	repoMap := map[api.RepoURI]*search.RepositoryRevisions{}
+	for _, repoRev := range makeRepositoryRevisions("a", "b@release/1") {
+		repoMap[repoRev.Repo.URI] = repoRev
+	}
+	files := []zoekt.FileMatch{
+		{Repository: "a", FileName: "head", Branches: []string{"HEAD"}},
+		{Repository: "b", FileName: "exact", Branches: []string{"release/1"}},
+		{Repository: "b", FileName: "substring", Branches: []string{"release/10"}},
+		{Repository: "b", FileName: "ambiguous", Branches: []string{""}},
+		{Repository: "c", FileName: "unknown repo", Branches: []string{"HEAD"}},
+	}
+	var got []string
+	for _, file := range zoektFilesOnBranches(files, repoMap) {
+		got = append(got, file.FileName)
+	}
+	if want := []string{"head", "exact"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestZoektReposQuery(t *testing.T) {
+	q := zoektReposQuery(makeRepositoryRevisions("a", "b@release/1", "c@refs/heads/release/1", "d@HEAD"))
+	want := zoektquery.NewOr(
+		zoektquery.NewAnd(
+			&zoektquery.RepoSet{Set: map[string]bool{"a": true, "d": true}},
+			&zoektquery.Branch{Pattern: "HEAD"},
+		),
+		zoektquery.NewAnd(
+			&zoektquery.RepoSet{Set: map[string]bool{"b": true, "c": true}},
+			&zoektquery.Branch{Pattern: "release/1"},
+		),
+	)
+	if !queryEqual(q, want) {
+		t.Errorf("got %s, want %s", q, want)
+	}
+}
+
 func makeRepositoryRevisions(repos ...string) []*search.RepositoryRevisions {
 	r := make([]*search.RepositoryRevisions, len(repos))
 	for i, urispec := range repos {
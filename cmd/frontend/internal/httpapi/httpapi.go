@@ -39,6 +39,8 @@ func NewHandler(m *mux.Router) http.Handler {
 
 	m.Get(apirouter.Telemetry).Handler(trace.TraceRoute(telemetryHandler))
 
+	m.Get(apirouter.Webhooks).Handler(trace.TraceRoute(webhooksHandler))
+
 	m.Get(apirouter.XLang).Handler(trace.TraceRoute(handler(serveXLang)))
 
 	if envvar.SourcegraphDotComMode() {
@@ -77,6 +79,10 @@ func NewInternalHandler(m *mux.Router) http.Handler {
 	m.Get(apirouter.ReposInventoryUncached).Handler(trace.TraceRoute(handler(serveReposInventoryUncached)))
 	m.Get(apirouter.ReposList).Handler(trace.TraceRoute(handler(serveReposList)))
 	m.Get(apirouter.ReposListEnabled).Handler(trace.TraceRoute(handler(serveReposListEnabled)))
+	m.Get(apirouter.ReposListExternalService).Handler(trace.TraceRoute(handler(serveReposListExternalService)))
+	m.Get(apirouter.ReposListUpdateSchedules).Handler(trace.TraceRoute(handler(serveReposListUpdateSchedules)))
+	m.Get(apirouter.ReposSaveUpdateSchedules).Handler(trace.TraceRoute(handler(serveReposSaveUpdateSchedules)))
+	m.Get(apirouter.ReposSync).Handler(trace.TraceRoute(handler(serveReposSync)))
 	m.Get(apirouter.ReposGetByURI).Handler(trace.TraceRoute(handler(serveReposGetByURI)))
 	m.Get(apirouter.SettingsGetForSubject).Handler(trace.TraceRoute(handler(serveSettingsGetForSubject)))
 	m.Get(apirouter.SavedQueriesListAll).Handler(trace.TraceRoute(handler(serveSavedQueriesListAll)))
@@ -93,6 +99,7 @@ func NewInternalHandler(m *mux.Router) http.Handler {
 	m.Get(apirouter.DefsRefreshIndex).Handler(trace.TraceRoute(handler(serveDefsRefreshIndex)))
 	m.Get(apirouter.PkgsRefreshIndex).Handler(trace.TraceRoute(handler(servePkgsRefreshIndex)))
 	m.Get(apirouter.GitInfoRefs).Handler(trace.TraceRoute(handler(serveGitInfoRefs)))
+	m.Get(apirouter.GitIndexBranches).Handler(trace.TraceRoute(handler(serveGitIndexBranches)))
 	m.Get(apirouter.GitResolveRevision).Handler(trace.TraceRoute(handler(serveGitResolveRevision)))
 	m.Get(apirouter.GitTar).Handler(trace.TraceRoute(handler(serveGitTar)))
 	m.Get(apirouter.GitUploadPack).Handler(trace.TraceRoute(handler(serveGitUploadPack)))
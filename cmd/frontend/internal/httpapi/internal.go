@@ -185,6 +185,49 @@ func serveReposListEnabled(w http.ResponseWriter, r *http.Request) error {
 	return json.NewEncoder(w).Encode(names)
 }
 
+func serveReposListExternalService(w http.ResponseWriter, r *http.Request) error {
+	var req api.ReposListByExternalServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	repos, err := db.Repos.ListByExternalService(r.Context(), req.ServiceType, req.ServiceID)
+	if err != nil {
+		return errors.Wrap(err, "Repos.ListByExternalService failed")
+	}
+	return json.NewEncoder(w).Encode(repos)
+}
+
+func serveReposSync(w http.ResponseWriter, r *http.Request) error {
+	var req api.ReposSyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	resp, err := db.Repos.Sync(r.Context(), req)
+	if err != nil {
+		return errors.Wrap(err, "Repos.Sync failed")
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func serveReposListUpdateSchedules(w http.ResponseWriter, r *http.Request) error {
+	schedules, err := db.RepoUpdateSchedules.List(r.Context())
+	if err != nil {
+		return errors.Wrap(err, "RepoUpdateSchedules.List failed")
+	}
+	return json.NewEncoder(w).Encode(schedules)
+}
+
+func serveReposSaveUpdateSchedules(w http.ResponseWriter, r *http.Request) error {
+	var schedules []*api.RepoUpdateSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedules); err != nil {
+		return err
+	}
+	if err := db.RepoUpdateSchedules.Upsert(r.Context(), schedules); err != nil {
+		return errors.Wrap(err, "RepoUpdateSchedules.Upsert failed")
+	}
+	return nil
+}
+
 func serveSavedQueriesListAll(w http.ResponseWriter, r *http.Request) error {
 	// List settings for all users, orgs, etc.
 	settings, err := db.Settings.ListAll(r.Context())
@@ -425,6 +468,36 @@ func serveGitResolveRevision(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+func serveGitIndexBranches(w http.ResponseWriter, r *http.Request) error {
+	// used by zoekt-sourcegraph-indexserver to find the branches to index
+	uri := api.RepoURI(mux.Vars(r)["RepoURI"])
+	repo, err := backend.Repos.GetByURI(r.Context(), uri)
+	if err != nil {
+		return err
+	}
+
+	// Do not trigger a repo-updater lookup since this is a batch job.
+	head, err := git.ResolveRevision(r.Context(), gitserver.Repo{Name: repo.URI}, nil, "HEAD", nil)
+	if err != nil {
+		return err
+	}
+	branches := []api.IndexBranch{{Name: "HEAD", Commit: head}}
+
+	refs, err := backend.SearchIndex.Branches(r.Context(), repo)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		branches = append(branches, api.IndexBranch{
+			Name:   strings.TrimPrefix(ref.Name, "refs/heads/"),
+			Commit: ref.CommitID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(branches)
+}
+
 func serveGitTar(w http.ResponseWriter, r *http.Request) error {
 	// used by zoekt-sourcegraph-mirror
 	vars := mux.Vars(r)
@@ -15,35 +15,41 @@ const (
 	RepoShield  = "repo.shield"
 	RepoRefresh = "repo.refresh"
 	Telemetry   = "telemetry"
-
-	SavedQueriesListAll    = "internal.saved-queries.list-all"
-	SavedQueriesGetInfo    = "internal.saved-queries.get-info"
-	SavedQueriesSetInfo    = "internal.saved-queries.set-info"
-	SavedQueriesDeleteInfo = "internal.saved-queries.delete-info"
-	SettingsGetForSubject  = "internal.settings.get-for-subject"
-	OrgsListUsers          = "internal.orgs.list-users"
-	OrgsGetByName          = "internal.orgs.get-by-name"
-	UsersGetByUsername     = "internal.users.get-by-username"
-	UserEmailsGetEmail     = "internal.user-emails.get-email"
-	AppURL                 = "internal.app-url"
-	CanSendEmail           = "internal.can-send-email"
-	SendEmail              = "internal.send-email"
-	Extension              = "internal.extension"
-	DefsRefreshIndex       = "internal.defs.refresh-index"
-	PkgsRefreshIndex       = "internal.pkgs.refresh-index"
-	GitInfoRefs            = "internal.git.info-refs"
-	GitResolveRevision     = "internal.git.resolve-revision"
-	GitTar                 = "internal.git.tar"
-	GitUploadPack          = "internal.git.upload-pack"
-	PhabricatorRepoCreate  = "internal.phabricator.repo.create"
-	ReposCreateIfNotExists = "internal.repos.create-if-not-exists"
-	ReposGetByURI          = "internal.repos.get-by-uri"
-	ReposInventoryUncached = "internal.repos.inventory-uncached"
-	ReposInventory         = "internal.repos.inventory"
-	ReposList              = "internal.repos.list"
-	ReposListEnabled       = "internal.repos.list-enabled"
-	ReposUpdateIndex       = "internal.repos.update-index"
-	ReposUpdateMetadata    = "internal.repos.update-metadata"
+	Webhooks    = "webhooks"
+
+	SavedQueriesListAll      = "internal.saved-queries.list-all"
+	SavedQueriesGetInfo      = "internal.saved-queries.get-info"
+	SavedQueriesSetInfo      = "internal.saved-queries.set-info"
+	SavedQueriesDeleteInfo   = "internal.saved-queries.delete-info"
+	SettingsGetForSubject    = "internal.settings.get-for-subject"
+	OrgsListUsers            = "internal.orgs.list-users"
+	OrgsGetByName            = "internal.orgs.get-by-name"
+	UsersGetByUsername       = "internal.users.get-by-username"
+	UserEmailsGetEmail       = "internal.user-emails.get-email"
+	AppURL                   = "internal.app-url"
+	CanSendEmail             = "internal.can-send-email"
+	SendEmail                = "internal.send-email"
+	Extension                = "internal.extension"
+	DefsRefreshIndex         = "internal.defs.refresh-index"
+	PkgsRefreshIndex         = "internal.pkgs.refresh-index"
+	GitIndexBranches         = "internal.git.index-branches"
+	GitInfoRefs              = "internal.git.info-refs"
+	GitResolveRevision       = "internal.git.resolve-revision"
+	GitTar                   = "internal.git.tar"
+	GitUploadPack            = "internal.git.upload-pack"
+	PhabricatorRepoCreate    = "internal.phabricator.repo.create"
+	ReposCreateIfNotExists   = "internal.repos.create-if-not-exists"
+	ReposGetByURI            = "internal.repos.get-by-uri"
+	ReposInventoryUncached   = "internal.repos.inventory-uncached"
+	ReposInventory           = "internal.repos.inventory"
+	ReposList                = "internal.repos.list"
+	ReposListEnabled         = "internal.repos.list-enabled"
+	ReposListExternalService = "internal.repos.list-external-service"
+	ReposListUpdateSchedules = "internal.repos.list-update-schedules"
+	ReposSaveUpdateSchedules = "internal.repos.save-update-schedules"
+	ReposSync                = "internal.repos.sync"
+	ReposUpdateIndex         = "internal.repos.update-index"
+	ReposUpdateMetadata      = "internal.repos.update-metadata"
 )
 
 // New creates a new API router with route URL pattern definitions but
@@ -59,6 +65,7 @@ func New(base *mux.Router) *mux.Router {
 	addRegistryRoute(base)
 	addGraphQLRoute(base)
 	addTelemetryRoute(base)
+	base.Path("/webhooks/{CodeHost:github|gitlab|bitbucket-server}").Methods("POST").Name(Webhooks)
 
 	// repo contains routes that are NOT specific to a revision. In these routes, the URL may not contain a revspec after the repo (that is, no "github.com/foo/bar@myrevspec").
 	repoPath := `/repos/` + routevar.Repo
@@ -95,6 +102,7 @@ func NewInternal(base *mux.Router) *mux.Router {
 	base.Path("/extension").Methods("POST").Name(Extension)
 	base.Path("/defs/refresh-index").Methods("POST").Name(DefsRefreshIndex)
 	base.Path("/pkgs/refresh-index").Methods("POST").Name(PkgsRefreshIndex)
+	base.Path("/git/{RepoURI:.*}/index-branches").Methods("GET").Name(GitIndexBranches)
 	base.Path("/git/{RepoURI:.*}/info/refs").Methods("GET").Name(GitInfoRefs)
 	base.Path("/git/{RepoURI:.*}/resolve-revision/{Spec}").Methods("GET").Name(GitResolveRevision)
 	base.Path("/git/{RepoURI:.*}/tar/{Commit}").Methods("GET").Name(GitTar)
@@ -105,6 +113,10 @@ func NewInternal(base *mux.Router) *mux.Router {
 	base.Path("/repos/inventory").Methods("POST").Name(ReposInventory)
 	base.Path("/repos/list").Methods("POST").Name(ReposList)
 	base.Path("/repos/list-enabled").Methods("POST").Name(ReposListEnabled)
+	base.Path("/repos/list-external-service").Methods("POST").Name(ReposListExternalService)
+	base.Path("/repos/list-update-schedules").Methods("POST").Name(ReposListUpdateSchedules)
+	base.Path("/repos/save-update-schedules").Methods("POST").Name(ReposSaveUpdateSchedules)
+	base.Path("/repos/sync").Methods("POST").Name(ReposSync)
 	base.Path("/repos/update-index").Methods("POST").Name(ReposUpdateIndex)
 	base.Path("/repos/update-metadata").Methods("POST").Name(ReposUpdateMetadata)
 	base.Path("/repos/{RepoURI:.*}").Methods("POST").Name(ReposGetByURI)
@@ -0,0 +1,38 @@
+package httpapi
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/gorilla/mux"
+
+	"github.com/sourcegraph/sourcegraph/pkg/env"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+)
+
+// webhooksHandler proxies push event webhooks from code hosts to
+// repo-updater, which authenticates them with the webhook secret of the code
+// host connection and updates the repository that was pushed to.
+//
+// 🚨 SECURITY: This handler is served to anonymous users, so repo-updater
+// MUST authenticate the requests.
+var webhooksHandler = &httputil.ReverseProxy{
+	Director: func(req *http.Request) {
+		u, err := url.Parse(repoupdater.DefaultClient.URL)
+		if err != nil {
+			// The request fails since it has no host.
+			log.Printf("webhooks proxy: invalid repo-updater URL: %s", err)
+			return
+		}
+		req.URL.Scheme = u.Scheme
+		req.URL.Host = u.Host
+		req.URL.Path = "/webhooks/" + mux.Vars(req)["CodeHost"]
+		req.URL.RawQuery = ""
+		// Don't forward the user's credentials, if any.
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+	},
+	ErrorLog: log.New(env.DebugOut, "webhooks proxy: ", log.LstdFlags),
+}
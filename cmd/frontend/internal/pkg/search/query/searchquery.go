@@ -23,6 +23,10 @@ const (
 	FieldRef   = "ref"
 	FieldHints = "hints"
 
+	// For symbol search only:
+	FieldKind   = "kind"   // Only return symbols of the kind (e.g. kind:class)
+	FieldParent = "parent" // Only return symbols whose parent (e.g. their class) has the name
+
 	// For diff and commit search only:
 	FieldBefore    = "before"
 	FieldAfter     = "after"
@@ -31,10 +35,15 @@ const (
 	FieldMessage   = "message"
 
 	// Temporary experimental fields:
-	FieldIndex   = "index"
-	FieldCount   = "count" // Searches that specify `count:` will fetch at least that number of results, or the full result set
-	FieldMax     = "max"   // Deprecated alias for count
-	FieldTimeout = "timeout"
+	FieldIndex     = "index"
+	FieldCount     = "count" // Searches that specify `count:` will fetch at least that number of results, or the full result set
+	FieldMax       = "max"   // Deprecated alias for count
+	FieldTimeout   = "timeout"
+	FieldMultiline = "multiline" // Searches that specify `multiline:yes` allow content matches to span lines
+	FieldContext   = "context"   // Searches that specify `context:N` return N lines of context around each line match
+
+	FieldRepoHasFile        = "repohasfile"        // Only search repos that contain a file matching the regexp
+	FieldRepoHasCommitAfter = "repohascommitafter" // Only search repos with a commit after the date
 )
 
 var (
@@ -56,6 +65,10 @@ var (
 			FieldRef:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
 			FieldHints: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
 
+			// kind: and parent: only apply to symbols.
+			FieldKind:   {Literal: types.StringType, Quoted: types.StringType, RequiresField: FieldType, RequiresValue: "symbol"},
+			FieldParent: {Literal: types.StringType, Quoted: types.StringType, Singular: true, RequiresField: FieldType, RequiresValue: "symbol"},
+
 			FieldBefore:    stringFieldType,
 			FieldAfter:     stringFieldType,
 			FieldAuthor:    regexpNegatableFieldType,
@@ -63,10 +76,15 @@ var (
 			FieldMessage:   regexpNegatableFieldType,
 
 			// Experimental fields:
-			FieldIndex:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldCount:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldMax:     {Literal: types.StringType, Quoted: types.StringType, Singular: true},
-			FieldTimeout: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldIndex:     {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldCount:     {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldMax:       {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldTimeout:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+			FieldMultiline: {Literal: types.BoolType, Quoted: types.BoolType, Singular: true},
+			FieldContext:   {Literal: types.StringType, Quoted: types.StringType, Singular: true},
+
+			FieldRepoHasFile:        regexpNegatableFieldType,
+			FieldRepoHasCommitAfter: {Literal: types.StringType, Quoted: types.StringType, Singular: true},
 		},
 		FieldAliases: map[string]string{
 			"r":        FieldRepo,
@@ -107,6 +125,21 @@ func parseAndCheck(conf *types.Config, input string) (*Query, error) {
 	return &Query{conf: conf, Query: checkedQuery}, nil
 }
 
+// Branches returns the branches of a boolean query (one that uses the and, or
+// and not operators) in disjunctive normal form, or nil if q is not a boolean
+// query. The results of q are the union of the results of its branches. See
+// types.Query.Branches.
+func (q *Query) Branches() []*Query {
+	if len(q.Query.Branches) == 0 {
+		return nil
+	}
+	branches := make([]*Query, len(q.Query.Branches))
+	for i, b := range q.Query.Branches {
+		branches[i] = &Query{conf: q.conf, Query: b}
+	}
+	return branches
+}
+
 // BoolValue returns the last boolean value (yes/no) for the field. For example, if the query is
 // "foo:yes foo:no foo:yes", then the last boolean value for the "foo" field is true ("yes"). The
 // default boolean value is false.
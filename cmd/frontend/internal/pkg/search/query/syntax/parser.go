@@ -1,6 +1,9 @@
 package syntax
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // ParseError describes an error in query parsing.
 type ParseError struct {
@@ -32,8 +35,24 @@ type context struct {
 //   expr      := fieldExpr | lit | quoted | pattern
 //   fieldExpr := lit ":" value
 //   value     := lit | quoted
+//
+// If the query uses any of the boolean operators "AND", "OR" and "NOT", it is
+// parsed as a boolean query instead (see Query.Tree):
+//
+//   orExpr     := andExpr ("OR" andExpr)*
+//   andExpr    := unaryExpr (["AND"] unaryExpr)*
+//   unaryExpr  := "NOT" notOperand | "(" orExpr ")" | exprSign (sep exprSign)*
+//   notOperand := "NOT" notOperand | "(" orExpr ")" | exprSign
+//
+// So "NOT" applies only to the term or group that follows it, and "AND"
+// binds more tightly than "OR". Operators must be written in upper case, so
+// that ordinary words in a query (as in "page not found") are still matched
+// literally.
 func Parse(input string) (*Query, error) {
 	tokens := Scan(input)
+	if hasOperator(tokens) {
+		return parseBoolean(input)
+	}
 	p := parser{tokens: tokens}
 	ctx := context{field: ""}
 	exprs, err := p.parseExprList(ctx)
@@ -43,6 +62,67 @@ func Parse(input string) (*Query, error) {
 	return &Query{Expr: exprs, Input: input}, nil
 }
 
+// parseBoolean parses a query that uses boolean operators.
+func parseBoolean(input string) (*Query, error) {
+	p := parser{tokens: scan(input, true)}
+	tree, err := p.parseOr(context{field: ""})
+	if err != nil {
+		return nil, err
+	}
+	p.skipSep()
+	if tok := p.next(); tok.Type != TokenEOF {
+		return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want operator or EOF", tok.Type)}
+	}
+	return &Query{Expr: tree.Exprs(), Tree: tree, Input: input}, nil
+}
+
+// operator returns the boolean operator (in lower case) that tokens[i] is,
+// or "" if it is not an operator. Only the upper-case words AND, OR and NOT
+// are operators. A field name (as in "OR:foo") or value (as in "file:OR")
+// is not an operator.
+func operator(tokens []Token, i int) string {
+	tok := tokens[i]
+	if tok.Type != TokenLiteral {
+		return ""
+	}
+	if i > 0 && (tokens[i-1].Type == TokenColon || tokens[i-1].Type == TokenMinus) {
+		return ""
+	}
+	if i+1 < len(tokens) && tokens[i+1].Type == TokenColon {
+		return ""
+	}
+	switch tok.Value {
+	case "AND", "OR", "NOT":
+		return strings.ToLower(tok.Value)
+	}
+	return ""
+}
+
+// hasOperator reports whether tokens contains a boolean operator.
+func hasOperator(tokens []Token) bool {
+	for i := range tokens {
+		if operator(tokens, i) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// peekOperator returns the boolean operator that the next token is, or "".
+func (p *parser) peekOperator() string {
+	if p.pos < len(p.tokens) {
+		return operator(p.tokens, p.pos)
+	}
+	return ""
+}
+
+// skipSep consumes any separators.
+func (p *parser) skipSep() {
+	for p.peek().Type == TokenSep {
+		p.next()
+	}
+}
+
 // peek returns the next token without consuming it. Peeking beyond the end of
 // the token stream will return TokenEOF.
 func (p *parser) peek() Token {
@@ -129,15 +209,23 @@ func (p *parser) parseExpr(ctx context) (*Expr, error) {
 			valueTok := p.next()
 			switch valueTok.Type {
 			case TokenLiteral, TokenQuoted:
-				if tok3 := p.next(); tok3.Type != TokenSep && tok3.Type != TokenEOF {
+				if tok3 := p.next(); tok3.Type == TokenRParen {
+					p.backup()
+				} else if tok3.Type != TokenSep && tok3.Type != TokenEOF {
 					return nil, &ParseError{Pos: tok3.Pos, Msg: fmt.Sprintf("got %s, want separator or EOF", tok3.Type)}
 				}
 				return &Expr{Pos: tok.Pos, Field: tok.Value, Value: valueTok.Value, ValueType: valueTok.Type}, nil
+			case TokenRParen:
+				p.backup()
+				return &Expr{Pos: tok.Pos, Field: tok.Value, Value: "", ValueType: TokenLiteral}, nil
 			case TokenSep, TokenEOF:
 				return &Expr{Pos: tok.Pos, Field: tok.Value, Value: "", ValueType: TokenLiteral}, nil
 			default:
 				return nil, &ParseError{Pos: valueTok.Pos, Msg: fmt.Sprintf("got %s, want value", valueTok.Type)}
 			}
+		case TokenRParen:
+			p.backup()
+			return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
 		case TokenSep, TokenEOF:
 			return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
 		default:
@@ -146,6 +234,9 @@ func (p *parser) parseExpr(ctx context) (*Expr, error) {
 	case TokenQuoted, TokenPattern:
 		tok2 := p.next()
 		switch tok2.Type {
+		case TokenRParen:
+			p.backup()
+			return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
 		case TokenSep, TokenEOF:
 			return &Expr{Pos: tok.Pos, Value: tok.Value, ValueType: tok.Type}, nil
 		default:
@@ -155,3 +246,119 @@ func (p *parser) parseExpr(ctx context) (*Expr, error) {
 
 	return nil, &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want expr", tok.Type)}
 }
+
+// orExpr := andExpr ("OR" andExpr)*
+func (p *parser) parseOr(ctx context) (*Node, error) {
+	var operands []*Node
+	for {
+		operand, err := p.parseAnd(ctx)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		p.skipSep()
+		if p.peekOperator() != "or" {
+			break
+		}
+		p.next()
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return &Node{Pos: operands[0].Pos, Op: OpOr, Operands: operands}, nil
+}
+
+// andExpr := unaryExpr (["AND"] unaryExpr)*
+func (p *parser) parseAnd(ctx context) (*Node, error) {
+	var operands []*Node
+	for {
+		operand, err := p.parseUnary(ctx)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		p.skipSep()
+		if tok := p.peek(); tok.Type == TokenEOF || tok.Type == TokenRParen || p.peekOperator() == "or" {
+			break
+		}
+		if p.peekOperator() == "and" {
+			p.next()
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return &Node{Pos: operands[0].Pos, Op: OpAnd, Operands: operands}, nil
+}
+
+// unaryExpr := "NOT" notOperand | "(" orExpr ")" | exprSign (sep exprSign)*
+func (p *parser) parseUnary(ctx context) (*Node, error) {
+	p.skipSep()
+	tok := p.peek()
+	if p.peekOperator() == "not" || tok.Type == TokenLParen {
+		return p.parseNotOperand(ctx)
+	}
+	if err := p.checkOperand(); err != nil {
+		return nil, err
+	}
+
+	leaf := &Node{Pos: tok.Pos}
+	for {
+		expr, err := p.parseExprSign(ctx)
+		if err != nil {
+			return nil, err
+		}
+		leaf.Expr = append(leaf.Expr, expr)
+		p.skipSep()
+		if tok := p.peek(); tok.Type == TokenEOF || tok.Type == TokenLParen || tok.Type == TokenRParen || p.peekOperator() != "" {
+			break
+		}
+	}
+	return leaf, nil
+}
+
+// notOperand := "NOT" notOperand | "(" orExpr ")" | exprSign
+func (p *parser) parseNotOperand(ctx context) (*Node, error) {
+	p.skipSep()
+	tok := p.peek()
+	switch {
+	case p.peekOperator() == "not":
+		p.next()
+		operand, err := p.parseNotOperand(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Pos: tok.Pos, Op: OpNot, Operands: []*Node{operand}}, nil
+	case tok.Type == TokenLParen:
+		p.next()
+		group, err := p.parseOr(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSep()
+		if end := p.next(); end.Type != TokenRParen {
+			return nil, &ParseError{Pos: tok.Pos, Msg: "unclosed parenthesis"}
+		}
+		return group, nil
+	}
+	if err := p.checkOperand(); err != nil {
+		return nil, err
+	}
+	expr, err := p.parseExprSign(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{Pos: expr.Pos, Expr: []*Expr{expr}}, nil
+}
+
+// checkOperand returns an error if the next token can't start an operand.
+func (p *parser) checkOperand() error {
+	tok := p.peek()
+	if p.peekOperator() != "" {
+		return &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got operator %q, want expr", tok.Value)}
+	}
+	if tok.Type == TokenEOF || tok.Type == TokenRParen {
+		return &ParseError{Pos: tok.Pos, Msg: fmt.Sprintf("got %s, want expr", tok.Type)}
+	}
+	return nil
+}
@@ -111,3 +111,87 @@ func TestParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_boolean(t *testing.T) {
+	tests := map[string]struct {
+		wantTree string // the Node.String of the tree, or "" for no tree
+		wantErr  string
+	}{
+		"a b":                                  {},
+		"a(b|c) d":                             {},
+		"(a|b)":                                {},
+		"a or b":                               {},
+		"not found":                            {},
+		"Page Not Found":                       {},
+		"if a and b":                           {},
+		"a Or b":                               {},
+		"a OR b":                               {wantTree: "a OR b"},
+		"a AND b":                              {wantTree: "a AND b"},
+		"a b OR c":                             {wantTree: "a b OR c"},
+		"a AND b OR c AND d":                   {wantTree: "a AND b OR c AND d"},
+		"a AND (b OR c)":                       {wantTree: "a AND (b OR c)"},
+		"(a OR b) c":                           {wantTree: "(a OR b) AND c"},
+		"NOT a":                                {wantTree: "NOT a"},
+		"NOT a b":                              {wantTree: "NOT a AND b"},
+		"NOT NOT a":                            {wantTree: "NOT NOT a"},
+		"NOT (a b)":                            {wantTree: "NOT (a b)"},
+		"NOT (a OR b)":                         {wantTree: "NOT (a OR b)"},
+		"((a))":                                {},
+		"(a(b|c) OR d)":                        {wantTree: "a(b|c) OR d"},
+		`(a\) OR b)`:                           {wantTree: `a\) OR b`},
+		`("a b" OR /c d/)`:                     {wantTree: `"a b" OR /c d/`},
+		"(file:x OR -repo:y)":                  {wantTree: "file:x OR -repo:y"},
+		"(file: OR b)":                         {wantTree: "file: OR b"},
+		"OR:a AND:b":                           {},
+		"file:OR a":                            {},
+		"-OR a":                                {},
+		"(foo OR bar) AND NOT baz file:\\.go$": {wantTree: "(foo OR bar) AND NOT baz AND file:\\.go$"},
+		"OR":                                   {wantErr: `parse error at character 0: got operator "OR", want expr`},
+		"a OR":                                 {wantErr: "parse error at character 4: got TokenEOF, want expr"},
+		"a AND AND b":                          {wantErr: `parse error at character 6: got operator "AND", want expr`},
+		"NOT":                                  {wantErr: "parse error at character 3: got TokenEOF, want expr"},
+		"(a OR b":                              {wantErr: "parse error at character 0: unclosed parenthesis"},
+		"a OR b)":                              {wantErr: "parse error at character 6: got TokenRParen, want operator or EOF"},
+		"() OR a":                              {wantErr: "parse error at character 1: got TokenRParen, want expr"},
+		`"a"(b) OR c`:                          {wantErr: "parse error at character 3: got TokenLParen, want separator or EOF"},
+		"-(a) OR b":                            {wantErr: "parse error at character 1: got TokenLParen, want expr"},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			query, err := Parse(input)
+			if err != nil {
+				if test.wantErr == "" {
+					t.Fatal(err)
+				}
+				if err.Error() != test.wantErr {
+					t.Fatalf("got err %q, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if test.wantErr != "" {
+				t.Fatalf("got err == nil, want %q", test.wantErr)
+			}
+			if query.Tree == nil {
+				if test.wantTree != "" {
+					t.Fatalf("got no tree, want %q", test.wantTree)
+				}
+				return
+			}
+			if got := query.Tree.String(); got != test.wantTree {
+				t.Errorf("got tree %q, want %q", got, test.wantTree)
+			}
+			if got, want := len(query.Expr), len(query.Tree.Exprs()); got != want {
+				t.Errorf("got %d exprs, want %d", got, want)
+			}
+
+			// The tree's string should parse to the same tree.
+			query2, err := Parse(test.wantTree)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := query2.Tree.String(); got != test.wantTree {
+				t.Errorf("reparsed tree: got %q, want %q", got, test.wantTree)
+			}
+		})
+	}
+}
@@ -8,7 +8,70 @@ import (
 // A Query contains the parse tree of a query.
 type Query struct {
 	Input string  // the original input query string
-	Expr  []*Expr // expressions in this query
+	Expr  []*Expr // expressions in this query (for a boolean query, all of the expressions in Tree)
+	Tree  *Node   // the boolean expression tree, or nil if the query does not use boolean operators
+}
+
+// An Operator is a boolean operator in a query.
+type Operator int
+
+// All Operator values.
+const (
+	OpAnd Operator = iota + 1
+	OpOr
+	OpNot
+)
+
+// A Node is a node in the boolean expression tree of a query. It is either an
+// operator node, or a leaf node with a list of expressions that are
+// interpreted as they would be in a query without boolean operators.
+type Node struct {
+	Pos      int      // the starting character position of the node
+	Op       Operator // the operator, or 0 for a leaf node
+	Operands []*Node  // the operands of an operator node
+	Expr     []*Expr  // the expressions of a leaf node
+}
+
+// Exprs returns all of the expressions in the leaf nodes under n.
+func (n *Node) Exprs() []*Expr {
+	if n.Op == 0 {
+		return n.Expr
+	}
+	var exprs []*Expr
+	for _, operand := range n.Operands {
+		exprs = append(exprs, operand.Exprs()...)
+	}
+	return exprs
+}
+
+func (n *Node) String() string {
+	// operand returns the string for an operand of n, in parentheses if
+	// it binds less tightly than n.
+	operand := func(o *Node) string {
+		s := o.String()
+		switch {
+		case o.Op == OpOr && n.Op != OpOr,
+			o.Op == OpAnd && n.Op == OpNot,
+			o.Op == 0 && len(o.Expr) > 1 && n.Op == OpNot:
+			return "(" + s + ")"
+		}
+		return s
+	}
+	switch n.Op {
+	case OpNot:
+		return "NOT " + operand(n.Operands[0])
+	case OpAnd, OpOr:
+		sep := " AND "
+		if n.Op == OpOr {
+			sep = " OR "
+		}
+		s := make([]string, len(n.Operands))
+		for i, o := range n.Operands {
+			s[i] = operand(o)
+		}
+		return strings.Join(s, sep)
+	}
+	return ExprString(n.Expr)
 }
 
 // An Expr describes an expression in a query.
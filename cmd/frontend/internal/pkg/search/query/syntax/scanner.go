@@ -21,7 +21,9 @@ const (
 	TokenPattern
 	TokenColon
 	TokenMinus
-	TokenSep // separator (like a semicolon)
+	TokenSep    // separator (like a semicolon)
+	TokenLParen // "(" that begins a group (only in boolean queries)
+	TokenRParen // ")" that ends a group (only in boolean queries)
 )
 
 var singleCharTokens = map[rune]TokenType{
@@ -38,7 +40,15 @@ type Token struct {
 
 // Scan scans the query and returns a list of tokens.
 func Scan(input string) []Token {
-	s := &scanner{input: input}
+	return scan(input, false)
+}
+
+// scan scans the query and returns a list of tokens. If parens is true,
+// parentheses at the start and end of terms are scanned as TokenLParen and
+// TokenRParen, for grouping in boolean queries. Parentheses inside a term
+// (such as in the regexp "a(b|c)") are part of the term as usual.
+func scan(input string, parens bool) []Token {
+	s := &scanner{input: input, parens: parens}
 
 	for state := scanDefault; state != nil; {
 		state = state(s)
@@ -54,6 +64,7 @@ type scanner struct {
 	pos     int
 	prevPos int
 	start   int
+	parens  bool // whether to scan grouping parentheses (see scan)
 }
 
 func (s *scanner) next() rune {
@@ -111,6 +122,15 @@ func scanDefault(s *scanner) stateFn {
 			s.emit(typ)
 			return scanDefault
 		}
+		if s.parens && (r == '(' || r == ')') {
+			s.next()
+			if r == '(' {
+				s.emit(TokenLParen)
+			} else {
+				s.emit(TokenRParen)
+			}
+			return scanDefault
+		}
 
 		if r == '"' || r == '\'' {
 			return scanQuoted
@@ -146,6 +166,9 @@ func scanText(s *scanner) stateFn {
 			return scanValue
 		}
 		if !strings.ContainsRune(preColonChars, r) {
+			// Let scanLiteral see r, since it tracks escapes and
+			// parentheses.
+			s.backup()
 			return scanLiteral
 		}
 	}
@@ -159,7 +182,7 @@ func scanValue(s *scanner) stateFn {
 		return scanDefault
 	}
 	r := s.peek()
-	if unicode.IsSpace(r) {
+	if unicode.IsSpace(r) || (s.parens && r == ')') {
 		return scanDefault
 	}
 	if r == '"' || r == '\'' {
@@ -169,6 +192,9 @@ func scanValue(s *scanner) stateFn {
 }
 
 func scanLiteral(s *scanner) stateFn {
+	// depth and escaped track the parentheses in the literal, so that we
+	// know whether a ")" ends the enclosing group (only if s.parens).
+	depth, escaped := 0, false
 	for {
 		if s.eof() {
 			break
@@ -178,9 +204,23 @@ func scanLiteral(s *scanner) stateFn {
 			s.backup()
 			break
 		}
+		if s.parens && !escaped {
+			if r == '(' {
+				depth++
+			} else if r == ')' {
+				if depth == 0 {
+					s.backup()
+					break
+				}
+				depth--
+			}
+		}
+		escaped = !escaped && r == '\\'
 	}
 
-	s.emit(TokenLiteral)
+	if s.pos > s.start {
+		s.emit(TokenLiteral)
+	}
 	return scanDefault
 }
 
@@ -89,6 +89,30 @@ func TestScanner(t *testing.T) {
 	}
 }
 
+func TestScanner_parens(t *testing.T) {
+	tests := map[string][]string{
+		"(a)":     {"(", "a", ")"},
+		"((a b))": {"(", "(", "a", " ", "b", ")", ")"},
+		"(a(b))":  {"(", "a(b)", ")"},
+		`(a\))`:   {"(", `a\)`, ")"},
+		`(a\\))`:  {"(", `a\\`, ")", ")"},
+		`("a)")`:  {"(", `"a)"`, ")"},
+		"(/a)/)":  {"(", "a)", ")"},
+		"(a:b)":   {"(", "a", ":", "b", ")"},
+		"(a:)":    {"(", "a", ":", ")"},
+		"(a:(b))": {"(", "a", ":", "(b)", ")"},
+		"(abc):d": {"(", "abc", ")", ":", "d"},
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			tokens := scan(input, true)
+			if got := tokenValues(tokens[:len(tokens)-1]); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
 func tokenTypes(tokens []Token) []TokenType {
 	types := make([]TokenType, len(tokens))
 	for i, t := range tokens {
@@ -0,0 +1,185 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
+)
+
+// maxBranches is the maximum number of branches of a boolean query in
+// disjunctive normal form. Each branch is a separate search, so this bounds
+// the cost of a query like "(a OR b) AND (c OR d) AND ...".
+const maxBranches = 16
+
+// A literal is an operand of a conjunction (a branch) of a boolean query in
+// disjunctive normal form. It is either one field expression, or a pattern:
+// the default-field terms of a leaf node, which are combined into a single
+// pattern as they would be in a query without boolean operators.
+type literal struct {
+	not   bool
+	exprs []*syntax.Expr
+}
+
+// leafLiterals returns the literals of a leaf node. The leaf's pattern (if
+// any) is first, so it is the main pattern of the branches it ends up in.
+// Negated terms are not part of the pattern: "a -b" means "a and not b".
+func leafLiterals(exprs []*syntax.Expr) []literal {
+	var pattern []*syntax.Expr
+	var lits []literal
+	for _, e := range exprs {
+		switch {
+		case e.Field == "" && !e.Not:
+			pattern = append(pattern, e)
+		case e.Field == "":
+			e2 := *e
+			e2.Not = false
+			lits = append(lits, literal{not: true, exprs: []*syntax.Expr{&e2}})
+		default:
+			lits = append(lits, literal{not: e.Not, exprs: []*syntax.Expr{e}})
+		}
+	}
+	if len(pattern) > 0 {
+		lits = append([]literal{{exprs: pattern}}, lits...)
+	}
+	return lits
+}
+
+// dnf returns n (or if negate, not n) in disjunctive normal form: a list of
+// branches, each of which is a list of literals that must all match.
+func dnf(n *syntax.Node, negate bool) ([][]literal, error) {
+	switch n.Op {
+	case 0:
+		lits := leafLiterals(n.Expr)
+		if !negate {
+			return [][]literal{lits}, nil
+		}
+		// not (a and b) == (not a) or (not b)
+		branches := make([][]literal, len(lits))
+		for i, lit := range lits {
+			lit.not = !lit.not
+			branches[i] = []literal{lit}
+		}
+		return branches, nil
+	case syntax.OpNot:
+		return dnf(n.Operands[0], !negate)
+	}
+
+	// not (a or b) == (not a) and (not b), and vice versa.
+	isAnd := (n.Op == syntax.OpAnd) != negate
+	var branches [][]literal
+	for i, operand := range n.Operands {
+		operandBranches, err := dnf(operand, negate)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !isAnd:
+			branches = append(branches, operandBranches...)
+		case i == 0:
+			branches = operandBranches
+		default:
+			// (a or b) and (c or d) == (a and c) or (a and d) or (b and c) or (b and d)
+			product := make([][]literal, 0, len(branches)*len(operandBranches))
+			for _, b1 := range branches {
+				for _, b2 := range operandBranches {
+					b := make([]literal, 0, len(b1)+len(b2))
+					product = append(product, append(append(b, b1...), b2...))
+				}
+			}
+			branches = product
+		}
+		if len(branches) > maxBranches {
+			return nil, &TypeError{Pos: n.Pos, Err: fmt.Errorf("boolean query is too complex (it has more than %d alternatives)", maxBranches)}
+		}
+	}
+	return branches, nil
+}
+
+// literalExpr returns the expression for lit.
+func literalExpr(lit literal) (*syntax.Expr, error) {
+	if len(lit.exprs) == 1 {
+		e := *lit.exprs[0]
+		e.Not = lit.not
+		return &e, nil
+	}
+
+	// Combine the pattern's terms into one regexp that matches them in
+	// order. Quoted terms are matched literally. This must be kept in
+	// sync with how the search backends combine terms (see
+	// regexpPatternMatchingExprsInOrder in graphqlbackend).
+	patterns := make([]string, len(lit.exprs))
+	for i, e := range lit.exprs {
+		switch e.ValueType {
+		case syntax.TokenQuoted:
+			s, err := unquoteString(e.Value)
+			if err != nil {
+				return nil, &TypeError{Pos: e.Pos, Err: err}
+			}
+			patterns[i] = regexp.QuoteMeta(s)
+		default:
+			patterns[i] = e.Value
+		}
+	}
+	return &syntax.Expr{
+		Pos:       lit.exprs[0].Pos,
+		Not:       lit.not,
+		Value:     "(" + strings.Join(patterns, ").*?(") + ")",
+		ValueType: syntax.TokenPattern,
+	}, nil
+}
+
+// checkBranches typechecks each branch of the boolean query (whose
+// typechecked form is checkedQuery) in disjunctive normal form.
+func (c *Config) checkBranches(query *syntax.Query, checkedQuery *Query) ([]*Query, error) {
+	conjunctions, err := dnf(query.Tree, false)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]*Query, 0, len(conjunctions))
+	for _, conjunction := range conjunctions {
+		exprs := make([]*syntax.Expr, 0, len(conjunction))
+		fields := map[string]struct{}{}
+		hasPattern, hasNegatedPattern := false, false
+		for _, lit := range conjunction {
+			e, err := literalExpr(lit)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, e)
+			fields[c.resolveAlias(e.Field)] = struct{}{}
+			if e.Field == "" {
+				if e.Not {
+					hasNegatedPattern = true
+				} else {
+					hasPattern = true
+				}
+			}
+		}
+		if hasNegatedPattern && !hasPattern {
+			return nil, &TypeError{Pos: query.Tree.Pos, Err: errors.New(`a negated pattern must be combined with a pattern to match (such as "foo AND NOT bar")`)}
+		}
+
+		// Fields that may only be used once (such as case:yes) apply to
+		// the whole query, not just to the branches they are in.
+		for _, e := range query.Expr {
+			field := c.resolveAlias(e.Field)
+			if _, ok := fields[field]; ok || !c.FieldTypes[field].Singular {
+				continue
+			}
+			fields[field] = struct{}{}
+			exprs = append(exprs, checkedQuery.Fields[field][0].syntax)
+		}
+
+		branch, err := c.check(&syntax.Query{Input: syntax.ExprString(exprs), Expr: exprs}, true)
+		if err != nil {
+			return nil, err
+		}
+		branch.IsBranch = true
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/frontend/internal/pkg/search/query/syntax"
+)
+
+func TestCheck_branches(t *testing.T) {
+	conf := Config{
+		FieldTypes: map[string]FieldType{
+			"":  {Literal: RegexpType, Quoted: StringType},
+			"r": {Literal: RegexpType, Quoted: RegexpType, Negatable: true},
+			"b": {Literal: BoolType, Quoted: BoolType, Singular: true},
+		},
+		FieldAliases: map[string]string{"r2": "r"},
+	}
+	tests := map[string]struct {
+		want    []string // the Syntax.Input of each branch
+		wantErr string
+	}{
+		"a b":                 {},
+		"a OR b":              {want: []string{"a", "b"}},
+		"a AND b":             {want: []string{"a b"}},
+		"a b OR c":            {want: []string{"/(a).*?(b)/", "c"}},
+		`"a.b" c OR d`:        {want: []string{`/(a\.b).*?(c)/`, "d"}},
+		"(a OR b) AND NOT c":  {want: []string{"a -c", "b -c"}},
+		"a -b OR c":           {want: []string{"a -b", "c"}},
+		"a AND NOT (b OR c)":  {want: []string{"a -b -c"}},
+		"a AND NOT (b AND c)": {want: []string{"a -b", "a -c"}},
+		"a AND NOT (b r:x)":   {want: []string{"a -b", "a -r:x"}},
+		"a AND NOT NOT b":     {want: []string{"a b"}},
+		"(a OR b) (c OR d)":   {want: []string{"a c", "a d", "b c", "b d"}},
+		"(a r:x) OR (b r2:y)": {want: []string{"a r:x", "b r2:y"}},
+		"a OR b b:yes":        {want: []string{"a b:yes", "b b:yes"}},
+		"(a b:no) OR b":       {want: []string{"a b:no", "b b:no"}},
+		"NOT a":               {wantErr: "type error at character 0: a negated pattern must be combined with a pattern to match (such as \"foo AND NOT bar\")"},
+		"a OR NOT b":          {wantErr: "type error at character 0: a negated pattern must be combined with a pattern to match (such as \"foo AND NOT bar\")"},
+		"a AND NOT b:yes":     {wantErr: `type error at character 10: field "b" does not support negation`},
+		"a OR b:yes OR b:no":  {wantErr: `type error at character 14: field "b" may not be used more than once`},
+		"(a OR b) (c OR d) (e OR f) (g OR h) (i OR j)": {wantErr: "type error at character 1: boolean query is too complex (it has more than 16 alternatives)"},
+	}
+	for input, test := range tests {
+		t.Run(input, func(t *testing.T) {
+			parsed, err := syntax.Parse(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, err := conf.Check(parsed)
+			if err != nil {
+				if test.wantErr == "" {
+					t.Fatal(err)
+				}
+				if err.Error() != test.wantErr {
+					t.Fatalf("got err %q, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if test.wantErr != "" {
+				t.Fatalf("got err == nil, want %q", test.wantErr)
+			}
+			var got []string
+			for _, b := range query.Branches {
+				if !b.IsBranch {
+					t.Error("IsBranch == false, want true")
+				}
+				got = append(got, b.Syntax.Input)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got branches %q, want %q", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("got branches %q, want %q", got, test.want)
+				}
+			}
+		})
+	}
+}
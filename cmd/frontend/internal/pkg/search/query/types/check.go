@@ -33,6 +33,11 @@ type FieldType struct {
 	Singular  bool      // whether the field may only be used 0 or 1 times
 	Negatable bool      // whether the field can be matched negated (i.e., -field:value)
 
+	// RequiresField and RequiresValue, if set, mean that the field may only
+	// be used in a query that also has the string value RequiresValue for
+	// the field RequiresField (e.g. kind: may only be used with type:symbol).
+	RequiresField, RequiresValue string
+
 	// FeatureFlagEnabled returns true if this field is enabled.
 	// The field is always enabled if this is nil.
 	FeatureFlagEnabled func() bool
@@ -40,12 +45,29 @@ type FieldType struct {
 
 // Check typechecks the input query for field and type validity.
 func (c *Config) Check(query *syntax.Query) (*Query, error) {
+	isBoolean := query.Tree != nil
+	checkedQuery, err := c.check(query, isBoolean)
+	if err != nil {
+		return nil, err
+	}
+	if isBoolean {
+		checkedQuery.Branches, err = c.checkBranches(query, checkedQuery)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return checkedQuery, nil
+}
+
+// check typechecks the expressions of query. If negatedPatterns is true,
+// negated default-field terms are allowed (as they are in boolean queries).
+func (c *Config) check(query *syntax.Query, negatedPatterns bool) (*Query, error) {
 	checkedQuery := Query{
 		Syntax: query,
 		Fields: map[string][]*Value{},
 	}
 	for _, expr := range query.Expr {
-		field, fieldType, value, err := c.checkExpr(expr)
+		field, fieldType, value, err := c.checkExpr(expr, negatedPatterns)
 		if err != nil {
 			return nil, err
 		}
@@ -54,14 +76,41 @@ func (c *Config) Check(query *syntax.Query) (*Query, error) {
 		}
 		checkedQuery.Fields[field] = append(checkedQuery.Fields[field], value)
 	}
+	for _, expr := range query.Expr {
+		if err := c.checkRequiredField(expr, checkedQuery.Fields); err != nil {
+			return nil, err
+		}
+	}
 	return &checkedQuery, nil
 }
 
-func (c *Config) resolveField(field string, not bool) (resolvedField string, typ FieldType, err error) {
-	// Resolve field alias, if any.
+// checkRequiredField returns an error if the field of expr requires another
+// field value (see FieldType.RequiresField) that is not in fields.
+func (c *Config) checkRequiredField(expr *syntax.Expr, fields map[string][]*Value) error {
+	fieldType := c.FieldTypes[c.resolveAlias(expr.Field)]
+	if fieldType.RequiresField == "" {
+		return nil
+	}
+	for _, v := range fields[fieldType.RequiresField] {
+		if !v.Not() && v.String != nil && *v.String == fieldType.RequiresValue {
+			return nil
+		}
+	}
+	return &TypeError{Pos: expr.Pos, Err: fmt.Errorf("field %q may only be used with %s:%s", expr.Field, fieldType.RequiresField, fieldType.RequiresValue)}
+}
+
+// resolveAlias returns the field name that field is an alias for, or field
+// if it is not an alias.
+func (c *Config) resolveAlias(field string) string {
 	if resolvedField, ok := c.FieldAliases[field]; ok {
-		field = resolvedField
+		return resolvedField
 	}
+	return field
+}
+
+func (c *Config) resolveField(field string, not bool) (resolvedField string, typ FieldType, err error) {
+	// Resolve field alias, if any.
+	field = c.resolveAlias(field)
 
 	// Check that field is recognized.
 	var ok bool
@@ -85,9 +134,10 @@ func (c *Config) resolveField(field string, not bool) (resolvedField string, typ
 	return field, typ, nil
 }
 
-func (c *Config) checkExpr(expr *syntax.Expr) (field string, fieldType FieldType, value *Value, err error) {
+func (c *Config) checkExpr(expr *syntax.Expr, negatedPatterns bool) (field string, fieldType FieldType, value *Value, err error) {
 	// Resolve field name.
-	resolvedField, fieldType, err := c.resolveField(expr.Field, expr.Not)
+	not := expr.Not && !(negatedPatterns && expr.Field == "")
+	resolvedField, fieldType, err := c.resolveField(expr.Field, not)
 	if err != nil {
 		return "", FieldType{}, nil, &TypeError{Pos: expr.Pos, Err: err}
 	}
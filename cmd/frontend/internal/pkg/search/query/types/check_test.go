@@ -45,6 +45,16 @@ func TestCheck(t *testing.T) {
 				Quoted:   BoolType,
 				Singular: true,
 			},
+			"t": {
+				Literal: StringType,
+				Quoted:  StringType,
+			},
+			"k": {
+				Literal:       StringType,
+				Quoted:        StringType,
+				RequiresField: "t",
+				RequiresValue: "x",
+			},
 		},
 		FieldAliases: map[string]string{
 			"f":  "",
@@ -92,6 +102,12 @@ func TestCheck(t *testing.T) {
 				"b": {{Value: true}},
 			},
 		},
+		"t:y t:x k:a": {
+			want: map[string][]value{
+				"t": {{Value: "y"}, {Value: "x"}},
+				"k": {{Value: "a"}},
+			},
+		},
 		`-a`:         {wantErr: &TypeError{Pos: 1, Err: errors.New(`negated terms (-term) are not yet supported`)}},
 		`-b:yes`:     {wantErr: &TypeError{Pos: 1, Err: errors.New(`field "b" does not support negation`)}},
 		"b:yes b:no": {wantErr: &TypeError{Pos: 6, Err: errors.New(`field "b" may not be used more than once`)}},
@@ -100,6 +116,7 @@ func TestCheck(t *testing.T) {
 		"b:z":        {wantErr: &TypeError{Pos: 0, Err: errors.New(`invalid boolean "z"`)}},
 		`b:"z"`:      {wantErr: &TypeError{Pos: 0, Err: errors.New(`invalid boolean "z"`)}},
 		"z:a":        {wantErr: &TypeError{Pos: 0, Err: errors.New(`unrecognized field "z"`)}},
+		"t:y k:a":    {wantErr: &TypeError{Pos: 4, Err: errors.New(`field "k" may only be used with t:x`)}},
 	}
 	for input, test := range tests {
 		t.Run(input, func(t *testing.T) {
@@ -10,6 +10,17 @@ import (
 type Query struct {
 	Syntax *syntax.Query       // the query syntax
 	Fields map[string][]*Value // map of field name -> values
+
+	// Branches is the query in disjunctive normal form, if it is a boolean
+	// query (see syntax.Query.Tree). The query matches what any of its
+	// branches matches.
+	Branches []*Query
+
+	// IsBranch is whether the query is one of the Branches of a boolean
+	// query. In a branch, each value of the default field is a whole
+	// pattern that must match (or if negated, must not match), instead of a
+	// term to combine with the other terms into a single pattern.
+	IsBranch bool
 }
 
 // ValueType is the set of types of values in queries.
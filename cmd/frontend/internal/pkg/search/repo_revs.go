@@ -1,6 +1,8 @@
 package search
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/sourcegraph/sourcegraph/cmd/frontend/types"
@@ -49,6 +51,9 @@ func (r1 RevisionSpecifier) Less(r2 RevisionSpecifier) bool {
 	return r1.ExcludeRefGlob < r2.ExcludeRefGlob
 }
 
+// allBranchesRefGlob is the ref glob that the '@*' shorthand expands to.
+const allBranchesRefGlob = "refs/heads/"
+
 // RepositoryRevisions specifies a repository and 0 or more revspecs and ref
 // globs.  If no revspecs and no ref globs are specified, then the
 // repository's default branch is used.
@@ -78,6 +83,8 @@ type RepositoryRevisions struct {
 // - 'foo@*bar' refers to the 'foo' repo and all refs matching the glob 'bar/*',
 //   because git interprets the ref glob 'bar' as being 'bar/*' (see `man git-log`
 //   section on the --glob flag)
+// - 'foo@*' refers to the 'foo' repo and all of its branches. It is shorthand
+//   for 'foo@*refs/heads/'.
 func ParseRepositoryRevisions(repoAndOptionalRev string) (api.RepoURI, []RevisionSpecifier) {
 	i := strings.Index(repoAndOptionalRev, "@")
 	if i == -1 {
@@ -98,6 +105,8 @@ func ParseRepositoryRevisions(repoAndOptionalRev string) (api.RepoURI, []Revisio
 		var rev RevisionSpecifier
 		if strings.HasPrefix(part, "*!") {
 			rev.ExcludeRefGlob = part[2:]
+		} else if part == "*" {
+			rev.RefGlob = allBranchesRefGlob
 		} else if strings.HasPrefix(part, "*") {
 			rev.RefGlob = part[1:]
 		} else {
@@ -130,3 +139,110 @@ func (r *RepositoryRevisions) RevSpecs() []string {
 	}
 	return revspecs
 }
+
+// HasRefGlobs reports whether r has any ref globs, which need to be expanded
+// (e.g. with a RefGlobMatcher) to get the revisions to search.
+func (r *RepositoryRevisions) HasRefGlobs() bool {
+	for _, rev := range r.Revs {
+		if rev.RefGlob != "" || rev.ExcludeRefGlob != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// A RefGlobMatcher matches ref names against the ref globs in a list of
+// revision specifiers, with the same semantics as git log's --glob and
+// --exclude flags: a ref matches if it matches any RefGlob and no
+// ExcludeRefGlob.
+type RefGlobMatcher struct {
+	include, exclude []*regexp.Regexp
+}
+
+// NewRefGlobMatcher returns a matcher for the ref globs in revs. Revision
+// specifiers which are not ref globs are ignored.
+func NewRefGlobMatcher(revs []RevisionSpecifier) (*RefGlobMatcher, error) {
+	var m RefGlobMatcher
+	for _, rev := range revs {
+		switch {
+		case rev.RefGlob != "":
+			glob := rev.RefGlob
+			// As with --glob, "refs/" is implied, and so is a trailing
+			// "/*" if the glob has no glob characters.
+			if !strings.HasPrefix(glob, "refs/") {
+				glob = "refs/" + glob
+			}
+			if !strings.ContainsAny(glob, "*?[") {
+				glob = strings.TrimSuffix(glob, "/") + "/*"
+			}
+			re, err := compileRefGlob(glob)
+			if err != nil {
+				return nil, err
+			}
+			m.include = append(m.include, re)
+
+		case rev.ExcludeRefGlob != "":
+			// As with --exclude, the glob is used as is.
+			re, err := compileRefGlob(rev.ExcludeRefGlob)
+			if err != nil {
+				return nil, err
+			}
+			m.exclude = append(m.exclude, re)
+		}
+	}
+	return &m, nil
+}
+
+// Match reports whether the ref (such as "refs/heads/master") matches.
+func (m *RefGlobMatcher) Match(ref string) bool {
+	for _, re := range m.exclude {
+		if re.MatchString(ref) {
+			return false
+		}
+	}
+	for _, re := range m.include {
+		if re.MatchString(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileRefGlob converts a glob to an anchored regexp. Like git (and unlike
+// path.Match), '*' also matches '/'.
+func compileRefGlob(glob string) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			buf.WriteString(".*")
+		case '?':
+			buf.WriteString(".")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			buf.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			j := strings.IndexByte(glob[i+1:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("invalid ref glob %q: missing ']'", glob)
+			}
+			class := glob[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += j + 1
+		default:
+			buf.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	buf.WriteString("$")
+	re, err := regexp.Compile(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid ref glob %q: %s", glob, err)
+	}
+	return re, nil
+}
@@ -18,6 +18,7 @@ func TestParseRepositoryRevisions(t *testing.T) {
 		"repo@rev1:rev2": {repo: "repo", revs: []RevisionSpecifier{{RevSpec: "rev1"}, {RevSpec: "rev2"}}},
 		"repo@:rev1:":    {repo: "repo", revs: []RevisionSpecifier{{RevSpec: "rev1"}}},
 		"repo@*glob":     {repo: "repo", revs: []RevisionSpecifier{{RefGlob: "glob"}}},
+		"repo@*":         {repo: "repo", revs: []RevisionSpecifier{{RefGlob: "refs/heads/"}}},
 		"repo@rev1:*glob1:^rev2": {
 			repo: "repo",
 			revs: []RevisionSpecifier{{RevSpec: "rev1"}, {RefGlob: "glob1"}, {RevSpec: "^rev2"}},
@@ -45,3 +46,64 @@ func TestParseRepositoryRevisions(t *testing.T) {
 		})
 	}
 }
+
+func TestRefGlobMatcher(t *testing.T) {
+	tests := []struct {
+		revs  string
+		match []string
+		skip  []string
+	}{
+		{
+			revs:  "*",
+			match: []string{"refs/heads/master", "refs/heads/release/1.0"},
+			skip:  []string{"refs/tags/v1.0", "refs/pull/1/head", "HEAD"},
+		},
+		{
+			revs:  "*refs/heads/release/*",
+			match: []string{"refs/heads/release/1.0", "refs/heads/release/2.x/fix"},
+			skip:  []string{"refs/heads/master", "refs/heads/release", "refs/tags/release/1.0"},
+		},
+		{
+			revs:  "*tags",
+			match: []string{"refs/tags/v1.0"},
+			skip:  []string{"refs/heads/master", "refs/tagsfoo"},
+		},
+		{
+			revs:  "*refs/heads/v[0-9]?:*!refs/heads/v1?",
+			match: []string{"refs/heads/v2a"},
+			skip:  []string{"refs/heads/v1a", "refs/heads/vxa", "refs/heads/v2ab"},
+		},
+		{
+			revs:  "*refs/heads/[!m]*",
+			match: []string{"refs/heads/dev"},
+			skip:  []string{"refs/heads/master"},
+		},
+		{
+			revs: "master:rev",
+			skip: []string{"refs/heads/master"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.revs, func(t *testing.T) {
+			_, revs := ParseRepositoryRevisions("repo@" + test.revs)
+			m, err := NewRefGlobMatcher(revs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ref := range test.match {
+				if !m.Match(ref) {
+					t.Errorf("%q should match", ref)
+				}
+			}
+			for _, ref := range test.skip {
+				if m.Match(ref) {
+					t.Errorf("%q should not match", ref)
+				}
+			}
+		})
+	}
+
+	if _, err := NewRefGlobMatcher([]RevisionSpecifier{{RefGlob: "refs/heads/[a"}}); err == nil {
+		t.Error("expected error for invalid glob")
+	}
+}
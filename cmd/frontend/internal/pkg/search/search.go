@@ -14,9 +14,8 @@ type PatternInfo struct {
 	IsWordMatch     bool
 	IsCaseSensitive bool
 	FileMatchLimit  int32
+	IsMultiline     bool
 
-	// We do not support IsMultiline
-	//IsMultiline     bool
 	IncludePattern  string
 	IncludePatterns []string
 	ExcludePattern  string
@@ -26,6 +25,27 @@ type PatternInfo struct {
 
 	PatternMatchesContent bool
 	PatternMatchesPath    bool
+
+	// BeforeContextLines and AfterContextLines are the number of lines of
+	// context to return around each line match.
+	BeforeContextLines int32
+	AfterContextLines  int32
+
+	// IsReplace is whether to replace each match of Pattern with Replacement
+	// and return a diff per file instead of line matches.
+	IsReplace   bool
+	Replacement string
+
+	// AndPatterns are additional patterns that must all match a file's
+	// content, and NotPatterns are patterns that must not match it. They
+	// come from boolean queries (e.g. "foo AND bar AND NOT baz").
+	AndPatterns []string
+	NotPatterns []string
+
+	// CountOnly indicates that the results are only counted, never
+	// displayed. File matches then have a count of their matches instead
+	// of line matches, and the number of matches per file is not capped.
+	CountOnly bool
 }
 
 func (p *PatternInfo) IsEmpty() bool {
@@ -38,6 +58,11 @@ func (p *PatternInfo) Validate() error {
 		if _, err := syntax.Parse(p.Pattern, syntax.Perl); err != nil {
 			return err
 		}
+		for _, expr := range append(append([]string{}, p.AndPatterns...), p.NotPatterns...) {
+			if _, err := syntax.Parse(expr, syntax.Perl); err != nil {
+				return err
+			}
+		}
 	}
 
 	if p.PathPatternsAreRegExps {
@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -21,14 +22,27 @@ import (
 	"github.com/sourcegraph/sourcegraph/cmd/gitserver/server"
 	"github.com/sourcegraph/sourcegraph/pkg/debugserver"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
+	gitserverclient "github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/tracer"
 )
 
-const janitorInterval = 24 * time.Hour
+const (
+	janitorInterval   = 24 * time.Hour
+	rebalanceInterval = 5 * time.Minute
+	evictionInterval  = time.Minute
+
+	// maxRebalanceInterval is the longest time between rebalancing runs. The
+	// interval doubles after each run that moves no repositories, since the
+	// list of gitservers only changes when gitserver is restarted.
+	maxRebalanceInterval = 24 * time.Hour
+)
 
 var (
 	reposDir          = env.Get("SRC_REPOS_DIR", "", "Root dir containing repos.")
 	runRepoCleanup, _ = strconv.ParseBool(env.Get("SRC_RUN_REPO_CLEANUP", "", "Periodically remove inactive repositories."))
+	addr              = env.Get("SRC_GITSERVER_ADDR", "", "The address of this gitserver in SRC_GIT_SERVERS. If set, repositories are rebalanced between gitservers when SRC_GIT_SERVERS changes.")
+	rebalanceFrom     = env.Get("SRC_GITSERVER_REBALANCE_FROM", "", "Addresses of gitservers not in SRC_GIT_SERVERS (such as gitservers being removed) to copy repositories from when rebalancing.")
+	wantPctFree       = env.Get("SRC_REPOS_DESIRED_PERCENT_FREE", "10", "Target percentage of free space on the disk of SRC_REPOS_DIR. The least recently used repositories are removed when less space is free. 0 disables removal.")
 )
 
 func main() {
@@ -42,10 +56,18 @@ func main() {
 	if err := os.MkdirAll(reposDir, os.ModePerm); err != nil {
 		log.Fatalf("failed to create SRC_REPOS_DIR: %s", err)
 	}
+	desiredPercentFree, err := strconv.Atoi(wantPctFree)
+	if err != nil || desiredPercentFree < 0 || desiredPercentFree > 100 {
+		log.Fatalf("SRC_REPOS_DESIRED_PERCENT_FREE must be a percentage between 0 and 100, got %q", wantPctFree)
+	}
 
 	gitserver := server.Server{
 		ReposDir:                reposDir,
 		DeleteStaleRepositories: runRepoCleanup,
+		DesiredPercentFree:      desiredPercentFree,
+		Addr:                    addr,
+		Addrs:                   gitserverclient.DefaultClient.Addrs,
+		RebalanceFrom:           strings.Fields(rebalanceFrom),
 	}
 	gitserver.RegisterMetrics()
 
@@ -69,6 +91,29 @@ func main() {
 		}
 	}()
 
+	if desiredPercentFree > 0 {
+		go func() {
+			for {
+				gitserver.EvictRepos()
+				time.Sleep(evictionInterval)
+			}
+		}()
+	}
+
+	if addr != "" {
+		go func() {
+			interval := rebalanceInterval
+			for {
+				if gitserver.Rebalance() > 0 {
+					interval = rebalanceInterval
+				} else if interval *= 2; interval > maxRebalanceInterval {
+					interval = maxRebalanceInterval
+				}
+				time.Sleep(interval)
+			}
+		}()
+	}
+
 	port := "3178"
 	host := ""
 	if env.InsecureDev {
@@ -15,6 +15,7 @@ import (
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -32,6 +33,11 @@ func init() {
 const inactiveRepoTTL = time.Hour * 24 * 20
 const repoTTL = time.Hour * 24 * 45
 
+// maxFilterReclonesPerRun is the maximum number of repositories recloned per
+// cleanup run because their partial clone filter was changed. A change may
+// apply to many repositories; the others are recloned in later runs.
+var maxFilterReclonesPerRun = 100
+
 var reposRemoved = prometheus.NewCounter(prometheus.CounterOpts{
 	Namespace: "src",
 	Subsystem: "gitserver",
@@ -51,6 +57,7 @@ var reposRecloned = prometheus.NewCounter(prometheus.CounterOpts{
 // 2. Remove stale lock files.
 // 3. Remove inactive repos on sourcegraph.com
 // 4. Reclone repos after a while. (simulate git gc)
+// 5. Run git maintenance on repos fetched since their last maintenance.
 func (s *Server) cleanupRepos() {
 	bCtx, bCancel := s.serverContext()
 	defer bCancel()
@@ -99,15 +106,22 @@ func (s *Server) cleanupRepos() {
 		return false, setGitAttributes(gitDir)
 	}
 
+	filterReclones := 0
 	maybeReclone := func(gitDir string) (done bool, err error) {
 		recloneTime, err := getRecloneTime(gitDir)
 		if err != nil {
 			return false, err
 		}
-
 		// Add a jitter to spread out recloning of repos cloned at the same
 		// time.
-		if time.Since(recloneTime) <= repoTTL+randDuration(repoTTL/4) {
+		expired := time.Since(recloneTime) > repoTTL+randDuration(repoTTL/4)
+
+		// Repos whose partial clone filter was changed in the site
+		// configuration are recloned, so that the change applies to existing
+		// clones. Looking up the filter of a repo requires its remote URL,
+		// which is only needed if the repo has a filter or any filter is
+		// configured.
+		if !expired && (filterReclones >= maxFilterReclonesPerRun || (repoCloneFilter(gitDir) == "" && !cloneFiltersConfigured(conf.Get()))) {
 			return false, nil
 		}
 
@@ -116,7 +130,6 @@ func (s *Server) cleanupRepos() {
 
 		// name is the relative path to ReposDir, but without the .git suffix.
 		repo := protocol.NormalizeRepo(api.RepoURI(strings.TrimPrefix(filepath.Dir(gitDir), s.ReposDir+"/")))
-		log15.Info("recloning expired repo", "repo", repo)
 
 		remoteURL := OriginMap(repo)
 		if remoteURL == "" {
@@ -127,6 +140,23 @@ func (s *Server) cleanupRepos() {
 			}
 		}
 
+		if filter := cloneFilter(repo, remoteURL); filter != repoCloneFilter(gitDir) {
+			if !expired {
+				filterReclones++
+			}
+			log15.Info("recloning repo with new partial clone filter", "repo", repo, "filter", filter)
+		} else if !expired {
+			return false, nil
+		} else {
+			log15.Info("recloning expired repo", "repo", repo)
+		}
+
+		// The stored remote URL has no short-lived token to reclone with.
+		remoteURL, err = currentRemoteURL(ctx, repo, remoteURL)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get current remote URL")
+		}
+
 		if _, err := s.cloneRepo(ctx, repo, remoteURL, &cloneOptions{Block: true, Overwrite: true}); err != nil {
 			return true, err
 		}
@@ -134,6 +164,14 @@ func (s *Server) cleanupRepos() {
 		return true, nil
 	}
 
+	maybeMaintain := func(gitDir string) (done bool, err error) {
+		if due, err := maintenanceDue(gitDir); err != nil || !due {
+			return false, err
+		}
+		repo := protocol.NormalizeRepo(api.RepoURI(strings.TrimPrefix(filepath.Dir(gitDir), s.ReposDir+"/")))
+		return false, s.maintainRepo(bCtx, repo, gitDir)
+	}
+
 	removeStaleLocks := func(gitDir string) (done bool, err error) {
 		// if removing a lock fails, we still want to try the other locks.
 		var multi error
@@ -196,6 +234,10 @@ func (s *Server) cleanupRepos() {
 	// these problems. git gc is slow and resource intensive. It is
 	// cheaper and faster to just reclone the repository.
 	cleanups = append(cleanups, cleanupFn{"maybe reclone", maybeReclone})
+	// Recloning is too expensive to do often, so in between fetches
+	// accumulate packs and slow down walking the history. Optimize repos
+	// which were fetched since we last did.
+	cleanups = append(cleanups, cleanupFn{"maybe run maintenance", maybeMaintain})
 
 	filepath.Walk(s.ReposDir, func(gitDir string, fi os.FileInfo, fileErr error) error {
 		if fileErr != nil {
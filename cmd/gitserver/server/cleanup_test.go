@@ -11,6 +11,9 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/schema"
 )
 
 const (
@@ -84,7 +87,9 @@ func TestCleanupExpired(t *testing.T) {
 	}
 
 	origRepoRemoteURL := repoRemoteURL
+	var lookedUp []string
 	repoRemoteURL = func(ctx context.Context, dir string) (string, error) {
+		lookedUp = append(lookedUp, dir)
 		return remote, nil
 	}
 	defer func() { repoRemoteURL = origRepoRemoteURL }()
@@ -122,6 +127,60 @@ func TestCleanupExpired(t *testing.T) {
 	if fi.ModTime().Before(ti) {
 		t.Error("expected repoB to be recloned during clean up")
 	}
+	// Only the remote URL of the expired repo is looked up.
+	if len(lookedUp) != 1 || lookedUp[0] != repoB {
+		t.Errorf("got remote URLs looked up for %v, want only %s", lookedUp, repoB)
+	}
+}
+
+func TestCleanupFilterChanged(t *testing.T) {
+	root, err := ioutil.TempDir("", "gitserver-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	remote := path.Join(root, testRepoC, ".git")
+	if err := exec.Command("git", "--bare", "init", remote).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Command("git", "-C", remote, "config", "uploadpack.allowFilter", "true").Run(); err != nil {
+		t.Fatal(err)
+	}
+	var repos []string
+	for _, name := range []string{testRepoA, testRepoB} {
+		dir := path.Join(root, name, ".git")
+		if err := exec.Command("git", "--bare", "init", dir).Run(); err != nil {
+			t.Fatal(err)
+		}
+		repos = append(repos, dir)
+	}
+
+	origRepoRemoteURL := repoRemoteURL
+	repoRemoteURL = func(ctx context.Context, dir string) (string, error) {
+		return "file://" + remote, nil
+	}
+	defer func() { repoRemoteURL = origRepoRemoteURL }()
+	conf.Mock(&schema.SiteConfiguration{GitCloneFilters: map[string]string{testRepoA: "blob:none", testRepoB: "blob:none"}})
+	defer conf.Mock(nil)
+	defer func(orig int) { maxFilterReclonesPerRun = orig }(maxFilterReclonesPerRun)
+	maxFilterReclonesPerRun = 1
+
+	// Only one of the repos whose filter changed is recloned per run.
+	s := &Server{ReposDir: root}
+	s.Handler() // Handler as a side-effect sets up Server
+	for run, want := range []int{1, 2} {
+		s.cleanupRepos()
+		recloned := 0
+		for _, dir := range repos {
+			if repoCloneFilter(dir) == "blob:none" {
+				recloned++
+			}
+		}
+		if recloned != want {
+			t.Errorf("got %d repos recloned after run %d, want %d", recloned, run+1, want)
+		}
+	}
 }
 
 func TestCleanupOldLocks(t *testing.T) {
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var reposEvicted = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "repos_evicted",
+	Help:      "number of least recently used repos removed to free up disk space",
+})
+
+func init() {
+	prometheus.MustRegister(reposEvicted)
+}
+
+const (
+	// maxEvictionsPerRun is the maximum number of repositories that a
+	// single call to evictRepos removes. If more space is needed, the next
+	// call continues.
+	maxEvictionsPerRun = 100
+
+	// maxEvictionsWithoutProgress is the number of repositories that
+	// evictRepos removes without more disk space becoming available before
+	// it gives up, since something other than repositories is filling the
+	// disk.
+	maxEvictionsWithoutProgress = 3
+)
+
+// diskSpace returns the size of the file system containing dir and the space
+// available on it, in bytes.
+var diskSpace = func(dir string) (total, avail uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Blocks * uint64(stat.Bsize), stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// EvictRepos removes the least recently used repositories when less than
+// s.DesiredPercentFree percent of the disk containing s.ReposDir is free,
+// until enough space is free. Evicted repositories are cloned again when they
+// are next used.
+func (s *Server) EvictRepos() {
+	if err := s.evictRepos(); err != nil {
+		log15.Error("failed to evict repositories", "error", err)
+	}
+}
+
+func (s *Server) evictRepos() error {
+	if s.DesiredPercentFree <= 0 {
+		return nil
+	}
+	total, avail, err := diskSpace(s.ReposDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to get available disk space")
+	}
+	desired := total / 100 * uint64(s.DesiredPercentFree)
+	if avail >= desired {
+		return nil
+	}
+	ctx, cancel := s.serverContext()
+	defer cancel()
+	names, err := s.listCloned(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list cloned repositories")
+	}
+
+	type repoAccess struct {
+		repo         api.RepoURI
+		dir          string
+		lastAccessed time.Time
+	}
+	repos := make([]repoAccess, 0, len(names))
+	for _, name := range names {
+		dir := filepath.Join(s.ReposDir, name)
+		lastAccessed, err := repoLastAccessed(dir)
+		if err != nil {
+			log15.Warn("error computing last-accessed date", "repo", name, "error", err)
+			continue
+		}
+		repos = append(repos, repoAccess{repo: api.RepoURI(name), dir: dir, lastAccessed: lastAccessed})
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].lastAccessed.Before(repos[j].lastAccessed)
+	})
+
+	log15.Warn("disk space is low, evicting least recently used repositories", "available", avail, "desired", desired)
+	var evicted, withoutProgress int
+	for _, r := range repos {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if evicted == maxEvictionsPerRun {
+			return errors.Errorf("evicted %d repositories, but %d bytes still need to be freed", evicted, desired-avail)
+		}
+		if _, cloning := s.locker.Status(r.dir); cloning {
+			continue
+		}
+		if err := s.deleteRepo(r.repo); err != nil {
+			log15.Warn("failed to evict repository", "repo", r.repo, "error", err)
+			continue
+		}
+		log15.Info("evicted repository", "repo", r.repo, "lastAccessed", r.lastAccessed)
+		reposEvicted.Inc()
+		evicted++
+
+		prevAvail := avail
+		_, avail, err = diskSpace(s.ReposDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to get available disk space")
+		}
+		if avail >= desired {
+			return nil
+		}
+		if avail > prevAvail {
+			withoutProgress = 0
+			continue
+		}
+		withoutProgress++
+		if withoutProgress == maxEvictionsWithoutProgress {
+			return errors.Errorf("evicted %d repositories without freeing disk space", withoutProgress)
+		}
+	}
+	return errors.Errorf("evicted all %d repositories that can be evicted, but %d bytes still need to be freed", evicted, desired-avail)
+}
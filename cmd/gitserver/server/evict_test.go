@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// createEvictableRepos creates a repository of about 1MB in reposDir for
+// each repo, last accessed at the given time.
+func createEvictableRepos(t *testing.T, reposDir string, lastAccessed map[string]time.Time) {
+	for repo, atime := range lastAccessed {
+		gitDir := filepath.Join(reposDir, repo, ".git")
+		if out, err := exec.Command("git", "init", "--bare", gitDir).CombinedOutput(); err != nil {
+			t.Fatalf("git init failed: %s\n%s", err, out)
+		}
+		if err := ioutil.WriteFile(filepath.Join(gitDir, "objects", "big"), make([]byte, 1<<20), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := setLastAccessed(filepath.Join(reposDir, repo)); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(gitDir, "sg_last_access"), atime, atime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEvictRepos(t *testing.T) {
+	reposDir, cleanup := tmpDir(t)
+	defer cleanup()
+
+	// a was accessed least recently.
+	now := time.Now()
+	lastAccessed := map[string]time.Time{
+		"example.com/a": now.Add(-3 * time.Hour),
+		"example.com/b": now.Add(-2 * time.Hour),
+		"example.com/c": now.Add(-1 * time.Hour),
+	}
+	createEvictableRepos(t, reposDir, lastAccessed)
+
+	// 1.5MB are missing to have 10% of the disk free. Each evicted
+	// repository frees 1MB.
+	origDiskSpace := diskSpace
+	diskSpace = func(string) (uint64, uint64, error) {
+		avail := uint64(17 << 19)
+		for repo := range lastAccessed {
+			if !repoCloned(filepath.Join(reposDir, repo)) {
+				avail += 1 << 20
+			}
+		}
+		return 100 << 20, avail, nil
+	}
+	defer func() { diskSpace = origDiskSpace }()
+
+	s := &Server{
+		ReposDir:           reposDir,
+		DesiredPercentFree: 10,
+		ctx:                context.Background(),
+		locker:             &RepositoryLocker{},
+	}
+	if err := s.evictRepos(); err != nil {
+		t.Fatal(err)
+	}
+	for repo, wantCloned := range map[string]bool{
+		"example.com/a": false,
+		"example.com/b": false,
+		"example.com/c": true,
+	} {
+		if cloned := repoCloned(filepath.Join(reposDir, repo)); cloned != wantCloned {
+			t.Errorf("%s: got cloned %v, want %v", repo, cloned, wantCloned)
+		}
+	}
+
+	// Nothing is evicted when enough space is free.
+	diskSpace = func(string) (uint64, uint64, error) { return 100 << 20, 50 << 20, nil }
+	if err := s.evictRepos(); err != nil {
+		t.Fatal(err)
+	}
+	if !repoCloned(filepath.Join(reposDir, "example.com/c")) {
+		t.Error("example.com/c was evicted")
+	}
+}
+
+func TestEvictRepos_noProgress(t *testing.T) {
+	reposDir, cleanup := tmpDir(t)
+	defer cleanup()
+
+	now := time.Now()
+	lastAccessed := map[string]time.Time{}
+	for i := 0; i < maxEvictionsWithoutProgress+2; i++ {
+		lastAccessed[fmt.Sprintf("example.com/%d", i)] = now.Add(-time.Duration(i) * time.Hour)
+	}
+	createEvictableRepos(t, reposDir, lastAccessed)
+
+	// Something else is filling the disk, so evicting doesn't help.
+	origDiskSpace := diskSpace
+	diskSpace = func(string) (uint64, uint64, error) { return 100 << 20, 1 << 20, nil }
+	defer func() { diskSpace = origDiskSpace }()
+
+	s := &Server{
+		ReposDir:           reposDir,
+		DesiredPercentFree: 10,
+		ctx:                context.Background(),
+		locker:             &RepositoryLocker{},
+	}
+	if err := s.evictRepos(); err == nil {
+		t.Fatal("got no error, want an error because no space was freed")
+	}
+	var cloned int
+	for repo := range lastAccessed {
+		if repoCloned(filepath.Join(reposDir, repo)) {
+			cloned++
+		}
+	}
+	if want := len(lastAccessed) - maxEvictionsWithoutProgress; cloned != want {
+		t.Errorf("got %d repositories left, want %d", cloned, want)
+	}
+}
+
+func TestSetLastAccessed(t *testing.T) {
+	dir, cleanup := tmpDir(t)
+	defer cleanup()
+	gitDir := filepath.Join(dir, ".git")
+	if out, err := exec.Command("git", "init", "--bare", gitDir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s\n%s", err, out)
+	}
+
+	if err := setLastAccessed(dir); err != nil {
+		t.Fatal(err)
+	}
+	first, err := repoLastAccessed(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Accesses within lastAccessedResolution don't update the file.
+	old := first.Add(-lastAccessedResolution / 2)
+	if err := os.Chtimes(filepath.Join(gitDir, "sg_last_access"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := setLastAccessed(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repoLastAccessed(dir); !got.Equal(old) {
+		t.Errorf("got last accessed %s, want %s", got, old)
+	}
+
+	old = first.Add(-2 * lastAccessedResolution)
+	if err := os.Chtimes(filepath.Join(gitDir, "sg_last_access"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := setLastAccessed(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repoLastAccessed(dir); !got.After(old) {
+		t.Errorf("got last accessed %s, want it to be updated", got)
+	}
+}
@@ -69,67 +69,75 @@ func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
 		}
 
 	case query("cloned"):
-		err := filepath.Walk(s.ReposDir, func(path string, info os.FileInfo, err error) error {
-			if ctx.Err() != nil {
-				return ctx.Err()
-			}
+		var err error
+		repos, err = s.listCloned(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-			if s.ignorePath(path) {
-				if info.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
-			}
+	default:
+		// empty list response for unrecognized URL query
+	}
 
-			if err != nil {
-				return nil
-			}
+	if err := json.NewEncoder(w).Encode(repos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
 
-			// We only care about directories
-			if !info.IsDir() {
-				return nil
-			}
+// listCloned returns the names of the repositories cloned in s.ReposDir.
+func (s *Server) listCloned(ctx context.Context) ([]string, error) {
+	repos := make([]string, 0)
+	err := filepath.Walk(s.ReposDir, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
-			// New style git directory layout
-			if filepath.Base(path) == ".git" {
-				name, err := filepath.Rel(s.ReposDir, filepath.Dir(path))
-				if err != nil {
-					return err
-				}
-				repos = append(repos, name)
+		if s.ignorePath(path) {
+			if info.IsDir() {
 				return filepath.SkipDir
 			}
+			return nil
+		}
 
-			// For old-style directory layouts we need to do an extra extra
-			// stat to check if this is a repo.
-			if _, err := os.Stat(filepath.Join(path, "HEAD")); os.IsNotExist(err) {
-				// HEAD doesn't exist, so keep recursing
-				return nil
-			} else if err != nil {
-				return err
-			}
+		if err != nil {
+			return nil
+		}
+
+		// We only care about directories
+		if !info.IsDir() {
+			return nil
+		}
 
-			// path is an old style git repo since it contains HEAD
-			name, err := filepath.Rel(s.ReposDir, path)
+		// New style git directory layout
+		if filepath.Base(path) == ".git" {
+			name, err := filepath.Rel(s.ReposDir, filepath.Dir(path))
 			if err != nil {
 				return err
 			}
 			repos = append(repos, name)
 			return filepath.SkipDir
-		})
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
 		}
 
-	default:
-		// empty list response for unrecognized URL query
-	}
+		// For old-style directory layouts we need to do an extra extra
+		// stat to check if this is a repo.
+		if _, err := os.Stat(filepath.Join(path, "HEAD")); os.IsNotExist(err) {
+			// HEAD doesn't exist, so keep recursing
+			return nil
+		} else if err != nil {
+			return err
+		}
 
-	if err := json.NewEncoder(w).Encode(repos); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+		// path is an old style git repo since it contains HEAD
+		name, err := filepath.Rel(s.ReposDir, path)
+		if err != nil {
+			return err
+		}
+		repos = append(repos, name)
+		return filepath.SkipDir
+	})
+	return repos, err
 }
 
 func listGitoliteRepos(ctx context.Context, gconf *schema.GitoliteConnection) ([]string, error) {
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/repotrackutil"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+var (
+	maintenanceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "maintenance_duration_seconds",
+		Help:      "Duration of git maintenance (gc, commit-graph and multi-pack-index) of a repo.",
+		Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 3600},
+	}, []string{"status"})
+	repoLastMaintenance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "repo_last_maintenance_timestamp_seconds",
+		Help:      "Unix time of the last git maintenance of a repo.",
+	}, []string{"repo"})
+	repoLastMaintenanceDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "gitserver",
+		Name:      "repo_last_maintenance_duration_seconds",
+		Help:      "Duration of the last git maintenance of a repo.",
+	}, []string{"repo"})
+)
+
+func init() {
+	prometheus.MustRegister(maintenanceDuration)
+	prometheus.MustRegister(repoLastMaintenance)
+	prometheus.MustRegister(repoLastMaintenanceDuration)
+}
+
+// maintenanceDue reports whether the repository in gitDir should be
+// maintained (see maintainRepo). That is when it was fetched since its last
+// maintenance, or was never maintained. The janitor checks every repository
+// once a day, so active repositories are maintained daily.
+func maintenanceDue(gitDir string) (bool, error) {
+	last, err := getMaintenanceTime(gitDir)
+	if err != nil {
+		return false, err
+	}
+	if last.IsZero() {
+		return true, nil
+	}
+	fetched, err := repoLastFetched(gitDir)
+	if err != nil {
+		return false, err
+	}
+	// The maintenance time is stored in seconds.
+	return fetched.Truncate(time.Second).After(last), nil
+}
+
+// maintainRepo optimizes the repository in gitDir, which otherwise
+// accumulates packs and loose objects with every fetch. It runs `git gc
+// --auto`, and writes commit-graph and multi-pack-index files if the git
+// version supports them. The commit-graph speeds up walking the history
+// (such as for `git log` and `git rev-list`), and its changed-path Bloom
+// filters speed up `git log` and `git blame` of a path.
+//
+// Maintenance shares the clone limiter with clones, since it is as
+// expensive.
+func (s *Server) maintainRepo(ctx context.Context, repo api.RepoURI, gitDir string) (err error) {
+	ctx, cancel1, err := s.acquireCloneLimiter(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel1()
+	ctx, cancel2 := context.WithTimeout(ctx, longGitCommandTimeout)
+	defer cancel2()
+
+	start := time.Now()
+	defer func() {
+		duration := time.Since(start)
+		status := "success"
+		if err != nil {
+			status = "failed"
+		}
+		maintenanceDuration.WithLabelValues(status).Observe(duration.Seconds())
+		if err == nil {
+			trackedRepo := repotrackutil.GetTrackedRepo(repo)
+			repoLastMaintenance.WithLabelValues(trackedRepo).Set(float64(start.Unix()))
+			repoLastMaintenanceDuration.WithLabelValues(trackedRepo).Set(duration.Seconds())
+			log15.Info("maintained repo", "repo", repo, "duration", duration)
+		}
+	}()
+
+	version := gitVersion()
+	commands := [][]string{
+		// Don't write the commit-graph in gc, since we write it below.
+		{"-c", "gc.writeCommitGraph=false", "gc", "--auto", "--quiet"},
+	}
+	if version.atLeast(2, 24) {
+		// Since git 2.24, git reads commit-graph files by default.
+		args := []string{"commit-graph", "write", "--reachable", "--split"}
+		// Computing changed-path Bloom filters diffs every commit, which
+		// fetches the trees missing from a partial clone.
+		if version.atLeast(2, 27) && repoCloneFilter(gitDir) == "" {
+			args = append(args, "--changed-paths")
+		}
+		commands = append(commands, args)
+	}
+	// A multi-pack-index can't be written without packs, such as for
+	// empty repos.
+	if packs, _ := filepath.Glob(filepath.Join(gitDir, "objects", "pack", "*.pack")); len(packs) > 0 && version.atLeast(2, 21) {
+		args := []string{"multi-pack-index", "write"}
+		if version.atLeast(2, 34) {
+			args = append(args, "--bitmap")
+		}
+		commands = append(commands, args)
+	}
+
+	for _, args := range commands {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = gitDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, "git %s failed with output %q", strings.Join(args, " "), out)
+		}
+	}
+	return setMaintenanceTime(gitDir, start)
+}
+
+// getMaintenanceTime returns the time of the last maintenance of the
+// repository in gitDir, or the zero time if it was never maintained.
+func getMaintenanceTime(gitDir string) (time.Time, error) {
+	cmd := exec.Command("git", "config", "--get", "sourcegraph.maintenanceTimestamp")
+	cmd.Dir = gitDir
+	out, err := cmd.Output()
+	if err != nil {
+		// Exit code 1 means the key is not set.
+		if ee, ok := err.(*exec.ExitError); ok && ee.Sys().(syscall.WaitStatus).ExitStatus() == 1 {
+			return time.Time{}, nil
+		}
+		return time.Time{}, errors.Wrap(wrapCmdError(cmd, err), "failed to determine maintenance timestamp")
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 0)
+	if err != nil {
+		// Maintain repos with a bad value, which fixes it.
+		return time.Time{}, nil
+	}
+	return time.Unix(sec, 0), nil
+}
+
+func setMaintenanceTime(gitDir string, t time.Time) error {
+	cmd := exec.Command("git", "config", "sourcegraph.maintenanceTimestamp", strconv.FormatInt(t.Unix(), 10))
+	cmd.Dir = gitDir
+	if _, err := cmd.Output(); err != nil {
+		return errors.Wrap(wrapCmdError(cmd, err), "failed to update maintenance timestamp")
+	}
+	return nil
+}
+
+// version is a git version, such as [2 39 5] for git 2.39.5.
+type version []int
+
+func (v version) atLeast(major, minor int) bool {
+	if len(v) < 2 {
+		return false
+	}
+	return v[0] > major || (v[0] == major && v[1] >= minor)
+}
+
+var (
+	gitVersionOnce  sync.Once
+	gitVersionValue version
+	gitVersionRe    = regexp.MustCompile(`^git version (\d+)\.(\d+)(?:\.(\d+))?`)
+)
+
+// gitVersion returns the version of the installed git, or nil if it cannot
+// be determined (in which case no optional maintenance is done).
+func gitVersion() version {
+	gitVersionOnce.Do(func() {
+		out, err := exec.Command("git", "version").Output()
+		if err != nil {
+			log15.Warn("failed to determine git version", "error", err)
+			return
+		}
+		gitVersionValue = parseGitVersion(out)
+	})
+	return gitVersionValue
+}
+
+func parseGitVersion(out []byte) version {
+	m := gitVersionRe.FindSubmatch(bytes.TrimSpace(out))
+	if m == nil {
+		return nil
+	}
+	var v version
+	for _, s := range m[1:] {
+		if len(s) == 0 {
+			break
+		}
+		n, _ := strconv.Atoi(string(s))
+		v = append(v, n)
+	}
+	return v
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+)
+
+func TestMaintainRepo(t *testing.T) {
+	root, cleanup := tmpDir(t)
+	defer cleanup()
+	run := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = append(os.Environ(),
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		)
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s\n%s", name, strings.Join(arg, " "), err, b)
+		}
+		return strings.TrimSpace(string(b))
+	}
+
+	remote := filepath.Join(root, "remote")
+	gitDir := filepath.Join(root, "repos", "example.com/foo/bar", ".git")
+	if err := os.MkdirAll(remote, 0700); err != nil {
+		t.Fatal(err)
+	}
+	run(remote, "git", "init", ".")
+	run(remote, "git", "commit", "--allow-empty", "-m", "a")
+	run(root, "git", "clone", "--mirror", remote, gitDir)
+
+	due, err := maintenanceDue(gitDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !due {
+		t.Fatal("expected maintenance to be due for a repo that was never maintained")
+	}
+
+	s := &Server{ReposDir: filepath.Join(root, "repos"), cloneLimiter: mutablelimiter.New(1)}
+	if err := s.maintainRepo(context.Background(), "example.com/foo/bar", gitDir); err != nil {
+		t.Fatal(err)
+	}
+	if gitVersion().atLeast(2, 24) {
+		if _, err := os.Stat(filepath.Join(gitDir, "objects", "info", "commit-graphs", "commit-graph-chain")); err != nil {
+			t.Errorf("expected commit-graph to be written: %s", err)
+		}
+	}
+	if due, err := maintenanceDue(gitDir); err != nil || due {
+		t.Errorf("got due %v (error %v) right after maintenance, want false", due, err)
+	}
+
+	// Fetching makes maintenance due again.
+	run(remote, "git", "commit", "--allow-empty", "-m", "b")
+	run(gitDir, "git", "fetch", "origin")
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(gitDir, "FETCH_HEAD"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := maintenanceDue(gitDir); err != nil || !due {
+		t.Errorf("got due %v (error %v) after fetch, want true", due, err)
+	}
+}
+
+func TestParseGitVersion(t *testing.T) {
+	tests := map[string]version{
+		"git version 2.39.5\n":                  {2, 39, 5},
+		"git version 2.20.1 (Apple Git-117)":    {2, 20, 1},
+		"git version 2.37.1.windows.1":          {2, 37, 1},
+		"git version 3.0":                       {3, 0},
+		"not git":                               nil,
+		"git version 2.18.0.rc1.1.g1234567\n\n": {2, 18, 0},
+	}
+	for in, want := range tests {
+		if got := parseGitVersion([]byte(in)); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseGitVersion(%q) = %v, want %v", in, got, want)
+		}
+	}
+
+	if v := (version{2, 24, 0}); !v.atLeast(2, 24) || !v.atLeast(1, 30) || v.atLeast(2, 25) || v.atLeast(3, 0) {
+		t.Errorf("unexpected atLeast results for %v", v)
+	}
+	if (version(nil)).atLeast(0, 0) {
+		t.Error("unknown version should not be at least 0.0")
+	}
+}
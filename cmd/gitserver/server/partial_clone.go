@@ -0,0 +1,142 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+// cloneFilter returns the partial clone filter (such as "blob:none") to
+// clone repo from remoteURL with, or "" for a full clone. It comes from the
+// "gitCloneFilters" site configuration property, or the "gitCloneFilter"
+// property of the "repos.list" entry or code host connection of repo.
+func cloneFilter(repo api.RepoURI, remoteURL string) string {
+	return cloneFilterFromConfig(conf.Get(), repo, remoteURL)
+}
+
+func cloneFilterFromConfig(c *schema.SiteConfiguration, repo api.RepoURI, remoteURL string) string {
+	repo = protocol.NormalizeRepo(repo)
+	for name, filter := range c.GitCloneFilters {
+		if protocol.NormalizeRepo(api.RepoURI(name)) == repo {
+			return filter
+		}
+	}
+	for _, r := range c.ReposList {
+		if r.GitCloneFilter != "" && protocol.NormalizeRepo(api.RepoURI(r.Path)) == repo {
+			return r.GitCloneFilter
+		}
+	}
+	for _, g := range c.Gitolite {
+		if g.GitCloneFilter != "" && strings.HasPrefix(string(repo), string(protocol.NormalizeRepo(api.RepoURI(g.Prefix)))) {
+			return g.GitCloneFilter
+		}
+	}
+
+	// Repositories of other code hosts are matched by the host of their
+	// remote URL, since their names may not contain it.
+	host := remoteHost(remoteURL)
+	if host == "" {
+		return ""
+	}
+	matches := func(connURL, filter string) bool {
+		return filter != "" && remoteHost(connURL) == host
+	}
+	for _, c := range c.Github {
+		if matches(c.Url, c.GitCloneFilter) {
+			return c.GitCloneFilter
+		}
+	}
+	for _, c := range c.Gitlab {
+		if matches(c.Url, c.GitCloneFilter) {
+			return c.GitCloneFilter
+		}
+	}
+	for _, c := range c.BitbucketServer {
+		if matches(c.Url, c.GitCloneFilter) {
+			return c.GitCloneFilter
+		}
+	}
+	return ""
+}
+
+// cloneFiltersConfigured reports whether c configures a partial clone filter
+// for any repository.
+func cloneFiltersConfigured(c *schema.SiteConfiguration) bool {
+	if len(c.GitCloneFilters) > 0 {
+		return true
+	}
+	for _, r := range c.ReposList {
+		if r.GitCloneFilter != "" {
+			return true
+		}
+	}
+	for _, g := range c.Gitolite {
+		if g.GitCloneFilter != "" {
+			return true
+		}
+	}
+	for _, c := range c.Github {
+		if c.GitCloneFilter != "" {
+			return true
+		}
+	}
+	for _, c := range c.Gitlab {
+		if c.GitCloneFilter != "" {
+			return true
+		}
+	}
+	for _, c := range c.BitbucketServer {
+		if c.GitCloneFilter != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// scpLikeURL matches remote URLs such as "git@example.com:foo/bar.git".
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):`)
+
+// remoteHost returns the lowercase host name of the Git remote URL rawurl,
+// or "" if it has none.
+func remoteHost(rawurl string) string {
+	if u, err := url.Parse(rawurl); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	if strings.Contains(rawurl, "://") {
+		return ""
+	}
+	if m := scpLikeURL.FindStringSubmatch(rawurl); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// partialCloneFilterConfig matches the partial clone filter in a Git config
+// file.
+var partialCloneFilterConfig = regexp.MustCompile(`(?im)^\s*partialclonefilter\s*=\s*(\S+)\s*$`)
+
+// repoCloneFilter returns the partial clone filter the repository in dir
+// was cloned with, or "" if it is a full clone. Missing objects of a partial
+// clone are fetched from its remote when a command needs them.
+func repoCloneFilter(dir string) string {
+	// Read the config file instead of running git config, since this is
+	// called for every exec request.
+	b, err := ioutil.ReadFile(filepath.Join(dir, ".git", "config"))
+	if err != nil {
+		b, err = ioutil.ReadFile(filepath.Join(dir, "config"))
+		if err != nil {
+			return ""
+		}
+	}
+	if m := partialCloneFilterConfig.FindSubmatch(b); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
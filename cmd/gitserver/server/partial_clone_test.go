@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/mutablelimiter"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestCloneFilterFromConfig(t *testing.T) {
+	c := &schema.SiteConfiguration{
+		GitCloneFilters: map[string]string{"github.com/foo/Huge": "tree:0"},
+		ReposList:       []*schema.Repository{{Path: "example.com/a", GitCloneFilter: "blob:none"}},
+		Gitolite:        []*schema.GitoliteConnection{{Prefix: "gitolite.example.com/", Host: "git@gitolite.example.com", GitCloneFilter: "blob:none"}},
+		Github: []*schema.GitHubConnection{
+			{Url: "https://github.com", GitCloneFilter: "blob:none"},
+			{Url: "https://ghe.example.com"},
+		},
+		BitbucketServer: []*schema.BitbucketServerConnection{{Url: "https://bitbucket.example.com:8443", GitCloneFilter: "tree:0"}},
+	}
+	tests := []struct {
+		repo      api.RepoURI
+		remoteURL string
+		want      string
+	}{
+		{"github.com/foo/huge", "https://github.com/foo/huge", "tree:0"},
+		{"github.com/foo/bar", "https://token@github.com/foo/bar", "blob:none"},
+		{"github.com/foo/bar", "git@github.com:foo/bar.git", "blob:none"},
+		{"ghe.example.com/foo/bar", "https://ghe.example.com/foo/bar", ""},
+		{"example.com/a", "https://example.com/a", "blob:none"},
+		{"example.com/b", "https://example.com/b", ""},
+		{"gitolite.example.com/x", "git@gitolite.example.com:x", "blob:none"},
+		{"bitbucket/x", "ssh://git@bitbucket.example.com:7999/x.git", "tree:0"},
+		{"other/x", "/local/path", ""},
+	}
+	for _, test := range tests {
+		if got := cloneFilterFromConfig(c, test.repo, test.remoteURL); got != test.want {
+			t.Errorf("%s (%s): got %q, want %q", test.repo, test.remoteURL, got, test.want)
+		}
+	}
+}
+
+func TestCloneRepo_partial(t *testing.T) {
+	remote, cleanup1 := tmpDir(t)
+	defer cleanup1()
+	run := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = append(os.Environ(),
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		)
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s\n%s", name, strings.Join(arg, " "), err, b)
+		}
+		return strings.TrimSpace(string(b))
+	}
+	run(remote, "git", "init", ".")
+	run(remote, "git", "config", "uploadpack.allowFilter", "true")
+	run(remote, "sh", "-c", "echo hello world > hello.txt")
+	run(remote, "git", "add", "hello.txt")
+	run(remote, "git", "commit", "-m", "hello")
+	remoteURL := "file://" + remote
+
+	conf.Mock(&schema.SiteConfiguration{GitCloneFilters: map[string]string{"example.com/foo/bar": "blob:none"}})
+	defer conf.Mock(nil)
+
+	reposDir, cleanup2 := tmpDir(t)
+	defer cleanup2()
+	s := &Server{
+		ReposDir:         reposDir,
+		ctx:              context.Background(),
+		locker:           &RepositoryLocker{},
+		cloneLimiter:     mutablelimiter.New(1),
+		cloneableLimiter: mutablelimiter.New(1),
+	}
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", remoteURL, &cloneOptions{Block: true}); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(reposDir, "example.com/foo/bar")
+	if got := repoCloneFilter(dir); got != "blob:none" {
+		t.Fatalf("got clone filter %q, want blob:none", got)
+	}
+
+	// The clone has no blobs, which are fetched when needed.
+	if out := run(dir, "git", "rev-list", "--objects", "--all", "--missing=print"); !strings.Contains(out, "?") {
+		t.Errorf("expected missing objects in partial clone, got %q", out)
+	}
+	if got := run(dir, "git", "show", "HEAD:hello.txt"); got != "hello world" {
+		t.Errorf("got %q, want the contents of hello.txt", got)
+	}
+
+	// Fetches keep using the filter.
+	run(remote, "sh", "-c", "echo bye > bye.txt")
+	run(remote, "git", "add", "bye.txt")
+	run(remote, "git", "commit", "-m", "bye")
+	if err := s.doRepoUpdate2("example.com/foo/bar", remoteURL); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := run(dir, "git", "rev-parse", "HEAD"), run(remote, "git", "rev-parse", "HEAD"); got != want {
+		t.Errorf("got HEAD %s after fetch, want %s", got, want)
+	}
+	if out := run(dir, "git", "rev-list", "--objects", "--missing=print", "HEAD^..HEAD"); !strings.Contains(out, "?") {
+		t.Errorf("expected fetch to omit blobs, got %q", out)
+	}
+
+	// Once the filter is removed, the janitor reclones the repository in full.
+	conf.Mock(&schema.SiteConfiguration{})
+	if filter := cloneFilter("example.com/foo/bar", remoteURL); filter != "" {
+		t.Fatalf("got filter %q after disabling it", filter)
+	}
+	if _, err := s.cloneRepo(context.Background(), "example.com/foo/bar", remoteURL, &cloneOptions{Block: true, Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := repoCloneFilter(dir); got != "" {
+		t.Errorf("got clone filter %q after full reclone", got)
+	}
+}
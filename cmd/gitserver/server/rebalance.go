@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// rebalanceConcurrency is the maximum number of repositories copied from
+// other gitservers at once by Rebalance.
+const rebalanceConcurrency = 5
+
+var reposRebalanced = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "src",
+	Subsystem: "gitserver",
+	Name:      "repos_rebalanced",
+	Help:      "number of repos copied from another gitserver, or removed after they were copied to another gitserver, when rebalancing",
+}, []string{"action"})
+
+func init() {
+	prometheus.MustRegister(reposRebalanced)
+}
+
+// Rebalance moves repositories between gitservers after the list of
+// gitservers (s.Addrs) changes. Each repository is stored by the gitserver
+// that gitserver.AddrForRepo picks for it, so adding or removing a gitserver
+// changes where many repositories are stored.
+//
+// Rebalance copies the repositories that s should store but that are cloned
+// on other gitservers (s.Addrs and s.RebalanceFrom) from them, which is much
+// faster than cloning them from their code host. It removes the repositories
+// that s should no longer store once the gitserver that should store them has
+// them. It must be run periodically on every gitserver until all repositories
+// have moved.
+//
+// It returns the number of repositories it copied or removed. Since each run
+// lists the repositories on all other gitservers, callers should run it less
+// often after runs that moved nothing.
+func (s *Server) Rebalance() (moved int) {
+	ctx, cancel := s.serverContext()
+	defer cancel()
+	moved, err := s.rebalance(ctx)
+	if err != nil {
+		log15.Error("failed to rebalance repositories", "error", err)
+	}
+	return moved
+}
+
+func (s *Server) rebalance(ctx context.Context) (moved int, err error) {
+	if s.Addr == "" || len(s.Addrs) == 0 {
+		return 0, errors.New("rebalancing requires the address of this gitserver and of all gitservers")
+	}
+
+	local, err := s.listCloned(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to list cloned repositories")
+	}
+	have := make(map[api.RepoURI]bool, len(local))
+	for _, repo := range local {
+		have[protocol.NormalizeRepo(api.RepoURI(repo))] = true
+	}
+
+	// cloned maps the address of each other gitserver that we could list
+	// to the repositories cloned on it.
+	cloned := map[string]map[api.RepoURI]bool{}
+	for _, addr := range append(append([]string{}, s.Addrs...), s.RebalanceFrom...) {
+		if addr == s.Addr || cloned[addr] != nil {
+			continue
+		}
+		repos, err := gitserver.DefaultClient.ListClonedOn(ctx, addr)
+		if err != nil {
+			// The gitserver may be down, so only skip it. Its
+			// repositories are copied in a later run.
+			log15.Warn("failed to list repositories on gitserver", "addr", addr, "error", err)
+			continue
+		}
+		cloned[addr] = make(map[api.RepoURI]bool, len(repos))
+		for _, repo := range repos {
+			cloned[addr][protocol.NormalizeRepo(api.RepoURI(repo))] = true
+		}
+	}
+
+	// Copy the repositories we should store but don't have.
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		sem    = make(chan struct{}, rebalanceConcurrency)
+		copied = map[api.RepoURI]bool{}
+	)
+	for addr, repos := range cloned {
+		for repo := range repos {
+			if have[repo] || copied[repo] || gitserver.AddrForRepo(repo, s.Addrs) != s.Addr {
+				continue
+			}
+			copied[repo] = true
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(addr string, repo api.RepoURI) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if err := s.copyRepo(ctx, addr, repo); err != nil {
+					log15.Warn("failed to copy repository from gitserver", "repo", repo, "addr", addr, "error", err)
+					return
+				}
+				log15.Info("copied repository from gitserver", "repo", repo, "addr", addr)
+				reposRebalanced.WithLabelValues("copied").Inc()
+				mu.Lock()
+				moved++
+				mu.Unlock()
+			}(addr, repo)
+		}
+	}
+	wg.Wait()
+
+	// Remove the repositories we should no longer store once the gitserver
+	// that should store them has them.
+	for repo := range have {
+		owner := gitserver.AddrForRepo(repo, s.Addrs)
+		if owner == s.Addr || !cloned[owner][repo] {
+			continue
+		}
+		if err := s.deleteRepo(repo); err != nil {
+			log15.Warn("failed to remove repository moved to another gitserver", "repo", repo, "addr", owner, "error", err)
+			continue
+		}
+		log15.Info("removed repository moved to another gitserver", "repo", repo, "addr", owner)
+		reposRebalanced.WithLabelValues("removed").Inc()
+		moved++
+	}
+	return moved, ctx.Err()
+}
+
+// copyRepo clones repo from the gitserver at addr, with the same remote URL.
+func (s *Server) copyRepo(ctx context.Context, addr string, repo api.RepoURI) error {
+	info, err := gitserver.DefaultClient.RepoInfoOn(ctx, addr, repo)
+	if err != nil {
+		return err
+	}
+	if !info.Cloned || info.URL == "" {
+		return errors.New("repository is not cloned or has no remote URL")
+	}
+	_, err = s.cloneRepo(ctx, repo, "http://"+addr+"/git/"+string(repo), &cloneOptions{Block: true, RemoteURL: info.URL})
+	return err
+}
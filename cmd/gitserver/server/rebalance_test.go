@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+func TestRebalance(t *testing.T) {
+	remote, cleanup := tmpDir(t)
+	defer cleanup()
+	run := func(dir, name string, arg ...string) string {
+		t.Helper()
+		c := exec.Command(name, arg...)
+		c.Dir = dir
+		c.Env = append(os.Environ(),
+			"GIT_COMMITTER_NAME=a",
+			"GIT_COMMITTER_EMAIL=a@a.com",
+			"GIT_AUTHOR_NAME=a",
+			"GIT_AUTHOR_EMAIL=a@a.com",
+		)
+		b, err := c.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s %s failed: %s\n%s", name, strings.Join(arg, " "), err, b)
+		}
+		return strings.TrimSpace(string(b))
+	}
+	run(remote, "git", "init", ".")
+	run(remote, "sh", "-c", "echo hello world > hello.txt")
+	run(remote, "git", "add", "hello.txt")
+	run(remote, "git", "commit", "-m", "hello")
+	wantCommit := run(remote, "git", "rev-parse", "HEAD")
+
+	// Start with a single gitserver a storing all repositories, then add b.
+	newServer := func() (*Server, string, func()) {
+		reposDir, cleanup := tmpDir(t)
+		s := &Server{ReposDir: reposDir}
+		ts := httptest.NewServer(s.Handler())
+		return s, strings.TrimPrefix(ts.URL, "http://"), func() {
+			ts.Close()
+			s.Stop()
+			cleanup()
+		}
+	}
+	a, addrA, cleanupA := newServer()
+	defer cleanupA()
+	b, addrB, cleanupB := newServer()
+	defer cleanupB()
+	for _, s := range []*Server{a, b} {
+		s.Addrs = []string{addrA, addrB}
+	}
+	a.Addr, b.Addr = addrA, addrB
+
+	// Find a repository that stays on a, and one that moves to b.
+	var repoA, repoB api.RepoURI
+	for i := 0; repoA == "" || repoB == ""; i++ {
+		repo := api.RepoURI(fmt.Sprintf("example.com/repo%d", i))
+		if gitserver.AddrForRepo(repo, a.Addrs) == addrA {
+			repoA = repo
+		} else {
+			repoB = repo
+		}
+	}
+	for _, repo := range []api.RepoURI{repoA, repoB} {
+		run(a.ReposDir, "git", "clone", "--mirror", remote, filepath.Join(string(repo), ".git"))
+	}
+
+	ctx := context.Background()
+	if moved, err := b.rebalance(ctx); err != nil || moved != 1 {
+		t.Fatalf("got %d repositories moved to b (error %v), want 1", moved, err)
+	}
+	if repoCloned(filepath.Join(b.ReposDir, string(repoA))) {
+		t.Errorf("%s was copied to b", repoA)
+	}
+	dirB := filepath.Join(b.ReposDir, string(repoB))
+	if got := run(dirB, "git", "rev-parse", "HEAD"); got != wantCommit {
+		t.Errorf("got HEAD %s on b, want %s", got, wantCommit)
+	}
+	if got := run(dirB, "git", "config", "remote.origin.url"); got != remote {
+		t.Errorf("got remote URL %q on b, want %q", got, remote)
+	}
+
+	// a removes the repository that moved, once b has it.
+	if moved, err := a.rebalance(ctx); err != nil || moved != 1 {
+		t.Fatalf("got %d repositories moved from a (error %v), want 1", moved, err)
+	}
+	if !repoCloned(filepath.Join(a.ReposDir, string(repoA))) {
+		t.Errorf("%s was removed from a", repoA)
+	}
+	if repoCloned(filepath.Join(a.ReposDir, string(repoB))) {
+		t.Errorf("%s was not removed from a", repoB)
+	}
+	if !repoCloned(dirB) {
+		t.Errorf("%s was removed from b", repoB)
+	}
+
+	// Once all repositories have moved, nothing is moved.
+	for _, s := range []*Server{a, b} {
+		if moved, err := s.rebalance(ctx); err != nil || moved != 0 {
+			t.Errorf("got %d repositories moved after rebalancing (error %v), want 0", moved, err)
+		}
+	}
+}
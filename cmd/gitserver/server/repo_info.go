@@ -53,6 +53,20 @@ func (s *Server) handleRepoInfo(w http.ResponseWriter, r *http.Request) {
 		} else {
 			resp.CloneTime = &cloneTime
 		}
+
+		if atime, err := repoLastAccessed(dir); err != nil {
+			log15.Warn("error computing last-accessed date", "repo", req.Repo, "err", err)
+		} else {
+			resp.LastAccessed = &atime
+		}
+
+		if req.IncludeSize {
+			if size, err := repoSize(dir); err != nil {
+				log15.Warn("error computing repo size", "repo", req.Repo, "err", err)
+			} else {
+				resp.Size = size
+			}
+		}
 	}
 
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -10,7 +10,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
 )
 
@@ -22,14 +21,13 @@ func TestServer_handleRepoInfo(t *testing.T) {
 		t.Fatal("could not acquire lock")
 	}
 
-	getRepoInfo := func(t *testing.T, repo api.RepoURI) (resp protocol.RepoInfoResponse) {
+	getRepoInfo := func(t *testing.T, req protocol.RepoInfoRequest) (resp protocol.RepoInfoResponse) {
 		rr := httptest.NewRecorder()
-		body, err := json.Marshal(protocol.RepoInfoRequest{Repo: repo})
+		body, err := json.Marshal(req)
 		if err != nil {
 			t.Fatal(err)
 		}
-		req := httptest.NewRequest("GET", "/repo", bytes.NewReader(body))
-		h.ServeHTTP(rr, req)
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/repo", bytes.NewReader(body)))
 		if rr.Code != http.StatusOK {
 			t.Fatalf("http non-200 status %d", rr.Code)
 		}
@@ -44,7 +42,7 @@ func TestServer_handleRepoInfo(t *testing.T) {
 		repoCloned = func(dir string) bool { return false }
 		defer func() { repoCloned = origRepoCloned }()
 
-		if got, want := getRepoInfo(t, "x"), (protocol.RepoInfoResponse{}); !reflect.DeepEqual(got, want) {
+		if got, want := getRepoInfo(t, protocol.RepoInfoRequest{Repo: "x"}), (protocol.RepoInfoResponse{}); !reflect.DeepEqual(got, want) {
 			t.Errorf("got %+v, want %+v", got, want)
 		}
 	})
@@ -54,7 +52,7 @@ func TestServer_handleRepoInfo(t *testing.T) {
 		repoCloned = func(dir string) bool { return false }
 		defer func() { repoCloned = origRepoCloned }()
 
-		if got, want := getRepoInfo(t, "a"), (protocol.RepoInfoResponse{CloneInProgress: true, CloneProgress: "test status"}); !reflect.DeepEqual(got, want) {
+		if got, want := getRepoInfo(t, protocol.RepoInfoRequest{Repo: "a"}), (protocol.RepoInfoResponse{CloneInProgress: true, CloneProgress: "test status"}); !reflect.DeepEqual(got, want) {
 			t.Errorf("got %+v, want %+v", got, want)
 		}
 	})
@@ -73,7 +71,23 @@ func TestServer_handleRepoInfo(t *testing.T) {
 		repoRemoteURL = func(context.Context, string) (string, error) { return "u", nil }
 		defer func() { repoRemoteURL = origRepoRemoteURL }()
 
-		if got, want := getRepoInfo(t, "x"), (protocol.RepoInfoResponse{Cloned: true, LastFetched: &lastFetched, URL: "u"}); !reflect.DeepEqual(got, want) {
+		lastAccessed := time.Date(1988, 1, 3, 3, 4, 5, 6, time.UTC)
+		origRepoLastAccessed := repoLastAccessed
+		repoLastAccessed = func(dir string) (time.Time, error) { return lastAccessed, nil }
+		defer func() { repoLastAccessed = origRepoLastAccessed }()
+
+		origRepoSize := repoSize
+		repoSize = func(dir string) (int64, error) { return 1234, nil }
+		defer func() { repoSize = origRepoSize }()
+
+		want := protocol.RepoInfoResponse{Cloned: true, LastFetched: &lastFetched, LastAccessed: &lastAccessed, URL: "u"}
+		if got := getRepoInfo(t, protocol.RepoInfoRequest{Repo: "x"}); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+
+		// The size is only computed when asked for.
+		want.Size = 1234
+		if got := getRepoInfo(t, protocol.RepoInfoRequest{Repo: "x", IncludeSize: true}); !reflect.DeepEqual(got, want) {
 			t.Errorf("got %+v, want %+v", got, want)
 		}
 	})
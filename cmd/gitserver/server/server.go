@@ -111,6 +111,24 @@ type Server struct {
 	// Janitor job runs.
 	DeleteStaleRepositories bool
 
+	// DesiredPercentFree is the percentage of the disk containing ReposDir
+	// that EvictRepos keeps free by removing the least recently used
+	// repositories. If zero, repositories are never evicted.
+	DesiredPercentFree int
+
+	// Addr is the address of this gitserver in Addrs. It is only needed to
+	// rebalance repositories, see Rebalance.
+	Addr string
+
+	// Addrs are the addresses of all gitservers. Each repository is stored
+	// by one of them, see gitserver.AddrForRepo.
+	Addrs []string
+
+	// RebalanceFrom are the addresses of other gitservers (such as
+	// gitservers being removed) that Rebalance copies repositories from, in
+	// addition to Addrs.
+	RebalanceFrom []string
+
 	// skipCloneForTests is set by tests to avoid clones.
 	skipCloneForTests bool
 
@@ -230,6 +248,7 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/enqueue-repo-update", s.handleEnqueueRepoUpdate)
 	mux.HandleFunc("/repo-update", s.handleRepoUpdate)
 	mux.HandleFunc("/upload-pack", s.handleUploadPack)
+	mux.HandleFunc("/git/", s.handleGit)
 	mux.HandleFunc("/getGitolitePhabricatorMetadata", s.handleGetGitolitePhabricatorMetadata)
 	mux.HandleFunc("/create-commit-from-patch", s.handleCreateCommitFromPatch)
 	return mux
@@ -640,6 +659,12 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The last access time decides which repositories are evicted first when
+	// the disk is almost full (see EvictRepos).
+	if err := setLastAccessed(dir); err != nil {
+		log15.Warn("failed to set last accessed time", "repo", req.Repo, "error", err)
+	}
+
 	didUpdate := s.ensureRevision(ctx, req.Repo, req.URL, req.EnsureRevision, dir)
 	if didUpdate {
 		ensureRevisionStatus = "fetched"
@@ -674,6 +699,15 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 	cmd.Dir = dir
 	cmd.Stdout = stdoutW
 	cmd.Stderr = stderrW
+	if repoCloneFilter(dir) != "" {
+		// git fetches the objects missing from a partial clone from its
+		// remote when the command needs them.
+		cmd.Env = os.Environ()
+		configureRemoteOpts(cmd)
+		if err := withRemoteToken(ctx, cmd, req.Repo, dir); err != nil {
+			log15.Warn("Unable to authenticate to the remote of a partial clone", "repo", req.Repo, "error", err)
+		}
+	}
 
 	var err error
 	err, exitStatus = runCommand(ctx, cmd)
@@ -728,6 +762,11 @@ type cloneOptions struct {
 
 	// Overwrite will overwrite the existing clone.
 	Overwrite bool
+
+	// RemoteURL, if set, is the remote URL of the clone, if it differs from
+	// the URL it is cloned from. It is used to copy a repository from
+	// another gitserver.
+	RemoteURL string
 }
 
 // cloneRepo issues a git clone command for the given repo. It is
@@ -813,8 +852,20 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoURI, url string, op
 		defer os.RemoveAll(tmpPath)
 		tmpPath = filepath.Join(tmpPath, ".git")
 
-		cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", "--progress", url, tmpPath)
-		log15.Info("cloning repo", "repo", repo, "tmp", tmpPath, "dst", dstPath)
+		args := []string{"-c", "protocol.version=2", "clone", "--mirror", "--progress"}
+		// A short-lived token in the URL is not stored in the clone's remote.
+		cloneURL, token := splitShortLivedToken(url)
+		remoteURL := cloneURL
+		if opts != nil && opts.RemoteURL != "" {
+			remoteURL, _ = splitShortLivedToken(opts.RemoteURL)
+		}
+		filter := cloneFilter(repo, remoteURL)
+		if filter != "" {
+			args = append(args, "--filter="+filter)
+		}
+		cmd := exec.CommandContext(ctx, "git", append(args, cloneURL, tmpPath)...)
+		withToken(cmd, token)
+		log15.Info("cloning repo", "repo", repo, "tmp", tmpPath, "dst", dstPath, "filter", filter)
 
 		pr, pw := io.Pipe()
 		defer pw.Close()
@@ -834,6 +885,14 @@ func (s *Server) cloneRepo(ctx context.Context, repo api.RepoURI, url string, op
 			return err
 		}
 
+		if opts != nil && opts.RemoteURL != "" {
+			cmd := exec.CommandContext(ctx, "git", "remote", "set-url", "origin", remoteURL)
+			cmd.Dir = tmpPath
+			if output, err := cmd.CombinedOutput(); err != nil {
+				return errors.Wrapf(err, "failed to set remote URL. Output: %s", string(output))
+			}
+		}
+
 		if overwrite {
 			// remove the current repo by putting it into our temporary directory
 			err := os.Rename(dstPath, filepath.Join(filepath.Dir(tmpPath), "old"))
@@ -1268,8 +1327,13 @@ func (s *Server) doRepoUpdate2(repo api.RepoURI, url string) error {
 			log15.Error("Failed to determine Git remote URL", "repo", repo, "error", err)
 			return errors.Wrap(err, "failed to determine Git remote URL")
 		}
+		if url, err = currentRemoteURL(ctx, repo, url); err != nil {
+			log15.Error("Failed to determine current Git remote URL", "repo", repo, "error", err)
+			return errors.Wrap(err, "failed to determine current Git remote URL")
+		}
 		urlIsGitRemote = true
 	}
+	url, token := splitShortLivedToken(url)
 
 	// url is now guaranteed to != "". Store the URL as the remote origin. If
 	// a future call does not set the URL, we can fallback to this one. This
@@ -1292,8 +1356,15 @@ func (s *Server) doRepoUpdate2(repo api.RepoURI, url string) error {
 		}
 	}
 
-	cmd := exec.CommandContext(ctx, "git", "fetch", "--prune", url, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*")
+	// A partial clone must be fetched from its remote (instead of url, which
+	// is the URL of the remote), so that the fetch uses its filter.
+	remote := url
+	if repoCloneFilter(dir) != "" {
+		remote = "origin"
+	}
+	cmd := exec.CommandContext(ctx, "git", "-c", "protocol.version=2", "fetch", "--prune", remote, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*", "+refs/pull/*:refs/pull/*")
 	cmd.Dir = dir
+	withToken(cmd, token)
 
 	// drop temporary pack files after a fetch. this function won't
 	// return until this fetch has completed or definitely-failed,
@@ -1316,6 +1387,7 @@ func (s *Server) doRepoUpdate2(repo api.RepoURI, url string) error {
 	// try to fetch HEAD from origin
 	cmd = exec.CommandContext(ctx, "git", "remote", "show", url)
 	cmd.Dir = path.Join(s.ReposDir, string(repo))
+	withToken(cmd, token)
 	output, err := s.runWithRemoteOpts(ctx, cmd, nil)
 	if err != nil {
 		log15.Error("Failed to fetch remote info", "repo", repo, "error", err, "output", string(output))
@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,22 +17,16 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+	repoupdaterprotocol "github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
 	log15 "gopkg.in/inconshreveable/log15.v2"
 )
 
 // runWithRemoteOpts runs the command after applying the remote options.
 // If progress is not nil, all output is written to it in a separate goroutine.
 func (s *Server) runWithRemoteOpts(ctx context.Context, cmd *exec.Cmd, progress io.Writer) ([]byte, error) {
-	cmd.Env = append(cmd.Env, "GIT_ASKPASS=true") // disable password prompt
-
-	// Suppress asking to add SSH host key to known_hosts (which will hang because
-	// the command is non-interactive).
-	//
-	// And set a timeout to avoid indefinite hangs if the server is unreachable.
-	cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes -o ConnectTimeout=30")
-
-	// Unset credential helper because the command is non-interactive.
-	cmd.Args = append(cmd.Args[:1], append([]string{"-c", "credential.helper="}, cmd.Args[1:]...)...)
+	configureRemoteOpts(cmd)
 
 	var b interface {
 		Bytes() []byte
@@ -61,6 +56,90 @@ func (s *Server) runWithRemoteOpts(ctx context.Context, cmd *exec.Cmd, progress
 	return b.Bytes(), err
 }
 
+// configureRemoteOpts configures the git command cmd, which contacts a
+// remote, to run non-interactively.
+func configureRemoteOpts(cmd *exec.Cmd) {
+	cmd.Env = append(cmd.Env, "GIT_ASKPASS=true") // disable password prompt
+
+	// Suppress asking to add SSH host key to known_hosts (which will hang because
+	// the command is non-interactive).
+	//
+	// And set a timeout to avoid indefinite hangs if the server is unreachable.
+	cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes -o ConnectTimeout=30")
+
+	// Unset credential helper because the command is non-interactive.
+	cmd.Args = append(cmd.Args[:1], append([]string{"-c", "credential.helper="}, cmd.Args[1:]...)...)
+}
+
+// shortLivedTokenUser is the username in Git remote URLs whose password is a
+// short-lived token, such as a GitHub App installation token (which expires
+// after an hour).
+const shortLivedTokenUser = "x-access-token"
+
+// splitShortLivedToken returns remoteURL without its password if the password
+// is a short-lived token, and the token. Such tokens are not stored in the
+// remote of a repository, where they would expire, but passed to each git
+// command that uses them (see withToken).
+func splitShortLivedToken(remoteURL string) (string, string) {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.User == nil || u.User.Username() != shortLivedTokenUser {
+		return remoteURL, ""
+	}
+	token, _ := u.User.Password()
+	u.User = url.User(shortLivedTokenUser)
+	return u.String(), token
+}
+
+// withToken configures the git command cmd to authenticate with token, if it
+// is nonempty. The token is passed to a credential helper in the environment,
+// so it is not stored in the repository or shown in the command line.
+func withToken(cmd *exec.Cmd, token string) {
+	if token == "" {
+		return
+	}
+	cmd.Args = append(cmd.Args[:1], append([]string{"-c", `credential.helper=!f() { echo "password=$GIT_REMOTE_TOKEN"; }; f`}, cmd.Args[1:]...)...)
+	cmd.Env = append(cmd.Env, "GIT_REMOTE_TOKEN="+token)
+}
+
+// currentRemoteURL returns remoteURL, a remote URL of repo stored by
+// gitserver, with a current short-lived token if it authenticates with one.
+// Stored remotes never contain these tokens (see splitShortLivedToken), so
+// the URL is looked up from repo-updater, which mints them.
+func currentRemoteURL(ctx context.Context, repo api.RepoURI, remoteURL string) (string, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.User == nil || u.User.Username() != shortLivedTokenUser {
+		return remoteURL, nil
+	}
+	if _, ok := u.User.Password(); ok {
+		return remoteURL, nil
+	}
+	result, err := repoupdater.DefaultClient.RepoLookup(ctx, repoupdaterprotocol.RepoLookupArgs{Repo: repo})
+	if err != nil {
+		return "", err
+	}
+	if result.Repo == nil || result.Repo.VCS.URL == "" {
+		return "", fmt.Errorf("no remote URL for repo %s from repo-updater (%s)", repo, result)
+	}
+	return result.Repo.VCS.URL, nil
+}
+
+// withRemoteToken configures the git command cmd, which may fetch from the
+// remote of repo in dir (such as to fill in a partial clone), to authenticate
+// with a current short-lived token if the remote needs one.
+func withRemoteToken(ctx context.Context, cmd *exec.Cmd, repo api.RepoURI, dir string) error {
+	remoteURL, err := repoRemoteURL(ctx, dir)
+	if err != nil {
+		return err
+	}
+	remoteURL, err = currentRemoteURL(ctx, repo, remoteURL)
+	if err != nil {
+		return err
+	}
+	_, token := splitShortLivedToken(remoteURL)
+	withToken(cmd, token)
+	return nil
+}
+
 // repoCloned checks if dir or `${dir}/.git` is a valid GIT_DIR.
 var repoCloned = func(dir string) bool {
 	if _, err := os.Stat(filepath.Join(dir, "HEAD")); !os.IsNotExist(err) {
@@ -118,6 +197,71 @@ var repoLastChanged = func(dir string) (time.Time, error) {
 	return fi.ModTime(), nil
 }
 
+// lastAccessedResolution is how often setLastAccessed updates the last access
+// time of a repository, to avoid writing to disk for every command.
+const lastAccessedResolution = time.Minute
+
+// repoLastAccessed returns the mtime of the repo's sg_last_access, which is
+// the last time a command was run on the repository (see setLastAccessed). As
+// a special case when sg_last_access is missing we return repoLastFetched(dir).
+var repoLastAccessed = func(dir string) (time.Time, error) {
+	fi, err := os.Stat(filepath.Join(dir, "sg_last_access"))
+	if os.IsNotExist(err) {
+		fi, err = os.Stat(filepath.Join(dir, ".git", "sg_last_access"))
+	}
+	if os.IsNotExist(err) {
+		return repoLastFetched(dir)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
+// setLastAccessed records that a command was run on the repository in dir by
+// updating the mtime of its sg_last_access file, unless it was updated less
+// than lastAccessedResolution ago.
+func setLastAccessed(dir string) error {
+	// Handle two different locations for GIT_DIR :'(
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); os.IsNotExist(err) {
+		dir = filepath.Join(dir, ".git")
+	}
+	accessFile := filepath.Join(dir, "sg_last_access")
+
+	now := time.Now()
+	fi, err := os.Stat(accessFile)
+	if os.IsNotExist(err) {
+		return ioutil.WriteFile(accessFile, nil, 0600)
+	}
+	if err != nil {
+		return err
+	}
+	if now.Sub(fi.ModTime()) < lastAccessedResolution {
+		return nil
+	}
+	return os.Chtimes(accessFile, now, now)
+}
+
+// repoSize returns the total size in bytes of the files of the repository in
+// dir.
+var repoSize = func(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Files can be removed by concurrent git commands.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fi.IsDir() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
 // repoRemoteURL returns the "origin" remote fetch URL for the Git repository in dir. If the repository
 // doesn't exist or the remote doesn't exist and have a fetch URL, an error is returned. If there are
 // multiple fetch URLs, only the first is returned.
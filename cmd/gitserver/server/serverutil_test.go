@@ -1,10 +1,16 @@
 package server
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater"
+	repoupdaterprotocol "github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
 )
 
 func TestProgressWriter(t *testing.T) {
@@ -188,3 +194,56 @@ func TestUpdateFileIfDifferent(t *testing.T) {
 		t.Fatal("expected update to not update file")
 	}
 }
+
+func TestSplitShortLivedToken(t *testing.T) {
+	tests := map[string][2]string{
+		"https://x-access-token:t@github.com/a/b": {"https://x-access-token@github.com/a/b", "t"},
+		"https://token@github.com/a/b":            {"https://token@github.com/a/b", ""},
+		"git@github.com:a/b.git":                  {"git@github.com:a/b.git", ""},
+	}
+	for remoteURL, want := range tests {
+		if gotURL, gotToken := splitShortLivedToken(remoteURL); gotURL != want[0] || gotToken != want[1] {
+			t.Errorf("%s: got %q, %q, want %q, %q", remoteURL, gotURL, gotToken, want[0], want[1])
+		}
+	}
+}
+
+func TestWithToken(t *testing.T) {
+	cmd := exec.Command("git", "credential", "fill")
+	withToken(cmd, "t")
+	configureRemoteOpts(cmd)
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=github.com\nusername=x-access-token\n\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s: %s", err, out)
+	}
+	if !strings.Contains(string(out), "password=t\n") {
+		t.Errorf("got credentials %q, want password t", out)
+	}
+}
+
+func TestCurrentRemoteURL(t *testing.T) {
+	repoupdater.MockRepoLookup = func(args repoupdaterprotocol.RepoLookupArgs) (*repoupdaterprotocol.RepoLookupResult, error) {
+		return &repoupdaterprotocol.RepoLookupResult{Repo: &repoupdaterprotocol.RepoInfo{
+			URI: args.Repo,
+			VCS: repoupdaterprotocol.VCSInfo{URL: "https://x-access-token:t@github.com/a/b"},
+		}}, nil
+	}
+	defer func() { repoupdater.MockRepoLookup = nil }()
+
+	tests := map[string]string{
+		"https://x-access-token@github.com/a/b":    "https://x-access-token:t@github.com/a/b",
+		"https://x-access-token:t2@github.com/a/b": "https://x-access-token:t2@github.com/a/b",
+		"https://token@github.com/a/b":             "https://token@github.com/a/b",
+		"git@github.com:a/b.git":                   "git@github.com:a/b.git",
+	}
+	for remoteURL, want := range tests {
+		got, err := currentRemoteURL(context.Background(), "github.com/a/b", remoteURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", remoteURL, got, want)
+		}
+	}
+}
@@ -2,12 +2,16 @@ package server
 
 import (
 	"compress/gzip"
+	"context"
 	"net/http"
+	"os"
 	"os/exec"
 	"path"
+	"strings"
 
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
 )
 
 func (s *Server) handleUploadPack(w http.ResponseWriter, r *http.Request) {
@@ -16,7 +20,60 @@ func (s *Server) handleUploadPack(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "repo missing", http.StatusBadRequest)
 		return
 	}
+	s.serveUploadPack(w, r, repo)
+}
+
+// handleGit serves the repositories of s over the smart HTTP git protocol
+// (read-only), so that other gitservers can clone them when rebalancing. The
+// URL of a repository is /git/{repo}.
+func (s *Server) handleGit(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/git/")
+	var (
+		repo      api.RepoURI
+		advertise bool
+	)
+	switch {
+	case r.Method == "GET" && strings.HasSuffix(p, "/info/refs"):
+		if r.URL.Query().Get("service") != "git-upload-pack" {
+			http.Error(w, "only git-upload-pack is supported", http.StatusForbidden)
+			return
+		}
+		repo, advertise = api.RepoURI(strings.TrimSuffix(p, "/info/refs")), true
+	case r.Method == "POST" && strings.HasSuffix(p, "/git-upload-pack"):
+		repo = api.RepoURI(strings.TrimSuffix(p, "/git-upload-pack"))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	repo = protocol.NormalizeRepo(repo)
+	if repo == "" || !repoCloned(path.Join(s.ReposDir, string(repo))) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !advertise {
+		s.serveUploadPack(w, r, repo)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+	w.Header().Set("Cache-Control", "no-cache")
+	// The advertisement starts with the service pkt-line and a flush-pkt.
+	if _, err := w.Write([]byte("001e# service=git-upload-pack\n0000")); err != nil {
+		return
+	}
+	cmd := s.uploadPackCommand(r.Context(), repo, "--advertise-refs")
+	cmd.Stdout = w
+	if err := cmd.Run(); err != nil {
+		// The response has started, so the client will see a truncated
+		// advertisement.
+		log15.Warn("failed to advertise refs", "repo", repo, "error", err)
+	}
+}
 
+// serveUploadPack serves a git-upload-pack request of the stateless (smart
+// HTTP) git protocol for repo.
+func (s *Server) serveUploadPack(w http.ResponseWriter, r *http.Request, repo api.RepoURI) {
 	if r.Header.Get("Content-Type") != "application/x-git-upload-pack-request" {
 		http.Error(w, "Unexpected Content-Type", http.StatusBadRequest)
 		return
@@ -34,11 +91,30 @@ func (s *Server) handleUploadPack(w http.ResponseWriter, r *http.Request) {
 	}
 	defer body.Close()
 
-	cmd := exec.CommandContext(r.Context(), "git", "upload-pack", "--stateless-rpc", ".")
-	cmd.Dir = path.Join(s.ReposDir, string(repo))
+	cmd := s.uploadPackCommand(r.Context(), repo)
 	cmd.Stdout = w
 	cmd.Stdin = body
 	if err := cmd.Run(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// uploadPackCommand returns the git upload-pack command of the stateless git
+// protocol for repo, with the extra args.
+func (s *Server) uploadPackCommand(ctx context.Context, repo api.RepoURI, args ...string) *exec.Cmd {
+	dir := path.Join(s.ReposDir, string(repo))
+	// Allow partial clones, which gitserver itself makes when rebalancing.
+	args = append([]string{"-c", "uploadpack.allowFilter=true", "upload-pack", "--stateless-rpc"}, args...)
+	cmd := exec.CommandContext(ctx, "git", append(args, ".")...)
+	cmd.Dir = dir
+	if repoCloneFilter(dir) != "" {
+		// upload-pack fetches the objects missing from a partial clone
+		// from its remote if they are requested.
+		cmd.Env = os.Environ()
+		configureRemoteOpts(cmd)
+		if err := withRemoteToken(ctx, cmd, repo, dir); err != nil {
+			log15.Warn("Unable to authenticate to the remote of a partial clone", "repo", repo, "error", err)
+		}
+	}
+	return cmd
+}
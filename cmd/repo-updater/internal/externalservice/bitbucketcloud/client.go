@@ -0,0 +1,204 @@
+package bitbucketcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/internal/pkg/metrics"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/time/rate"
+)
+
+var requestCounter = metrics.NewRequestCounter("bitbucket_cloud", "Total number of requests sent to the Bitbucket Cloud API.")
+
+// WithRequestCounter wraps the given transport with a request counter metric.
+func WithRequestCounter(transport http.RoundTripper) http.RoundTripper {
+	return requestCounter.Transport(transport, func(u *url.URL) string {
+		// API to URL mapping looks like this:
+		//
+		// 	Repo -> 2.0/repositories/%s/%s
+		// 	Repos -> 2.0/repositories or 2.0/repositories/%s
+		//
+		// We guess the category based on the number of path components after "repositories".
+		i := strings.Index(u.Path, "/repositories")
+		if i < 0 {
+			// don't return the path directly as that could introduce too much dimensionality
+			return "unknown"
+		}
+		if strings.Count(strings.Trim(u.Path[i+len("/repositories"):], "/"), "/") >= 1 {
+			return "Repo"
+		}
+		return "Repos"
+	})
+}
+
+// Client accesses Bitbucket Cloud via the REST API (version 2.0).
+type Client struct {
+	// URL is the base URL of the Bitbucket Cloud API, such as https://api.bitbucket.org/.
+	URL *url.URL
+
+	// The username and app password for accessing the API. The app password needs the
+	// Repositories: Read permission. https://bitbucket.org/account/settings/app-passwords/
+	Username, AppPassword string
+
+	// HTTPClient is the client used to access Bitbucket Cloud. To enable tracing, ensure
+	// the transport includes nethttp.Transport.
+	//
+	// To enable metrics, always wrap the Transport using WithRequestCounter.
+	HTTPClient *http.Client
+
+	// RateLimit is the self-imposed rate limiter (since Bitbucket Cloud does not report
+	// its rate limits in HTTP response headers).
+	RateLimit *rate.Limiter
+}
+
+// Repo returns the repository with the given slug in the workspace (also known as
+// team or user). The repoSlug can also be the UUID of the repository (including the
+// curly braces), in which case workspace can be "{}".
+func (c *Client) Repo(ctx context.Context, workspace, repoSlug string) (*Repo, error) {
+	u := fmt.Sprintf("2.0/repositories/%s/%s", url.PathEscape(workspace), url.PathEscape(repoSlug))
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp Repo
+	err = c.do(ctx, req, &resp)
+	return &resp, err
+}
+
+// Repos returns a page of the repositories in the workspace (also known as team or
+// user). If workspace is empty, it returns the repositories that the authenticated
+// user is a member of.
+func (c *Client) Repos(ctx context.Context, pageToken *PageToken, workspace string) ([]*Repo, *PageToken, error) {
+	var u string
+	if pageToken != nil && pageToken.Next != "" {
+		u = pageToken.Next
+	} else if workspace != "" {
+		u = fmt.Sprintf("2.0/repositories/%s%s", url.PathEscape(workspace), pageToken.Query(nil))
+	} else {
+		u = "2.0/repositories" + pageToken.Query(url.Values{"role": {"member"}})
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var resp struct {
+		PageToken
+		Values []*Repo `json:"values"`
+	}
+	err = c.do(ctx, req, &resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp.PageToken.fetched = true
+	return resp.Values, &resp.PageToken, nil
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request, result interface{}) error {
+	req.URL = c.URL.ResolveReference(req.URL)
+	if req.URL.Host != c.URL.Host {
+		// Next page URLs come from the API response, so don't send our credentials anywhere else.
+		return errors.Errorf("refusing to request %s outside of the Bitbucket Cloud API at %s", req.URL, c.URL)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if c.Username != "" || c.AppPassword != "" {
+		req.SetBasicAuth(c.Username, c.AppPassword)
+	}
+
+	req, ht := nethttp.TraceRequest(opentracing.GlobalTracer(), req,
+		nethttp.OperationName("Bitbucket Cloud"),
+		nethttp.ClientTrace(false))
+	defer ht.Finish()
+
+	if err := c.RateLimit.Wait(ctx); err != nil {
+		return err
+	}
+	resp, err := ctxhttp.Do(ctx, c.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.WithStack(&httpError{URL: req.URL, StatusCode: resp.StatusCode})
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// PageToken describes a page of a paginated Bitbucket Cloud API response. The URL
+// of the next page is Next, which is empty on the last page.
+type PageToken struct {
+	Size    int    `json:"size"`
+	Page    int    `json:"page"`
+	Pagelen int    `json:"pagelen"`
+	Next    string `json:"next"`
+
+	fetched bool // whether the token was returned by the API (and isn't for the first page)
+}
+
+func (t *PageToken) HasMore() bool {
+	if t == nil || !t.fetched {
+		return true
+	}
+	return t.Next != ""
+}
+
+// Query returns the query string to request the first page, with the parameters in v.
+func (t *PageToken) Query(v url.Values) string {
+	if t != nil && t.Pagelen != 0 {
+		if v == nil {
+			v = url.Values{}
+		}
+		v.Set("pagelen", strconv.Itoa(t.Pagelen))
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "?" + v.Encode()
+}
+
+type Repo struct {
+	UUID        string `json:"uuid"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	SCM         string `json:"scm"`
+	IsPrivate   bool   `json:"is_private"`
+	Parent      *Repo  `json:"parent"`
+	Links       struct {
+		Clone []Link `json:"clone"`
+		HTML  Link   `json:"html"`
+	} `json:"links"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Name string `json:"name,omitempty"`
+}
+
+type httpError struct {
+	StatusCode int
+	URL        *url.URL
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("unexpected %d response from Bitbucket Cloud REST API at %s", e.StatusCode, e.URL)
+}
+
+func (e *httpError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized
+}
+
+func (e *httpError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,69 @@
+package bitbucketcloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestClient_Repos(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "u" || pass != "p" {
+			t.Errorf("got credentials %q %q, want u p", user, pass)
+		}
+		switch r.URL.RequestURI() {
+		case "/2.0/repositories/myteam?pagelen=1":
+			fmt.Fprintf(w, `{"pagelen": 1, "values": [{"full_name": "myteam/a"}], "next": "%s/2.0/repositories/myteam?page=2&pagelen=1"}`, srv.URL)
+		case "/2.0/repositories/myteam?page=2&pagelen=1":
+			fmt.Fprint(w, `{"pagelen": 1, "values": [{"full_name": "myteam/b"}]}`)
+		case "/2.0/repositories?pagelen=1&role=member":
+			fmt.Fprint(w, `{"pagelen": 1, "values": [{"full_name": "u/c"}], "next": "https://example.com/2.0/repositories?page=2"}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/")
+	c := &Client{URL: u, Username: "u", AppPassword: "p", HTTPClient: http.DefaultClient, RateLimit: rate.NewLimiter(rate.Inf, 1)}
+
+	listAll := func(workspace string) (names []string, err error) {
+		page := &PageToken{Pagelen: 1}
+		for page.HasMore() {
+			var repos []*Repo
+			repos, page, err = c.Repos(context.Background(), page, workspace)
+			if err != nil {
+				return names, err
+			}
+			for _, r := range repos {
+				names = append(names, r.FullName)
+			}
+		}
+		return names, nil
+	}
+
+	names, err := listAll("myteam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"myteam/a", "myteam/b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	// The next page is on another host, so it is not requested with our credentials.
+	names, err = listAll("")
+	if err == nil {
+		t.Error("got no error for next page on another host")
+	}
+	if want := []string{"u/c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
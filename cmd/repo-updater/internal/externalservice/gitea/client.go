@@ -0,0 +1,99 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/internal/pkg/metrics"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+var requestCounter = metrics.NewRequestCounter("gitea", "Total number of requests sent to the Gitea API.")
+
+// Client is a Gitea API client.
+type Client struct {
+	baseURL    *url.URL     // base URL of the Gitea API; e.g., https://gitea.example.com/api/v1/
+	token      string       // an access token to authenticate requests, if set
+	httpClient *http.Client // the HTTP client to use
+}
+
+// NewClient creates a new Gitea API client with an optional access token to authenticate requests.
+//
+// The URL must point to the base URL of the Gitea instance, such as https://gitea.example.com.
+func NewClient(baseURL *url.URL, token string, transport http.RoundTripper) *Client {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	transport = requestCounter.Transport(transport, func(u *url.URL) string {
+		// The component of the Path after /api/v1/ (such as "repos", "repositories", "orgs",
+		// "users" or "user") mostly maps to the type of API request we are making.
+		parts := strings.Split(strings.TrimPrefix(u.Path, baseURL.Path), "/")
+		if len(parts) >= 3 && parts[0] == "api" && parts[1] == "v1" {
+			return parts[2]
+		}
+		return "unknown"
+	})
+
+	return &Client{
+		baseURL:    baseURL.ResolveReference(&url.URL{Path: path.Join(baseURL.Path, "api/v1") + "/"}),
+		token:      token,
+		httpClient: &http.Client{Transport: transport},
+	}
+}
+
+func (c *Client) do(ctx context.Context, req *http.Request, result interface{}) (err error) {
+	req.URL = c.baseURL.ResolveReference(req.URL)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
+	}
+
+	var resp *http.Response
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Gitea")
+	span.SetTag("URL", req.URL.String())
+	defer func() {
+		if err != nil {
+			span.SetTag("error", err.Error())
+		}
+		if resp != nil {
+			span.SetTag("status", resp.Status)
+		}
+		span.Finish()
+	}()
+
+	resp, err = ctxhttp.Do(ctx, c.httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.WithStack(&httpError{URL: req.URL, StatusCode: resp.StatusCode})
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+type httpError struct {
+	StatusCode int
+	URL        *url.URL
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("unexpected %d response from Gitea API at %s", e.StatusCode, e.URL)
+}
+
+func (e *httpError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized
+}
+
+func (e *httpError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,2 @@
+// Package gitea implements a Gitea API client.
+package gitea
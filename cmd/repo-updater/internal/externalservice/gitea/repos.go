@@ -0,0 +1,82 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Repository is a Gitea repository.
+type Repository struct {
+	ID          int64  `json:"id"`          // ID of the repository, which doesn't change when it is renamed or transferred
+	FullName    string `json:"full_name"`   // full name of the repository ("owner/name")
+	Description string `json:"description"` // description of the repository
+	Private     bool   `json:"private"`     // whether the repository is private
+	Fork        bool   `json:"fork"`        // whether the repository is a fork
+	Archived    bool   `json:"archived"`    // whether the repository is archived (Gitea 1.8 and newer)
+	HTMLURL     string `json:"html_url"`    // the web URL of the repository ("https://gitea.example.com/foo/bar")
+	CloneURL    string `json:"clone_url"`   // HTTP clone URL
+	SSHURL      string `json:"ssh_url"`     // SSH clone URL ("git@gitea.example.com:foo/bar.git")
+}
+
+// GetRepository gets the repository with the given owner and name.
+func (c *Client) GetRepository(ctx context.Context, owner, name string) (*Repository, error) {
+	return c.getRepository(ctx, fmt.Sprintf("repos/%s/%s", url.PathEscape(owner), url.PathEscape(name)))
+}
+
+// GetRepositoryByID gets the repository with the given ID.
+func (c *Client) GetRepositoryByID(ctx context.Context, id int64) (*Repository, error) {
+	return c.getRepository(ctx, "repositories/"+strconv.FormatInt(id, 10))
+}
+
+func (c *Client) getRepository(ctx context.Context, urlStr string) (*Repository, error) {
+	req, err := http.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	var repo Repository
+	if err := c.do(ctx, req, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// PerPage is the number of repositories requested per page. It is the maximum
+// number of items per page of a default Gitea configuration.
+const PerPage = 50
+
+// ListOrgRepositories returns the given page (starting at 1) of the repositories
+// of an organization. There are no more pages if it returns no repositories.
+func (c *Client) ListOrgRepositories(ctx context.Context, org string, page int) ([]*Repository, error) {
+	return c.listRepositories(ctx, fmt.Sprintf("orgs/%s/repos", url.PathEscape(org)), page)
+}
+
+// ListUserRepositories returns the given page (starting at 1) of the repositories
+// owned by a user. There are no more pages if it returns no repositories.
+func (c *Client) ListUserRepositories(ctx context.Context, user string, page int) ([]*Repository, error) {
+	return c.listRepositories(ctx, fmt.Sprintf("users/%s/repos", url.PathEscape(user)), page)
+}
+
+// ListAuthenticatedUserRepositories returns the given page (starting at 1) of the
+// repositories that the authenticated user owns or has access to. There are no
+// more pages if it returns no repositories.
+func (c *Client) ListAuthenticatedUserRepositories(ctx context.Context, page int) ([]*Repository, error) {
+	return c.listRepositories(ctx, "user/repos", page)
+}
+
+func (c *Client) listRepositories(ctx context.Context, urlStr string, page int) ([]*Repository, error) {
+	// We don't rely on the number of repositories per page to detect the last page,
+	// since the Gitea instance may return fewer than requested.
+	q := url.Values{"page": {strconv.Itoa(page)}, "limit": {strconv.Itoa(PerPage)}}
+	req, err := http.NewRequest("GET", urlStr+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var repos []*Repository
+	if err := c.do(ctx, req, &repos); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
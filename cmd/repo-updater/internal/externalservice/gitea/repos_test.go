@@ -0,0 +1,149 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+)
+
+type mockHTTPResponseBody struct {
+	requests       []string
+	authorizations []string
+	responseBody   string
+}
+
+func (s *mockHTTPResponseBody) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.requests = append(s.requests, req.URL.String())
+	s.authorizations = append(s.authorizations, req.Header.Get("Authorization"))
+	return &http.Response{
+		Request:    req,
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(s.responseBody)),
+	}, nil
+}
+
+type mockHTTPEmptyResponse struct {
+	statusCode int
+}
+
+func (s mockHTTPEmptyResponse) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Request:    req,
+		StatusCode: s.statusCode,
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}, nil
+}
+
+func newTestClient(t *testing.T, transport http.RoundTripper) *Client {
+	return NewClient(&url.URL{Scheme: "https", Host: "gitea.example.com", Path: "/"}, "t", transport)
+}
+
+const testRepositoryJSON = `
+{
+	"id": 3,
+	"owner": {"id": 1, "login": "o", "username": "o"},
+	"name": "r",
+	"full_name": "o/r",
+	"description": "d",
+	"empty": false,
+	"private": true,
+	"fork": true,
+	"parent": {"id": 2, "full_name": "p/r"},
+	"mirror": false,
+	"archived": false,
+	"html_url": "https://gitea.example.com/o/r",
+	"ssh_url": "git@gitea.example.com:o/r.git",
+	"clone_url": "https://gitea.example.com/o/r.git"
+}`
+
+var testRepository = Repository{
+	ID:          3,
+	FullName:    "o/r",
+	Description: "d",
+	Private:     true,
+	Fork:        true,
+	HTMLURL:     "https://gitea.example.com/o/r",
+	CloneURL:    "https://gitea.example.com/o/r.git",
+	SSHURL:      "git@gitea.example.com:o/r.git",
+}
+
+// TestClient_GetRepository tests the behavior of GetRepository and GetRepositoryByID.
+func TestClient_GetRepository(t *testing.T) {
+	mock := mockHTTPResponseBody{responseBody: testRepositoryJSON}
+	c := newTestClient(t, &mock)
+
+	repo, err := c.GetRepository(context.Background(), "o", "r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(repo, &testRepository) {
+		t.Errorf("got repository %+v, want %+v", repo, &testRepository)
+	}
+
+	repo, err = c.GetRepositoryByID(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(repo, &testRepository) {
+		t.Errorf("got repository %+v, want %+v", repo, &testRepository)
+	}
+
+	if want := []string{
+		"https://gitea.example.com/api/v1/repos/o/r",
+		"https://gitea.example.com/api/v1/repositories/3",
+	}; !reflect.DeepEqual(mock.requests, want) {
+		t.Errorf("got requests %v, want %v", mock.requests, want)
+	}
+	if want := []string{"token t", "token t"}; !reflect.DeepEqual(mock.authorizations, want) {
+		t.Errorf("got Authorization headers %v, want %v", mock.authorizations, want)
+	}
+}
+
+// TestClient_GetRepository_nonexistent tests the behavior of GetRepository when called
+// on a repository that does not exist.
+func TestClient_GetRepository_nonexistent(t *testing.T) {
+	c := newTestClient(t, mockHTTPEmptyResponse{http.StatusNotFound})
+
+	repo, err := c.GetRepository(context.Background(), "o", "r")
+	if !errcode.IsNotFound(err) {
+		t.Errorf("got err == %v, want errcode.IsNotFound(err) == true", err)
+	}
+	if repo != nil {
+		t.Error("repo != nil")
+	}
+}
+
+// TestClient_ListRepositories tests the requests of the ListXyzRepositories methods.
+func TestClient_ListRepositories(t *testing.T) {
+	mock := mockHTTPResponseBody{responseBody: "[" + testRepositoryJSON + "]"}
+	c := newTestClient(t, &mock)
+
+	for _, list := range []func() ([]*Repository, error){
+		func() ([]*Repository, error) { return c.ListOrgRepositories(context.Background(), "myorg", 1) },
+		func() ([]*Repository, error) { return c.ListUserRepositories(context.Background(), "myuser", 2) },
+		func() ([]*Repository, error) { return c.ListAuthenticatedUserRepositories(context.Background(), 3) },
+	} {
+		repos, err := list()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []*Repository{&testRepository}; !reflect.DeepEqual(repos, want) {
+			t.Errorf("got repositories %+v, want %+v", repos, want)
+		}
+	}
+
+	if want := []string{
+		"https://gitea.example.com/api/v1/orgs/myorg/repos?limit=50&page=1",
+		"https://gitea.example.com/api/v1/users/myuser/repos?limit=50&page=2",
+		"https://gitea.example.com/api/v1/user/repos?limit=50&page=3",
+	}; !reflect.DeepEqual(mock.requests, want) {
+		t.Errorf("got requests %v, want %v", mock.requests, want)
+	}
+}
@@ -3,6 +3,7 @@ package github
 import (
 	"bytes"
 	"context"
+	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -19,6 +20,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/internal/pkg/metrics"
 	"github.com/sourcegraph/sourcegraph/pkg/env"
+	"github.com/sourcegraph/sourcegraph/pkg/githubapp"
 	"github.com/sourcegraph/sourcegraph/pkg/ratelimit"
 	"github.com/sourcegraph/sourcegraph/pkg/rcache"
 	"golang.org/x/net/context/ctxhttp"
@@ -44,6 +46,10 @@ type Client struct {
 	token        string       // a personal access token to authenticate requests, if set
 	httpClient   *http.Client // the HTTP client to use
 
+	// app and installationID are the GitHub App installation to authenticate requests as, if app is set.
+	app            *githubapp.App
+	installationID int64
+
 	repoCache *rcache.Cache
 
 	RateLimit *ratelimit.Monitor // the API rate limit monitor
@@ -60,55 +66,112 @@ type abuseRateLimit struct {
 // The API URL must point to the base URL of the GitHub API. This is https://api.github.com for
 // GitHub.com and http[s]://[github-enterprise-hostname]/api for GitHub Enterprise.
 func NewClient(apiURL *url.URL, token string, transport http.RoundTripper) *Client {
-	if gitHubDisable {
-		transport = disabledTransport{}
+	c := newClient(apiURL, token, transport)
+	c.token = token
+	return c
+}
+
+// NewAppInstallationClient creates a new GitHub API client that authenticates requests as the
+// installation of the GitHub App, with installation access tokens that app mints (and refreshes
+// before they expire).
+//
+// The API URL is the same as for NewClient.
+func NewAppInstallationClient(apiURL *url.URL, app *githubapp.App, installationID int64, transport http.RoundTripper) *Client {
+	c := newClient(apiURL, fmt.Sprintf("app:%d:%d", app.ID, installationID), transport)
+	c.app = app
+	c.installationID = installationID
+	return c
+}
+
+// NewApp returns the GitHub App with the given ID and private key, which sends its requests to the
+// GitHub API at apiURL (the same as for NewClient).
+func NewApp(apiURL *url.URL, appID int64, privateKey *rsa.PrivateKey, transport http.RoundTripper) *githubapp.App {
+	apiURL, githubDotCom := resolveAPIURL(apiURL)
+	restURL := *apiURL
+	if githubDotCom {
+		restURL.Path = "/"
+	} else {
+		restURL.Path = strings.TrimSuffix(restURL.Path, "/") + "/v3/"
 	}
-	if transport == nil {
-		transport = http.DefaultTransport
+	return &githubapp.App{
+		ID:         appID,
+		PrivateKey: privateKey,
+		APIURL:     &restURL,
+		HTTPClient: &http.Client{Transport: wrapTransport(transport)},
 	}
-	transport = requestCounter.Transport(transport, func(u *url.URL) string {
-		// The first component of the Path mostly maps to the type of API
-		// request we are making. See `curl https://api.github.com` for the
-		// exact mapping
-		var category string
-		if parts := strings.SplitN(u.Path, "/", 3); len(parts) > 1 {
-			category = parts[1]
-		}
-		return category
-	})
+}
+
+// newClient creates a new GitHub API client without authentication. Its repository metadata
+// cache is keyed by cacheKey (in addition to the API URL), so that clients that authenticate
+// differently don't share cached metadata.
+func newClient(apiURL *url.URL, cacheKey string, transport http.RoundTripper) *Client {
+	apiURL, githubDotCom := resolveAPIURL(apiURL)
 
 	var cacheTTL time.Duration
-	hostname := strings.ToLower(apiURL.Hostname())
-	githubDotCom := hostname == "api.github.com" || hostname == "github.com" || hostname == "www.github.com"
 	if githubDotCom {
 		cacheTTL = 10 * time.Minute
-		// For GitHub.com API requests, use github-proxy (which adds our OAuth2 client ID/secret to get a much higher
-		// rate limit).
-		apiURL = githubProxyURL
 	} else {
 		// GitHub Enterprise
 		cacheTTL = 30 * time.Second
 	}
 
 	// Cache for repository metadata.
-	key := sha256.Sum256([]byte(token + ":" + apiURL.String()))
+	key := sha256.Sum256([]byte(cacheKey + ":" + apiURL.String()))
 	repoCache := rcache.NewWithTTL("gh_repo:"+base64.URLEncoding.EncodeToString(key[:]), int(cacheTTL/time.Second))
 
 	return &Client{
 		apiURL:       apiURL,
 		githubDotCom: githubDotCom,
-		token:        token,
-		httpClient:   &http.Client{Transport: transport},
+		httpClient:   &http.Client{Transport: wrapTransport(transport)},
 		RateLimit:    &ratelimit.Monitor{HeaderPrefix: "X-"},
 		repoCache:    repoCache,
 	}
 }
 
+// resolveAPIURL returns the URL to send requests for the GitHub API at apiURL to, and whether it
+// is the GitHub.com API.
+func resolveAPIURL(apiURL *url.URL) (_ *url.URL, githubDotCom bool) {
+	hostname := strings.ToLower(apiURL.Hostname())
+	if hostname == "api.github.com" || hostname == "github.com" || hostname == "www.github.com" {
+		// For GitHub.com API requests, use github-proxy (which adds our OAuth2 client ID/secret
+		// or GitHub App credentials to get a much higher rate limit).
+		return githubProxyURL, true
+	}
+	return apiURL, false
+}
+
+// wrapTransport wraps the transport with the request counter metric (and disables it if
+// SRC_GITHUB_DISABLE is set).
+func wrapTransport(transport http.RoundTripper) http.RoundTripper {
+	if gitHubDisable {
+		transport = disabledTransport{}
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return requestCounter.Transport(transport, func(u *url.URL) string {
+		// The first component of the Path mostly maps to the type of API
+		// request we are making. See `curl https://api.github.com` for the
+		// exact mapping
+		var category string
+		if parts := strings.SplitN(u.Path, "/", 3); len(parts) > 1 {
+			category = parts[1]
+		}
+		return category
+	})
+}
+
 func (c *Client) do(ctx context.Context, req *http.Request, result interface{}) (err error) {
 	req.URL.Path = path.Join(c.apiURL.Path, req.URL.Path)
 	req.URL = c.apiURL.ResolveReference(req.URL)
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	if c.token != "" {
+	if c.app != nil {
+		token, err := c.app.InstallationToken(ctx, c.installationID)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "token "+token)
+	} else if c.token != "" {
 		req.Header.Set("Authorization", "bearer "+c.token)
 	}
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 
@@ -318,3 +319,36 @@ func (c *Client) ListViewerRepositories(ctx context.Context, page int) (repos []
 	c.addRepositoriesToCache(repos)
 	return repos, len(repos) > 0, 1, nil
 }
+
+// ListInstallationRepositories lists the GitHub repositories that the GitHub App installation
+// (that the client authenticates as) has access to. page is the page of results to return. Pages
+// are 1-indexed (so the first call should be for page 1).
+func (c *Client) ListInstallationRepositories(ctx context.Context, page int) (repos []*Repository, hasNextPage bool, rateLimitCost int, err error) {
+	const perPage = 100
+	path := fmt.Sprintf("installation/repositories?per_page=%d&page=%d", perPage, page)
+	if !c.githubDotCom {
+		path = "v3/" + path
+	}
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, false, 1, err
+	}
+	// The installation API is in preview. Also include node_id (GraphQL ID) in the response, like
+	// requestGet.
+	req.Header.Add("Accept", "application/vnd.github.machine-man-preview+json")
+	req.Header.Add("Accept", "application/vnd.github.jean-grey-preview+json")
+
+	var result struct {
+		TotalCount   int              `json:"total_count"`
+		Repositories []restRepository `json:"repositories"`
+	}
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, false, 1, err
+	}
+	repos = make([]*Repository, 0, len(result.Repositories))
+	for _, restRepo := range result.Repositories {
+		repos = append(repos, convertRestRepo(restRepo))
+	}
+	c.addRepositoriesToCache(repos)
+	return repos, page*perPage < result.TotalCount, 1, nil
+}
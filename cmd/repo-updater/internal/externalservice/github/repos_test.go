@@ -3,6 +3,8 @@ package github
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -10,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/sourcegraph/sourcegraph/pkg/githubapp"
 	"github.com/sourcegraph/sourcegraph/pkg/ratelimit"
 	"github.com/sourcegraph/sourcegraph/pkg/rcache"
 )
@@ -211,3 +214,72 @@ func TestClient_GetRepositoryByNodeID_nonexistent(t *testing.T) {
 		t.Error("repo != nil")
 	}
 }
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// TestClient_ListInstallationRepositories tests that ListInstallationRepositories authenticates
+// as the GitHub App installation.
+func TestClient_ListInstallationRepositories(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokenRequests []string
+	app := &githubapp.App{
+		ID:         1,
+		PrivateKey: key,
+		APIURL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/"},
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			tokenRequests = append(tokenRequests, req.Method+" "+req.URL.Path)
+			return &http.Response{
+				Request:    req,
+				StatusCode: http.StatusCreated,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"token": "t", "expires_at": "2100-01-01T00:00:00Z"}`)),
+			}, nil
+		})},
+	}
+
+	var authorizations []string
+	c := newTestClient(t)
+	c.githubDotCom = true
+	c.app, c.installationID = app, 2
+	c.httpClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+		if want := "/installation/repositories"; req.URL.Path != want {
+			t.Errorf("got request path %q, want %q", req.URL.Path, want)
+		}
+		return &http.Response{
+			Request:    req,
+			StatusCode: http.StatusOK,
+			Body: ioutil.NopCloser(strings.NewReader(`
+{
+	"total_count": 101,
+	"repositories": [{"node_id": "i", "full_name": "o/r", "html_url": "https://github.com/o/r", "private": true}]
+}`)),
+		}, nil
+	})
+
+	for page, wantHasNextPage := range map[int]bool{1: true, 2: false} {
+		repos, hasNextPage, _, err := c.ListInstallationRepositories(context.Background(), page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []*Repository{{ID: "i", NameWithOwner: "o/r", URL: "https://github.com/o/r", IsPrivate: true}}
+		if !reflect.DeepEqual(repos, want) {
+			t.Errorf("got repositories %+v, want %+v", repos, want)
+		}
+		if hasNextPage != wantHasNextPage {
+			t.Errorf("page %d: got hasNextPage %v, want %v", page, hasNextPage, wantHasNextPage)
+		}
+	}
+
+	// The installation token is minted once and reused.
+	if want := []string{"POST /app/installations/2/access_tokens"}; !reflect.DeepEqual(tokenRequests, want) {
+		t.Errorf("got token requests %v, want %v", tokenRequests, want)
+	}
+	if want := []string{"token t", "token t"}; !reflect.DeepEqual(authorizations, want) {
+		t.Errorf("got authorizations %v, want %v", authorizations, want)
+	}
+}
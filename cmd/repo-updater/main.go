@@ -35,7 +35,7 @@ func main() {
 		Name: "Repo Updater State",
 		Path: "/repo-updater-state",
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			d, err := json.MarshalIndent(repos.QueueSnapshot(), "", "  ")
+			d, err := json.MarshalIndent(repos.UpdateQueue(0), "", "  ")
 			if err != nil {
 				http.Error(w, "failed to marshal snapshot: "+err.Error(), http.StatusInternalServerError)
 				return
@@ -60,29 +60,26 @@ func main() {
 	// Sync relies on access to frontend, so wait until it has started up.
 	api.WaitForFrontend(ctx)
 
+	// Resume the update schedule from before the restart, so that repos are
+	// not all updated at once. This must happen before repos are added.
+	if err := repos.LoadUpdateSchedules(ctx); err != nil {
+		log15.Warn("failed to load repo update schedules, all repos will be updated", "error", err)
+	}
+
 	// Repos List syncing thread
 	go repos.RunRepositorySyncWorker(ctx)
 
 	// Repos purging thread
 	go repos.RunRepositoryPurgeWorker(ctx)
 
-	// GitHub Repository syncing thread
-	go repos.RunGitHubRepositorySyncWorker(ctx)
-
-	// GitLab Repository syncing thread
-	go repos.RunGitLabRepositorySyncWorker(ctx)
-
-	// AWS CodeCommit repository syncing thread
-	go repos.RunAWSCodeCommitRepositorySyncWorker(ctx)
+	// Syncing thread for repositories from external services
+	go repos.RunSyncWorker(ctx)
 
 	// Phabricator Repository syncing thread
 	go repos.RunPhabricatorRepositorySyncWorker(ctx)
 
-	// Gitolite syncing thread
-	go repos.RunGitoliteRepositorySyncWorker(ctx)
-
-	// Bitbucket Server syncing thread
-	go repos.RunBitbucketServerRepositorySyncWorker(ctx)
+	// Gitolite Phabricator metadata thread
+	go repos.RunGitolitePhabricatorMetadataWorker(ctx)
 
 	select {}
 }
@@ -57,7 +57,7 @@ func init() {
 			}
 			return conns
 		})
-		awsCodeCommitRepositorySyncWorker.restart()
+		syncWorker.restart()
 	})
 }
 
@@ -124,79 +124,58 @@ func GetAWSCodeCommitRepository(ctx context.Context, args protocol.RepoLookupArg
 	return nil, false, nil
 }
 
-var awsCodeCommitRepositorySyncWorker = &worker{
-	work: func(ctx context.Context, shutdown chan struct{}) {
-		awsCodeCommitConnections := awsCodeCommitConnections.Get().([]*awsCodeCommitConnection)
-		if len(awsCodeCommitConnections) == 0 {
-			return
-		}
-		for _, c := range awsCodeCommitConnections {
-			go func(c *awsCodeCommitConnection) {
-				// Hit the AWS API to determine our account ID (which is a fixed value but not derivable
-				// from the values in the Sourcegraph site config). Be robust to the API being
-				// unreachable when we start up.
-				const retryInterval = 20 * time.Second
-				for {
-					_, err := c.tryPopulateAWSAccountID()
-					if err == nil {
-						break
-					}
-					log15.Error("Unable to reach AWS CodeCommit API to determine AWS account ID.", "region", c.config.Region, "error", err, "retryInterval", retryInterval)
-					select {
-					case <-shutdown:
-						return
-					case <-time.After(retryInterval):
-					}
-				}
-
-				for {
-					updateAWSCodeCommitRepositories(ctx, c)
-					awsCodeCommitUpdateTime.WithLabelValues(c.awsAccountID).Set(float64(time.Now().Unix()))
-					select {
-					case <-shutdown:
-						return
-					case <-time.After(getUpdateInterval()):
-					}
-				}
-			}(c)
-		}
-	},
+func awsCodeCommitRepositoryToRepoPath(conn *awsCodeCommitConnection, repo *awscodecommit.Repository) api.RepoURI {
+	return reposource.AWSRepoURI(conn.config.RepositoryPathPattern, repo.Name)
 }
 
-// RunAWSCodeCommitRepositorySyncWorker runs the worker that syncs repositories from the configured AWSCodeCommit and AWSCodeCommit
-// Enterprise instances to Sourcegraph.
-func RunAWSCodeCommitRepositorySyncWorker(ctx context.Context) {
-	awsCodeCommitRepositorySyncWorker.start(ctx)
+// An awsCodeCommitSource lists the repositories of an AWS CodeCommit
+// connection.
+type awsCodeCommitSource struct {
+	conn      *awsCodeCommitConnection
+	serviceID string
 }
 
-func awsCodeCommitRepositoryToRepoPath(conn *awsCodeCommitConnection, repo *awscodecommit.Repository) api.RepoURI {
-	return reposource.AWSRepoURI(conn.config.RepositoryPathPattern, repo.Name)
+func (s *awsCodeCommitSource) ExternalService() (serviceType, serviceID string) {
+	return AWSCodeCommitServiceType, s.serviceID
 }
 
-// updateAWSCodeCommitRepositories ensures that all provided repositories have been added and updated on Sourcegraph.
-func updateAWSCodeCommitRepositories(ctx context.Context, conn *awsCodeCommitConnection) {
-	repos := conn.listAllRepositories(ctx)
-
-	repoChan := make(chan repoCreateOrUpdateRequest)
-	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("aws:%s", conn.config.AccessKeyID), repoChan)
-	for repo := range repos {
-		// log15.Debug("awscodecommit sync: create/enable/update repo", "repo", repo.Name)
-		remoteURL, err := conn.authenticatedRemoteURL(repo)
+func (s *awsCodeCommitSource) ListRepos(ctx context.Context) ([]*SourceRepo, error) {
+	ccrepos, err := s.conn.listAllRepositories(ctx)
+	var repos []*SourceRepo
+	for _, r := range ccrepos {
+		repo, err := s.sourceRepo(r)
 		if err != nil {
-			log15.Error("Error generating remote URL for AWS CodeCommit repository. Skipping.", "repo", repo.ARN, "error", err)
-			continue
-		}
-		repoChan <- repoCreateOrUpdateRequest{
-			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
-				RepoURI:      awsCodeCommitRepositoryToRepoPath(conn, repo),
-				ExternalRepo: AWSCodeCommitExternalRepoSpec(repo, createAWSCodeCommitServiceID(conn.awsPartition, conn.awsRegion, repo.AccountID)),
-				Description:  repo.Description,
-				Enabled:      conn.config.InitialRepositoryEnablement,
-			},
-			URL: remoteURL,
+			return repos, err
 		}
+		repos = append(repos, repo)
 	}
+	return repos, err
+}
+
+func (s *awsCodeCommitSource) GetRepo(ctx context.Context, id string) (*SourceRepo, error) {
+	repo, err := s.conn.client.GetRepository(ctx, id)
+	if awscodecommit.IsNotFound(err) {
+		return nil, &repoNotFoundError{id: id, err: err}
+	} else if err != nil {
+		return nil, err
+	}
+	return s.sourceRepo(repo)
+}
+
+func (s *awsCodeCommitSource) sourceRepo(repo *awscodecommit.Repository) (*SourceRepo, error) {
+	remoteURL, err := s.conn.authenticatedRemoteURL(repo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "generating remote URL for AWS CodeCommit repository %s", repo.ARN)
+	}
+	return &SourceRepo{
+		RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+			RepoURI:      awsCodeCommitRepositoryToRepoPath(s.conn, repo),
+			ExternalRepo: AWSCodeCommitExternalRepoSpec(repo, createAWSCodeCommitServiceID(s.conn.awsPartition, s.conn.awsRegion, repo.AccountID)),
+			Description:  repo.Description,
+			Enabled:      s.conn.config.InitialRepositoryEnablement,
+		},
+		URL: remoteURL,
+	}, nil
 }
 
 func newAWSCodeCommitConnection(config *schema.AWSCodeCommitConnection) (*awsCodeCommitConnection, error) {
@@ -331,26 +310,21 @@ func makeSHA256(data []byte) []byte {
 	return hash.Sum(nil)
 }
 
-func (c *awsCodeCommitConnection) listAllRepositories(ctx context.Context) <-chan *awscodecommit.Repository {
+// listAllRepositories returns all repositories of the connection. If any of
+// them could not be listed, it returns those that were along with the error.
+func (c *awsCodeCommitConnection) listAllRepositories(ctx context.Context) ([]*awscodecommit.Repository, error) {
 	const maxItems = 50
-	ch := make(chan *awscodecommit.Repository, maxItems)
-	go func() {
-		defer close(ch)
-		var nextToken string
-		for {
-			repos, token, err := c.client.ListRepositories(ctx, maxItems, nextToken)
-			if err != nil {
-				log15.Error("Error listing AWS CodeCommit repositories", "error", err)
-				return
-			}
-			for _, r := range repos {
-				ch <- r
-			}
-			if len(repos) == 0 || token == "" {
-				return // last page
-			}
-			nextToken = token
+	var all []*awscodecommit.Repository
+	var nextToken string
+	for {
+		repos, token, err := c.client.ListRepositories(ctx, maxItems, nextToken)
+		if err != nil {
+			return all, errors.Wrap(err, "listing AWS CodeCommit repositories")
+		}
+		all = append(all, repos...)
+		if len(repos) == 0 || token == "" {
+			return all, nil // last page
 		}
-	}()
-	return ch
+		nextToken = token
+	}
 }
@@ -0,0 +1,317 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/internal/externalservice/bitbucketcloud"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/atomicvalue"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+	"github.com/sourcegraph/sourcegraph/schema"
+	"golang.org/x/time/rate"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// bitbucketCloudServiceType is the (api.ExternalRepoSpec).ServiceType value
+// for Bitbucket Cloud repositories. The ServiceID value is the base URL to
+// Bitbucket Cloud (https://bitbucket.org/), and the ID value is the
+// repository's UUID (including the curly braces), which doesn't change when it
+// is renamed or transferred.
+const bitbucketCloudServiceType = "bitbucketCloud"
+
+var bitbucketCloudConnections = atomicvalue.New()
+
+func init() {
+	conf.Watch(func() {
+		bitbucketCloudConnections.Set(func() interface{} {
+			var conns []*bitbucketCloudConnection
+			for _, c := range conf.Get().BitbucketCloud {
+				conn, err := newBitbucketCloudConnection(c)
+				if err != nil {
+					log15.Error("Error processing configured Bitbucket Cloud connection. Skipping it.", "url", c.Url, "error", err)
+					continue
+				}
+				conns = append(conns, conn)
+			}
+			return conns
+		})
+		syncWorker.restart()
+	})
+}
+
+// getBitbucketCloudConnection returns the Bitbucket Cloud connection (config + API client) that is responsible
+// for the repository specified by the args.
+func getBitbucketCloudConnection(args protocol.RepoLookupArgs) (*bitbucketCloudConnection, error) {
+	conns := bitbucketCloudConnections.Get().([]*bitbucketCloudConnection)
+
+	if args.ExternalRepo != nil && args.ExternalRepo.ServiceType == bitbucketCloudServiceType {
+		// Look up by external repository spec.
+		for _, conn := range conns {
+			if args.ExternalRepo.ServiceID == conn.baseURL.String() {
+				return conn, nil
+			}
+		}
+		return nil, errors.Errorf("no configured Bitbucket Cloud connection with URL: %q", args.ExternalRepo.ServiceID)
+	}
+
+	if args.Repo != "" {
+		// Look up by repository URI.
+		repo := strings.ToLower(string(args.Repo))
+		for _, conn := range conns {
+			if strings.HasPrefix(repo, conn.baseURL.Hostname()+"/") {
+				return conn, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func bitbucketCloudRepoInfo(config *schema.BitbucketCloudConnection, repo *bitbucketcloud.Repo) *protocol.RepoInfo {
+	host, err := url.Parse(config.Url)
+	if err != nil {
+		// This should never happen
+		log15.Error("malformed bitbucket cloud config, invalid URL", "url", config.Url, "error", err)
+		return nil
+	}
+	host = NormalizeBaseURL(host)
+
+	// Clone URL
+	var cloneURL string
+	for _, l := range repo.Links.Clone {
+		if l.Name == "ssh" && config.GitURLType == "ssh" {
+			cloneURL = l.Href
+			break
+		}
+		if l.Name == "https" {
+			cloneURL = setUserinfoBestEffort(l.Href, config.Username, config.AppPassword)
+			// No break, so that we fallback to https in case of ssh missing
+			// with GitURLType == "ssh"
+		}
+	}
+
+	// Repo Links
+	var links *protocol.RepoLinks
+	if repo.Links.HTML.Href != "" {
+		root := strings.TrimSuffix(repo.Links.HTML.Href, "/")
+		links = &protocol.RepoLinks{
+			Root:   root,
+			Tree:   root + "/src/{rev}/{path}",
+			Blob:   root + "/src/{rev}/{path}",
+			Commit: root + "/commits/{commit}",
+		}
+	}
+
+	return &protocol.RepoInfo{
+		URI: reposource.BitbucketCloudRepoURI(config.RepositoryPathPattern, host.Hostname(), repo.FullName),
+		ExternalRepo: &api.ExternalRepoSpec{
+			ID:          repo.UUID,
+			ServiceType: bitbucketCloudServiceType,
+			ServiceID:   host.String(),
+		},
+		Description: repo.Description,
+		Fork:        repo.Parent != nil,
+		VCS: protocol.VCSInfo{
+			URL: cloneURL,
+		},
+		Links: links,
+	}
+}
+
+// GetBitbucketCloudRepository queries a configured Bitbucket Cloud connection for information about the
+// specified repository.
+//
+// If args.Repo refers to a repository that is not known to be on a configured Bitbucket Cloud connection's
+// host, it returns authoritative == false.
+func GetBitbucketCloudRepository(ctx context.Context, args protocol.RepoLookupArgs) (repo *protocol.RepoInfo, authoritative bool, err error) {
+	conn, err := getBitbucketCloudConnection(args)
+	if err != nil {
+		return nil, true, err // refers to a Bitbucket Cloud repo but the host is not configured
+	}
+	if conn == nil {
+		return nil, false, nil // refers to a non-Bitbucket Cloud repo
+	}
+
+	if args.ExternalRepo != nil && args.ExternalRepo.ServiceType == bitbucketCloudServiceType {
+		// Look up by external repository spec (the repository's UUID).
+		repo, err := conn.getRepo(ctx, args.ExternalRepo.ID)
+		if err != nil {
+			return nil, true, err
+		}
+		return bitbucketCloudRepoInfo(conn.config, repo), true, nil
+	}
+
+	if args.Repo != "" {
+		// Look up by repository URI. Expect suffix {workspace}/{repoSlug}
+		match := bitbucketServerRepoInfoSuffix.FindStringSubmatch(string(args.Repo))
+		if len(match) == 0 {
+			return nil, true, errors.Errorf("malformed bitbucket cloud repo URL: %q", args.Repo)
+		}
+		repo, err := conn.client.Repo(ctx, match[1], match[2])
+		if err != nil {
+			return nil, true, err
+		}
+		return bitbucketCloudRepoInfo(conn.config, repo), true, nil
+	}
+
+	return nil, true, fmt.Errorf("unable to look up Bitbucket Cloud repository (%+v)", args)
+}
+
+// A bitbucketCloudSource lists the repositories of a Bitbucket Cloud
+// connection.
+type bitbucketCloudSource struct {
+	conn *bitbucketCloudConnection
+}
+
+func (s *bitbucketCloudSource) ExternalService() (serviceType, serviceID string) {
+	return bitbucketCloudServiceType, s.conn.baseURL.String()
+}
+
+func (s *bitbucketCloudSource) ListRepos(ctx context.Context) ([]*SourceRepo, error) {
+	bbrepos, err := s.conn.listAllRepos(ctx)
+	var repos []*SourceRepo
+	for _, r := range bbrepos {
+		if repo := s.sourceRepo(r); repo != nil {
+			repos = append(repos, repo)
+		}
+	}
+	return repos, err
+}
+
+func (s *bitbucketCloudSource) GetRepo(ctx context.Context, id string) (*SourceRepo, error) {
+	repo, err := s.conn.getRepo(ctx, id)
+	if errcode.IsNotFound(err) {
+		return nil, &repoNotFoundError{id: id, err: err}
+	} else if err != nil {
+		return nil, err
+	}
+	sourceRepo := s.sourceRepo(repo)
+	if sourceRepo == nil {
+		return nil, errors.Errorf("bitbucket cloud repository %q has no clone URL", id)
+	}
+	return sourceRepo, nil
+}
+
+// sourceRepo returns nil for repositories without a clone URL.
+func (s *bitbucketCloudSource) sourceRepo(repo *bitbucketcloud.Repo) *SourceRepo {
+	ri := bitbucketCloudRepoInfo(s.conn.config, repo)
+	if ri == nil || ri.VCS.URL == "" {
+		return nil
+	}
+	return &SourceRepo{
+		RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+			RepoURI:      ri.URI,
+			ExternalRepo: ri.ExternalRepo,
+			Description:  ri.Description,
+			Fork:         ri.Fork,
+			Enabled:      s.conn.config.InitialRepositoryEnablement,
+		},
+		URL: ri.VCS.URL,
+	}
+}
+
+// These fields define the self-imposed Bitbucket Cloud rate limit. Bitbucket Cloud
+// restricts "List repositories" requests (which are most of our requests) to 1,000/hr
+// per user, but does not report it in HTTP response headers.
+//
+// We list 100 repositories per request, so the burst lets us list 20,000 repositories
+// at once and still have 50 API requests left-over to serve users. Larger teams are
+// listed more slowly.
+const (
+	bitbucketCloudRateLimitRequestsPerHour  = 1000
+	bitbucketCloudRateLimitMaxBurstRequests = 250
+)
+
+func newBitbucketCloudConnection(config *schema.BitbucketCloudConnection) (*bitbucketCloudConnection, error) {
+	baseURL, err := url.Parse(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	baseURL = NormalizeBaseURL(baseURL)
+
+	transport, err := cachedTransportWithCertTrusted("")
+	if err != nil {
+		return nil, err
+	}
+
+	return &bitbucketCloudConnection{
+		config:  config,
+		baseURL: baseURL,
+		client: &bitbucketcloud.Client{
+			URL:         bitbucketCloudAPIURL(baseURL),
+			Username:    config.Username,
+			AppPassword: config.AppPassword,
+			HTTPClient: &http.Client{
+				Transport: bitbucketcloud.WithRequestCounter(transport),
+			},
+			RateLimit: rate.NewLimiter(rate.Every(time.Hour/bitbucketCloudRateLimitRequestsPerHour), bitbucketCloudRateLimitMaxBurstRequests),
+		},
+	}, nil
+}
+
+// bitbucketCloudAPIURL returns the API URL of the Bitbucket Cloud at baseURL.
+// The API of bitbucket.org is on its own host, api.bitbucket.org. Other hosts
+// (such as proxies to bitbucket.org) serve it under the "!api" path, like
+// bitbucket.org does too.
+func bitbucketCloudAPIURL(baseURL *url.URL) *url.URL {
+	if baseURL.Hostname() == "bitbucket.org" {
+		return &url.URL{Scheme: "https", Host: "api.bitbucket.org", Path: "/"}
+	}
+	return baseURL.ResolveReference(&url.URL{Path: "!api/"})
+}
+
+type bitbucketCloudConnection struct {
+	config  *schema.BitbucketCloudConnection
+	baseURL *url.URL // the normalized URL of Bitbucket Cloud, such as https://bitbucket.org/
+	client  *bitbucketcloud.Client
+}
+
+// getRepo returns the repository with the given UUID.
+func (c *bitbucketCloudConnection) getRepo(ctx context.Context, uuid string) (*bitbucketcloud.Repo, error) {
+	if !strings.HasPrefix(uuid, "{") || !strings.HasSuffix(uuid, "}") {
+		return nil, errors.Errorf("malformed bitbucket cloud repository UUID: %q", uuid)
+	}
+	// The workspace is not needed to look up a repository by UUID, so we pass the "{}" placeholder.
+	return c.client.Repo(ctx, "{}", uuid)
+}
+
+// listAllRepos returns all Git repositories of the configured teams, or those
+// that the user is a member of if no teams are configured. If any of them could
+// not be listed, it returns those that were along with the error.
+func (c *bitbucketCloudConnection) listAllRepos(ctx context.Context) ([]*bitbucketcloud.Repo, error) {
+	workspaces := c.config.Teams
+	if len(workspaces) == 0 {
+		workspaces = []string{""} // the user's repositories
+	}
+
+	var all []*bitbucketcloud.Repo
+	seen := make(map[string]bool)
+	for _, workspace := range workspaces {
+		page := &bitbucketcloud.PageToken{Pagelen: 100}
+		for page.HasMore() {
+			repos, next, err := c.client.Repos(ctx, page, workspace)
+			if err != nil {
+				return all, errors.Wrapf(err, "listing Bitbucket Cloud repos of %q", workspace)
+			}
+			page = next
+			for _, r := range repos {
+				// Mercurial repositories can't be cloned with Git.
+				if r.SCM != "git" || seen[r.UUID] {
+					continue
+				}
+				seen[r.UUID] = true
+				all = append(all, r)
+			}
+		}
+	}
+	return all, nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/atomicvalue"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
 	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/vcs"
 	"github.com/sourcegraph/sourcegraph/schema"
@@ -43,7 +44,7 @@ func init() {
 			}
 			return conns
 		})
-		bitbucketServerWorker.restart()
+		syncWorker.restart()
 	})
 }
 
@@ -197,69 +198,82 @@ func GetBitbucketServerRepository(ctx context.Context, args protocol.RepoLookupA
 	return nil, true, fmt.Errorf("unable to look up Bitbucket Server repository (%+v)", args)
 }
 
-var bitbucketServerWorker = &worker{
-	work: func(ctx context.Context, shutdown chan struct{}) {
-		for _, c := range bitbucketServerConnections.Get().([]*bitbucketServerConnection) {
-			go func(c *bitbucketServerConnection) {
-				for {
-					reservationTime := time.Now()
-					r := c.client.RateLimit.ReserveN(reservationTime, rateLimitReservationSize)
-					if !r.OK() {
-						log15.Error("Bitbucket worker cannot reserve requests. Is the maximum burst size lower than the reservation size?", "reservation_size", rateLimitReservationSize, "max_burst_size", rateLimitMaxBurstRequests)
-					}
-					delay := r.Delay()
-					// Since we're not actually planning to use the reservation, cancel it now.
-					// We only wanted to know the delay / availability of the reservation.
-					r.CancelAt(reservationTime)
-					if delay > time.Second {
-						log15.Warn("Bitbucket self-enforced API rate limit is almost exhausted. Waiting before doing more work", "delay", r.Delay())
-					}
-					time.Sleep(delay)
-
-					updateBitbucketServerRepos(ctx, c)
-					bitbucketServerUpdateTime.WithLabelValues(c.config.Url).Set(float64(time.Now().Unix()))
-					select {
-					case <-shutdown:
-						return
-					case <-time.After(getUpdateInterval()):
-					}
-				}
-			}(c)
-		}
-	},
+// A bitbucketServerSource lists the repositories of a Bitbucket Server
+// connection.
+type bitbucketServerSource struct {
+	conn *bitbucketServerConnection
 }
 
-// RunBitbucketServerRepositorySyncWorker runs the worker that syncs projects from configured BitbucketServer instances to
-// Sourcegraph.
-func RunBitbucketServerRepositorySyncWorker(ctx context.Context) {
-	bitbucketServerWorker.start(ctx)
+func (s *bitbucketServerSource) ExternalService() (serviceType, serviceID string) {
+	return bitbucketServerServiceType, s.conn.client.URL.String()
 }
 
-// updateBitbucketServerRepos ensures that all provided repositories exist in the repository table.
-func updateBitbucketServerRepos(ctx context.Context, conn *bitbucketServerConnection) {
-	repoChan := make(chan repoCreateOrUpdateRequest)
-	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("bitbucket:%s", conn.config.Username), repoChan)
-	for r := range conn.listAllRepos(ctx) {
+func (s *bitbucketServerSource) ListRepos(ctx context.Context) ([]*SourceRepo, error) {
+	c := s.conn
+	reservationTime := time.Now()
+	r := c.client.RateLimit.ReserveN(reservationTime, rateLimitReservationSize)
+	if !r.OK() {
+		log15.Error("Bitbucket worker cannot reserve requests. Is the maximum burst size lower than the reservation size?", "reservation_size", rateLimitReservationSize, "max_burst_size", rateLimitMaxBurstRequests)
+	}
+	delay := r.Delay()
+	// Since we're not actually planning to use the reservation, cancel it now.
+	// We only wanted to know the delay / availability of the reservation.
+	r.CancelAt(reservationTime)
+	if delay > time.Second {
+		log15.Warn("Bitbucket self-enforced API rate limit is almost exhausted. Waiting before doing more work", "delay", r.Delay())
+	}
+	time.Sleep(delay)
+
+	bbrepos, err := c.listAllRepos(ctx)
+	var repos []*SourceRepo
+	for _, r := range bbrepos {
 		if r.State != "AVAILABLE" {
 			continue
 		}
-
-		ri := bitbucketServerRepoInfo(conn.config, r)
-		if ri.VCS.URL == "" {
-			continue
+		if repo := s.sourceRepo(r); repo != nil {
+			repos = append(repos, repo)
 		}
+	}
+	return repos, err
+}
 
-		repoChan <- repoCreateOrUpdateRequest{
-			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
-				RepoURI:      ri.URI,
-				ExternalRepo: ri.ExternalRepo,
-				Description:  ri.Description,
-				Fork:         ri.Fork,
-				Enabled:      conn.config.InitialRepositoryEnablement,
-			},
-			URL: ri.VCS.URL,
-		}
+func (s *bitbucketServerSource) GetRepo(ctx context.Context, id string) (*SourceRepo, error) {
+	// Expect {projectKey}/{repoSlug}
+	i := strings.Index(id, "/")
+	if i < 0 || i == len(id)-1 {
+		return nil, errors.Errorf("malformed bitbucket server ID: %q", id)
+	}
+	repo, err := s.conn.client.Repo(ctx, id[:i], id[i+1:])
+	if errcode.IsNotFound(err) {
+		return nil, &repoNotFoundError{id: id, err: err}
+	} else if err != nil {
+		return nil, err
+	}
+	if s.conn.config.ExcludePersonalRepositories && repo.IsPersonalRepository() {
+		return nil, &repoNotFoundError{id: id}
+	}
+	sourceRepo := s.sourceRepo(repo)
+	if sourceRepo == nil {
+		return nil, errors.Errorf("bitbucket server repository %q has no clone URL", id)
+	}
+	return sourceRepo, nil
+}
+
+// sourceRepo returns nil for repositories without a clone URL.
+func (s *bitbucketServerSource) sourceRepo(repo *bitbucketserver.Repo) *SourceRepo {
+	ri := bitbucketServerRepoInfo(s.conn.config, repo)
+	if ri == nil || ri.VCS.URL == "" {
+		return nil
+	}
+	return &SourceRepo{
+		RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+			RepoURI:      ri.URI,
+			ExternalRepo: ri.ExternalRepo,
+			Description:  ri.Description,
+			Fork:         ri.Fork,
+			Enabled:      s.conn.config.InitialRepositoryEnablement,
+		},
+		URL: ri.VCS.URL,
 	}
 }
 
@@ -321,45 +335,43 @@ type bitbucketServerConnection struct {
 	client *bitbucketserver.Client
 }
 
-func (c *bitbucketServerConnection) listAllRepos(ctx context.Context) <-chan *bitbucketserver.Repo {
+// listAllRepos returns all repositories on the Bitbucket Server, with the
+// recently accessed repositories first. If any of them could not be listed, it
+// returns those that were along with the error.
+func (c *bitbucketServerConnection) listAllRepos(ctx context.Context) ([]*bitbucketserver.Repo, error) {
 	perPage := 100
-	ch := make(chan *bitbucketserver.Repo, perPage)
-	go func() {
-		defer close(ch)
+	var all []*bitbucketserver.Repo
+
+	// First we list one page of recent repos, so that we clone them first
+	repos, _, err := c.client.RecentRepos(ctx, &bitbucketserver.PageToken{Limit: perPage})
+	if err != nil {
+		log15.Warn("failed to list recent repos for Bitbucket Server", "url", c.client.URL, "error", err)
+	}
+	recent := map[int]bool{}
+	for _, r := range repos {
+		if c.config.ExcludePersonalRepositories && r.IsPersonalRepository() {
+			continue
+		}
+		recent[r.ID] = true
+		all = append(all, r)
+	}
 
-		// First we list one page of recent repos, so that we clone them first
-		repos, _, err := c.client.RecentRepos(ctx, &bitbucketserver.PageToken{Limit: perPage})
+	// Then we list all repos, taking care not to repeat repos we have
+	// already listed via recent.
+	page := &bitbucketserver.PageToken{Limit: perPage}
+	for page.HasMore() {
+		repos, page, err = c.client.Repos(ctx, page)
 		if err != nil {
-			log15.Warn("failed to list recent repos for Bitbucket Server", "url", c.client.URL, "error", err)
+			return all, errors.Wrapf(err, "listing Bitbucket Server repos at %s", c.client.URL)
 		}
-		recent := map[int]bool{}
 		for _, r := range repos {
 			if c.config.ExcludePersonalRepositories && r.IsPersonalRepository() {
 				continue
 			}
-			recent[r.ID] = true
-			ch <- r
-		}
-
-		// Then we list all repos, taking care not to repeat repos we have
-		// already sent via recent.
-		page := &bitbucketserver.PageToken{Limit: perPage}
-		for page.HasMore() {
-			repos, page, err = c.client.Repos(ctx, page)
-			if err != nil {
-				log15.Error("failed when listing Bitbucket Server repos", "url", c.client.URL, "error", err)
-				return
-			}
-			for _, r := range repos {
-				if c.config.ExcludePersonalRepositories && r.IsPersonalRepository() {
-					continue
-				}
-				if !recent[r.ID] {
-					ch <- r
-				}
+			if !recent[r.ID] {
+				all = append(all, r)
 			}
 		}
-	}()
-
-	return ch
+	}
+	return all, nil
 }
@@ -0,0 +1,278 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/internal/externalservice/gitea"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/atomicvalue"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+	"github.com/sourcegraph/sourcegraph/schema"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// giteaServiceType is the (api.ExternalRepoSpec).ServiceType value for Gitea repositories. The ServiceID value
+// is the base URL to the Gitea instance, and the ID value is the repository's numeric ID.
+const giteaServiceType = "gitea"
+
+// giteaExternalRepoSpec returns an api.ExternalRepoSpec that refers to the specified Gitea repository.
+func giteaExternalRepoSpec(repo *gitea.Repository, baseURL url.URL) *api.ExternalRepoSpec {
+	return &api.ExternalRepoSpec{
+		ID:          strconv.FormatInt(repo.ID, 10),
+		ServiceType: giteaServiceType,
+		ServiceID:   NormalizeBaseURL(&baseURL).String(),
+	}
+}
+
+var giteaConnections = atomicvalue.New()
+
+func init() {
+	conf.Watch(func() {
+		giteaConnections.Set(func() interface{} {
+			var conns []*giteaConnection
+			for _, c := range conf.Get().Gitea {
+				conn, err := newGiteaConnection(c)
+				if err != nil {
+					log15.Error("Error processing configured Gitea connection. Skipping it.", "url", c.Url, "error", err)
+					continue
+				}
+				conns = append(conns, conn)
+			}
+			return conns
+		})
+		syncWorker.restart()
+	})
+}
+
+// getGiteaConnection returns the Gitea connection (config + API client) that is responsible for the repository
+// specified by the args.
+func getGiteaConnection(args protocol.RepoLookupArgs) (*giteaConnection, error) {
+	giteaConnections := giteaConnections.Get().([]*giteaConnection)
+	if args.ExternalRepo != nil && args.ExternalRepo.ServiceType == giteaServiceType {
+		// Look up by external repository spec.
+		for _, conn := range giteaConnections {
+			if args.ExternalRepo.ServiceID == conn.baseURL.String() {
+				return conn, nil
+			}
+		}
+		return nil, errors.Errorf("no configured Gitea connection with URL: %q", args.ExternalRepo.ServiceID)
+	}
+
+	if args.Repo != "" {
+		// Look up by repository URI.
+		repo := strings.ToLower(string(args.Repo))
+		for _, conn := range giteaConnections {
+			if strings.HasPrefix(repo, conn.baseURL.Hostname()+"/") {
+				return conn, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// GetGiteaRepository queries a configured Gitea connection endpoint for information about the specified
+// repository.
+//
+// If args.Repo refers to a repository that is not known to be on a configured Gitea connection's host, it
+// returns authoritative == false.
+func GetGiteaRepository(ctx context.Context, args protocol.RepoLookupArgs) (repo *protocol.RepoInfo, authoritative bool, err error) {
+	conn, err := getGiteaConnection(args)
+	if err != nil {
+		return nil, true, err // refers to a Gitea repo but the host is not configured
+	}
+	if conn == nil {
+		return nil, false, nil // refers to a non-Gitea repo
+	}
+
+	if args.ExternalRepo != nil && args.ExternalRepo.ServiceType == giteaServiceType {
+		// Look up by external repository spec.
+		id, err := strconv.ParseInt(args.ExternalRepo.ID, 10, 64)
+		if err != nil {
+			return nil, true, err
+		}
+		giteaRepo, err := conn.client.GetRepositoryByID(ctx, id)
+		if giteaRepo != nil {
+			repo = giteaRepoInfo(conn, giteaRepo)
+		}
+		return repo, true, err
+	}
+
+	if args.Repo != "" {
+		// Look up by repository URI.
+		nameWithOwner := strings.TrimPrefix(strings.ToLower(string(args.Repo)), conn.baseURL.Hostname()+"/")
+		i := strings.Index(nameWithOwner, "/")
+		if i < 0 {
+			return nil, true, errors.Errorf("malformed Gitea repository name with owner: %q", nameWithOwner)
+		}
+		giteaRepo, err := conn.client.GetRepository(ctx, nameWithOwner[:i], nameWithOwner[i+1:])
+		if giteaRepo != nil {
+			repo = giteaRepoInfo(conn, giteaRepo)
+		}
+		return repo, true, err
+	}
+
+	return nil, true, fmt.Errorf("unable to look up Gitea repository (%+v)", args)
+}
+
+func giteaRepoInfo(conn *giteaConnection, repo *gitea.Repository) *protocol.RepoInfo {
+	return &protocol.RepoInfo{
+		URI:          giteaRepoToRepoPath(conn, repo),
+		ExternalRepo: giteaExternalRepoSpec(repo, *conn.baseURL),
+		Description:  repo.Description,
+		Fork:         repo.Fork,
+		Archived:     repo.Archived,
+		VCS: protocol.VCSInfo{
+			URL: conn.authenticatedRemoteURL(repo),
+		},
+		Links: &protocol.RepoLinks{
+			Root:   repo.HTMLURL,
+			Tree:   repo.HTMLURL + "/src/{rev}/{path}",
+			Blob:   repo.HTMLURL + "/src/{rev}/{path}",
+			Commit: repo.HTMLURL + "/commit/{commit}",
+		},
+	}
+}
+
+func giteaRepoToRepoPath(conn *giteaConnection, repo *gitea.Repository) api.RepoURI {
+	return reposource.GiteaRepoURI(conn.config.RepositoryPathPattern, conn.baseURL.Hostname(), repo.FullName)
+}
+
+// A giteaSource lists the repositories of a Gitea connection.
+type giteaSource struct {
+	conn *giteaConnection
+}
+
+func (s *giteaSource) ExternalService() (serviceType, serviceID string) {
+	return giteaServiceType, s.conn.baseURL.String()
+}
+
+func (s *giteaSource) ListRepos(ctx context.Context) ([]*SourceRepo, error) {
+	giteaRepos, err := s.conn.listAllRepositories(ctx)
+	repos := make([]*SourceRepo, 0, len(giteaRepos))
+	for _, repo := range giteaRepos {
+		repos = append(repos, s.sourceRepo(repo))
+	}
+	return repos, err
+}
+
+func (s *giteaSource) GetRepo(ctx context.Context, id string) (*SourceRepo, error) {
+	repoID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := s.conn.client.GetRepositoryByID(ctx, repoID)
+	if errcode.IsNotFound(err) {
+		return nil, &repoNotFoundError{id: id, err: err}
+	} else if err != nil {
+		return nil, err
+	}
+	return s.sourceRepo(repo), nil
+}
+
+func (s *giteaSource) sourceRepo(repo *gitea.Repository) *SourceRepo {
+	return &SourceRepo{
+		RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+			RepoURI:      giteaRepoToRepoPath(s.conn, repo),
+			ExternalRepo: giteaExternalRepoSpec(repo, *s.conn.baseURL),
+			Description:  repo.Description,
+			Fork:         repo.Fork,
+			Archived:     repo.Archived,
+			Enabled:      s.conn.config.InitialRepositoryEnablement,
+		},
+		URL: s.conn.authenticatedRemoteURL(repo),
+	}
+}
+
+func newGiteaConnection(config *schema.GiteaConnection) (*giteaConnection, error) {
+	baseURL, err := url.Parse(config.Url)
+	if err != nil {
+		return nil, err
+	}
+	baseURL = NormalizeBaseURL(baseURL)
+
+	transport, err := cachedTransportWithCertTrusted(config.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &giteaConnection{
+		config:  config,
+		baseURL: baseURL,
+		client:  gitea.NewClient(baseURL, config.Token, transport),
+	}, nil
+}
+
+type giteaConnection struct {
+	config  *schema.GiteaConnection
+	baseURL *url.URL // the normalized base URL of the Gitea instance
+	client  *gitea.Client
+}
+
+// authenticatedRemoteURL returns the Gitea repository's Git remote URL with the configured Gitea access token
+// inserted in the URL userinfo.
+func (c *giteaConnection) authenticatedRemoteURL(repo *gitea.Repository) string {
+	if c.config.GitURLType == "ssh" {
+		return repo.SSHURL // SSH authentication must be provided out-of-band
+	}
+	u, err := url.Parse(repo.CloneURL)
+	if err != nil {
+		log15.Warn("Error adding authentication to Gitea repository Git remote URL.", "url", repo.CloneURL, "error", err)
+		return repo.CloneURL
+	}
+	// Gitea accepts an access token as the username if the password is "x-oauth-basic".
+	u.User = url.UserPassword(c.config.Token, "x-oauth-basic")
+	return u.String()
+}
+
+// listAllRepositories returns the repositories of the configured orgs and users, or all repositories that the
+// token's user has access to if none are configured. If any of them could not be listed, it returns those that were
+// along with the error.
+func (c *giteaConnection) listAllRepositories(ctx context.Context) ([]*gitea.Repository, error) {
+	type listFunc func(ctx context.Context, page int) ([]*gitea.Repository, error)
+	var lists []listFunc
+	for _, org := range c.config.Orgs {
+		org := org
+		lists = append(lists, func(ctx context.Context, page int) ([]*gitea.Repository, error) {
+			return c.client.ListOrgRepositories(ctx, org, page)
+		})
+	}
+	for _, user := range c.config.Users {
+		user := user
+		lists = append(lists, func(ctx context.Context, page int) ([]*gitea.Repository, error) {
+			return c.client.ListUserRepositories(ctx, user, page)
+		})
+	}
+	if len(lists) == 0 {
+		lists = append(lists, c.client.ListAuthenticatedUserRepositories)
+	}
+
+	var all []*gitea.Repository
+	seen := make(map[int64]bool)
+	for _, list := range lists {
+		for page := 1; ; page++ {
+			repos, err := list(ctx, page)
+			if err != nil {
+				return all, errors.Wrapf(err, "listing Gitea repositories at %s", c.baseURL)
+			}
+			if len(repos) == 0 {
+				break
+			}
+			for _, r := range repos {
+				if !seen[r.ID] {
+					all = append(all, r)
+					seen[r.ID] = true
+				}
+			}
+		}
+	}
+	return all, nil
+}
@@ -3,6 +3,7 @@ package repos
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
@@ -16,6 +17,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/atomicvalue"
 	"github.com/sourcegraph/sourcegraph/pkg/conf"
 	"github.com/sourcegraph/sourcegraph/pkg/conf/reposource"
+	"github.com/sourcegraph/sourcegraph/pkg/githubapp"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
 	"github.com/sourcegraph/sourcegraph/schema"
@@ -71,7 +73,7 @@ func init() {
 			}
 			return conns
 		})
-		gitHubRepositorySyncWorker.restart()
+		syncWorker.restart()
 	})
 }
 
@@ -84,7 +86,7 @@ func getGitHubConnection(args protocol.RepoLookupArgs) (*githubConnection, error
 		skippedBecauseNoAuth := false
 		for _, conn := range githubConnections {
 			if args.ExternalRepo.ServiceID == conn.baseURL.String() {
-				if canUseGraphQLAPI := conn.config.Token != ""; !canUseGraphQLAPI { // GraphQL API requires authentication
+				if canUseGraphQLAPI := conn.authenticated(); !canUseGraphQLAPI { // GraphQL API requires authentication
 					skippedBecauseNoAuth = true
 					continue
 				}
@@ -146,7 +148,7 @@ func GetGitHubRepository(ctx context.Context, args protocol.RepoLookupArgs) (rep
 				Commit: ghrepo.URL + "/commit/{commit}",
 			},
 			VCS: protocol.VCSInfo{
-				URL: conn.authenticatedRemoteURL(ghrepo),
+				URL: conn.authenticatedRemoteURL(ctx, ghrepo),
 			},
 		}
 	}
@@ -186,7 +188,7 @@ func GetGitHubRepository(ctx context.Context, args protocol.RepoLookupArgs) (rep
 
 		// For public repositories, we can bypass the GitHub API and still get almost everything we
 		// need (except for the repository's ID, description, and fork status).
-		isPublicRepo := args.Repo != "" && conn.config.Token == ""
+		isPublicRepo := args.Repo != "" && !conn.authenticated()
 		if isPublicRepo {
 			log15.Debug("Bypassing GitHub API when getting public repository. Some repository metadata fields will be blank.", logArgs...)
 
@@ -219,10 +221,10 @@ func GetGitHubRepository(ctx context.Context, args protocol.RepoLookupArgs) (rep
 
 	log15.Debug("GetGitHubRepository", "repo", args.Repo, "externalRepo", args.ExternalRepo)
 
-	canUseGraphQLAPI := conn.config.Token != "" // GraphQL API requires authentication
+	canUseGraphQLAPI := conn.authenticated() // GraphQL API requires authentication
 	if canUseGraphQLAPI && args.ExternalRepo != nil && args.ExternalRepo.ServiceType == GitHubServiceType {
 		// Look up by external repository spec.
-		ghrepo, err := conn.client.GetRepositoryByNodeID(ctx, args.ExternalRepo.ID)
+		ghrepo, err := conn.getRepositoryByNodeID(ctx, args.ExternalRepo.ID)
 		if ghrepo != nil {
 			repo = ghrepoToRepoInfo(ghrepo, conn)
 		}
@@ -237,7 +239,7 @@ func GetGitHubRepository(ctx context.Context, args protocol.RepoLookupArgs) (rep
 			return nil, true, err
 		}
 
-		ghrepo, err := conn.client.GetRepository(ctx, owner, repoName)
+		ghrepo, err := conn.getRepository(ctx, owner, repoName)
 		if ghrepo != nil {
 			repo = ghrepoToRepoInfo(ghrepo, conn)
 		}
@@ -247,63 +249,59 @@ func GetGitHubRepository(ctx context.Context, args protocol.RepoLookupArgs) (rep
 	return nil, true, fmt.Errorf("unable to look up GitHub repository (%+v)", args)
 }
 
-var gitHubRepositorySyncWorker = &worker{
-	work: func(ctx context.Context, shutdown chan struct{}) {
-		githubConnections := githubConnections.Get().([]*githubConnection)
-		if len(githubConnections) == 0 {
-			return
-		}
-		for _, c := range githubConnections {
-			go func(c *githubConnection) {
-				for {
-					if rateLimitRemaining, rateLimitReset, ok := c.client.RateLimit.Get(); ok && rateLimitRemaining < 200 {
-						wait := rateLimitReset + 10*time.Second
-						log15.Warn("GitHub API rate limit is almost exhausted. Waiting until rate limit is reset.", "wait", rateLimitReset, "rateLimitRemaining", rateLimitRemaining)
-						time.Sleep(wait)
-					}
-					updateGitHubRepositories(ctx, c)
-					githubUpdateTime.WithLabelValues(c.baseURL.String()).Set(float64(time.Now().Unix()))
-					select {
-					case <-shutdown:
-						return
-					case <-time.After(getUpdateInterval()):
-					}
-				}
-			}(c)
-		}
-	},
+func githubRepositoryToRepoPath(conn *githubConnection, repo *github.Repository) api.RepoURI {
+	return reposource.GitHubRepoURI(conn.config.RepositoryPathPattern, conn.originalHostname, repo.NameWithOwner)
 }
 
-// RunGitHubRepositorySyncWorker runs the worker that syncs repositories from the configured GitHub and GitHub
-// Enterprise instances to Sourcegraph.
-func RunGitHubRepositorySyncWorker(ctx context.Context) {
-	gitHubRepositorySyncWorker.start(ctx)
+// A githubSource lists the repositories of a GitHub connection.
+type githubSource struct {
+	conn *githubConnection
 }
 
-func githubRepositoryToRepoPath(conn *githubConnection, repo *github.Repository) api.RepoURI {
-	return reposource.GitHubRepoURI(conn.config.RepositoryPathPattern, conn.originalHostname, repo.NameWithOwner)
+func (s *githubSource) ExternalService() (serviceType, serviceID string) {
+	return GitHubServiceType, s.conn.baseURL.String()
 }
 
-// updateGitHubRepositories ensures that all provided repositories have been added and updated on Sourcegraph.
-func updateGitHubRepositories(ctx context.Context, conn *githubConnection) {
-	repos := conn.listAllRepositories(ctx)
-
-	repoChan := make(chan repoCreateOrUpdateRequest)
-	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("github:%s", conn.config.Token), repoChan)
-	for repo := range repos {
-		// log15.Debug("github sync: create/enable/update repo", "repo", repo.NameWithOwner)
-		repoChan <- repoCreateOrUpdateRequest{
-			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
-				RepoURI:      githubRepositoryToRepoPath(conn, repo),
-				ExternalRepo: GitHubExternalRepoSpec(repo, *conn.baseURL),
-				Description:  repo.Description,
-				Fork:         repo.IsFork,
-				Archived:     repo.IsArchived,
-				Enabled:      conn.config.InitialRepositoryEnablement,
-			},
-			URL: conn.authenticatedRemoteURL(repo),
-		}
+func (s *githubSource) ListRepos(ctx context.Context) ([]*SourceRepo, error) {
+	c := s.conn
+	if rateLimitRemaining, rateLimitReset, ok := c.client.RateLimit.Get(); ok && rateLimitRemaining < 200 {
+		wait := rateLimitReset + 10*time.Second
+		log15.Warn("GitHub API rate limit is almost exhausted. Waiting until rate limit is reset.", "wait", rateLimitReset, "rateLimitRemaining", rateLimitRemaining)
+		time.Sleep(wait)
+	}
+
+	ghrepos, err := c.listAllRepositories(ctx)
+	repos := make([]*SourceRepo, 0, len(ghrepos))
+	for _, repo := range ghrepos {
+		repos = append(repos, s.sourceRepo(ctx, repo))
+	}
+	return repos, err
+}
+
+func (s *githubSource) GetRepo(ctx context.Context, id string) (*SourceRepo, error) {
+	if !s.conn.authenticated() { // GraphQL API requires authentication
+		return nil, errors.New("unable to look up GitHub repository by ID without a token")
+	}
+	repo, err := s.conn.getRepositoryByNodeID(ctx, id)
+	if github.IsNotFound(err) {
+		return nil, &repoNotFoundError{id: id, err: err}
+	} else if err != nil {
+		return nil, err
+	}
+	return s.sourceRepo(ctx, repo), nil
+}
+
+func (s *githubSource) sourceRepo(ctx context.Context, repo *github.Repository) *SourceRepo {
+	return &SourceRepo{
+		RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+			RepoURI:      githubRepositoryToRepoPath(s.conn, repo),
+			ExternalRepo: GitHubExternalRepoSpec(repo, *s.conn.baseURL),
+			Description:  repo.Description,
+			Fork:         repo.IsFork,
+			Archived:     repo.IsArchived,
+			Enabled:      s.conn.config.InitialRepositoryEnablement,
+		},
+		URL: s.conn.authenticatedRemoteURL(ctx, repo),
 	}
 }
 
@@ -334,35 +332,163 @@ func newGitHubConnection(config *schema.GitHubConnection) (*githubConnection, er
 		return nil, err
 	}
 
-	return &githubConnection{
+	conn := &githubConnection{
 		config:           config,
 		baseURL:          baseURL,
 		githubDotCom:     githubDotCom,
 		client:           github.NewClient(&apiURL, config.Token, transport),
 		originalHostname: originalHostname,
-	}, nil
+	}
+	if config.GithubApp != nil {
+		privateKey, err := githubapp.ParsePrivateKey(config.GithubApp.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		conn.app = github.NewApp(&apiURL, int64(config.GithubApp.AppID), privateKey, transport)
+		conn.apiURL = &apiURL
+		conn.transport = transport
+	}
+	return conn, nil
 }
 
 type githubConnection struct {
 	config       *schema.GitHubConnection
 	githubDotCom bool
 	baseURL      *url.URL
-	client       *github.Client
+	client       *github.Client // unauthenticated if the connection authenticates as a GitHub App
 
 	// originalHostname is the hostname of config.Url (differs from client APIURL, whose host is api.github.com
 	// for an originalHostname of github.com).
 	originalHostname string
+
+	// app is the GitHub App that the connection authenticates as (instead of with a token), if
+	// configured. Each of its installations has its own client (with apiURL and transport).
+	app       *githubapp.App
+	apiURL    *url.URL
+	transport http.RoundTripper
+
+	mu                   sync.Mutex
+	installations        []*githubInstallation // the app's installations (cached)
+	installationsFetched time.Time             // when installations was last listed
+}
+
+// A githubInstallation is an installation of a connection's GitHub App on an organization or user
+// account.
+type githubInstallation struct {
+	id     int64
+	owner  string // the lowercase login of the account
+	client *github.Client
+}
+
+// authenticated reports whether the connection authenticates its API requests, with a token or as a
+// GitHub App.
+func (c *githubConnection) authenticated() bool {
+	return c.config.Token != "" || c.app != nil
+}
+
+// listInstallations returns the installations of the connection's GitHub App. They are listed
+// at most once per minute, so that new installations are picked up soon without listing them
+// for every request.
+func (c *githubConnection) listInstallations(ctx context.Context) ([]*githubInstallation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.installationsFetched) < time.Minute {
+		return c.installations, nil
+	}
+
+	installations, err := c.app.Installations(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "listing GitHub App installations")
+	}
+	clients := make(map[int64]*github.Client, len(c.installations))
+	for _, inst := range c.installations {
+		clients[inst.id] = inst.client // reuse clients to keep their rate limit monitors
+	}
+	c.installations = make([]*githubInstallation, 0, len(installations))
+	for _, inst := range installations {
+		client := clients[inst.ID]
+		if client == nil {
+			client = github.NewAppInstallationClient(c.apiURL, c.app, inst.ID, c.transport)
+		}
+		c.installations = append(c.installations, &githubInstallation{
+			id:     inst.ID,
+			owner:  strings.ToLower(inst.Account.Login),
+			client: client,
+		})
+	}
+	c.installationsFetched = time.Now()
+	return c.installations, nil
+}
+
+// installationForOwner returns the GitHub App installation on the owner's account. If the app is
+// not installed there, it returns another installation (which can still access public
+// repositories).
+func (c *githubConnection) installationForOwner(ctx context.Context, owner string) (*githubInstallation, error) {
+	installations, err := c.listInstallations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(installations) == 0 {
+		return nil, errors.Errorf("GitHub App %d has no installations", c.app.ID)
+	}
+	for _, inst := range installations {
+		if inst.owner == strings.ToLower(owner) {
+			return inst, nil
+		}
+	}
+	return installations[0], nil
+}
+
+// clientForOwner returns the client to access the owner's repositories with.
+func (c *githubConnection) clientForOwner(ctx context.Context, owner string) (*github.Client, error) {
+	if c.app == nil {
+		return c.client, nil
+	}
+	inst, err := c.installationForOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return inst.client, nil
+}
+
+func (c *githubConnection) getRepository(ctx context.Context, owner, name string) (*github.Repository, error) {
+	client, err := c.clientForOwner(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetRepository(ctx, owner, name)
+}
+
+// getRepositoryByNodeID looks up the repository with the client of each of the GitHub App's
+// installations in turn (if the connection authenticates as a GitHub App), because the node ID
+// doesn't tell which installation has access to it.
+func (c *githubConnection) getRepositoryByNodeID(ctx context.Context, id string) (*github.Repository, error) {
+	if c.app == nil {
+		return c.client.GetRepositoryByNodeID(ctx, id)
+	}
+	installations, err := c.listInstallations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, inst := range installations {
+		repo, err := inst.client.GetRepositoryByNodeID(ctx, id)
+		if !github.IsNotFound(err) {
+			return repo, err
+		}
+	}
+	return nil, github.ErrNotFound
 }
 
 // authenticatedRemoteURL returns the repository's Git remote URL with the configured
-// GitHub personal access token inserted in the URL userinfo.
-func (c *githubConnection) authenticatedRemoteURL(repo *github.Repository) string {
+// GitHub personal access token (or an access token of the GitHub App's installation on
+// the repository owner's account) inserted in the URL userinfo.
+func (c *githubConnection) authenticatedRemoteURL(ctx context.Context, repo *github.Repository) string {
 	if c.config.GitURLType == "ssh" {
 		url := fmt.Sprintf("git@%s:%s.git", c.originalHostname, repo.NameWithOwner)
 		return url
 	}
 
-	if c.config.Token == "" {
+	if !c.authenticated() {
 		return repo.URL
 	}
 	u, err := url.Parse(repo.URL)
@@ -370,13 +496,92 @@ func (c *githubConnection) authenticatedRemoteURL(repo *github.Repository) strin
 		log15.Warn("Error adding authentication to GitHub repository Git remote URL.", "url", repo.URL, "error", err)
 		return repo.URL
 	}
-	u.User = url.User(c.config.Token)
+	if c.app == nil {
+		u.User = url.User(c.config.Token)
+		return u.String()
+	}
+
+	// Installation tokens expire after an hour, so the token is refreshed before each update
+	// request (see refreshGitHubAppRemoteURL), and gitserver doesn't store it in the clone.
+	owner, _, _ := github.SplitRepositoryNameWithOwner(repo.NameWithOwner)
+	return c.installationRemoteURL(ctx, u, owner)
+}
+
+// installationRemoteURL returns the Git remote URL u with an access token of the GitHub
+// App's installation on the owner's account inserted in the URL userinfo.
+func (c *githubConnection) installationRemoteURL(ctx context.Context, u *url.URL, owner string) string {
+	inst, err := c.installationForOwner(ctx, owner)
+	var token string
+	if err == nil {
+		token, err = c.app.InstallationToken(ctx, inst.id)
+	}
+	if err != nil {
+		u.User = nil
+		log15.Warn("Error getting GitHub App installation token for GitHub repository Git remote URL.", "url", u.String(), "error", err)
+		return u.String()
+	}
+	u.User = url.UserPassword("x-access-token", token)
 	return u.String()
 }
 
-func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *github.Repository {
-	const first = 100 // max GitHub API "first" parameter
-	ch := make(chan *github.Repository, first)
+// refreshGitHubAppRemoteURL returns remoteURL with a current installation access token, if
+// it is the Git remote URL of a repository on a GitHub App connection. Otherwise, it returns
+// remoteURL unchanged.
+//
+// The scheduler calls it before each update request, since the remote URLs it keeps are
+// from the last sync, and installation tokens expire after an hour.
+func refreshGitHubAppRemoteURL(ctx context.Context, remoteURL string) string {
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.User == nil || u.User.Username() != "x-access-token" {
+		return remoteURL
+	}
+	for _, c := range githubConnections.Get().([]*githubConnection) {
+		if c.app == nil || c.config.GitURLType == "ssh" || !strings.EqualFold(u.Hostname(), c.originalHostname) {
+			continue
+		}
+		owner := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+		return c.installationRemoteURL(ctx, u, owner)
+	}
+	return remoteURL
+}
+
+// listsRepos reports whether the connection is configured to list any
+// repositories. The default GitHub.com connection doesn't.
+func (c *githubConnection) listsRepos() bool {
+	if len(c.config.Repos) > 0 || len(c.config.RepositoryQuery) == 0 {
+		return true
+	}
+	for _, q := range c.config.RepositoryQuery {
+		if q != "none" {
+			return true
+		}
+	}
+	return false
+}
+
+// listAllRepositories returns the repositories matching the connection's
+// repositoryQuery, and those in its repos list. If any of them could not be
+// listed, it returns those that were along with the error.
+func (c *githubConnection) listAllRepositories(ctx context.Context) ([]*github.Repository, error) {
+	var (
+		mu    sync.Mutex
+		repos []*github.Repository
+		seen  = make(map[string]bool)
+		errs  []error
+	)
+	add := func(repo *github.Repository) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !seen[repo.URL] {
+			repos = append(repos, repo)
+			seen[repo.URL] = true
+		}
+	}
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
 
 	var wg sync.WaitGroup
 
@@ -399,11 +604,16 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 					log15.Warn(`ignoring unsupported configuration "public" for "repositoryQuery" for github.com`)
 					return
 				}
+				client, err := c.clientForOwner(ctx, "")
+				if err != nil {
+					fail(err)
+					return
+				}
 				var sinceRepoID int64
 				for {
-					repos, err := c.client.ListPublicRepositories(ctx, sinceRepoID)
+					repos, err := client.ListPublicRepositories(ctx, sinceRepoID)
 					if err != nil {
-						log15.Error("Error listing public repositories", "sinceRepoID", sinceRepoID, "error", err)
+						fail(errors.Wrapf(err, "listing public repositories since %d", sinceRepoID))
 						return
 					}
 					if len(repos) == 0 {
@@ -411,13 +621,20 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 						return
 					}
 					for _, r := range repos {
-						ch <- r
+						add(r)
 						if sinceRepoID < r.DatabaseID {
 							sinceRepoID = r.DatabaseID
 						}
 					}
 				}
 			case "affiliated":
+				if c.app != nil {
+					// The repositories affiliated with a GitHub App are those of its installations.
+					if err := c.listInstallationRepositories(ctx, add); err != nil {
+						fail(err)
+					}
+					return
+				}
 				hasNextPage := true
 				for page := 1; hasNextPage; page++ {
 					var repos []*github.Repository
@@ -425,8 +642,8 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 					var err error
 					repos, hasNextPage, rateLimitCost, err = c.client.ListViewerRepositories(ctx, page)
 					if err != nil {
-						log15.Error("Error listing viewer's affiliated GitHub repositories", "page", page, "error", err)
-						break
+						fail(errors.Wrapf(err, "listing viewer's affiliated repositories (page %d)", page))
+						return
 					}
 					rateLimitRemaining, rateLimitReset, _ := c.client.RateLimit.Get()
 					log15.Debug("github sync: ListViewerRepositories", "repos", len(repos), "rateLimitCost", rateLimitCost, "rateLimitRemaining", rateLimitRemaining, "rateLimitReset", rateLimitReset)
@@ -435,8 +652,7 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 							log15.Debug("not syncing readonly fork", "repo", r.NameWithOwner)
 							continue
 						}
-						// log15.Debug("github sync: ListViewerRepositories: repo", "repo", r.NameWithOwner)
-						ch <- r
+						add(r)
 					}
 					if hasNextPage {
 						time.Sleep(c.client.RateLimit.RecommendedWaitForBackgroundOp(rateLimitCost))
@@ -461,38 +677,51 @@ func (c *githubConnection) listAllRepositories(ctx context.Context) <-chan *gith
 				log15.Error("Invalid GitHub repository", "nameWithOwner", nameWithOwner)
 				continue
 			}
-			repo, err := c.client.GetRepository(ctx, owner, name)
-			if err != nil {
-				log15.Error("Error getting GitHub repository", "nameWithOwner", nameWithOwner, "error", err)
+			repo, err := c.getRepository(ctx, owner, name)
+			if github.IsNotFound(err) {
+				log15.Warn("GitHub repository not found", "nameWithOwner", nameWithOwner)
 				continue
+			} else if err != nil {
+				fail(errors.Wrapf(err, "getting repository %s", nameWithOwner))
+				return
 			}
 			log15.Debug("github sync: GetRepository", "repo", repo.NameWithOwner)
-			ch <- repo
+			add(repo)
 			time.Sleep(c.client.RateLimit.RecommendedWaitForBackgroundOp(1)) // 0-duration sleep unless nearing rate limit exhaustion
 		}
 	}()
 
-	go func() {
-		wg.Wait()
-		close(ch)
-	}()
-
-	return unique(ch)
+	wg.Wait()
+	if len(errs) > 0 {
+		return repos, errs[0]
+	}
+	return repos, nil
 }
 
-// unique returns a channel that only forwards repositories
-// that have never been sent on the channel before.
-func unique(in <-chan *github.Repository) <-chan *github.Repository {
-	out := make(chan *github.Repository)
-	go func() {
-		found := make(map[string]struct{})
-		for repo := range in {
-			if _, ok := found[repo.URL]; !ok {
-				out <- repo
-				found[repo.URL] = struct{}{}
+// listInstallationRepositories calls add for each repository that the GitHub App's installations
+// have access to.
+func (c *githubConnection) listInstallationRepositories(ctx context.Context, add func(*github.Repository)) error {
+	installations, err := c.listInstallations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, inst := range installations {
+		hasNextPage := true
+		for page := 1; hasNextPage; page++ {
+			var repos []*github.Repository
+			var rateLimitCost int
+			var err error
+			repos, hasNextPage, rateLimitCost, err = inst.client.ListInstallationRepositories(ctx, page)
+			if err != nil {
+				return errors.Wrapf(err, "listing repositories of GitHub App installation %d (page %d)", inst.id, page)
+			}
+			for _, r := range repos {
+				add(r)
+			}
+			if hasNextPage {
+				time.Sleep(inst.client.RateLimit.RecommendedWaitForBackgroundOp(rateLimitCost))
 			}
 		}
-		close(out)
-	}()
-	return out
+	}
+	return nil
 }
@@ -68,7 +68,7 @@ func init() {
 			}
 			return conns
 		})
-		gitLabRepositorySyncWorker.restart()
+		syncWorker.restart()
 	})
 }
 
@@ -165,62 +165,60 @@ func GetGitLabRepository(ctx context.Context, args protocol.RepoLookupArgs) (rep
 	return nil, true, fmt.Errorf("unable to look up GitLab repository (%+v)", args)
 }
 
-var gitLabRepositorySyncWorker = &worker{
-	work: func(ctx context.Context, shutdown chan struct{}) {
-		gitlabConnections := gitlabConnections.Get().([]*gitlabConnection)
-		if len(gitlabConnections) == 0 {
-			return
-		}
-		for _, c := range gitlabConnections {
-			go func(c *gitlabConnection) {
-				for {
-					if rateLimitRemaining, rateLimitReset, ok := c.client.RateLimit.Get(); ok && rateLimitRemaining < 50 {
-						wait := rateLimitReset + 10*time.Second
-						log15.Warn("GitLab API rate limit is almost exhausted. Waiting until rate limit is reset.", "wait", rateLimitReset, "rateLimitRemaining", rateLimitRemaining)
-						time.Sleep(wait)
-					}
-					updateGitLabProjects(ctx, c)
-					gitlabUpdateTime.WithLabelValues(c.baseURL.String()).Set(float64(time.Now().Unix()))
-					select {
-					case <-shutdown:
-						return
-					case <-time.After(getUpdateInterval()):
-					}
-				}
-			}(c)
-		}
-	},
+func gitlabProjectToRepoPath(conn *gitlabConnection, proj *gitlab.Project) api.RepoURI {
+	return reposource.GitLabRepoURI(conn.config.RepositoryPathPattern, conn.baseURL.Hostname(), proj.PathWithNamespace)
 }
 
-// RunGitLabRepositorySyncWorker runs the worker that syncs projects from configured GitLab instances to
-// Sourcegraph.
-func RunGitLabRepositorySyncWorker(ctx context.Context) {
-	gitLabRepositorySyncWorker.start(ctx)
+// A gitlabSource lists the projects of a GitLab connection.
+type gitlabSource struct {
+	conn *gitlabConnection
 }
 
-func gitlabProjectToRepoPath(conn *gitlabConnection, proj *gitlab.Project) api.RepoURI {
-	return reposource.GitLabRepoURI(conn.config.RepositoryPathPattern, conn.baseURL.Hostname(), proj.PathWithNamespace)
+func (s *gitlabSource) ExternalService() (serviceType, serviceID string) {
+	return GitLabServiceType, s.conn.baseURL.String()
 }
 
-// updateGitLabProjects ensures that all provided repositories exist in the repository table.
-func updateGitLabProjects(ctx context.Context, conn *gitlabConnection) {
-	projs := conn.listAllProjects(ctx)
-
-	repoChan := make(chan repoCreateOrUpdateRequest)
-	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("gitlab:%s", conn.config.Token), repoChan)
-	for proj := range projs {
-		repoChan <- repoCreateOrUpdateRequest{
-			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
-				RepoURI:      gitlabProjectToRepoPath(conn, proj),
-				ExternalRepo: GitLabExternalRepoSpec(proj, *conn.baseURL),
-				Description:  proj.Description,
-				Fork:         proj.ForkedFromProject != nil,
-				Archived:     proj.Archived,
-				Enabled:      conn.config.InitialRepositoryEnablement,
-			},
-			URL: conn.authenticatedRemoteURL(proj),
-		}
+func (s *gitlabSource) ListRepos(ctx context.Context) ([]*SourceRepo, error) {
+	c := s.conn
+	if rateLimitRemaining, rateLimitReset, ok := c.client.RateLimit.Get(); ok && rateLimitRemaining < 50 {
+		wait := rateLimitReset + 10*time.Second
+		log15.Warn("GitLab API rate limit is almost exhausted. Waiting until rate limit is reset.", "wait", rateLimitReset, "rateLimitRemaining", rateLimitRemaining)
+		time.Sleep(wait)
+	}
+
+	projs, err := c.listAllProjects(ctx)
+	repos := make([]*SourceRepo, 0, len(projs))
+	for _, proj := range projs {
+		repos = append(repos, s.sourceRepo(proj))
+	}
+	return repos, err
+}
+
+func (s *gitlabSource) GetRepo(ctx context.Context, id string) (*SourceRepo, error) {
+	projID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+	proj, err := s.conn.client.GetProject(ctx, projID, "")
+	if gitlab.IsNotFound(err) {
+		return nil, &repoNotFoundError{id: id, err: err}
+	} else if err != nil {
+		return nil, err
+	}
+	return s.sourceRepo(proj), nil
+}
+
+func (s *gitlabSource) sourceRepo(proj *gitlab.Project) *SourceRepo {
+	return &SourceRepo{
+		RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+			RepoURI:      gitlabProjectToRepoPath(s.conn, proj),
+			ExternalRepo: GitLabExternalRepoSpec(proj, *s.conn.baseURL),
+			Description:  proj.Description,
+			Fork:         proj.ForkedFromProject != nil,
+			Archived:     proj.Archived,
+			Enabled:      s.conn.config.InitialRepositoryEnablement,
+		},
+		URL: s.conn.authenticatedRemoteURL(proj),
 	}
 }
 
@@ -268,7 +266,24 @@ func (c *gitlabConnection) authenticatedRemoteURL(proj *gitlab.Project) string {
 	return u.String()
 }
 
-func (c *gitlabConnection) listAllProjects(ctx context.Context) <-chan *gitlab.Project {
+// listsRepos reports whether the connection is configured to list any
+// projects. The default GitLab.com connection doesn't.
+func (c *gitlabConnection) listsRepos() bool {
+	if len(c.config.ProjectQuery) == 0 {
+		return true
+	}
+	for _, q := range c.config.ProjectQuery {
+		if q != "none" {
+			return true
+		}
+	}
+	return false
+}
+
+// listAllProjects returns the projects matching the connection's
+// projectQuery. If any of them could not be listed, it returns those that were
+// along with the error.
+func (c *gitlabConnection) listAllProjects(ctx context.Context) ([]*gitlab.Project, error) {
 	if len(c.config.ProjectQuery) == 0 {
 		c.config.ProjectQuery = []string{"?membership=true"}
 	}
@@ -286,38 +301,36 @@ func (c *gitlabConnection) listAllProjects(ctx context.Context) <-chan *gitlab.P
 	}
 
 	const perPage = 100 // max GitLab API per_page parameter
-	ch := make(chan *gitlab.Project, perPage)
-	go func() {
-	projectsQueries:
-		for _, projectQuery := range c.config.ProjectQuery {
-			if projectQuery == "none" {
-				continue
-			}
-			q, err := normalizeQuery(projectQuery)
+	var projs []*gitlab.Project
+	seen := make(map[int]bool)
+	for _, projectQuery := range c.config.ProjectQuery {
+		if projectQuery == "none" {
+			continue
+		}
+		q, err := normalizeQuery(projectQuery)
+		if err != nil {
+			log15.Error("Skipping invalid GitLab projectQuery", "projectQuery", projectQuery, "error", err)
+			continue
+		}
+		q.Set("per_page", strconv.Itoa(perPage))
+
+		url := "projects?" + q.Encode() // first page URL
+		for {
+			projects, nextPageURL, err := c.client.ListProjects(ctx, url)
 			if err != nil {
-				log15.Error("Skipping invalid GitLab projectQuery", "projectQuery", projectQuery, "error", err)
-				continue
+				return projs, errors.Wrapf(err, "listing GitLab projects %s", url)
 			}
-			q.Set("per_page", strconv.Itoa(perPage))
-
-			url := "projects?" + q.Encode() // first page URL
-			for {
-				projects, nextPageURL, err := c.client.ListProjects(ctx, url)
-				if err != nil {
-					log15.Error("Error listing GitLab projects", "url", url, "error", err)
-					continue projectsQueries
-				}
-				for _, p := range projects {
-					ch <- p
+			for _, p := range projects {
+				if !seen[p.ID] {
+					projs = append(projs, p)
+					seen[p.ID] = true
 				}
-				if nextPageURL == nil {
-					break
-				}
-				url = *nextPageURL
 			}
+			if nextPageURL == nil {
+				break
+			}
+			url = *nextPageURL
 		}
-		close(ch)
-	}()
-
-	return ch
+	}
+	return projs, nil
 }
@@ -2,7 +2,6 @@ package repos
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -21,26 +20,28 @@ var (
 	phabTaskMu      sync.Mutex
 )
 
-// RunGitoliteRepositorySyncWorker runs the worker that syncs repositories from gitolite hosts to Sourcegraph
-//
-// If there is a PhabricatorMetadataCommand set, every ten loops we will try to run that for every
-// repo also.
-func RunGitoliteRepositorySyncWorker(ctx context.Context) {
-	phabricatorMetadataCounter := 0
+// gitoliteServiceType is the (api.ExternalRepoSpec).ServiceType value for
+// Gitolite repositories. The ServiceID value is the Gitolite host, and the
+// ID is the repository name.
+const gitoliteServiceType = "gitolite"
+
+// RunGitolitePhabricatorMetadataWorker runs the worker that creates
+// Phabricator mappings for Gitolite repositories, for connections with a
+// PhabricatorMetadataCommand. It runs every ten update intervals.
+func RunGitolitePhabricatorMetadataWorker(ctx context.Context) {
 	for {
-		log15.Debug("RunGitoliteRepositorySyncWorker:GitoliteUpdateRepos")
 		for _, gconf := range conf.Get().Gitolite {
-			if err := gitoliteUpdateRepos(ctx, gconf, (phabricatorMetadataCounter%10) == 0); err != nil {
-				log15.Error("error updating Gitolite repositories", "err", err, "prefix", gconf.Prefix)
-			} else {
-				log15.Debug("updated Gitolite repositories", "prefix", gconf.Prefix)
+			if gconf.PhabricatorMetadataCommand == "" {
+				continue
+			}
+			rlist, err := gitserver.DefaultClient.ListGitolite(ctx, gconf.Host)
+			if err != nil {
+				log15.Error("error listing Gitolite repositories", "err", err, "prefix", gconf.Prefix)
+				continue
 			}
+			tryUpdateGitolitePhabricatorMetadata(ctx, gconf, rlist)
 		}
-		// If you are looking at this line of code because this ran long enough that
-		// an int wrapped, I'm sorry.
-		phabricatorMetadataCounter++
-		gitoliteUpdateTime.Set(float64(time.Now().Unix()))
-		time.Sleep(getUpdateInterval())
+		time.Sleep(10 * getUpdateInterval())
 	}
 }
 
@@ -93,31 +94,68 @@ func tryUpdateGitolitePhabricatorMetadata(ctx context.Context, gconf *schema.Git
 	log15.Info("updated gitolite/phabricator metadata for repos", "repos", len(repos))
 }
 
-// gitoliteUpdateRepos updates the repos associated with a specific
-// Gitolite connection.
-func gitoliteUpdateRepos(ctx context.Context, gconf *schema.GitoliteConnection, doPhabricator bool) error {
-	// Get list of Gitolite repositories for this connection.
-	rlist, err := gitserver.DefaultClient.ListGitolite(ctx, gconf.Host)
+// A gitoliteSource lists the repositories of a Gitolite connection.
+type gitoliteSource struct {
+	conf *schema.GitoliteConnection
+
+	mu     sync.Mutex
+	listed map[string]bool // the repositories listed by ListRepos, if it succeeded
+}
+
+func (s *gitoliteSource) ExternalService() (serviceType, serviceID string) {
+	return gitoliteServiceType, s.conf.Host
+}
+
+func (s *gitoliteSource) ListRepos(ctx context.Context) ([]*SourceRepo, error) {
+	rlist, err := gitserver.DefaultClient.ListGitolite(ctx, s.conf.Host)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	listed := make(map[string]bool, len(rlist))
+	repos := make([]*SourceRepo, 0, len(rlist))
+	for _, entry := range rlist {
+		listed[entry] = true
+		repos = append(repos, s.sourceRepo(entry))
 	}
+	s.mu.Lock()
+	s.listed = listed
+	s.mu.Unlock()
+	return repos, nil
+}
 
-	repoChan := make(chan repoCreateOrUpdateRequest)
-	defer close(repoChan)
-	go createEnableUpdateRepos(ctx, fmt.Sprintf("gitolite:%s", gconf.Prefix), repoChan)
-	if doPhabricator && gconf.PhabricatorMetadataCommand != "" {
-		go tryUpdateGitolitePhabricatorMetadata(ctx, gconf, rlist)
+// GetRepo looks the repository up in the listing of ListRepos, since Gitolite
+// can't look up a single one. If ListRepos wasn't called (or failed), it lists
+// all repositories once.
+func (s *gitoliteSource) GetRepo(ctx context.Context, id string) (*SourceRepo, error) {
+	s.mu.Lock()
+	listed := s.listed
+	s.mu.Unlock()
+	if listed == nil {
+		if _, err := s.ListRepos(ctx); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		listed = s.listed
+		s.mu.Unlock()
 	}
-	for _, entry := range rlist {
-		// We don't have descriptions available for these. The old code didn't do that either.
-		url := strings.Replace(entry, gconf.Prefix, gconf.Host+":", 1)
-		repoChan <- repoCreateOrUpdateRequest{
-			RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
-				RepoURI: api.RepoURI(entry),
-				Enabled: true,
+	if !listed[id] {
+		return nil, &repoNotFoundError{id: id}
+	}
+	return s.sourceRepo(id), nil
+}
+
+func (s *gitoliteSource) sourceRepo(entry string) *SourceRepo {
+	// We don't have descriptions available for these. The old code didn't do that either.
+	return &SourceRepo{
+		RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+			RepoURI: api.RepoURI(entry),
+			ExternalRepo: &api.ExternalRepoSpec{
+				ID:          entry,
+				ServiceType: gitoliteServiceType,
+				ServiceID:   s.conf.Host,
 			},
-			URL: url,
-		}
+			Enabled: true,
+		},
+		URL: strings.Replace(entry, s.conf.Prefix, s.conf.Host+":", 1),
 	}
-	return nil
 }
@@ -33,6 +33,18 @@ var (
 		Name:      "time_last_bitbucketserver_sync",
 		Help:      "The last time a comprehensive Bitbucket Server sync finished",
 	}, []string{"id"})
+	bitbucketCloudUpdateTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "repoupdater",
+		Name:      "time_last_bitbucketcloud_sync",
+		Help:      "The last time a comprehensive Bitbucket Cloud sync finished",
+	}, []string{"id"})
+	giteaUpdateTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "src",
+		Subsystem: "repoupdater",
+		Name:      "time_last_gitea_sync",
+		Help:      "The last time a comprehensive Gitea sync finished",
+	}, []string{"id"})
 
 	// gitoliteUpdateTime is an ugly duckling as repo-updater just
 	// hits a HTTP endpoint on the frontend that returns after
@@ -53,6 +65,13 @@ var (
 		Help:      "The time the last repository sync loop completed",
 	})
 
+	syncedRepos = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "src",
+		Subsystem: "repoupdater",
+		Name:      "synced_repos",
+		Help:      "Incremented each time the syncer creates, updates, renames or deletes a repository.",
+	}, []string{"state"})
+
 	purgeSuccess = prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: "src",
 		Subsystem: "repoupdater",
@@ -116,8 +135,11 @@ func init() {
 	prometheus.MustRegister(awsCodeCommitUpdateTime)
 	prometheus.MustRegister(phabricatorUpdateTime)
 	prometheus.MustRegister(bitbucketServerUpdateTime)
+	prometheus.MustRegister(bitbucketCloudUpdateTime)
+	prometheus.MustRegister(giteaUpdateTime)
 	prometheus.MustRegister(gitoliteUpdateTime)
 	prometheus.MustRegister(repoListUpdateTime)
+	prometheus.MustRegister(syncedRepos)
 	prometheus.MustRegister(purgeSuccess)
 	prometheus.MustRegister(purgeFailed)
 	prometheus.MustRegister(purgeSkipped)
@@ -77,6 +77,10 @@ type repoData struct {
 	// LastUpdateDuration is the duration of last update.
 	LastUpdateDuration time.Duration
 
+	// LastChanged is when the repository last changed, as reported by
+	// gitserver. It is nil if unknown.
+	LastChanged *time.Time
+
 	// AutoUpdate is true if repo should be scheduled for automatic updates.
 	AutoUpdate bool
 
@@ -87,6 +91,10 @@ type repoData struct {
 	// IsUpdating is true if the repository is currently being updated.
 	IsUpdating bool
 
+	// UpdateAgain is true if repo should be updated again once the running
+	// update finishes, because it was pushed to during the update.
+	UpdateAgain bool
+
 	// UpdateInterval is how often we should automatically check for updates
 	// in a repo.
 	UpdateInterval time.Duration
@@ -184,6 +192,10 @@ type repoList struct {
 	stats               repoListStats             // usage stats so we can observe usage
 	activeRequests      int                       // current active requests
 	maxRequests         int                       // max requests we should attempt at once
+
+	// Repo schedules are saved so that they survive restarts (see schedules.go).
+	saved   map[string]*api.RepoUpdateSchedule // schedules saved before the restart, of repos not added yet
+	unsaved map[string]*repoData               // repos whose schedule changed since it was last saved
 }
 
 // A configuredRepo represents the configuration data for a given repo from
@@ -378,6 +390,33 @@ func (r *repoList) update(name, url string) {
 	r.ping(repo.Due, repo.Name)
 }
 
+// bump gives the named repository priority over other repositories on the
+// high priority queue, and shortens its update interval, because it was just
+// pushed to. If it is being updated, it is updated again afterwards, since
+// the running update may have started before the push. It does not create
+// the repository if it doesn't already exist.
+//
+// call only when you hold the mutex.
+func (r *repoList) bump(name string) {
+	repo, ok := r.repos[name]
+	if !ok {
+		return
+	}
+	// The repository just changed, so check it often again.
+	repo.UpdateInterval = r.interval(0)
+	if repo.IsUpdating {
+		repo.UpdateAgain = true
+		return
+	}
+	for i, bumped := range r.bumped {
+		if bumped == repo {
+			copy(r.bumped[1:i+1], r.bumped[:i])
+			r.bumped[0] = repo
+			break
+		}
+	}
+}
+
 // add creates the repository described, and schedules it for
 // an initial clone sync. do not call add unless you hold the mutex
 // for repoList.
@@ -398,6 +437,20 @@ func (r *repoList) add(name, url string, queue bool) {
 		LastUpdateDuration: 1 * time.Second,
 	}
 	r.repos[name] = repo
+	if saved, ok := r.saved[name]; ok {
+		delete(r.saved, name)
+		repo.UpdateInterval = saved.UpdateInterval
+		repo.LastUpdateDuration = saved.LastUpdateDuration
+		repo.LastChanged = saved.LastChanged
+		// Resume the schedule from before repo-updater restarted, instead
+		// of updating the repo as if it were new.
+		if queue && !r.autoUpdatesDisabled {
+			repo.Due = saved.Due
+			heap.Push(&r.heap, repo)
+			r.ping(repo.Due, repo.Name)
+			return
+		}
+	}
 	if queue {
 		r.newQueue = append(r.newQueue, repo)
 	} else {
@@ -462,6 +515,7 @@ func (r *repoList) doUpdate(ctx context.Context, repo *repoData) {
 		if manual {
 			interval = 5 * time.Second
 		}
+		url = refreshGitHubAppRemoteURL(ctx, url)
 		resp, err = gitserver.DefaultClient.RequestRepoUpdate(ctx, gitserver.Repo{Name: api.RepoURI(name), URL: url}, interval)
 		if err != nil {
 			log15.Warn("error requesting repo update", "repo", name, "err", err)
@@ -517,6 +571,7 @@ func (r *repoList) requeue(repo *repoData, respP **gitserverprotocol.RepoUpdateR
 			}
 			log15.Info("interval backoff due to error", "repo", repo.Name, "error", resp.Error, "interval", repo.UpdateInterval)
 		case resp.LastChanged != nil:
+			repo.LastChanged = resp.LastChanged
 			sinceLast := now.Sub(*resp.LastChanged)
 			repo.UpdateInterval = r.interval(sinceLast)
 			log15.Debug("interval set", "repo", repo.Name, "sinceLast", sinceLast, "interval", repo.UpdateInterval)
@@ -533,6 +588,20 @@ func (r *repoList) requeue(repo *repoData, respP **gitserverprotocol.RepoUpdateR
 		// No response at all, we try again relatively soon.
 		repo.UpdateInterval = r.interval(1 * time.Hour)
 	}
+	r.markUnsaved(repo)
+	// if this repo was pushed to while it was being updated, update it again
+	// right away. It is added back to the queue after that update.
+	if repo.UpdateAgain {
+		repo.UpdateAgain = false
+		repo.UpdateSoon = true
+		repo.Due = now
+		r.bumped = append([]*repoData{repo}, r.bumped...)
+		if repo.heapIndex >= 0 {
+			heap.Remove(&r.heap, repo.heapIndex)
+		}
+		r.ping(repo.Due, "update again: "+repo.Name)
+		return
+	}
 	// if this repo is set for auto updates, and auto-updates are not disabled,
 	// add it back to the queue.
 	if repo.AutoUpdate && !r.autoUpdatesDisabled {
@@ -670,6 +739,18 @@ func (r *repoList) updateLoop(ctx context.Context) {
 	}
 }
 
+// sourceList returns a copy of the list of configured repos associated with the
+// given source.
+func (r *repoList) sourceList(source string) sourceRepoList {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make(sourceRepoList, len(r.confRepos[source]))
+	for name, value := range r.confRepos[source] {
+		list[name] = value
+	}
+	return list
+}
+
 // updateSource updates the list of configured repos associated with the given
 // source.
 func (r *repoList) updateSource(source string, newList sourceRepoList) (enqueued, dequeued int) {
@@ -730,6 +811,7 @@ func RunRepositorySyncWorker(ctx context.Context) {
 		repos.updateConfig(ctx, c.ReposList)
 	})
 	go repos.updateLoop(ctx)
+	go repos.saveSchedulesLoop(ctx)
 }
 
 // updateConfig responds to changes in the configured list of repositories;
@@ -760,22 +842,10 @@ func (r *repoList) updateConfig(ctx context.Context, configs []*schema.Repositor
 	r.updateSource("internalConfig", newList)
 }
 
-// Snapshot represents the state of the various queues repo-updater
-// maintains. The fields are ordered by priority.
-type Snapshot struct {
-	HighPriority []*repoData
-	New          []*repoData
-	Queue        []*repoData
-	Unscheduled  []*repoData
-
-	// LockHeld is how long the global mutex was held to generate the
-	// snapshot.
-	LockHeld time.Duration
-}
-
-// snapshot returns a snapshot of the schedulers queue state. Note: This holds
-// the global mutex while copying the repo structures.
-func (r *repoList) snapshot() *Snapshot {
+// updateQueue returns the state of the scheduler's queues, with at most first
+// repos from each queue if first is positive. Note: This holds the global
+// mutex while copying the repo structures.
+func (r *repoList) updateQueue(first int) *protocol.UpdateQueue {
 	// Critical section. Avoid map lookups to make as fast as possible.
 	r.mu.Lock()
 	t := time.Now()
@@ -812,20 +882,37 @@ func (r *repoList) snapshot() *Snapshot {
 		heapStart++
 	}
 
-	s := &Snapshot{
-		HighPriority: make([]*repoData, len(highPriority)),
-		New:          make([]*repoData, len(new)),
-		Queue:        q[heapStart:],
-		Unscheduled:  q[:heapStart],
-		LockHeld:     lockHeld,
+	items := func(repos []*repoData) []*protocol.UpdateQueueItem {
+		if first > 0 && len(repos) > first {
+			repos = repos[:first]
+		}
+		items := make([]*protocol.UpdateQueueItem, len(repos))
+		for i, repo := range repos {
+			items[i] = &protocol.UpdateQueueItem{
+				Repo:               api.RepoURI(repo.Name),
+				Due:                repo.Due,
+				UpdateInterval:     repo.UpdateInterval,
+				LastUpdateDuration: repo.LastUpdateDuration,
+				LastChanged:        repo.LastChanged,
+				IsUpdating:         repo.IsUpdating,
+			}
+		}
+		return items
 	}
-	for i, k := range highPriority {
-		s.HighPriority[i] = repos[k]
+	lookup := func(names []string) []*repoData {
+		r := make([]*repoData, len(names))
+		for i, k := range names {
+			r[i] = repos[k]
+		}
+		return r
 	}
-	for i, k := range new {
-		s.New[i] = repos[k]
+	return &protocol.UpdateQueue{
+		HighPriority: items(lookup(highPriority)),
+		New:          items(lookup(new)),
+		Scheduled:    items(q[heapStart:]),
+		Unscheduled:  items(q[:heapStart]),
+		LockHeld:     lockHeld,
 	}
-	return s
 }
 
 // UpdateOnce causes a single update of the given repository.
@@ -835,6 +922,14 @@ func UpdateOnce(ctx context.Context, name api.RepoURI, url string) {
 	repos.update(string(name), url)
 }
 
+// Bump gives the given repository priority over other repositories being
+// updated, because it was just pushed to. Call it after UpdateOnce.
+func Bump(ctx context.Context, name api.RepoURI) {
+	repos.mu.Lock()
+	defer repos.mu.Unlock()
+	repos.bump(string(name))
+}
+
 // Queue requests periodic automatic updates of the given repository, which
 // will happen only if automatic updates are enabled. It will also perform
 // a one-time fetch/clone.
@@ -888,8 +983,8 @@ func GetExplicitlyConfiguredRepository(ctx context.Context, args protocol.RepoLo
 	return nil, false, nil // not found
 }
 
-// QueueSnapshot represents the state of the various queues repo-updater
-// maintains. The fields are ordered by priority.
-func QueueSnapshot() *Snapshot {
-	return repos.snapshot()
+// UpdateQueue returns the state of the scheduler's queues, with at most first
+// repositories from each queue if first is positive.
+func UpdateQueue(first int) *protocol.UpdateQueue {
+	return repos.updateQueue(first)
 }
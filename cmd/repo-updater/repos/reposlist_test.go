@@ -0,0 +1,58 @@
+package repos
+
+import (
+	"reflect"
+	"testing"
+
+	gitserverprotocol "github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+func TestRepoListBump(t *testing.T) {
+	r := &repoList{
+		repos:    make(map[string]*repoData),
+		pingChan: make(chan string, 1),
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		r.add(name, "https://example.com/"+name, true)
+		r.update(name, "https://example.com/"+name)
+	}
+	names := func() (names []string) {
+		for _, repo := range r.bumped {
+			names = append(names, repo.Name)
+		}
+		return names
+	}
+
+	// A pushed repository moves to the front of the high priority queue.
+	r.bump("c")
+	if got, want := names(), []string{"c", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got high priority queue %v, want %v", got, want)
+	}
+
+	// A repository pushed to while it is being updated is updated again.
+	c := r.repos["c"]
+	r.bumped = r.bumped[1:]
+	// This is what startUpdate does, without running the update.
+	c.IsUpdating = true
+	r.activeRequests++
+	r.update("c", "https://example.com/c")
+	r.bump("c")
+	if got, want := names(), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got high priority queue %v while updating, want %v", got, want)
+	}
+	var resp *gitserverprotocol.RepoUpdateResponse
+	var err error
+	r.requeue(c, &resp, &err)
+	if got, want := names(), []string{"c", "a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got high priority queue %v after update, want %v", got, want)
+	}
+	if c.UpdateAgain || !c.UpdateSoon {
+		t.Errorf("got UpdateAgain %v and UpdateSoon %v after update, want false and true", c.UpdateAgain, c.UpdateSoon)
+	}
+
+	// Unknown repositories are ignored.
+	r.bump("d")
+	if _, ok := r.repos["d"]; ok {
+		t.Error("bump added repository d")
+	}
+}
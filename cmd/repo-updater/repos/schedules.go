@@ -0,0 +1,117 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// The scheduler saves the schedule of each repo (when it is due, its update
+// interval and when it last changed) in the frontend's database, and restores
+// it on startup. Otherwise every repo would be new after a restart, and
+// repo-updater would update all of them at once.
+
+const (
+	// saveSchedulesInterval is how often the schedules of repos that were
+	// updated since the last save are saved.
+	saveSchedulesInterval = time.Minute
+
+	// saveSchedulesBatchSize is the maximum number of schedules saved per
+	// request to the frontend.
+	saveSchedulesBatchSize = 500
+)
+
+// saveUpdateSchedules saves the given schedules. It is a variable so tests can
+// mock it.
+var saveUpdateSchedules = func(ctx context.Context, schedules []*api.RepoUpdateSchedule) error {
+	return api.InternalClient.ReposSaveUpdateSchedules(ctx, schedules)
+}
+
+// LoadUpdateSchedules restores the repo schedules saved before repo-updater
+// last stopped. Repos that are added afterwards resume their saved schedule
+// instead of being updated right away. Call it before any repos are added.
+func LoadUpdateSchedules(ctx context.Context) error {
+	schedules, err := api.InternalClient.ReposListUpdateSchedules(ctx)
+	if err != nil {
+		return err
+	}
+	repos.mu.Lock()
+	defer repos.mu.Unlock()
+	repos.loadSchedules(schedules)
+	log15.Info("loaded repo update schedules", "count", len(schedules))
+	return nil
+}
+
+// loadSchedules sets the saved schedules used by add.
+//
+// call only when you hold the mutex.
+func (r *repoList) loadSchedules(schedules []*api.RepoUpdateSchedule) {
+	r.saved = make(map[string]*api.RepoUpdateSchedule, len(schedules))
+	for _, sched := range schedules {
+		r.saved[string(sched.Repo)] = sched
+	}
+}
+
+// markUnsaved records that the schedule of repo changed, so it is saved by
+// the next call to saveSchedules.
+//
+// call only when you hold the mutex.
+func (r *repoList) markUnsaved(repo *repoData) {
+	if r.unsaved == nil {
+		r.unsaved = make(map[string]*repoData)
+	}
+	r.unsaved[repo.Name] = repo
+}
+
+// saveSchedulesLoop periodically saves the schedules of repos that changed.
+func (r *repoList) saveSchedulesLoop(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(saveSchedulesInterval):
+		case <-ctx.Done():
+			return
+		}
+		r.saveSchedules(ctx)
+	}
+}
+
+// saveSchedules saves the schedules of repos that changed since they were
+// last saved. If saving fails, they are saved again next time.
+//
+// Only run if not holding the mutex.
+func (r *repoList) saveSchedules(ctx context.Context) {
+	r.mu.Lock()
+	schedules := make([]*api.RepoUpdateSchedule, 0, len(r.unsaved))
+	for _, repo := range r.unsaved {
+		schedules = append(schedules, &api.RepoUpdateSchedule{
+			Repo:               api.RepoURI(repo.Name),
+			Due:                repo.Due,
+			UpdateInterval:     repo.UpdateInterval,
+			LastUpdateDuration: repo.LastUpdateDuration,
+			LastChanged:        repo.LastChanged,
+		})
+	}
+	r.unsaved = nil
+	r.mu.Unlock()
+
+	for len(schedules) > 0 {
+		batch := schedules
+		if len(batch) > saveSchedulesBatchSize {
+			batch = batch[:saveSchedulesBatchSize]
+		}
+		if err := saveUpdateSchedules(ctx, batch); err != nil {
+			log15.Warn("failed to save repo update schedules", "count", len(schedules), "error", err)
+			r.mu.Lock()
+			for _, sched := range schedules {
+				if repo, ok := r.repos[string(sched.Repo)]; ok {
+					r.markUnsaved(repo)
+				}
+			}
+			r.mu.Unlock()
+			return
+		}
+		schedules = schedules[len(batch):]
+	}
+}
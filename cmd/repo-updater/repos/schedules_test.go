@@ -0,0 +1,72 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	gitserverprotocol "github.com/sourcegraph/sourcegraph/pkg/gitserver/protocol"
+)
+
+func TestRepoListSchedules(t *testing.T) {
+	r := &repoList{
+		repos:    make(map[string]*repoData),
+		pingChan: make(chan string, 1),
+	}
+	due := time.Now().Add(time.Hour)
+	changed := time.Now().Add(-time.Hour)
+	r.loadSchedules([]*api.RepoUpdateSchedule{
+		{Repo: "a", Due: due, UpdateInterval: 5 * time.Minute, LastUpdateDuration: 3 * time.Second, LastChanged: &changed},
+	})
+
+	// A repository with a saved schedule resumes it.
+	r.add("a", "https://example.com/a", true)
+	a := r.repos["a"]
+	if a.heapIndex < 0 || !a.Due.Equal(due) || a.UpdateInterval != 5*time.Minute || a.LastUpdateDuration != 3*time.Second || a.LastChanged != &changed {
+		t.Errorf("got %+v, want the saved schedule", a)
+	}
+	// Other repositories are new.
+	r.add("b", "https://example.com/b", true)
+	if len(r.newQueue) != 1 || r.newQueue[0].Name != "b" {
+		t.Errorf("got new queue %+v, want b", r.newQueue)
+	}
+
+	queue := r.updateQueue(0)
+	if len(queue.Scheduled) != 1 || queue.Scheduled[0].Repo != "a" || len(queue.New) != 1 || queue.New[0].Repo != "b" {
+		t.Errorf("got update queue %+v", queue)
+	}
+
+	// Updated repositories are saved.
+	var resp *gitserverprotocol.RepoUpdateResponse
+	var err error
+	r.activeRequests++
+	r.requeue(r.repos["b"], &resp, &err)
+	if _, ok := r.unsaved["b"]; !ok || len(r.unsaved) != 1 {
+		t.Fatalf("got unsaved %v, want b", r.unsaved)
+	}
+
+	orig := saveUpdateSchedules
+	defer func() { saveUpdateSchedules = orig }()
+	saveUpdateSchedules = func(ctx context.Context, schedules []*api.RepoUpdateSchedule) error {
+		return errors.New("x")
+	}
+	r.saveSchedules(context.Background())
+	if _, ok := r.unsaved["b"]; !ok {
+		t.Fatal("repository is not saved again after saving failed")
+	}
+
+	var saved []*api.RepoUpdateSchedule
+	saveUpdateSchedules = func(ctx context.Context, schedules []*api.RepoUpdateSchedule) error {
+		saved = append(saved, schedules...)
+		return nil
+	}
+	r.saveSchedules(context.Background())
+	if len(saved) != 1 || saved[0].Repo != "b" || !saved[0].Due.Equal(r.repos["b"].Due) {
+		t.Errorf("got saved schedules %+v, want b", saved)
+	}
+	if len(r.unsaved) != 0 {
+		t.Errorf("got unsaved %v after saving, want none", r.unsaved)
+	}
+}
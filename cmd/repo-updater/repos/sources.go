@@ -0,0 +1,91 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/conf"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// A Source lists the repositories of a configured connection to an external service (such as a GitHub
+// connection in the site configuration). Several sources can list repositories of the same external service,
+// such as two GitHub connections with different tokens.
+type Source interface {
+	// ExternalService returns the service type and ID of the external service, which is the ServiceType and
+	// ServiceID of the ExternalRepo of all its repositories.
+	ExternalService() (serviceType, serviceID string)
+
+	// ListRepos returns all repositories of the source. If it could not list all of them, it returns those it
+	// listed along with an error. The syncer only deletes the missing stored repositories that GetRepo
+	// confirms were deleted.
+	ListRepos(ctx context.Context) ([]*SourceRepo, error)
+
+	// GetRepo returns the repository with the given external ID (the ID of its ExternalRepo). If the
+	// repository doesn't exist (or is not accessible to the source), errcode.IsNotFound is true for the
+	// returned error.
+	GetRepo(ctx context.Context, id string) (*SourceRepo, error)
+}
+
+// A SourceRepo is a RepoCreateOrUpdateRequest, from the API, plus a specific
+// URL we'd like to use for it.
+type SourceRepo struct {
+	api.RepoCreateOrUpdateRequest
+	URL string // the repository's Git remote URL
+}
+
+// repoNotFoundError is returned by (Source).GetRepo when the repository
+// doesn't exist.
+type repoNotFoundError struct {
+	id  string
+	err error
+}
+
+func (e *repoNotFoundError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("repository %s not found: %s", e.id, e.err)
+	}
+	return fmt.Sprintf("repository %s not found", e.id)
+}
+
+func (e *repoNotFoundError) NotFound() bool { return true }
+
+// sources returns the sources of all configured connections that list
+// repositories.
+func sources() []Source {
+	var srcs []Source
+	for _, c := range githubConnections.Get().([]*githubConnection) {
+		if c.listsRepos() {
+			srcs = append(srcs, &githubSource{conn: c})
+		}
+	}
+	for _, c := range gitlabConnections.Get().([]*gitlabConnection) {
+		if c.listsRepos() {
+			srcs = append(srcs, &gitlabSource{conn: c})
+		}
+	}
+	for _, c := range bitbucketServerConnections.Get().([]*bitbucketServerConnection) {
+		srcs = append(srcs, &bitbucketServerSource{conn: c})
+	}
+	for _, c := range bitbucketCloudConnections.Get().([]*bitbucketCloudConnection) {
+		srcs = append(srcs, &bitbucketCloudSource{conn: c})
+	}
+	for _, c := range giteaConnections.Get().([]*giteaConnection) {
+		srcs = append(srcs, &giteaSource{conn: c})
+	}
+	for _, c := range awsCodeCommitConnections.Get().([]*awsCodeCommitConnection) {
+		// The external service ID includes the AWS account ID, which is
+		// only known after we reached the AWS API.
+		serviceID, err := c.getServiceID()
+		if err != nil {
+			log15.Error("Unable to reach AWS CodeCommit API to determine AWS account ID.", "region", c.config.Region, "error", err)
+			continue
+		}
+		srcs = append(srcs, &awsCodeCommitSource{conn: c, serviceID: serviceID})
+	}
+	for _, c := range conf.Get().Gitolite {
+		srcs = append(srcs, &gitoliteSource{conf: c})
+	}
+	return srcs
+}
@@ -0,0 +1,307 @@
+package repos
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/errcode"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// The syncer keeps the stored repositories of each external service in sync
+// with the repositories listed by the sources of its configured connections.
+// It diffs the listed repositories against the stored ones by their
+// ExternalRepo, and applies the difference in a single transaction: new
+// repositories are created, renamed ones are renamed, and repositories that
+// no longer exist on the external service are soft-deleted.
+
+// maxDeletionChecks is the maximum number of repositories per external
+// service and sync that are looked up to confirm that they were deleted.
+const maxDeletionChecks = 100
+
+// listExternalServiceRepos and syncExternalServiceRepos are variables so
+// tests can mock them.
+var (
+	listExternalServiceRepos = func(ctx context.Context, serviceType, serviceID string) ([]*api.Repo, error) {
+		return api.InternalClient.ReposListByExternalService(ctx, serviceType, serviceID)
+	}
+	syncExternalServiceRepos = func(ctx context.Context, req api.ReposSyncRequest) (*api.ReposSyncResponse, error) {
+		return api.InternalClient.ReposSync(ctx, req)
+	}
+)
+
+var syncWorker = &worker{
+	work: func(ctx context.Context, shutdown chan struct{}) {
+		for {
+			syncMu.Lock()
+			select {
+			case <-shutdown:
+				// The worker was restarted while waiting for the previous sync.
+				syncMu.Unlock()
+				return
+			default:
+			}
+			defaultSyncer.syncAll(ctx, sources())
+			syncMu.Unlock()
+
+			select {
+			case <-shutdown:
+				return
+			case <-time.After(getUpdateInterval()):
+			}
+		}
+	},
+}
+
+// syncMu ensures that only one sync runs at a time, since restarting the
+// worker doesn't wait for the previous sync to finish.
+var syncMu sync.Mutex
+
+// RunSyncWorker runs the worker that syncs repositories from the configured
+// external services (GitHub, GitLab, Bitbucket Server, Bitbucket Cloud, Gitea,
+// AWS CodeCommit and Gitolite connections) to Sourcegraph.
+func RunSyncWorker(ctx context.Context) {
+	syncWorker.start(ctx)
+}
+
+// An externalService is an external service identified by its service type
+// and ID, as in api.ExternalRepoSpec.
+type externalService struct {
+	serviceType, serviceID string
+}
+
+// An externalRepo is a repository on an external service, identified by its
+// external ID.
+type externalRepo struct {
+	externalService
+	id string
+}
+
+type syncer struct {
+	mu sync.Mutex
+	// exists are the unlisted repositories that were confirmed to exist,
+	// which are not looked up again until they are listed.
+	exists map[externalRepo]bool
+}
+
+var defaultSyncer = &syncer{exists: make(map[externalRepo]bool)}
+
+// syncAll syncs the repositories of the external services of srcs. Each
+// external service is synced separately, with the repositories of all its
+// sources.
+func (s *syncer) syncAll(ctx context.Context, srcs []Source) {
+	bySvc := make(map[externalService][]Source)
+	for _, src := range srcs {
+		serviceType, serviceID := src.ExternalService()
+		svc := externalService{serviceType: serviceType, serviceID: serviceID}
+		bySvc[svc] = append(bySvc[svc], src)
+	}
+
+	var wg sync.WaitGroup
+	for svc, srcs := range bySvc {
+		wg.Add(1)
+		go func(svc externalService, srcs []Source) {
+			defer wg.Done()
+			if err := s.sync(ctx, svc, srcs); err != nil {
+				log15.Error("Error syncing repositories", "serviceType", svc.serviceType, "serviceID", svc.serviceID, "error", err)
+				return
+			}
+			setLastSyncTime(svc)
+		}(svc, srcs)
+	}
+	wg.Wait()
+}
+
+// sync syncs the repositories of the external service svc with those listed
+// by srcs, and updates the scheduler's list of repositories of svc.
+func (s *syncer) sync(ctx context.Context, svc externalService, srcs []Source) error {
+	// A source that fails to list all its repositories still returns those
+	// it listed, which are created and renamed. The stored repositories that
+	// are missing are only deleted if confirmDeleted looks them up.
+	var listed []*SourceRepo
+	var listErr error
+	for _, src := range srcs {
+		srcRepos, err := src.ListRepos(ctx)
+		if err != nil && listErr == nil {
+			listErr = errors.Wrap(err, "listing repositories")
+		}
+		listed = append(listed, srcRepos...)
+	}
+	stored, err := listExternalServiceRepos(ctx, svc.serviceType, svc.serviceID)
+	if err != nil {
+		return errors.Wrap(err, "listing stored repositories")
+	}
+
+	diff := diffRepos(stored, listed)
+	req := api.ReposSyncRequest{
+		ServiceType: svc.serviceType,
+		ServiceID:   svc.serviceID,
+		Deleted:     s.confirmDeleted(ctx, svc, srcs, diff.unlisted),
+	}
+	var reqRepos []*SourceRepo
+	for _, group := range [][]*SourceRepo{diff.unchanged, diff.renamed, diff.created} {
+		for _, repo := range group {
+			req.Repos = append(req.Repos, repo.RepoCreateOrUpdateRequest)
+			reqRepos = append(reqRepos, repo)
+		}
+	}
+	s.mu.Lock()
+	for _, repo := range reqRepos {
+		delete(s.exists, externalRepo{externalService: svc, id: repo.ExternalRepo.ID})
+	}
+	s.mu.Unlock()
+
+	resp, err := syncExternalServiceRepos(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "storing repositories")
+	}
+	syncedRepos.WithLabelValues("created").Add(float64(resp.Created))
+	syncedRepos.WithLabelValues("updated").Add(float64(resp.Updated))
+	syncedRepos.WithLabelValues("renamed").Add(float64(resp.Renamed))
+	syncedRepos.WithLabelValues("deleted").Add(float64(resp.Deleted))
+	if resp.Created > 0 || resp.Updated > 0 || resp.Renamed > 0 || resp.Deleted > 0 {
+		log15.Info("synced repositories", "serviceType", svc.serviceType, "serviceID", svc.serviceID, "created", resp.Created, "updated", resp.Updated, "renamed", resp.Renamed, "deleted", resp.Deleted)
+	}
+
+	newList := make(sourceRepoList, len(resp.Repos))
+	for i, repo := range resp.Repos {
+		if repo == nil || i >= len(reqRepos) {
+			continue // skipped because another external service's repository has its URI
+		}
+		newList[string(repo.URI)] = configuredRepo{url: reqRepos[i].URL, enabled: repo.Enabled}
+	}
+	source := svc.serviceType + ":" + svc.serviceID
+	if listErr != nil {
+		// Keep updating the unlisted repositories that were not deleted,
+		// since they might only be missing from the partial listing.
+		deleted := make(map[api.RepoID]bool, len(req.Deleted))
+		for _, id := range req.Deleted {
+			deleted[id] = true
+		}
+		oldList := repos.sourceList(source)
+		for _, repo := range diff.unlisted {
+			if old, ok := oldList[string(repo.URI)]; ok && !deleted[repo.ID] {
+				newList[string(repo.URI)] = old
+			}
+		}
+	}
+	repos.updateSource(source, newList)
+	return listErr
+}
+
+// confirmDeleted returns the IDs of the stored repositories (that were not
+// listed) which its sources say don't exist. Repositories that are not listed
+// might still exist, such as those added by users or those that no longer
+// match the configuration.
+//
+// Since each lookup is an API request, a repository that exists is not looked
+// up again until it is listed, and at most maxDeletionChecks repositories are
+// looked up.
+func (s *syncer) confirmDeleted(ctx context.Context, svc externalService, srcs []Source, unlisted []*api.Repo) []api.RepoID {
+	var deleted []api.RepoID
+	checks := 0
+	for _, repo := range unlisted {
+		key := externalRepo{externalService: svc, id: repo.ExternalRepo.ID}
+		s.mu.Lock()
+		exists := s.exists[key]
+		s.mu.Unlock()
+		if exists {
+			continue
+		}
+		if checks >= maxDeletionChecks {
+			break
+		}
+		checks++
+
+		// The repository was deleted if no source can get it. A source
+		// might not have access to it.
+		notFound := 0
+		for _, src := range srcs {
+			_, err := src.GetRepo(ctx, repo.ExternalRepo.ID)
+			if err == nil {
+				s.mu.Lock()
+				s.exists[key] = true
+				s.mu.Unlock()
+				break
+			} else if errcode.IsNotFound(err) {
+				notFound++
+			} else {
+				log15.Debug("unable to determine whether repository was deleted", "repo", repo.URI, "error", err)
+				break
+			}
+		}
+		if notFound == len(srcs) {
+			deleted = append(deleted, repo.ID)
+		}
+	}
+	return deleted
+}
+
+// A repoDiff is the difference between the stored repositories of an
+// external service and those listed by its sources, matched by their
+// ExternalRepo.
+type repoDiff struct {
+	created   []*SourceRepo // listed but not stored
+	renamed   []*SourceRepo // listed and stored with another URI
+	unchanged []*SourceRepo // listed and stored with the same URI
+	unlisted  []*api.Repo   // stored but not listed
+}
+
+// diffRepos returns the difference between the stored and listed
+// repositories. Repositories listed more than once are only included once.
+func diffRepos(stored []*api.Repo, listed []*SourceRepo) repoDiff {
+	byID := make(map[string]*api.Repo, len(stored))
+	for _, repo := range stored {
+		if repo.ExternalRepo != nil {
+			byID[repo.ExternalRepo.ID] = repo
+		}
+	}
+
+	var diff repoDiff
+	seen := make(map[string]bool, len(listed))
+	for _, repo := range listed {
+		if repo.ExternalRepo == nil || seen[repo.ExternalRepo.ID] {
+			continue
+		}
+		seen[repo.ExternalRepo.ID] = true
+		switch s, ok := byID[repo.ExternalRepo.ID]; {
+		case !ok:
+			diff.created = append(diff.created, repo)
+		case s.URI != repo.RepoURI:
+			diff.renamed = append(diff.renamed, repo)
+		default:
+			diff.unchanged = append(diff.unchanged, repo)
+		}
+	}
+	for _, repo := range stored {
+		if repo.ExternalRepo != nil && !seen[repo.ExternalRepo.ID] {
+			diff.unlisted = append(diff.unlisted, repo)
+		}
+	}
+	return diff
+}
+
+// setLastSyncTime records the time of the last successful sync of svc in the
+// metric of its service type.
+func setLastSyncTime(svc externalService) {
+	now := float64(time.Now().Unix())
+	switch svc.serviceType {
+	case GitHubServiceType:
+		githubUpdateTime.WithLabelValues(svc.serviceID).Set(now)
+	case GitLabServiceType:
+		gitlabUpdateTime.WithLabelValues(svc.serviceID).Set(now)
+	case bitbucketServerServiceType:
+		bitbucketServerUpdateTime.WithLabelValues(svc.serviceID).Set(now)
+	case bitbucketCloudServiceType:
+		bitbucketCloudUpdateTime.WithLabelValues(svc.serviceID).Set(now)
+	case giteaServiceType:
+		giteaUpdateTime.WithLabelValues(svc.serviceID).Set(now)
+	case AWSCodeCommitServiceType:
+		awsCodeCommitUpdateTime.WithLabelValues(svc.serviceID).Set(now)
+	case gitoliteServiceType:
+		gitoliteUpdateTime.Set(now)
+	}
+}
@@ -0,0 +1,166 @@
+package repos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+type fakeSource struct {
+	repos   []*SourceRepo
+	listErr error
+	gets    []string
+}
+
+func (s *fakeSource) ExternalService() (serviceType, serviceID string) {
+	return "t", "s"
+}
+
+func (s *fakeSource) ListRepos(ctx context.Context) ([]*SourceRepo, error) {
+	return s.repos, s.listErr
+}
+
+func (s *fakeSource) GetRepo(ctx context.Context, id string) (*SourceRepo, error) {
+	s.gets = append(s.gets, id)
+	switch id {
+	case "deleted":
+		return nil, &repoNotFoundError{id: id}
+	case "error":
+		return nil, errors.New("x")
+	}
+	return &SourceRepo{}, nil
+}
+
+func sourceRepo(uri, id string) *SourceRepo {
+	return &SourceRepo{
+		RepoCreateOrUpdateRequest: api.RepoCreateOrUpdateRequest{
+			RepoURI:      api.RepoURI(uri),
+			ExternalRepo: &api.ExternalRepoSpec{ID: id, ServiceType: "t", ServiceID: "s"},
+		},
+		URL: "https://example.com/" + uri,
+	}
+}
+
+func storedRepo(repoID api.RepoID, uri, id string) *api.Repo {
+	return &api.Repo{
+		ID:           repoID,
+		URI:          api.RepoURI(uri),
+		ExternalRepo: &api.ExternalRepoSpec{ID: id, ServiceType: "t", ServiceID: "s"},
+		Enabled:      true,
+	}
+}
+
+func TestDiffRepos(t *testing.T) {
+	stored := []*api.Repo{storedRepo(1, "a", "1"), storedRepo(2, "b", "2"), storedRepo(3, "c", "3")}
+	listed := []*SourceRepo{sourceRepo("a", "1"), sourceRepo("b2", "2"), sourceRepo("d", "4"), sourceRepo("a", "1")}
+	diff := diffRepos(stored, listed)
+
+	uris := func(repos []*SourceRepo) (uris []string) {
+		for _, r := range repos {
+			uris = append(uris, string(r.RepoURI))
+		}
+		return uris
+	}
+	if got, want := uris(diff.unchanged), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got unchanged %v, want %v", got, want)
+	}
+	if got, want := uris(diff.renamed), []string{"b2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got renamed %v, want %v", got, want)
+	}
+	if got, want := uris(diff.created), []string{"d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got created %v, want %v", got, want)
+	}
+	if len(diff.unlisted) != 1 || diff.unlisted[0].ID != 3 {
+		t.Errorf("got unlisted %v, want c", diff.unlisted)
+	}
+}
+
+func TestSyncer(t *testing.T) {
+	origList, origSync := listExternalServiceRepos, syncExternalServiceRepos
+	defer func() { listExternalServiceRepos, syncExternalServiceRepos = origList, origSync }()
+	defer repos.updateSource("t:s", nil)
+
+	listExternalServiceRepos = func(ctx context.Context, serviceType, serviceID string) ([]*api.Repo, error) {
+		if serviceType != "t" || serviceID != "s" {
+			t.Errorf("got external service %s %s, want t s", serviceType, serviceID)
+		}
+		return []*api.Repo{
+			storedRepo(1, "a", "1"),
+			storedRepo(2, "deleted", "deleted"),
+			storedRepo(3, "exists", "exists"),
+			storedRepo(4, "error", "error"),
+			storedRepo(5, "b", "2"),
+		}, nil
+	}
+	var reqs []api.ReposSyncRequest
+	syncExternalServiceRepos = func(ctx context.Context, req api.ReposSyncRequest) (*api.ReposSyncResponse, error) {
+		reqs = append(reqs, req)
+		resp := &api.ReposSyncResponse{Deleted: len(req.Deleted)}
+		for i, r := range req.Repos {
+			resp.Repos = append(resp.Repos, &api.Repo{ID: api.RepoID(i + 10), URI: r.RepoURI, Enabled: true})
+		}
+		return resp, nil
+	}
+
+	s := &syncer{exists: make(map[externalRepo]bool)}
+	src1 := &fakeSource{repos: []*SourceRepo{sourceRepo("a", "1")}}
+	src2 := &fakeSource{repos: []*SourceRepo{sourceRepo("b", "2")}}
+	s.syncAll(context.Background(), []Source{src1, src2})
+
+	// Only repositories that no source can get are deleted.
+	if len(reqs) != 1 {
+		t.Fatalf("got %d sync requests, want 1", len(reqs))
+	}
+	if got, want := reqs[0].Deleted, []api.RepoID{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got deleted %v, want %v", got, want)
+	}
+	if len(reqs[0].Repos) != 2 {
+		t.Errorf("got repos %+v, want a and b", reqs[0].Repos)
+	}
+	repos.mu.Lock()
+	list := repos.confRepos["t:s"]
+	repos.mu.Unlock()
+	if got, want := list, (sourceRepoList{
+		"a": {url: "https://example.com/a", enabled: true},
+		"b": {url: "https://example.com/b", enabled: true},
+	}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got scheduled repos %v, want %v", got, want)
+	}
+
+	// Repositories that exist are not looked up again, unlike those whose
+	// lookup failed.
+	src1.gets, src2.gets = nil, nil
+	s.syncAll(context.Background(), []Source{src1, src2})
+	if got, want := src1.gets, []string{"deleted", "error"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got lookups %v, want %v", got, want)
+	}
+
+	// The repositories that a source lists are synced even if it fails to
+	// list all of them, and unlisted repositories are only deleted if no
+	// source can get them.
+	reqs = nil
+	src1.gets, src2.gets = nil, nil
+	src2.repos = nil
+	src2.listErr = errors.New("x")
+	if err := s.sync(context.Background(), externalService{serviceType: "t", serviceID: "s"}, []Source{src1, src2}); err == nil {
+		t.Error("got no error after listing failed")
+	}
+	if len(reqs) != 1 {
+		t.Fatalf("got %d sync requests after listing failed, want 1", len(reqs))
+	}
+	if got, want := reqs[0].Deleted, []api.RepoID{2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got deleted %v after listing failed, want %v", got, want)
+	}
+	if len(reqs[0].Repos) != 1 || reqs[0].Repos[0].RepoURI != "a" {
+		t.Errorf("got repos %+v after listing failed, want a", reqs[0].Repos)
+	}
+	repos.mu.Lock()
+	list = repos.confRepos["t:s"]
+	repos.mu.Unlock()
+	if _, ok := list["b"]; !ok || len(list) != 2 {
+		t.Errorf("got scheduled repos %v after listing failed, want a and b", list)
+	}
+}
@@ -11,9 +11,7 @@ import (
 	"github.com/gregjones/httpcache"
 	"github.com/opentracing-contrib/go-stdlib/nethttp"
 	"github.com/pkg/errors"
-	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/httputil"
-	log15 "gopkg.in/inconshreveable/log15.v2"
 )
 
 // NormalizeBaseURL modifies the input and returns a normalized form of the a base URL with insignificant
@@ -50,45 +48,6 @@ func cachedTransportWithCertTrusted(cert string) (http.RoundTripper, error) {
 	}, nil
 }
 
-// A repoCreateOrUpdateRequest is a RepoCreateOrUpdateRequest, from the API,
-// plus a specific URL we'd like to use for it.
-type repoCreateOrUpdateRequest struct {
-	api.RepoCreateOrUpdateRequest
-	URL string // the repository's Git remote URL
-}
-
-// createEnableUpdateRepos receives requests on the provided channel. The
-// source argument should be a distinctive string identifying the configuration
-// being updated, so repo-updater can detect when repositories are dropped from
-// a given source.
-func createEnableUpdateRepos(ctx context.Context, source string, repoChan <-chan repoCreateOrUpdateRequest) {
-	newList := make(sourceRepoList)
-
-	do := func(op repoCreateOrUpdateRequest) {
-		if op.RepoCreateOrUpdateRequest.RepoURI == "" {
-			log15.Warn("ignoring invalid request to create or enable repo with empty name", "source", source, "repo", op.RepoCreateOrUpdateRequest.ExternalRepo)
-			return
-		}
-		createdRepo, err := api.InternalClient.ReposCreateIfNotExists(ctx, op.RepoCreateOrUpdateRequest)
-		if err != nil {
-			log15.Warn("Error creating or updating repository", "repo", op.RepoURI, "error", err)
-			return
-		}
-
-		err = api.InternalClient.ReposUpdateMetadata(ctx, op.RepoURI, op.Description, op.Fork, op.Archived)
-		if err != nil {
-			log15.Warn("Error updating repository metadata", "repo", op.RepoURI, "error", err)
-			return
-		}
-
-		newList[string(createdRepo.URI)] = configuredRepo{url: op.URL, enabled: createdRepo.Enabled}
-	}
-	for repo := range repoChan {
-		do(repo)
-	}
-	repos.updateSource(source, newList)
-}
-
 // setUserinfoBestEffort adds the username and password to rawurl. If user is
 // not set in rawurl, username is used. If password is not set and there is a
 // user, password is used. If anything fails, the original rawurl is returned.
@@ -0,0 +1,168 @@
+package repos
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// ErrWebhookUnauthorized is returned by the webhook functions (such as
+// GitHubWebhookPushRepo) when a webhook request is not signed with (or does
+// not contain) the webhook secret of any configured connection.
+var ErrWebhookUnauthorized = errors.New("webhook request has no valid secret")
+
+// GitHubWebhookPushRepo authenticates a GitHub webhook request with the
+// webhookSecret of each GitHub connection, and returns the repository that
+// was pushed to. It returns a nil repository for other events (such as the
+// "ping" event sent when a webhook is added).
+func GitHubWebhookPushRepo(header http.Header, body []byte) (*api.ExternalRepoSpec, error) {
+	var serviceID string
+	for _, conn := range githubConnections.Get().([]*githubConnection) {
+		if validHubSignature(header, body, conn.config.WebhookSecret) {
+			serviceID = conn.baseURL.String()
+			break
+		}
+	}
+	if serviceID == "" {
+		return nil, ErrWebhookUnauthorized
+	}
+
+	if header.Get("X-GitHub-Event") != "push" {
+		return nil, nil
+	}
+	var payload struct {
+		Repository struct {
+			NodeID string `json:"node_id"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "invalid GitHub push event")
+	}
+	if payload.Repository.NodeID == "" {
+		return nil, errors.New("GitHub push event has no repository node_id")
+	}
+	return &api.ExternalRepoSpec{
+		ID:          payload.Repository.NodeID,
+		ServiceType: GitHubServiceType,
+		ServiceID:   serviceID,
+	}, nil
+}
+
+// GitLabWebhookPushRepo authenticates a GitLab webhook request with the
+// webhookSecret of each GitLab connection, and returns the project that was
+// pushed to. It returns a nil repository for other events.
+func GitLabWebhookPushRepo(header http.Header, body []byte) (*api.ExternalRepoSpec, error) {
+	var serviceID string
+	token := header.Get("X-Gitlab-Token")
+	for _, conn := range gitlabConnections.Get().([]*gitlabConnection) {
+		secret := conn.config.WebhookSecret
+		if secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1 {
+			serviceID = conn.baseURL.String()
+			break
+		}
+	}
+	if serviceID == "" {
+		return nil, ErrWebhookUnauthorized
+	}
+
+	// Pushes of branches and of tags both change the refs of the repository.
+	if event := header.Get("X-Gitlab-Event"); event != "Push Hook" && event != "Tag Push Hook" {
+		return nil, nil
+	}
+	var payload struct {
+		ProjectID int `json:"project_id"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "invalid GitLab push event")
+	}
+	if payload.ProjectID == 0 {
+		return nil, errors.New("GitLab push event has no project_id")
+	}
+	return &api.ExternalRepoSpec{
+		ID:          strconv.Itoa(payload.ProjectID),
+		ServiceType: GitLabServiceType,
+		ServiceID:   serviceID,
+	}, nil
+}
+
+// BitbucketServerWebhookPushRepo authenticates a Bitbucket Server webhook
+// request with the webhookSecret of each Bitbucket Server connection, and
+// returns the repository that was pushed to. It returns a nil repository for
+// other events (such as the "diagnostics:ping" event sent when testing a
+// webhook).
+func BitbucketServerWebhookPushRepo(header http.Header, body []byte) (*api.ExternalRepoSpec, error) {
+	var serviceID string
+	for _, conn := range bitbucketServerConnections.Get().([]*bitbucketServerConnection) {
+		if validHubSignature(header, body, conn.config.WebhookSecret) {
+			serviceID = conn.client.URL.String()
+			break
+		}
+	}
+	if serviceID == "" {
+		return nil, ErrWebhookUnauthorized
+	}
+
+	if header.Get("X-Event-Key") != "repo:refs_changed" {
+		return nil, nil
+	}
+	var payload struct {
+		Repository struct {
+			Slug    string `json:"slug"`
+			Project struct {
+				Key string `json:"key"`
+			} `json:"project"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "invalid Bitbucket Server push event")
+	}
+	if payload.Repository.Slug == "" || payload.Repository.Project.Key == "" {
+		return nil, errors.New("Bitbucket Server push event has no repository slug or project key")
+	}
+	return &api.ExternalRepoSpec{
+		ID:          payload.Repository.Project.Key + "/" + payload.Repository.Slug,
+		ServiceType: bitbucketServerServiceType,
+		ServiceID:   serviceID,
+	}, nil
+}
+
+// validHubSignature reports whether the webhook request body is signed with
+// secret in the X-Hub-Signature-256 or X-Hub-Signature header, as done by
+// GitHub and Bitbucket Server. The signature is "sha256=" or "sha1=" followed
+// by the hex-encoded HMAC of body.
+func validHubSignature(header http.Header, body []byte, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	sig := header.Get("X-Hub-Signature-256")
+	if sig == "" {
+		sig = header.Get("X-Hub-Signature")
+	}
+
+	var h func() hash.Hash
+	switch {
+	case strings.HasPrefix(sig, "sha256="):
+		h = sha256.New
+	case strings.HasPrefix(sig, "sha1="):
+		h = sha1.New
+	default:
+		return false
+	}
+	got, err := hex.DecodeString(sig[strings.Index(sig, "=")+1:])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,159 @@
+package repos
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/internal/externalservice/bitbucketserver"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestGitHubWebhookPushRepo(t *testing.T) {
+	orig := githubConnections.Get()
+	githubConnections.Set(func() interface{} {
+		return []*githubConnection{
+			{baseURL: &url.URL{Scheme: "https", Host: "github.com", Path: "/"}, config: &schema.GitHubConnection{}},
+			{baseURL: &url.URL{Scheme: "https", Host: "github.example.com", Path: "/"}, config: &schema.GitHubConnection{WebhookSecret: "s"}},
+		}
+	})
+	defer func() { githubConnections.Set(func() interface{} { return orig }) }()
+
+	body := []byte(`{"ref": "refs/heads/master", "repository": {"node_id": "MDEwOlJlcG9zaXRvcnkxMjk2MjY5", "full_name": "foo/bar"}}`)
+	header := func(event, signature string) http.Header {
+		return http.Header{"X-Github-Event": {event}, "X-Hub-Signature-256": {signature}}
+	}
+
+	t.Run("push", func(t *testing.T) {
+		spec, err := GitHubWebhookPushRepo(header("push", "sha256="+hexHMAC(sha256.New, "s", body)), body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &api.ExternalRepoSpec{ID: "MDEwOlJlcG9zaXRvcnkxMjk2MjY5", ServiceType: GitHubServiceType, ServiceID: "https://github.example.com/"}
+		if !reflect.DeepEqual(spec, want) {
+			t.Errorf("got %+v, want %+v", spec, want)
+		}
+	})
+
+	t.Run("sha1 signature", func(t *testing.T) {
+		h := http.Header{"X-Github-Event": {"push"}, "X-Hub-Signature": {"sha1=" + hexHMAC(sha1.New, "s", body)}}
+		if spec, err := GitHubWebhookPushRepo(h, body); err != nil || spec == nil {
+			t.Errorf("got %+v, %v, want repository", spec, err)
+		}
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		spec, err := GitHubWebhookPushRepo(header("ping", "sha256="+hexHMAC(sha256.New, "s", body)), body)
+		if err != nil || spec != nil {
+			t.Errorf("got %+v, %v, want no repository", spec, err)
+		}
+	})
+
+	for name, signature := range map[string]string{
+		"no signature":    "",
+		"wrong secret":    "sha256=" + hexHMAC(sha256.New, "x", body),
+		"malformed":       "sha256=zz",
+		"empty secret":    "sha256=" + hexHMAC(sha256.New, "", body),
+		"unknown hash":    "md5=" + hexHMAC(sha256.New, "s", body),
+		"truncated hash":  "sha256=" + hexHMAC(sha256.New, "s", body)[:10],
+		"signature of {}": "sha256=" + hexHMAC(sha256.New, "s", []byte("{}")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := GitHubWebhookPushRepo(header("push", signature), body); err != ErrWebhookUnauthorized {
+				t.Errorf("got error %v, want %v", err, ErrWebhookUnauthorized)
+			}
+		})
+	}
+}
+
+func TestGitLabWebhookPushRepo(t *testing.T) {
+	orig := gitlabConnections.Get()
+	gitlabConnections.Set(func() interface{} {
+		return []*gitlabConnection{
+			{baseURL: &url.URL{Scheme: "https", Host: "gitlab.com", Path: "/"}, config: &schema.GitLabConnection{}},
+			{baseURL: &url.URL{Scheme: "https", Host: "gitlab.example.com", Path: "/"}, config: &schema.GitLabConnection{WebhookSecret: "s"}},
+		}
+	})
+	defer func() { gitlabConnections.Set(func() interface{} { return orig }) }()
+
+	body := []byte(`{"object_kind": "push", "project_id": 15, "project": {"id": 15}}`)
+	tests := map[string]struct {
+		header  http.Header
+		want    *api.ExternalRepoSpec
+		wantErr error
+	}{
+		"push": {
+			header: http.Header{"X-Gitlab-Event": {"Push Hook"}, "X-Gitlab-Token": {"s"}},
+			want:   &api.ExternalRepoSpec{ID: "15", ServiceType: GitLabServiceType, ServiceID: "https://gitlab.example.com/"},
+		},
+		"tag push": {
+			header: http.Header{"X-Gitlab-Event": {"Tag Push Hook"}, "X-Gitlab-Token": {"s"}},
+			want:   &api.ExternalRepoSpec{ID: "15", ServiceType: GitLabServiceType, ServiceID: "https://gitlab.example.com/"},
+		},
+		"issue": {
+			header: http.Header{"X-Gitlab-Event": {"Issue Hook"}, "X-Gitlab-Token": {"s"}},
+		},
+		"wrong token": {
+			header:  http.Header{"X-Gitlab-Event": {"Push Hook"}, "X-Gitlab-Token": {"x"}},
+			wantErr: ErrWebhookUnauthorized,
+		},
+		"no token": {
+			header:  http.Header{"X-Gitlab-Event": {"Push Hook"}},
+			wantErr: ErrWebhookUnauthorized,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			spec, err := GitLabWebhookPushRepo(test.header, body)
+			if err != test.wantErr {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(spec, test.want) {
+				t.Errorf("got %+v, want %+v", spec, test.want)
+			}
+		})
+	}
+}
+
+func TestBitbucketServerWebhookPushRepo(t *testing.T) {
+	orig := bitbucketServerConnections.Get()
+	bitbucketServerConnections.Set(func() interface{} {
+		return []*bitbucketServerConnection{{
+			client: &bitbucketserver.Client{URL: &url.URL{Scheme: "https", Host: "bitbucket.example.com", Path: "/"}},
+			config: &schema.BitbucketServerConnection{WebhookSecret: "s"},
+		}}
+	})
+	defer func() { bitbucketServerConnections.Set(func() interface{} { return orig }) }()
+
+	body := []byte(`{"eventKey": "repo:refs_changed", "repository": {"slug": "bar", "project": {"key": "FOO"}}}`)
+	signature := "sha256=" + hexHMAC(sha256.New, "s", body)
+
+	spec, err := BitbucketServerWebhookPushRepo(http.Header{"X-Event-Key": {"repo:refs_changed"}, "X-Hub-Signature": {signature}}, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &api.ExternalRepoSpec{ID: "FOO/bar", ServiceType: bitbucketServerServiceType, ServiceID: "https://bitbucket.example.com/"}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("got %+v, want %+v", spec, want)
+	}
+
+	if spec, err := BitbucketServerWebhookPushRepo(http.Header{"X-Event-Key": {"diagnostics:ping"}, "X-Hub-Signature": {signature}}, body); err != nil || spec != nil {
+		t.Errorf("got %+v, %v for ping, want no repository", spec, err)
+	}
+	if _, err := BitbucketServerWebhookPushRepo(http.Header{"X-Event-Key": {"repo:refs_changed"}}, body); err != ErrWebhookUnauthorized {
+		t.Errorf("got error %v without signature, want %v", err, ErrWebhookUnauthorized)
+	}
+}
+
+func hexHMAC(h func() hash.Hash, secret string, body []byte) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
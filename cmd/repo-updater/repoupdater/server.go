@@ -26,6 +26,10 @@ func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/repo-lookup", s.handleRepoLookup)
 	mux.HandleFunc("/enqueue-repo-update", s.handleEnqueueRepoUpdate)
+	mux.HandleFunc("/update-queue", s.handleUpdateQueue)
+	mux.HandleFunc("/webhooks/github", s.handleWebhook(repos.GitHubWebhookPushRepo))
+	mux.HandleFunc("/webhooks/gitlab", s.handleWebhook(repos.GitLabWebhookPushRepo))
+	mux.HandleFunc("/webhooks/bitbucket-server", s.handleWebhook(repos.BitbucketServerWebhookPushRepo))
 	return mux
 }
 
@@ -65,6 +69,19 @@ func (s *Server) handleEnqueueRepoUpdate(w http.ResponseWriter, r *http.Request)
 	repos.UpdateOnce(r.Context(), req.Repo, req.URL)
 }
 
+func (s *Server) handleUpdateQueue(w http.ResponseWriter, r *http.Request) {
+	var req protocol.UpdateQueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(repos.UpdateQueue(req.First)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 var mockRepoLookup func(protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error)
 
 func repoLookup(ctx context.Context, args protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error) {
@@ -86,6 +103,12 @@ func repoLookup(ctx context.Context, args protocol.RepoLookupArgs) (*protocol.Re
 	if !authoritative {
 		repo, authoritative, err = repos.GetBitbucketServerRepository(ctx, args)
 	}
+	if !authoritative {
+		repo, authoritative, err = repos.GetBitbucketCloudRepository(ctx, args)
+	}
+	if !authoritative {
+		repo, authoritative, err = repos.GetGiteaRepository(ctx, args)
+	}
 	if !authoritative {
 		repo, authoritative, err = repos.GetAWSCodeCommitRepository(ctx, args)
 	}
@@ -0,0 +1,66 @@
+package repoupdater
+
+import (
+	"io/ioutil"
+	"net/http"
+
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/repos"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// maxWebhookBodySize is the maximum size of webhook request bodies. Push
+// events list the pushed commits, so they can be large.
+const maxWebhookBodySize = 25 << 20
+
+// handleWebhook returns a handler for the push event webhooks of a code host.
+// pushRepo authenticates a webhook request and returns the repository that
+// was pushed to, which is updated immediately.
+//
+// The frontend proxies webhook requests from code hosts to this handler (see
+// the webhooks route of the frontend HTTP API).
+func (s *Server) handleWebhook(pushRepo func(http.Header, []byte) (*api.ExternalRepoSpec, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		spec, err := pushRepo(r.Header, body)
+		if err == repos.ErrWebhookUnauthorized {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if spec == nil {
+			// Not a push event.
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		result, err := repoLookup(r.Context(), protocol.RepoLookupArgs{ExternalRepo: spec})
+		if err != nil {
+			log15.Error("repoLookup for webhook failed", "externalRepo", spec, "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if result.Repo == nil {
+			http.Error(w, "repository not found", http.StatusNotFound)
+			return
+		}
+
+		log15.Debug("updating repository pushed to", "repo", result.Repo.URI, "externalRepo", spec)
+		repos.UpdateOnce(r.Context(), result.Repo.URI, result.Repo.VCS.URL)
+		repos.Bump(r.Context(), result.Repo.URI)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
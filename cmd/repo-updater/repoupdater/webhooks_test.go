@@ -0,0 +1,67 @@
+package repoupdater
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/cmd/repo-updater/repos"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/repoupdater/protocol"
+)
+
+func TestServer_handleWebhook(t *testing.T) {
+	s := &Server{}
+	spec := &api.ExternalRepoSpec{ID: "a", ServiceType: repos.GitHubServiceType, ServiceID: "https://github.com/"}
+	mockRepoLookup = func(args protocol.RepoLookupArgs) (*protocol.RepoLookupResult, error) {
+		if args.ExternalRepo == nil || *args.ExternalRepo != *spec {
+			return &protocol.RepoLookupResult{ErrorNotFound: true}, nil
+		}
+		return &protocol.RepoLookupResult{Repo: &protocol.RepoInfo{
+			URI:          "github.com/webhooks/pushed",
+			ExternalRepo: spec,
+			VCS:          protocol.VCSInfo{URL: "https://github.com/webhooks/pushed"},
+		}}, nil
+	}
+	defer func() { mockRepoLookup = nil }()
+
+	serve := func(pushRepo func(http.Header, []byte) (*api.ExternalRepoSpec, error)) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/webhooks/github", strings.NewReader("{}"))
+		s.handleWebhook(pushRepo).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	tests := map[string]struct {
+		spec       *api.ExternalRepoSpec
+		err        error
+		wantStatus int
+	}{
+		"unauthorized": {err: repos.ErrWebhookUnauthorized, wantStatus: http.StatusUnauthorized},
+		"bad payload":  {err: errors.New("x"), wantStatus: http.StatusBadRequest},
+		"other event":  {wantStatus: http.StatusNoContent},
+		"unknown repo": {spec: &api.ExternalRepoSpec{ID: "b", ServiceType: repos.GitHubServiceType, ServiceID: "https://github.com/"}, wantStatus: http.StatusNotFound},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			status := serve(func(http.Header, []byte) (*api.ExternalRepoSpec, error) { return test.spec, test.err })
+			if status != test.wantStatus {
+				t.Errorf("got HTTP status %d, want %d", status, test.wantStatus)
+			}
+		})
+	}
+
+	t.Run("push", func(t *testing.T) {
+		status := serve(func(http.Header, []byte) (*api.ExternalRepoSpec, error) { return spec, nil })
+		if status != http.StatusNoContent {
+			t.Fatalf("got HTTP status %d, want %d", status, http.StatusNoContent)
+		}
+		// The repository is updated next.
+		queue := repos.UpdateQueue(1)
+		if len(queue.HighPriority) == 0 || queue.HighPriority[0].Repo != "github.com/webhooks/pushed" {
+			t.Errorf("pushed repository is not first in the high priority queue: %+v", queue.HighPriority)
+		}
+	})
+}
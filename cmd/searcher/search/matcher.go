@@ -8,6 +8,7 @@ import (
 	"io"
 	"regexp"
 	"regexp/syntax"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/sourcegraph/sourcegraph/pkg/pathmatch"
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/contextlines"
 	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
 
 	opentracing "github.com/opentracing/opentracing-go"
@@ -43,6 +45,10 @@ const (
 	// maxOffsets is the limit on number of matches to return on a line.
 	maxOffsets = 10
 
+	// maxContextLines is the limit on the number of context lines to
+	// return before and after a line match.
+	maxContextLines = 10
+
 	// numWorkers is how many concurrent readerGreps run per
 	// concurrentFind
 	numWorkers = 8
@@ -71,6 +77,27 @@ type readerGrep struct {
 	// ignoreCase if true means we need to do case insensitive matching.
 	ignoreCase bool
 
+	// multiline if true means matches may span lines. See findMultiline.
+	multiline bool
+
+	// beforeContext and afterContext are the number of context lines to
+	// return with each LineMatch.
+	beforeContext, afterContext int
+
+	// isReplace if true means we return a diff replacing each match with
+	// replacement instead of LineMatches. See replaceDiff.
+	isReplace   bool
+	replacement []byte
+
+	// countOnly if true means we only count the matching lines of a file
+	// instead of returning them. See countLines.
+	countOnly bool
+
+	// and and not are compiled from AndPatterns and NotPatterns. A file
+	// only matches if all of and (and none of not) match it too. See
+	// findConjunction.
+	and, not []*readerGrep
+
 	// transformBuf is reused between file searches to avoid
 	// re-allocating. It is only used if we need to transform the input
 	// before matching. For example we lower case the input in the case of
@@ -86,6 +113,11 @@ type readerGrep struct {
 	// re. It is the output of the longestLiteral function. It is only set if
 	// the regex has an empty LiteralPrefix.
 	literalSubstring []byte
+
+	// trigramLiteral is the output of the longestLiteral function, even if
+	// the regex has a LiteralPrefix. It is used to look up candidate files
+	// in the trigram index. See filterFilesWithIndex.
+	trigramLiteral []byte
 }
 
 // compile returns a readerGrep for matching p.
@@ -93,6 +125,7 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 	var (
 		re               *regexp.Regexp
 		literalSubstring []byte
+		trigramLiteral   []byte
 	)
 	if p.Pattern != "" {
 		expr := p.Pattern
@@ -125,18 +158,36 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 			return nil, err
 		}
 
+		ast, err := syntax.Parse(expr, syntax.Perl)
+		if err != nil {
+			return nil, err
+		}
+		ast = ast.Simplify()
+		trigramLiteral = []byte(longestLiteral(ast))
+
 		// Only use literalSubstring optimization if the regex engine doesn't
 		// have a prefix to use.
 		if pre, _ := re.LiteralPrefix(); pre == "" {
-			ast, err := syntax.Parse(expr, syntax.Perl)
-			if err != nil {
-				return nil, err
-			}
-			ast = ast.Simplify()
-			literalSubstring = []byte(longestLiteral(ast))
+			literalSubstring = trigramLiteral
 		}
 	}
 
+	replacement := p.Replacement
+	if !p.IsRegExp {
+		// A fixed string pattern has no capture groups, so the
+		// replacement is also a fixed string.
+		replacement = strings.Replace(replacement, "$", "$$", -1)
+	}
+
+	and, err := compileAll(p, p.AndPatterns)
+	if err != nil {
+		return nil, err
+	}
+	not, err := compileAll(p, p.NotPatterns)
+	if err != nil {
+		return nil, err
+	}
+
 	pathOptions := pathmatch.CompileOptions{
 		RegExp:        p.PathPatternsAreRegExps,
 		CaseSensitive: p.PathPatternsAreCaseSensitive,
@@ -149,11 +200,53 @@ func compile(p *protocol.PatternInfo) (*readerGrep, error) {
 	return &readerGrep{
 		re:               re,
 		ignoreCase:       !p.IsCaseSensitive,
+		multiline:        p.IsMultiline,
+		beforeContext:    p.BeforeContextLines,
+		afterContext:     p.AfterContextLines,
+		isReplace:        p.IsReplace,
+		replacement:      []byte(replacement),
+		countOnly:        p.CountOnly,
+		and:              and,
+		not:              not,
 		matchPath:        matchPath,
 		literalSubstring: literalSubstring,
+		trigramLiteral:   trigramLiteral,
 	}, nil
 }
 
+// compileAll returns a readerGrep for each of patterns, interpreted with the
+// options of p.
+func compileAll(p *protocol.PatternInfo, patterns []string) ([]*readerGrep, error) {
+	var rgs []*readerGrep
+	for _, pattern := range patterns {
+		rg, err := compile(&protocol.PatternInfo{
+			Pattern:            pattern,
+			IsRegExp:           p.IsRegExp,
+			IsWordMatch:        p.IsWordMatch,
+			IsCaseSensitive:    p.IsCaseSensitive,
+			BeforeContextLines: p.BeforeContextLines,
+			AfterContextLines:  p.AfterContextLines,
+		})
+		if err != nil {
+			return nil, err
+		}
+		rgs = append(rgs, rg)
+	}
+	return rgs, nil
+}
+
+// copyAll returns a copy of each of rgs.
+func copyAll(rgs []*readerGrep) []*readerGrep {
+	if rgs == nil {
+		return nil
+	}
+	copies := make([]*readerGrep, len(rgs))
+	for i, rg := range rgs {
+		copies[i] = rg.Copy()
+	}
+	return copies
+}
+
 // Copy returns a copied version of rg that is safe to use from another
 // goroutine.
 func (rg *readerGrep) Copy() *readerGrep {
@@ -164,8 +257,17 @@ func (rg *readerGrep) Copy() *readerGrep {
 	return &readerGrep{
 		re:               reCopy,
 		ignoreCase:       rg.ignoreCase,
+		multiline:        rg.multiline,
+		beforeContext:    rg.beforeContext,
+		afterContext:     rg.afterContext,
+		isReplace:        rg.isReplace,
+		replacement:      rg.replacement,
+		countOnly:        rg.countOnly,
+		and:              copyAll(rg.and),
+		not:              copyAll(rg.not),
 		matchPath:        rg.matchPath.Copy(),
 		literalSubstring: rg.literalSubstring,
+		trigramLiteral:   rg.trigramLiteral,
 	}
 }
 
@@ -181,18 +283,15 @@ func (rg *readerGrep) matchString(s string) bool {
 	return rg.re.MatchString(s)
 }
 
-// Find returns a LineMatch for each line that matches rg in reader.
-// LimitHit is true if some matches may not have been included in the result.
-// NOTE: This is not safe to use concurrently.
-func (rg *readerGrep) Find(zf *zipFile, f *srcFile) (matches []protocol.LineMatch, limitHit bool, err error) {
+// buffers returns the contents of f. fileMatchBuf is what we run match on,
+// fileBuf is the original data (for Preview).
+func (rg *readerGrep) buffers(zf *zipFile, f *srcFile) (fileBuf, fileMatchBuf []byte) {
 	if rg.ignoreCase && rg.transformBuf == nil {
 		rg.transformBuf = make([]byte, zf.MaxLen)
 	}
 
-	// fileMatchBuf is what we run match on, fileBuf is the original
-	// data (for Preview).
-	fileBuf := zf.DataFor(f)
-	fileMatchBuf := fileBuf
+	fileBuf = zf.DataFor(f)
+	fileMatchBuf = fileBuf
 
 	// If we are ignoring case, we transform the input instead of
 	// relying on the regular expression engine which can be
@@ -203,6 +302,18 @@ func (rg *readerGrep) Find(zf *zipFile, f *srcFile) (matches []protocol.LineMatc
 		fileMatchBuf = rg.transformBuf[:len(fileBuf)]
 		bytesToLowerASCII(fileMatchBuf, fileBuf)
 	}
+	return fileBuf, fileMatchBuf
+}
+
+// Find returns a LineMatch for each line that matches rg in reader.
+// LimitHit is true if some matches may not have been included in the result.
+// NOTE: This is not safe to use concurrently.
+func (rg *readerGrep) Find(zf *zipFile, f *srcFile) (matches []protocol.LineMatch, limitHit bool, err error) {
+	fileBuf, fileMatchBuf := rg.buffers(zf, f)
+
+	// data is the whole file. fileBuf is advanced as we scan lines, but we
+	// need the whole file to find context lines.
+	data := fileBuf
 
 	// Most files will not have a match and we bound the number of matched
 	// files we return. So we can avoid the overhead of parsing out new lines
@@ -260,6 +371,8 @@ func (rg *readerGrep) Find(zf *zipFile, f *srcFile) (matches []protocol.LineMatc
 				length := utf8.RuneCount(lineBuf[start:end])
 				offsetAndLengths[i] = [2]int{offset, length}
 			}
+			// lineBuf is data[idx-advance:idx] (including the newline)
+			before, after := contextlines.Around(data, idx-advance, idx, rg.beforeContext, rg.afterContext)
 			matches = append(matches, protocol.LineMatch{
 				// making a copy of lineBuf is intentional.
 				// we are not allowed to use the fileBuf data after the zipFile has been Closed,
@@ -271,6 +384,8 @@ func (rg *readerGrep) Find(zf *zipFile, f *srcFile) (matches []protocol.LineMatc
 				LineNumber:       i,
 				OffsetAndLengths: offsetAndLengths,
 				LimitHit:         lineLimitHit,
+				BeforeContext:    before,
+				AfterContext:     after,
 			})
 		}
 	}
@@ -278,9 +393,164 @@ func (rg *readerGrep) Find(zf *zipFile, f *srcFile) (matches []protocol.LineMatc
 	return matches, limitHit, nil
 }
 
-// FindZip is a convenience function to run Find on f.
+// findMultiline returns a MultilineMatch for each match of rg in reader.
+// Unlike Find, the file is not split into lines before matching so a match
+// may span several lines.
+// LimitHit is true if some matches were not included in the result.
+// NOTE: This is not safe to use concurrently.
+func (rg *readerGrep) findMultiline(zf *zipFile, f *srcFile) (matches []protocol.MultilineMatch, limitHit bool) {
+	fileBuf, fileMatchBuf := rg.buffers(zf, f)
+
+	// See Find for why we check literalSubstring first.
+	if !bytes.Contains(fileMatchBuf, rg.literalSubstring) {
+		return nil, false
+	}
+	// Ask for one more match than we return, so that we know whether any
+	// were left out.
+	locs := rg.re.FindAllIndex(fileMatchBuf, maxLineMatches+1)
+	if len(locs) == 0 {
+		return nil, false
+	}
+	if len(locs) > maxLineMatches {
+		locs = locs[:maxLineMatches]
+		limitHit = true
+	}
+
+	// Matches are returned in order and do not overlap, so we only ever
+	// need to scan forward from the start of the current line to find the
+	// location of an offset.
+	line, lineStart := 0, 0
+	location := func(offset int) protocol.Location {
+		for {
+			i := bytes.IndexByte(fileBuf[lineStart:offset], '\n')
+			if i < 0 {
+				break
+			}
+			line++
+			lineStart += i + 1
+		}
+		return protocol.Location{
+			Line:   line,
+			Column: utf8.RuneCount(fileBuf[lineStart:offset]),
+		}
+	}
+
+	matches = make([]protocol.MultilineMatch, 0, len(locs))
+	for _, loc := range locs {
+		start, end := loc[0], loc[1]
+		startLoc := location(start)
+		previewStart := lineStart
+		endLoc := location(end)
+
+		// The preview runs until the end of the line containing the last
+		// matched character. If the match ends with a newline, that is the
+		// previous line.
+		last := end
+		if end > start && fileBuf[end-1] == '\n' {
+			last = end - 1
+		}
+		previewEnd := len(fileBuf)
+		if i := bytes.IndexByte(fileBuf[last:], '\n'); i >= 0 {
+			previewEnd = last + i
+		}
+
+		matches = append(matches, protocol.MultilineMatch{
+			// Converting to a string copies the data, which is required
+			// since fileBuf is not valid after the zipFile is closed. See
+			// the comment on Preview in Find.
+			Preview: string(fileBuf[previewStart:previewEnd]),
+			Start:   startLoc,
+			End:     endLoc,
+		})
+	}
+	return matches, limitHit
+}
+
+// countLines returns the number of lines of f that match rg, or for a
+// multiline pattern the number of matches. Unlike Find it does not stop
+// after maxLineMatches. A line counts if it matches rg or any of rg.and, but
+// only if f matches all of rg.and (and none of rg.not).
+// NOTE: This is not safe to use concurrently.
+func (rg *readerGrep) countLines(zf *zipFile, f *srcFile) int {
+	if !rg.matches(zf, f) {
+		return 0
+	}
+	for _, not := range rg.not {
+		if not.matches(zf, f) {
+			return 0
+		}
+	}
+	for _, and := range rg.and {
+		if !and.matches(zf, f) {
+			return 0
+		}
+	}
+
+	fileBuf, fileMatchBuf := rg.buffers(zf, f)
+	if rg.multiline {
+		return len(rg.re.FindAllIndex(fileMatchBuf, -1))
+	}
+	// The buffers of rg.and are transformed like fileMatchBuf, so a line
+	// is at the same offsets in all of them.
+	andBufs := make([][]byte, len(rg.and))
+	for i, and := range rg.and {
+		_, andBufs[i] = and.buffers(zf, f)
+	}
+
+	count := 0
+	for idx := 0; idx < len(fileBuf); {
+		advance, lineBuf, _ := bufio.ScanLines(fileBuf[idx:], true)
+		if advance == 0 { // EOF
+			break
+		}
+		start, end := idx, idx+len(lineBuf)
+		idx += advance
+
+		// Skip lines that are too long, like Find.
+		if len(lineBuf) > maxLineSize {
+			continue
+		}
+		match := rg.re.Match(fileMatchBuf[start:end])
+		for i := 0; !match && i < len(rg.and); i++ {
+			match = rg.and[i].re.Match(andBufs[i][start:end])
+		}
+		if match {
+			count++
+		}
+	}
+	return count
+}
+
+// FindZip is a convenience function to run Find (or findMultiline,
+// replaceDiff or countLines) on f.
 func (rg *readerGrep) FindZip(zf *zipFile, f *srcFile) (protocol.FileMatch, error) {
+	if rg.isReplace {
+		return protocol.FileMatch{
+			Path: f.Name,
+			Diff: rg.replaceDiff(zf, f),
+		}, nil
+	}
+
+	if rg.countOnly {
+		return protocol.FileMatch{
+			Path:       f.Name,
+			MatchCount: rg.countLines(zf, f),
+		}, nil
+	}
+
+	if rg.multiline {
+		mm, limitHit := rg.findMultiline(zf, f)
+		return protocol.FileMatch{
+			Path:             f.Name,
+			MultilineMatches: mm,
+			LimitHit:         limitHit,
+		}, nil
+	}
+
 	lm, limitHit, err := rg.Find(zf, f)
+	if err == nil && len(lm) > 0 && (len(rg.and) > 0 || len(rg.not) > 0) {
+		lm, limitHit, err = rg.findConjunction(zf, f, lm, limitHit)
+	}
 	return protocol.FileMatch{
 		Path:        f.Name,
 		LineMatches: lm,
@@ -288,8 +558,74 @@ func (rg *readerGrep) FindZip(zf *zipFile, f *srcFile) (protocol.FileMatch, erro
 	}, err
 }
 
+// findConjunction returns the line matches lm of rg's pattern in f merged
+// with the line matches of rg.and. It returns no matches if any of rg.and do
+// not match f, or any of rg.not do.
+func (rg *readerGrep) findConjunction(zf *zipFile, f *srcFile, lm []protocol.LineMatch, limitHit bool) ([]protocol.LineMatch, bool, error) {
+	for _, not := range rg.not {
+		if not.matches(zf, f) {
+			return nil, false, nil
+		}
+	}
+	for _, and := range rg.and {
+		andLM, andLimitHit, err := and.Find(zf, f)
+		if err != nil || len(andLM) == 0 {
+			return nil, false, err
+		}
+		lm = mergeLineMatches(lm, andLM)
+		limitHit = limitHit || andLimitHit
+	}
+	return lm, limitHit, nil
+}
+
+// matches returns whether rg matches anywhere in f.
+func (rg *readerGrep) matches(zf *zipFile, f *srcFile) bool {
+	_, fileMatchBuf := rg.buffers(zf, f)
+	return bytes.Contains(fileMatchBuf, rg.literalSubstring) && rg.re.Match(fileMatchBuf)
+}
+
+// mergeLineMatches merges a and b, which are sorted by line number, into a
+// list sorted by line number with one LineMatch per line.
+func mergeLineMatches(a, b []protocol.LineMatch) []protocol.LineMatch {
+	merged := make([]protocol.LineMatch, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0].LineNumber < b[0].LineNumber:
+			merged = append(merged, a[0])
+			a = a[1:]
+		case a[0].LineNumber > b[0].LineNumber:
+			merged = append(merged, b[0])
+			b = b[1:]
+		default:
+			m := a[0]
+			offsets := append(append([][2]int{}, a[0].OffsetAndLengths...), b[0].OffsetAndLengths...)
+			sort.Slice(offsets, func(i, j int) bool {
+				if offsets[i][0] != offsets[j][0] {
+					return offsets[i][0] < offsets[j][0]
+				}
+				return offsets[i][1] < offsets[j][1]
+			})
+			m.OffsetAndLengths = offsets[:0]
+			for _, o := range offsets {
+				// Patterns may match the same text.
+				if n := len(m.OffsetAndLengths); n == 0 || o != m.OffsetAndLengths[n-1] {
+					m.OffsetAndLengths = append(m.OffsetAndLengths, o)
+				}
+			}
+			m.LimitHit = a[0].LimitHit || b[0].LimitHit
+			merged = append(merged, m)
+			a, b = a[1:], b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
 // concurrentFind searches files in zr looking for matches using rg.
-func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool) (fm []protocol.FileMatch, limitHit bool, err error) {
+//
+// If onMatch is non-nil it is called with each match as soon as it is found
+// (and before concurrentFind returns). Calls to onMatch are not concurrent.
+func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchLimit int, patternMatchesContent, patternMatchesPaths bool, onMatch func(protocol.FileMatch)) (fm []protocol.FileMatch, limitHit bool, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "ConcurrentFind")
 	ext.Component.Set(span, "matcher")
 	if rg.re != nil {
@@ -337,7 +673,11 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchL
 		for _, f := range files {
 			if rg.matchPath.MatchPath(f.Name) && rg.matchString(f.Name) {
 				if len(matches) < fileMatchLimit {
-					matches = append(matches, protocol.FileMatch{Path: f.Name})
+					fm := protocol.FileMatch{Path: f.Name}
+					matches = append(matches, fm)
+					if onMatch != nil {
+						onMatch(fm)
+					}
 				} else {
 					limitHit = true
 					break
@@ -347,6 +687,11 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchL
 		return matches, limitHit, nil
 	}
 
+	// We can skip the files that the trigram index rules out, unless their
+	// path matches.
+	files = filterFilesWithIndex(zf, rg, patternMatchesPaths)
+	span.LogFields(otlog.Int("filesSkippedByIndex", len(zf.Files)-len(files)))
+
 	var (
 		done          = ctx.Done()
 		wg            sync.WaitGroup
@@ -397,7 +742,7 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchL
 					})
 					return
 				}
-				match := len(fm.LineMatches) > 0
+				match := len(fm.LineMatches) > 0 || len(fm.MultilineMatches) > 0 || fm.Diff != "" || fm.MatchCount > 0
 				if !match && patternMatchesPaths {
 					// Try matching against the file path.
 					match = rg.matchString(f.Name)
@@ -409,6 +754,9 @@ func concurrentFind(ctx context.Context, rg *readerGrep, zf *zipFile, fileMatchL
 					matchesmu.Lock()
 					if len(matches) < fileMatchLimit {
 						matches = append(matches, fm)
+						if onMatch != nil {
+							onMatch(fm)
+						}
 					} else {
 						limitHit = true
 						cancel()
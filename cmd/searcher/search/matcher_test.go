@@ -10,6 +10,7 @@ import (
 	"regexp/syntax"
 	"sort"
 	"strconv"
+	"strings"
 	"testing"
 	"testing/iotest"
 	"testing/quick"
@@ -263,7 +264,7 @@ func benchConcurrentFind(b *testing.B, p *protocol.Request) {
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
-		_, _, err := concurrentFind(ctx, rg, zf, 0, p.PatternMatchesContent, p.PatternMatchesPath)
+		_, _, err := concurrentFind(ctx, rg, zf, 0, p.PatternMatchesContent, p.PatternMatchesPath, nil)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -500,7 +501,7 @@ func TestMaxMatches(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	fileMatches, limitHit, err := concurrentFind(context.Background(), rg, zf, 0, true, false)
+	fileMatches, limitHit, err := concurrentFind(context.Background(), rg, zf, 0, true, false, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -560,7 +561,7 @@ func TestPathMatches(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, true)
+	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, true, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -576,6 +577,108 @@ func TestPathMatches(t *testing.T) {
 	}
 }
 
+func TestFindMultiline(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"main.go": "package main\n\nfunc a() {\n}\n\nfunc b() {\n\treturn\n}\n\nfunc c() {}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{
+		Pattern:     `func \w+\(\)\s*\{\s*\}`,
+		IsRegExp:    true,
+		IsMultiline: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileMatches) != 1 {
+		t.Fatalf("expected 1 file match, got %d", len(fileMatches))
+	}
+
+	want := []protocol.MultilineMatch{{
+		Preview: "func a() {\n}",
+		Start:   protocol.Location{Line: 2, Column: 0},
+		End:     protocol.Location{Line: 3, Column: 1},
+	}, {
+		Preview: "func c() {}",
+		Start:   protocol.Location{Line: 9, Column: 0},
+		End:     protocol.Location{Line: 9, Column: 11},
+	}}
+	if got := fileMatches[0].MultilineMatches; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got multiline matches %+v, want %+v", got, want)
+	}
+}
+
+func TestFindMultiline_limitHit(t *testing.T) {
+	for _, n := range []int{maxLineMatches, maxLineMatches + 1} {
+		zipData, err := createZip(map[string]string{"a.txt": strings.Repeat("a\nb\n", n)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		zf, err := mockZipFile(zipData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rg, err := compile(&protocol.PatternInfo{
+			Pattern:     `a\nb`,
+			IsRegExp:    true,
+			IsMultiline: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches, limitHit := rg.findMultiline(zf, &zf.Files[0])
+		if len(matches) != maxLineMatches || limitHit != (n > maxLineMatches) {
+			t.Errorf("%d matches in file: got %d matches (limitHit=%v), want %d (limitHit=%v)", n, len(matches), limitHit, maxLineMatches, n > maxLineMatches)
+		}
+	}
+}
+
+func TestFindContext(t *testing.T) {
+	data := []byte("a\nb\r\nfoo\nc\nfoo\nd")
+	rg, err := compile(&protocol.PatternInfo{
+		Pattern:            "foo",
+		BeforeContextLines: 2,
+		AfterContextLines:  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakeZipFile := zipFile{MaxLen: len(data), Data: data}
+	fakeSrcFile := srcFile{Len: int32(len(data))}
+	matches, _, err := rg.Find(&fakeZipFile, &fakeSrcFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []protocol.LineMatch{{
+		Preview:          "foo",
+		LineNumber:       2,
+		OffsetAndLengths: [][2]int{{0, 3}},
+		BeforeContext:    []string{"a", "b"},
+		AfterContext:     []string{"c", "foo"},
+	}, {
+		Preview:          "foo",
+		LineNumber:       4,
+		OffsetAndLengths: [][2]int{{0, 3}},
+		BeforeContext:    []string{"foo", "c"},
+		AfterContext:     []string{"d"},
+	}}
+	if !reflect.DeepEqual(matches, want) {
+		t.Fatalf("got %+v, want %+v", matches, want)
+	}
+}
+
 func createZip(files map[string]string) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	zw := zip.NewWriter(buf)
@@ -608,3 +711,99 @@ func init() {
 	// Clear out store so we pick up changes in our store writing code.
 	os.RemoveAll(githubStore.Path)
 }
+
+func TestFindConjunction(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go":  "package a\n\nfunc Foo() { Bar() }\n",
+		"b.go":  "package b\n\nfunc Foo() {}\n\nfunc Bar() {}\n",
+		"c.go":  "package c\n\nfunc Foo() { Bar() }\n\nfunc Baz() {}\n",
+		"d.txt": "foo\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{
+		Pattern:     "foo",
+		AndPatterns: []string{"bar", "foo\\(\\)"},
+		NotPatterns: []string{"baz"},
+		IsRegExp:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []protocol.FileMatch{{
+		Path: "a.go",
+		LineMatches: []protocol.LineMatch{{
+			Preview:          "func Foo() { Bar() }",
+			LineNumber:       2,
+			OffsetAndLengths: [][2]int{{5, 3}, {5, 5}, {13, 3}},
+		}},
+	}, {
+		Path: "b.go",
+		LineMatches: []protocol.LineMatch{{
+			Preview:          "func Foo() {}",
+			LineNumber:       2,
+			OffsetAndLengths: [][2]int{{5, 3}, {5, 5}},
+		}, {
+			Preview:          "func Bar() {}",
+			LineNumber:       4,
+			OffsetAndLengths: [][2]int{{5, 3}},
+		}},
+	}}
+	sort.Slice(fileMatches, func(i, j int) bool { return fileMatches[i].Path < fileMatches[j].Path })
+	if !reflect.DeepEqual(fileMatches, want) {
+		t.Fatalf("got file matches %+v, want %+v", fileMatches, want)
+	}
+}
+
+func TestFindCountOnly(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go":  "package a\n\nfunc Foo() { Bar() }\n",
+		"b.go":  "package b\n\nfunc Foo() {}\n\nfunc Bar() {}\n",
+		"c.go":  "package c\n\nfunc Foo() { Bar() }\n\nfunc Baz() {}\n",
+		"d.txt": strings.Repeat("foo bar\n", maxLineMatches+1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{
+		Pattern:     "foo",
+		AndPatterns: []string{"bar"},
+		NotPatterns: []string{"baz"},
+		CountOnly:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lines matching any of the patterns are counted, and unlike line
+	// matches the count is not limited to maxLineMatches.
+	want := []protocol.FileMatch{
+		{Path: "a.go", MatchCount: 1},
+		{Path: "b.go", MatchCount: 2},
+		{Path: "d.txt", MatchCount: maxLineMatches + 1},
+	}
+	sort.Slice(fileMatches, func(i, j int) bool { return fileMatches[i].Path < fileMatches[j].Path })
+	if !reflect.DeepEqual(fileMatches, want) {
+		t.Fatalf("got file matches %+v, want %+v", fileMatches, want)
+	}
+}
@@ -0,0 +1,186 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change
+// in a replacement diff. It is the same as the default of diff -u.
+const diffContextLines = 3
+
+// replaceDiff returns a unified diff of f with every match of rg replaced by
+// rg.replacement. It returns an empty string if rg does not match f, or if
+// replacing the matches does not change f.
+//
+// We know exactly which lines each replacement changes, so unlike a general
+// diff algorithm we do not need to compare the old and new file contents.
+//
+// NOTE: This is not safe to use concurrently.
+func (rg *readerGrep) replaceDiff(zf *zipFile, f *srcFile) string {
+	fileBuf, fileMatchBuf := rg.buffers(zf, f)
+	if len(fileBuf) == 0 || !bytes.Contains(fileMatchBuf, rg.literalSubstring) {
+		return ""
+	}
+	// Unlike Find we replace every match, since a partial diff would be
+	// misleading.
+	locs := rg.re.FindAllSubmatchIndex(fileMatchBuf, -1)
+	if len(locs) == 0 {
+		return ""
+	}
+
+	lines := splitLines(fileBuf)
+	starts := make([]int, len(lines))
+	for i, offset := 0, 0; i < len(lines); i++ {
+		starts[i] = offset
+		offset += len(lines[i])
+	}
+	// lineOf returns the index of the line containing offset.
+	lineOf := func(offset int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+	}
+	// lastLineOf returns the index of the line containing the last byte of
+	// the match loc (or its start if it is empty).
+	lastLineOf := func(loc []int) int {
+		if loc[1] > loc[0] {
+			return lineOf(loc[1] - 1)
+		}
+		return lineOf(loc[0])
+	}
+	// expand returns the lines first to last (inclusive) with the matches
+	// locs (which must be contained in those lines) replaced.
+	expand := func(locs [][]int, first, last int) []byte {
+		var buf []byte
+		pos := starts[first]
+		for _, loc := range locs {
+			buf = append(buf, fileBuf[pos:loc[0]]...)
+			buf = rg.re.Expand(buf, rg.replacement, fileBuf, loc)
+			pos = loc[1]
+		}
+		return append(buf, fileBuf[pos:starts[last]+len(lines[last])]...)
+	}
+
+	// Group matches into changes to ranges of lines.
+	var changes []lineChange
+	for i := 0; i < len(locs); {
+		first, last := lineOf(locs[i][0]), lastLineOf(locs[i])
+		j := i + 1
+		var replaced []byte
+		for {
+			for ; j < len(locs) && lineOf(locs[j][0]) <= last; j++ {
+				if l := lastLineOf(locs[j]); l > last {
+					last = l
+				}
+			}
+			replaced = expand(locs[i:j], first, last)
+			if len(replaced) == 0 || replaced[len(replaced)-1] == '\n' || last == len(lines)-1 {
+				break
+			}
+			// The replacement removed the newline at the end of the
+			// last line, joining it with the following line. So the
+			// following line is changed too.
+			last++
+		}
+		if !bytes.Equal(replaced, fileBuf[starts[first]:starts[last]+len(lines[last])]) {
+			changes = append(changes, lineChange{
+				oldStart: first,
+				oldEnd:   last + 1,
+				newLines: splitLines(replaced),
+			})
+		}
+		i = j
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- a/%s\n+++ b/%s\n", f.Name, f.Name)
+	delta := 0 // the difference between new and old line numbers before the current hunk
+	for i := 0; i < len(changes); {
+		// Changes whose context lines overlap are in the same hunk.
+		j := i + 1
+		for j < len(changes) && changes[j].oldStart-changes[j-1].oldEnd <= 2*diffContextLines {
+			j++
+		}
+		hunk := changes[i:j]
+		oldStart := hunk[0].oldStart - diffContextLines
+		if oldStart < 0 {
+			oldStart = 0
+		}
+		oldEnd := hunk[len(hunk)-1].oldEnd + diffContextLines
+		if oldEnd > len(lines) {
+			oldEnd = len(lines)
+		}
+
+		var body bytes.Buffer
+		newCount := 0
+		pos := oldStart
+		for _, c := range hunk {
+			for ; pos < c.oldStart; pos++ {
+				writeDiffLine(&body, ' ', lines[pos])
+				newCount++
+			}
+			for ; pos < c.oldEnd; pos++ {
+				writeDiffLine(&body, '-', lines[pos])
+			}
+			for _, l := range c.newLines {
+				writeDiffLine(&body, '+', l)
+				newCount++
+			}
+		}
+		for ; pos < oldEnd; pos++ {
+			writeDiffLine(&body, ' ', lines[pos])
+			newCount++
+		}
+
+		oldCount := oldEnd - oldStart
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(oldStart+delta, newCount))
+		buf.Write(body.Bytes())
+		delta += newCount - oldCount
+		i = j
+	}
+	return buf.String()
+}
+
+// lineChange is a change to the lines [oldStart, oldEnd) of a file.
+type lineChange struct {
+	oldStart, oldEnd int
+	newLines         [][]byte
+}
+
+// hunkRange formats the 0-based start line and number of lines of one side of
+// a hunk, as in "@@ -1,3 +1,4 @@".
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range is described by the line before it.
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// writeDiffLine writes line (which includes its newline, if any) to w,
+// prefixed by prefix.
+func writeDiffLine(w *bytes.Buffer, prefix byte, line []byte) {
+	w.WriteByte(prefix)
+	w.Write(line)
+	if !bytes.HasSuffix(line, []byte{'\n'}) {
+		w.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// splitLines splits b into lines. Unlike bufio.ScanLines each line includes
+// its newline, so the lines can be joined to get back b.
+func splitLines(b []byte) [][]byte {
+	var lines [][]byte
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		lines = append(lines, b[:i])
+		b = b[i:]
+	}
+	return lines
+}
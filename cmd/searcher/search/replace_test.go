@@ -0,0 +1,144 @@
+package search
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
+)
+
+func TestReplaceDiff(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"main.go": "package main\n\nfunc main() {\n\tfmt.Println(\"Hello world\")\n}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg, err := compile(&protocol.PatternInfo{
+		Pattern:     `fmt\.(\w+)`,
+		IsRegExp:    true,
+		IsReplace:   true,
+		Replacement: "log.$1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileMatches) != 1 {
+		t.Fatalf("expected 1 file match, got %d", len(fileMatches))
+	}
+
+	want := strings.Join([]string{
+		"--- a/main.go",
+		"+++ b/main.go",
+		"@@ -1,5 +1,5 @@",
+		" package main",
+		" ",
+		" func main() {",
+		"-\tfmt.Println(\"Hello world\")",
+		"+\tlog.Println(\"Hello world\")",
+		" }",
+		"",
+	}, "\n")
+	if got := fileMatches[0].Diff; got != want {
+		t.Fatalf("got diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestReplaceDiff_apply checks that the diffs we generate can be applied with
+// git apply, and that the result is the same as regexp.ReplaceAll.
+func TestReplaceDiff_apply(t *testing.T) {
+	numbered := func(n int) string {
+		var lines []string
+		for i := 0; i < n; i++ {
+			lines = append(lines, "line "+strings.Repeat("x", i%3))
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	cases := []struct {
+		name, content, pattern, replacement string
+	}{
+		{name: "single", content: "foo\n", pattern: "foo", replacement: "bar"},
+		{name: "no trailing newline", content: "a\nfoo", pattern: "foo", replacement: "bar"},
+		{name: "add trailing newline", content: "a\nfoo", pattern: "foo$", replacement: "bar\n"},
+		{name: "capture groups", content: "a := b\nc := d\n", pattern: `(\w+) := (\w+)`, replacement: "$2 := $1"},
+		{name: "insert lines", content: "a\nfoo\nb\n", pattern: "foo", replacement: "foo1\nfoo2"},
+		{name: "delete lines", content: "a\nfoo\nb\n", pattern: `foo\n`, replacement: ""},
+		{name: "join lines", content: "a\nfoo\nb\nc\n", pattern: `foo\n`, replacement: "foo "},
+		{name: "multiline match", content: "a\nfunc() {\n}\nb\n", pattern: `\{\n\}`, replacement: "{}"},
+		{name: "separate hunks", content: numbered(30), pattern: "line xx", replacement: "LINE"},
+		{name: "close hunks", content: "foo\n" + numbered(6) + "foo\n" + numbered(20) + "foo\n", pattern: "foo", replacement: "bar"},
+		{name: "case insensitive", content: "Foo\nfoo\n", pattern: "(?i)FOO", replacement: "bar"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			zipData, err := createZip(map[string]string{"file.txt": c.content})
+			if err != nil {
+				t.Fatal(err)
+			}
+			zf, err := mockZipFile(zipData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pattern := strings.TrimPrefix(c.pattern, "(?i)")
+			rg, err := compile(&protocol.PatternInfo{
+				Pattern:         pattern,
+				IsRegExp:        true,
+				IsCaseSensitive: pattern == c.pattern,
+				IsReplace:       true,
+				Replacement:     c.replacement,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff := rg.replaceDiff(zf, &zf.Files[0])
+
+			want := regexp.MustCompile("(?m:"+c.pattern+")").ReplaceAllString(c.content, c.replacement)
+			got := gitApply(t, c.content, diff)
+			if got != want {
+				t.Fatalf("got %q, want %q. diff:\n%s", got, want, diff)
+			}
+		})
+	}
+}
+
+// gitApply applies diff to a file.txt containing content and returns the
+// resulting file contents.
+func gitApply(t *testing.T, content, diff string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "replace_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file.txt")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "apply", "-")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(diff)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git apply failed: %s\n%s\ndiff:\n%s", err, out, diff)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
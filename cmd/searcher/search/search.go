@@ -83,7 +83,28 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	matches, limitHit, deadlineHit, err := s.search(ctx, &p)
+	var (
+		stream  *streamWriter
+		onMatch func(protocol.FileMatch)
+	)
+	if p.Stream {
+		stream = &streamWriter{w: w, enc: json.NewEncoder(w)}
+		onMatch = func(fm protocol.FileMatch) {
+			stream.send(protocol.StreamEvent{Match: &fm})
+		}
+	}
+
+	matches, limitHit, deadlineHit, err := s.search(ctx, &p, onMatch)
+	if stream != nil && (err == nil || stream.started) {
+		// Once we have started streaming we can no longer respond with an
+		// error code, so the error is reported in the final event.
+		done := protocol.StreamDone{LimitHit: limitHit, DeadlineHit: deadlineHit}
+		if err != nil {
+			done.Error = err.Error()
+		}
+		stream.send(protocol.StreamEvent{Done: &done})
+		return
+	}
 	if err != nil {
 		code := http.StatusInternalServerError
 		if isBadRequest(err) || ctx.Err() == context.Canceled {
@@ -114,7 +135,31 @@ func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(&resp)
 }
 
-func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []protocol.FileMatch, limitHit, deadlineHit bool, err error) {
+// streamWriter writes the StreamEvents of a streaming response.
+type streamWriter struct {
+	w   http.ResponseWriter
+	enc *json.Encoder
+
+	// started is true once the first event has been written.
+	started bool
+}
+
+func (sw *streamWriter) send(ev protocol.StreamEvent) {
+	if !sw.started {
+		sw.w.Header().Set("Content-Type", protocol.StreamContentType)
+		sw.started = true
+	}
+	// As with non-streaming responses, the only reasonable error is the
+	// client going away, which we ignore.
+	_ = sw.enc.Encode(&ev)
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// search searches p. If onMatch is non-nil, it is called with each match as
+// soon as it is found. See concurrentFind.
+func (s *Service) search(ctx context.Context, p *protocol.Request, onMatch func(protocol.FileMatch)) (matches []protocol.FileMatch, limitHit, deadlineHit bool, err error) {
 	tr := trace.New("search", fmt.Sprintf("%s@%s", p.Repo, p.Commit))
 	tr.LazyPrintf("%s", p.Pattern)
 
@@ -127,12 +172,16 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	span.SetTag("isRegExp", strconv.FormatBool(p.IsRegExp))
 	span.SetTag("isWordMatch", strconv.FormatBool(p.IsWordMatch))
 	span.SetTag("isCaseSensitive", strconv.FormatBool(p.IsCaseSensitive))
+	span.SetTag("isMultiline", strconv.FormatBool(p.IsMultiline))
+	span.SetTag("isReplace", strconv.FormatBool(p.IsReplace))
+	span.SetTag("countOnly", strconv.FormatBool(p.CountOnly))
 	span.SetTag("pathPatternsAreRegExps", strconv.FormatBool(p.PathPatternsAreRegExps))
 	span.SetTag("pathPatternsAreCaseSensitive", strconv.FormatBool(p.PathPatternsAreCaseSensitive))
 	span.SetTag("fileMatchLimit", p.FileMatchLimit)
 	span.SetTag("patternMatchesContent", p.PatternMatchesContent)
 	span.SetTag("patternMatchesPath", p.PatternMatchesPath)
 	span.SetTag("deadline", p.Deadline)
+	span.SetTag("stream", p.Stream)
 	defer func(start time.Time) {
 		code := "200"
 		// We often have canceled and timed out requests. We do not want to
@@ -165,7 +214,7 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 		span.SetTag("limitHit", limitHit)
 		span.SetTag("deadlineHit", deadlineHit)
 		span.Finish()
-		log15.Debug("search request", "repo", p.Repo, "commit", p.Commit, "pattern", p.Pattern, "isRegExp", p.IsRegExp, "isWordMatch", p.IsWordMatch, "isCaseSensitive", p.IsCaseSensitive, "patternMatchesContent", p.PatternMatchesContent, "patternMatchesPath", p.PatternMatchesPath, "matches", len(matches), "code", code, "duration", time.Since(start), "err", err)
+		log15.Debug("search request", "repo", p.Repo, "commit", p.Commit, "pattern", p.Pattern, "isRegExp", p.IsRegExp, "isWordMatch", p.IsWordMatch, "isCaseSensitive", p.IsCaseSensitive, "isMultiline", p.IsMultiline, "isReplace", p.IsReplace, "countOnly", p.CountOnly, "patternMatchesContent", p.PatternMatchesContent, "patternMatchesPath", p.PatternMatchesPath, "matches", len(matches), "code", code, "duration", time.Since(start), "err", err)
 	}(time.Now())
 
 	rg, err := compile(&p.PatternInfo)
@@ -201,7 +250,7 @@ func (s *Service) search(ctx context.Context, p *protocol.Request) (matches []pr
 	archiveFiles.Observe(float64(nFiles))
 	archiveSize.Observe(float64(bytes))
 
-	matches, limitHit, err = concurrentFind(ctx, rg, zf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath)
+	matches, limitHit, err = concurrentFind(ctx, rg, zf, p.FileMatchLimit, p.PatternMatchesContent, p.PatternMatchesPath, onMatch)
 	return matches, limitHit, false, err
 }
 
@@ -216,6 +265,18 @@ func validateParams(p *protocol.Request) error {
 	if p.Pattern == "" && p.ExcludePattern == "" && len(p.IncludePatterns) == 0 && p.IncludePattern == "" {
 		return errors.New("At least one of pattern and include/exclude pattners must be non-empty")
 	}
+	if p.BeforeContextLines < 0 || p.BeforeContextLines > maxContextLines || p.AfterContextLines < 0 || p.AfterContextLines > maxContextLines {
+		return errors.Errorf("BeforeContextLines and AfterContextLines must be between 0 and %d", maxContextLines)
+	}
+	if p.IsReplace && (p.Pattern == "" || !p.PatternMatchesContent || p.PatternMatchesPath) {
+		return errors.New("IsReplace requires a non-empty pattern that only matches content")
+	}
+	if (len(p.AndPatterns) > 0 || len(p.NotPatterns) > 0) && (p.Pattern == "" || !p.PatternMatchesContent || p.PatternMatchesPath || p.IsMultiline || p.IsReplace) {
+		return errors.New("AndPatterns and NotPatterns require a non-empty pattern that only matches content, and are not supported with IsMultiline or IsReplace")
+	}
+	if p.IsMultiline && (p.BeforeContextLines > 0 || p.AfterContextLines > 0) {
+		return errors.New("BeforeContextLines and AfterContextLines are not supported with IsMultiline")
+	}
 	return nil
 }
 
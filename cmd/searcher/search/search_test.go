@@ -123,6 +123,22 @@ main.go:6:	fmt.Println("Hello world")
 `},
 		{protocol.PatternInfo{Pattern: "world", IncludePattern: `\.(MD|go)`, PathPatternsAreRegExps: true, PathPatternsAreCaseSensitive: true}, `
 main.go:6:	fmt.Println("Hello world")
+`},
+
+		{protocol.PatternInfo{Pattern: `import "fmt"\s+func`, IsRegExp: true}, ""},
+		{protocol.PatternInfo{Pattern: `import "fmt"\s+func`, IsRegExp: true, IsMultiline: true}, `
+main.go:3-5:import "fmt"
+
+func main() {
+`},
+
+		{protocol.PatternInfo{Pattern: "world", CountOnly: true}, `
+README.md:count=2
+main.go:count=1
+`},
+		{protocol.PatternInfo{Pattern: `hello\s+world`, IsRegExp: true, IsMultiline: true, CountOnly: true}, `
+README.md:count=2
+main.go:count=1
 `},
 
 		{protocol.PatternInfo{Pattern: "doesnotmatch"}, ""},
@@ -270,6 +286,19 @@ func TestSearch_badrequest(t *testing.T) {
 				PathPatternsAreRegExps: true,
 			},
 		},
+
+		// Context lines with a multiline pattern
+		{
+			Repo:   "foo",
+			URL:    "u",
+			Commit: "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+			PatternInfo: protocol.PatternInfo{
+				Pattern:            "a\\nb",
+				IsRegExp:           true,
+				IsMultiline:        true,
+				BeforeContextLines: 1,
+			},
+		},
 	}
 
 	store, cleanup, err := newStore(nil)
@@ -292,6 +321,60 @@ func TestSearch_badrequest(t *testing.T) {
 	}
 }
 
+func TestSearch_stream(t *testing.T) {
+	files := map[string]string{
+		"README.md": "# Hello World\n\nHello world example in go",
+		"main.go":   "package main\n\nfunc main() {\n\tfmt.Println(\"Hello world\")\n}\n",
+	}
+
+	store, cleanup, err := newStore(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	ts := httptest.NewServer(&search.Service{Store: store})
+	defer ts.Close()
+
+	for _, pattern := range []string{"world", "doesnotmatch"} {
+		req := protocol.Request{
+			Repo:         "foo",
+			URL:          "u",
+			Commit:       "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+			PatternInfo:  protocol.PatternInfo{Pattern: pattern, PatternMatchesContent: true},
+			FetchTimeout: "500ms",
+		}
+		want, err := doSearch(ts.URL, &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Stream = true
+		got, err := doSearch(ts.URL, &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sort.Sort(sortByPath(want))
+		sort.Sort(sortByPath(got))
+		if toString(got) != toString(want) {
+			t.Errorf("%s: streaming response differs from non-streaming response\ngot:\n%s\nwant:\n%s", pattern, toString(got), toString(want))
+		}
+	}
+
+	// Errors found before the first match is streamed are still reported
+	// with an HTTP error code.
+	_, err = doSearch(ts.URL, &protocol.Request{
+		Repo:        "foo",
+		URL:         "u",
+		Commit:      "deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+		PatternInfo: protocol.PatternInfo{Pattern: `\F`, IsRegExp: true, PatternMatchesContent: true},
+		Stream:      true,
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "non-200 response: code=400 ") {
+		t.Fatalf("expected HTTP 400 response. Got %v", err)
+	}
+}
+
 func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	form := url.Values{
 		"Repo":            []string{string(p.Repo)},
@@ -323,11 +406,30 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	if p.PatternMatchesPath {
 		form.Set("PatternMatchesPath", "true")
 	}
+	if p.IsMultiline {
+		form.Set("IsMultiline", "true")
+	}
+	if p.BeforeContextLines > 0 {
+		form.Set("BeforeContextLines", strconv.Itoa(p.BeforeContextLines))
+	}
+	if p.AfterContextLines > 0 {
+		form.Set("AfterContextLines", strconv.Itoa(p.AfterContextLines))
+	}
+	if p.CountOnly {
+		form.Set("CountOnly", "true")
+	}
+	if p.Stream {
+		form.Set("Stream", "true")
+	}
 	resp, err := http.PostForm(u, form)
 	if err != nil {
 		return nil, err
 	}
 
+	if resp.Header.Get("Content-Type") == protocol.StreamContentType {
+		return readStream(resp.Body)
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -344,6 +446,26 @@ func doSearch(u string, p *protocol.Request) ([]protocol.FileMatch, error) {
 	return r.Matches, err
 }
 
+func readStream(r io.Reader) ([]protocol.FileMatch, error) {
+	var matches []protocol.FileMatch
+	dec := json.NewDecoder(r)
+	for {
+		var ev protocol.StreamEvent
+		if err := dec.Decode(&ev); err != nil {
+			return nil, fmt.Errorf("stream ended without done event: %v", err)
+		}
+		if ev.Match != nil {
+			matches = append(matches, *ev.Match)
+		}
+		if ev.Done != nil {
+			if ev.Done.Error != "" {
+				return nil, errors.New(ev.Done.Error)
+			}
+			return matches, nil
+		}
+	}
+}
+
 func newStore(files map[string]string) (*search.Store, func(), error) {
 	buf := new(bytes.Buffer)
 	w := tar.NewWriter(buf)
@@ -386,7 +508,9 @@ func newStore(files map[string]string) (*search.Store, func(), error) {
 func toString(m []protocol.FileMatch) string {
 	buf := new(bytes.Buffer)
 	for _, f := range m {
-		if len(f.LineMatches) == 0 {
+		if f.MatchCount > 0 {
+			fmt.Fprintf(buf, "%s:count=%d\n", f.Path, f.MatchCount)
+		} else if len(f.LineMatches) == 0 && len(f.MultilineMatches) == 0 {
 			buf.WriteString(f.Path)
 			buf.WriteByte('\n')
 		}
@@ -398,6 +522,16 @@ func toString(m []protocol.FileMatch) string {
 			buf.WriteString(l.Preview)
 			buf.WriteByte('\n')
 		}
+		for _, m := range f.MultilineMatches {
+			buf.WriteString(f.Path)
+			buf.WriteByte(':')
+			buf.WriteString(strconv.Itoa(m.Start.Line + 1))
+			buf.WriteByte('-')
+			buf.WriteString(strconv.Itoa(m.End.Line + 1))
+			buf.WriteByte(':')
+			buf.WriteString(m.Preview)
+			buf.WriteByte('\n')
+		}
 
 	}
 	return buf.String()
@@ -9,6 +9,7 @@ import (
 	"encoding/hex"
 	"io"
 	"log"
+	"os"
 	"sync"
 	"time"
 
@@ -29,11 +30,17 @@ import (
 // do not want to search.
 //
 // We use an LRU to do cache eviction:
-// * When to evict is based on the total size of *.zip on disk.
+// * When to evict is based on the total size of *.zip (and their trigram
+//   indexes) on disk.
 // * What to evict uses the LRU algorithm.
 // * We touch files when opening them, so can do LRU based on file
 //   modification times.
 //
+// Next to each zip we store a trigram index of its contents, which searches
+// use to skip files that cannot match. It is built in the background after
+// the zip is fetched. Its size is counted towards MaxCacheSizeBytes, and it
+// is deleted with the zip.
+//
 // Note: The store fetches tarballs but stores zips. We want to be able to
 // filter which files we cache, so we need a format that supports streaming
 // (tar). We want to be able to support random concurrent access for reading,
@@ -69,8 +76,21 @@ type Store struct {
 
 	// zipCache provides efficient access to repo zip files.
 	zipCache zipCache
+
+	// indexSem is a semaphore to limit concurrent trigram index builds.
+	indexSem chan struct{}
+
+	// indexingMu protects indexing.
+	indexingMu sync.Mutex
+
+	// indexing is the set of zip paths whose trigram index is being built.
+	indexing map[string]bool
 }
 
+// maxConcurrentIndexBuilds is the maximum number of trigram indexes that are
+// built concurrently. Building an index needs a 64MB table.
+const maxConcurrentIndexBuilds = 2
+
 // Start initializes state and starts background goroutines. It can be called
 // more than once. It is optional to call, but starting it earlier avoids a
 // search request paying the cost of initializing.
@@ -80,12 +100,15 @@ func (s *Store) Start() {
 			s.MaxConcurrentFetchTar = 15
 		}
 		s.fetchSem = make(chan int, s.MaxConcurrentFetchTar)
+		s.indexSem = make(chan struct{}, maxConcurrentIndexBuilds)
+		s.indexing = map[string]bool{}
 
 		s.cache = &diskcache.Store{
 			Dir:               s.Path,
 			Component:         "store",
 			BackgroundTimeout: 2 * time.Minute,
 			BeforeEvict:       s.zipCache.delete,
+			SidecarSuffixes:   []string{trigramIndexSuffix},
 		}
 		go s.watchAndEvict()
 	})
@@ -139,6 +162,11 @@ func (s *Store) prepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 				f.File.Close()
 			}
 		}
+		if err == nil {
+			// Searches don't need the index, so don't make them wait
+			// for it.
+			go s.indexZip(path)
+		}
 		resC <- result{path, err}
 	}()
 
@@ -154,6 +182,58 @@ func (s *Store) prepareZip(ctx context.Context, repo gitserver.Repo, commit api.
 	}
 }
 
+// indexZip builds the trigram index of the zip at path if it doesn't have
+// one. It does nothing if the index is already being built, or too many
+// other indexes are, so that searches are not held up. They can use the
+// index once a later call has built it.
+func (s *Store) indexZip(path string) {
+	s.indexingMu.Lock()
+	if s.indexing[path] {
+		s.indexingMu.Unlock()
+		return
+	}
+	s.indexing[path] = true
+	s.indexingMu.Unlock()
+	defer func() {
+		s.indexingMu.Lock()
+		delete(s.indexing, path)
+		s.indexingMu.Unlock()
+	}()
+
+	zf, err := s.zipCache.get(path)
+	if err != nil {
+		log.Printf("failed to open %s to build trigram index: %s", path, err)
+		return
+	}
+	defer zf.Close()
+	if zf.trigrams() != nil {
+		return
+	}
+
+	select {
+	case s.indexSem <- struct{}{}:
+		defer func() { <-s.indexSem }()
+	default:
+		return
+	}
+
+	start := time.Now()
+	ix, err := buildTrigramIndex(path, zf)
+	if err != nil {
+		indexBuildFailed.Inc()
+		log.Printf("failed to build trigram index for %s: %s", path, err)
+		return
+	}
+	indexBuildDuration.Observe(time.Since(start).Seconds())
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// The zip was evicted while we were indexing it, so nothing would
+		// remove its index.
+		os.Remove(trigramIndexPath(path))
+		return
+	}
+	zf.setTrigrams(ix)
+}
+
 // fetch fetches an archive from the network and stores it on disk. It does
 // not populate the in-memory cache. You should probably be calling
 // prepareZip.
@@ -344,6 +424,19 @@ var (
 		Name:      "fetch_failed",
 		Help:      "The total number of archive fetches that failed.",
 	})
+	indexBuildDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "searcher",
+		Subsystem: "store",
+		Name:      "trigram_index_build_seconds",
+		Help:      "Observes how long it takes to build the trigram index of an archive.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+	})
+	indexBuildFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "searcher",
+		Subsystem: "store",
+		Name:      "trigram_index_build_failed",
+		Help:      "The total number of trigram index builds that failed.",
+	})
 )
 
 func init() {
@@ -352,4 +445,6 @@ func init() {
 	prometheus.MustRegister(fetching)
 	prometheus.MustRegister(fetchQueueSize)
 	prometheus.MustRegister(fetchFailed)
+	prometheus.MustRegister(indexBuildDuration)
+	prometheus.MustRegister(indexBuildFailed)
 }
@@ -0,0 +1,393 @@
+package search
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// trigramIndexSuffix is appended to the path of a zip in the store to get the
+// path of its trigram index.
+const trigramIndexSuffix = ".trigrams"
+
+// trigramIndexMagic is the start of every trigram index file. Bump the
+// version if the format changes, so that old indexes are rebuilt.
+const trigramIndexMagic = "SGTRI001"
+
+// A trigramIndex maps each trigram in the files of a zipFile to the files
+// that contain it. It is used to skip files that cannot contain the literal
+// parts of a pattern. Trigrams are ASCII lowercased, so the index can be
+// used for case sensitive and insensitive searches.
+//
+// The on-disk format is:
+//
+//	header:   magic (8 bytes) | number of files (uint32) | number of trigrams (uint32)
+//	table:    trigram (uint32) | number of files (uint32) | postings offset (uint64), sorted by trigram
+//	postings: for each trigram, the indexes into zipFile.Files of the files
+//	          containing it, as uvarint deltas
+//
+// All fixed size integers are big endian.
+type trigramIndex struct {
+	numFiles int
+	table    []byte
+	postings []byte
+
+	// data is the whole index. When f is non-nil it is mmapped from f.
+	data []byte
+	f    *os.File
+}
+
+const (
+	trigramIndexHeaderSize = len(trigramIndexMagic) + 8
+	trigramIndexEntrySize  = 16
+)
+
+func trigramIndexPath(zipPath string) string {
+	return zipPath + trigramIndexSuffix
+}
+
+// openTrigramIndex opens the trigram index at path. It returns a nil index
+// if it doesn't exist.
+func openTrigramIndex(path string) (*trigramIndex, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() < int64(trigramIndexHeaderSize) {
+		f.Close()
+		return nil, errors.Errorf("trigram index %s is truncated", path)
+	}
+	data, err := unix.Mmap(int(f.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ix, err := parseTrigramIndex(data)
+	if err != nil {
+		unix.Munmap(data)
+		f.Close()
+		return nil, errors.Wrapf(err, "trigram index %s", path)
+	}
+	ix.f = f
+	return ix, nil
+}
+
+func parseTrigramIndex(data []byte) (*trigramIndex, error) {
+	if len(data) < trigramIndexHeaderSize || string(data[:len(trigramIndexMagic)]) != trigramIndexMagic {
+		return nil, errors.New("invalid header")
+	}
+	header := data[len(trigramIndexMagic):trigramIndexHeaderSize]
+	numFiles := binary.BigEndian.Uint32(header)
+	numTrigrams := binary.BigEndian.Uint32(header[4:])
+	tableEnd := uint64(trigramIndexHeaderSize) + uint64(numTrigrams)*trigramIndexEntrySize
+	if uint64(len(data)) < tableEnd {
+		return nil, errors.New("table is truncated")
+	}
+	return &trigramIndex{
+		numFiles: int(numFiles),
+		table:    data[trigramIndexHeaderSize:tableEnd],
+		postings: data[tableEnd:],
+		data:     data,
+	}, nil
+}
+
+// close releases the resources of ix. It must not be used afterwards.
+func (ix *trigramIndex) close() error {
+	if ix.f == nil {
+		return nil
+	}
+	err := unix.Munmap(ix.data)
+	if err1 := ix.f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// lookup returns the postings of trigram t and the number of files in them.
+func (ix *trigramIndex) lookup(t uint32) (postings []byte, n int, ok bool) {
+	numTrigrams := len(ix.table) / trigramIndexEntrySize
+	entry := func(i int) []byte {
+		return ix.table[i*trigramIndexEntrySize : (i+1)*trigramIndexEntrySize]
+	}
+	i := sort.Search(numTrigrams, func(i int) bool {
+		return binary.BigEndian.Uint32(entry(i)) >= t
+	})
+	if i == numTrigrams || binary.BigEndian.Uint32(entry(i)) != t {
+		return nil, 0, false
+	}
+	e := entry(i)
+	n = int(binary.BigEndian.Uint32(e[4:]))
+	off := binary.BigEndian.Uint64(e[8:])
+	if off > uint64(len(ix.postings)) {
+		return nil, 0, false
+	}
+	return ix.postings[off:], n, true
+}
+
+// candidates returns the indexes into zipFile.Files of the files that contain
+// all of literals, in increasing order. It returns ok == false if the index
+// can't narrow down the files (e.g. because all literals are shorter than a
+// trigram).
+func (ix *trigramIndex) candidates(literals [][]byte) (files []uint32, ok bool) {
+	seen := map[uint32]bool{}
+	type list struct {
+		postings []byte
+		n        int
+	}
+	var lists []list
+	for _, lit := range literals {
+		for _, t := range trigrams(lit) {
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			postings, n, found := ix.lookup(t)
+			if !found {
+				// No file contains t.
+				return nil, true
+			}
+			lists = append(lists, list{postings: postings, n: n})
+		}
+	}
+	if len(lists) == 0 {
+		return nil, false
+	}
+
+	// Start with the shortest list, since the result can't be longer.
+	sort.Slice(lists, func(i, j int) bool { return lists[i].n < lists[j].n })
+	files = decodePostings(lists[0].postings, lists[0].n, nil)
+	for _, l := range lists[1:] {
+		if len(files) == 0 {
+			break
+		}
+		files = decodePostings(l.postings, l.n, files)
+	}
+	return files, true
+}
+
+// decodePostings decodes the n uvarint deltas at the start of postings. If
+// filter is non-nil, only the files also in filter are returned, reusing its
+// storage.
+func decodePostings(postings []byte, n int, filter []uint32) []uint32 {
+	var files []uint32
+	if filter != nil {
+		files = filter[:0]
+	} else {
+		files = make([]uint32, 0, n)
+	}
+	var file uint64
+	j := 0
+	for i := 0; i < n; i++ {
+		delta, size := binary.Uvarint(postings)
+		if size <= 0 {
+			break
+		}
+		postings = postings[size:]
+		file += delta
+		if filter == nil {
+			files = append(files, uint32(file))
+			continue
+		}
+		for j < len(filter) && uint64(filter[j]) < file {
+			j++
+		}
+		if j == len(filter) {
+			break
+		}
+		if uint64(filter[j]) == file {
+			// filter[j] has been read, so it is safe to overwrite
+			// files[len(files)], which is at or before j.
+			files = append(files, uint32(file))
+			j++
+		}
+	}
+	return files
+}
+
+// trigrams returns the ASCII lowercased trigrams of b, in order and with
+// duplicates.
+func trigrams(b []byte) []uint32 {
+	if len(b) < 3 {
+		return nil
+	}
+	ts := make([]uint32, 0, len(b)-2)
+	var t uint32
+	for i, c := range b {
+		t = (t<<8 | uint32(lowerASCII(c))) & 0xFFFFFF
+		if i >= 2 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// writeTrigramIndex writes the trigram index of zf to w.
+func writeTrigramIndex(w io.Writer, zf *zipFile) error {
+	type postingList struct {
+		trigram uint32
+		last    uint32 // the last file added
+		n       uint32
+		buf     []byte
+	}
+	var (
+		// listIndex maps a trigram to 1 + its index in lists. A flat
+		// table is much faster than a map when indexing large archives.
+		listIndex = make([]int32, 1<<24)
+		lists     []postingList
+		varint    [binary.MaxVarintLen64]byte
+	)
+	for i := range zf.Files {
+		file := uint32(i)
+		data := zf.DataFor(&zf.Files[i])
+		var t uint32
+		for j, c := range data {
+			t = (t<<8 | uint32(lowerASCII(c))) & 0xFFFFFF
+			if j < 2 {
+				continue
+			}
+			k := listIndex[t]
+			if k == 0 {
+				lists = append(lists, postingList{trigram: t})
+				k = int32(len(lists))
+				listIndex[t] = k
+			}
+			l := &lists[k-1]
+			if l.n > 0 && l.last == file {
+				continue
+			}
+			delta := file
+			if l.n > 0 {
+				delta = file - l.last
+			}
+			size := binary.PutUvarint(varint[:], uint64(delta))
+			l.buf = append(l.buf, varint[:size]...)
+			l.last = file
+			l.n++
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].trigram < lists[j].trigram })
+
+	bw := bufio.NewWriter(w)
+	var header [trigramIndexHeaderSize]byte
+	copy(header[:], trigramIndexMagic)
+	binary.BigEndian.PutUint32(header[len(trigramIndexMagic):], uint32(len(zf.Files)))
+	binary.BigEndian.PutUint32(header[len(trigramIndexMagic)+4:], uint32(len(lists)))
+	if _, err := bw.Write(header[:]); err != nil {
+		return err
+	}
+	var (
+		entry [trigramIndexEntrySize]byte
+		off   uint64
+	)
+	for _, l := range lists {
+		binary.BigEndian.PutUint32(entry[:], l.trigram)
+		binary.BigEndian.PutUint32(entry[4:], l.n)
+		binary.BigEndian.PutUint64(entry[8:], off)
+		if _, err := bw.Write(entry[:]); err != nil {
+			return err
+		}
+		off += uint64(len(l.buf))
+	}
+	for _, l := range lists {
+		if _, err := bw.Write(l.buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// buildTrigramIndex writes the trigram index of zf (the zip at zipPath) next
+// to it, and opens it.
+func buildTrigramIndex(zipPath string, zf *zipFile) (*trigramIndex, error) {
+	path := trigramIndexPath(zipPath)
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name()) // fails (harmlessly) after the rename
+	err = writeTrigramIndex(f, zf)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return nil, err
+	}
+	return openTrigramIndex(path)
+}
+
+// trigramLiterals returns the literals that appear in every file matched by
+// rg.
+func (rg *readerGrep) trigramLiterals() [][]byte {
+	var literals [][]byte
+	if len(rg.trigramLiteral) > 0 {
+		literals = append(literals, rg.trigramLiteral)
+	}
+	for _, and := range rg.and {
+		literals = append(literals, and.trigramLiterals()...)
+	}
+	return literals
+}
+
+// filterFilesWithIndex returns the files of zf which may contain a match of
+// rg according to zf's trigram index. If matchPaths is true, the files whose
+// path matches rg are returned too. It returns all files if zf has no index,
+// or it doesn't help.
+func filterFilesWithIndex(zf *zipFile, rg *readerGrep, matchPaths bool) []srcFile {
+	ix := zf.trigrams()
+	if ix == nil || ix.numFiles != len(zf.Files) {
+		return zf.Files
+	}
+	candidates, ok := ix.candidates(rg.trigramLiterals())
+	if !ok {
+		return zf.Files
+	}
+	if !matchPaths {
+		files := make([]srcFile, 0, len(candidates))
+		for _, i := range candidates {
+			if int(i) < len(zf.Files) {
+				files = append(files, zf.Files[i])
+			}
+		}
+		return files
+	}
+
+	isCandidate := make([]bool, len(zf.Files))
+	for _, i := range candidates {
+		if int(i) < len(zf.Files) {
+			isCandidate[i] = true
+		}
+	}
+	var files []srcFile
+	for i, f := range zf.Files {
+		if isCandidate[i] || rg.matchString(f.Name) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
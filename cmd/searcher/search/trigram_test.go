@@ -0,0 +1,181 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/searcher/protocol"
+)
+
+func TestTrigramIndex(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go":    "package a\n\nfunc Foo() { Bar() }\n",
+		"b.go":    "package b\n\nfunc FOO() {}\n",
+		"c.txt":   "hello world\n",
+		"d.txt":   "",
+		"e/f.txt": "Hello ünicode\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err := mockZipFile(zipData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeTrigramIndex(&buf, zf); err != nil {
+		t.Fatal(err)
+	}
+	ix, err := parseTrigramIndex(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.numFiles != len(zf.Files) {
+		t.Fatalf("got %d files, want %d", ix.numFiles, len(zf.Files))
+	}
+
+	tests := []struct {
+		literals []string
+		want     []string
+		ok       bool
+	}{
+		{literals: []string{"foo"}, want: []string{"a.go", "b.go"}, ok: true},
+		{literals: []string{"FOO()"}, want: []string{"a.go", "b.go"}, ok: true},
+		{literals: []string{"foo", "bar"}, want: []string{"a.go"}, ok: true},
+		{literals: []string{"hello"}, want: []string{"c.txt", "e/f.txt"}, ok: true},
+		{literals: []string{"ünicode"}, want: []string{"e/f.txt"}, ok: true},
+		{literals: []string{"missing"}, ok: true},
+		{literals: []string{"fo"}},
+		{},
+	}
+	for _, test := range tests {
+		var literals [][]byte
+		for _, lit := range test.literals {
+			literals = append(literals, []byte(lit))
+		}
+		candidates, ok := ix.candidates(literals)
+		var got []string
+		for _, i := range candidates {
+			got = append(got, zf.Files[i].Name)
+		}
+		sort.Strings(got) // createZip adds files in random order
+		if ok != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q (ok=%v), want %q (ok=%v)", test.literals, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestTrigramIndex_concurrentFind(t *testing.T) {
+	zipData, err := createZip(map[string]string{
+		"a.go":      "package a\n\nfunc Foo() { Bar() }\n",
+		"b.go":      "package b\n\nfunc FOO() {}\n",
+		"c.go":      "package c\n\nfunc Baz() {}\n",
+		"d.txt":     "foo\n",
+		"foo/e.txt": "bar\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := ioutil.TempDir("", "trigram_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+	path := filepath.Join(d, "repo.zip")
+	if err := ioutil.WriteFile(path, zipData, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var c zipCache
+	zf, err := c.get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zf.trigrams() != nil {
+		t.Fatal("expected no trigram index before it is built")
+	}
+	ix, err := buildTrigramIndex(path, zf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf.setTrigrams(ix)
+	zf.Close()
+	defer c.delete(path)
+
+	// The index must not change the results.
+	patterns := []protocol.PatternInfo{
+		{Pattern: "foo"},
+		{Pattern: "foo", IsCaseSensitive: true},
+		{Pattern: "func F.*\\(\\)", IsRegExp: true},
+		{Pattern: "foo", AndPatterns: []string{"bar"}},
+		{Pattern: "nomatch"},
+	}
+	for _, p := range patterns {
+		rg, err := compile(&p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, patternMatchesPaths := range []bool{false, true} {
+			find := func(zf *zipFile) []protocol.FileMatch {
+				fileMatches, _, err := concurrentFind(context.Background(), rg, zf, 10, true, patternMatchesPaths, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Slice(fileMatches, func(i, j int) bool { return fileMatches[i].Path < fileMatches[j].Path })
+				return fileMatches
+			}
+			withoutIndex, err := mockZipFile(zipData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zf, err := c.get(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, want := find(zf), find(withoutIndex)
+			zf.Close()
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%+v (patternMatchesPaths=%v): got %+v with the index, want %+v", p, patternMatchesPaths, got, want)
+			}
+		}
+	}
+
+	// With the default result types (content and path), files are skipped
+	// unless their content may match or their path matches.
+	rg, err := compile(&protocol.PatternInfo{Pattern: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zf, err = c.get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var searched []string
+	for _, f := range filterFilesWithIndex(zf, rg, true) {
+		searched = append(searched, f.Name)
+	}
+	zf.Close()
+	sort.Strings(searched)
+	if want := []string{"a.go", "b.go", "d.txt", "foo/e.txt"}; !reflect.DeepEqual(searched, want) {
+		t.Errorf("got searched files %q, want %q", searched, want)
+	}
+
+	// A new zipCache opens the index that is already on disk.
+	var c2 zipCache
+	zf, err = c2.get(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.delete(path)
+	defer zf.Close()
+	if zf.trigrams() == nil {
+		t.Error("expected the trigram index to be opened with the zip")
+	}
+}
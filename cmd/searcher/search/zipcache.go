@@ -78,6 +78,11 @@ func (c *zipCache) delete(path string) {
 	}
 	// Wait for all clients using this zipFile to complete their work.
 	zf.wg.Wait()
+	if zf.index != nil {
+		if err := zf.index.close(); err != nil {
+			log.Printf("failed to close trigram index of %q: %v", path, err)
+		}
+	}
 	// Mock zipFiles have nil f. Only try to munmap and close f if it is non-nil.
 	if zf.f != nil {
 		// For now, only log errors here.
@@ -102,6 +107,9 @@ type zipFile struct {
 	Data   []byte
 	f      *os.File
 	wg     sync.WaitGroup // ensures underlying file is not munmap'd or closed while in use
+
+	indexMu sync.Mutex    // protects index
+	index   *trigramIndex // nil until the trigram index is built, see Store.indexZip
 }
 
 func readZipFile(path string) (*zipFile, error) {
@@ -135,9 +143,41 @@ func readZipFile(path string) (*zipFile, error) {
 		log.Printf("failed to madvise for %q: %v", path, err)
 	}
 
+	// The index is optional, so only log failures here. Store.indexZip
+	// rebuilds a missing index.
+	ix, err := openTrigramIndex(trigramIndexPath(path))
+	if err != nil {
+		log.Printf("failed to open trigram index for %q: %v", path, err)
+	} else if ix != nil && ix.numFiles != len(zf.Files) {
+		log.Printf("ignoring trigram index for %q: it has %d files, want %d", path, ix.numFiles, len(zf.Files))
+		ix.close()
+	} else {
+		zf.index = ix
+	}
+
 	return zf, nil
 }
 
+// trigrams returns the trigram index of f, or nil if it has none.
+func (f *zipFile) trigrams() *trigramIndex {
+	f.indexMu.Lock()
+	defer f.indexMu.Unlock()
+	return f.index
+}
+
+// setTrigrams sets the trigram index of f to ix, unless it already has one.
+// f takes ownership of ix.
+func (f *zipFile) setTrigrams(ix *trigramIndex) {
+	f.indexMu.Lock()
+	defer f.indexMu.Unlock()
+	if f.index != nil {
+		// The current index may be in use, so keep it.
+		ix.close()
+		return
+	}
+	f.index = ix
+}
+
 func (f *zipFile) populateFiles(r *zip.Reader) error {
 	f.Files = make([]srcFile, len(r.File))
 	for i, file := range r.File {
@@ -19,7 +19,7 @@ type parseRequest struct {
 	data []byte
 }
 
-func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoURI, commitID api.CommitID) (<-chan parseRequest, <-chan error, error) {
+func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoURI, commitID api.CommitID, paths []string) (<-chan parseRequest, <-chan error, error) {
 	fetchQueueSize.Inc()
 	s.fetchSem <- 1 // acquire concurrent fetches semaphore
 	fetchQueueSize.Dec()
@@ -59,11 +59,21 @@ func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoURI,
 		span.Finish()
 	}
 
-	r, err := s.FetchTar(ctx, gitserver.Repo{Name: repo}, commitID)
+	r, err := s.FetchTar(ctx, gitserver.Repo{Name: repo}, commitID, paths)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// FetchTar may return more files than paths (e.g. if they contain glob
+	// characters), so only parse the files at paths.
+	var onlyPaths map[string]bool
+	if len(paths) > 0 {
+		onlyPaths = make(map[string]bool, len(paths))
+		for _, p := range paths {
+			onlyPaths[p] = true
+		}
+	}
+
 	// After this point we are not allowed to return an error. Instead we can
 	// return an error via the errChan we return. If you do want to update this
 	// code please ensure we still always call done once.
@@ -91,6 +101,9 @@ func (s *Service) fetchRepositoryArchive(ctx context.Context, repo api.RepoURI,
 			if path.Ext(hdr.Name) == ".json" {
 				continue
 			}
+			if onlyPaths != nil && !onlyPaths[hdr.Name] {
+				continue
+			}
 
 			// We only care about files
 			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
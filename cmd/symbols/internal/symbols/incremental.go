@@ -0,0 +1,112 @@
+package symbols
+
+import (
+	"context"
+	"os"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+const (
+	// maxIncrementalAncestors is the number of ancestors of a commit that are
+	// checked for cached symbols.
+	maxIncrementalAncestors = 20
+
+	// maxIncrementalChanges is the number of changed files above which it is
+	// not worth computing symbols incrementally.
+	maxIncrementalChanges = 1000
+)
+
+// writeSymbolIndexIncremental writes the symbols index of repo at commitID
+// to path, by copying the symbols from the cached index of the nearest
+// ancestor that has one, and parsing only the files that changed since. ok is
+// false if there is no such ancestor, in which case nothing is written.
+func (s *Service) writeSymbolIndexIncremental(ctx context.Context, path string, repo api.RepoURI, commitID api.CommitID) (ok bool, err error) {
+	if s.ListAncestors == nil || s.GitDiff == nil {
+		return false, nil
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "writeSymbolIndexIncremental")
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+
+	gitserverRepo := gitserver.Repo{Name: repo}
+	ancestors, err := s.ListAncestors(ctx, gitserverRepo, commitID, maxIncrementalAncestors)
+	if err != nil {
+		return false, err
+	}
+	var (
+		base      api.CommitID
+		baseIndex *symbolIndex
+	)
+	for _, ancestor := range ancestors {
+		f, err := s.cache.OpenCached(symbolIndexKey(repo, ancestor))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		index, err := openSymbolIndex(f)
+		if err != nil {
+			f.Close()
+			return false, err
+		}
+		base, baseIndex = ancestor, index
+		break
+	}
+	if baseIndex == nil {
+		return false, nil
+	}
+	defer baseIndex.Close()
+	span.SetTag("base", base)
+
+	changes, err := s.GitDiff(ctx, gitserverRepo, base, commitID)
+	if err != nil {
+		return false, err
+	}
+	if len(changes.Added)+len(changes.Modified)+len(changes.Deleted) > maxIncrementalChanges {
+		return false, nil
+	}
+	span.LogFields(otlog.Int("added", len(changes.Added)), otlog.Int("modified", len(changes.Modified)), otlog.Int("deleted", len(changes.Deleted)))
+
+	// Parse the changed files before writing the index, so that we don't
+	// write anything if there is an error.
+	parsePaths := append(append([]string(nil), changes.Added...), changes.Modified...)
+	var symbols []protocol.Symbol
+	if len(parsePaths) > 0 {
+		symbols, err = s.parseUncached(ctx, repo, commitID, parsePaths)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	removedPaths := append(append([]string(nil), changes.Modified...), changes.Deleted...)
+	if err := updateSymbolIndex(ctx, path, baseIndex, removedPaths, symbols); err != nil {
+		return false, err
+	}
+	incrementalIndexes.Inc()
+	return true, nil
+}
+
+var incrementalIndexes = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "symbols",
+	Subsystem: "index",
+	Name:      "incremental",
+	Help:      "The total number of commits whose symbols were computed from those of an ancestor.",
+})
+
+func init() {
+	prometheus.MustRegister(incrementalIndexes)
+}
@@ -1,23 +1,30 @@
 package symbols
 
 import (
-	"bytes"
-	"compress/gzip"
+	"bufio"
 	"context"
-	"encoding/gob"
+	"encoding/binary"
 	"io"
-	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/pkg/errors"
 	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/diskcache"
 	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
 	"golang.org/x/net/trace"
+	log15 "gopkg.in/inconshreveable/log15.v2"
 )
 
-func (s *Service) indexedSymbols(ctx context.Context, repo api.RepoURI, commitID api.CommitID) (symbols []protocol.Symbol, err error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "indexedSymbols")
+// symbolIndex returns the index of the symbols of repo at commitID, parsing
+// them first if they are not in the cache. The index must be closed when it
+// is no longer needed.
+func (s *Service) symbolIndex(ctx context.Context, repo api.RepoURI, commitID api.CommitID) (index *symbolIndex, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "symbolIndex")
 	defer func() {
 		if err != nil {
 			ext.Error.Set(span, true)
@@ -26,14 +33,12 @@ func (s *Service) indexedSymbols(ctx context.Context, repo api.RepoURI, commitID
 		span.Finish()
 	}()
 
-	key := string(repo) + ":" + string(commitID) + ":v1" // suffix is index format version (vN)
-
-	tr := trace.New("indexedSymbols", string(repo))
+	tr := trace.New("symbolIndex", string(repo))
 	tr.LazyPrintf("commitID: %s", commitID)
 
-	var fetched bool
+	var fetched, incremental bool
 	defer func() {
-		tr.LazyPrintf("fetched=%v symbols=%d", fetched, len(symbols))
+		tr.LazyPrintf("fetched=%v incremental=%v", fetched, incremental)
 		if err != nil {
 			tr.LazyPrintf("error: %s", err)
 			tr.SetError()
@@ -41,55 +46,213 @@ func (s *Service) indexedSymbols(ctx context.Context, repo api.RepoURI, commitID
 		tr.Finish()
 	}()
 
-	f, err := s.cache.Open(ctx, key, func(ctx context.Context) (io.ReadCloser, error) {
+	f, err := s.cache.OpenWithPath(ctx, symbolIndexKey(repo, commitID), func(ctx context.Context, path string) error {
 		fetched = true
 
 		var err error
-		symbols, err = s.parseUncached(ctx, repo, commitID)
+		incremental, err = s.writeSymbolIndexIncremental(ctx, path, repo, commitID)
 		if err != nil {
-			return nil, err
+			// Fall back to parsing every file.
+			log15.Warn("Incremental symbols indexing failed.", "repo", repo, "commitID", commitID, "error", err)
+			incremental = false
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if incremental {
+			return nil
 		}
-		return encodeSymbols(symbols)
+
+		symbols, err := s.parseUncached(ctx, repo, commitID, nil)
+		if err != nil {
+			return err
+		}
+		tr.LazyPrintf("write symbols=%d", len(symbols))
+		return writeSymbolIndex(ctx, path, symbols)
 	})
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	// Skip deserializing symbols if we just serialized them in the s.cache.Open call above and still have the
-	// slice.
-	if symbols == nil {
-		var size int64
-		if fi, err := f.Stat(); err == nil {
-			size = fi.Size()
+	// The index keeps f open, so that it remains readable if it is evicted
+	// from the cache while in use.
+	index, err = openSymbolIndex(f)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "open symbols index")
+	}
+	return index, nil
+}
+
+// symbolIndexKey returns the cache key of the symbols index of repo at
+// commitID.
+func symbolIndexKey(repo api.RepoURI, commitID api.CommitID) string {
+	return string(repo) + ":" + string(commitID) + ":v3" // suffix is index format version (vN)
+}
+
+// A symbolIndex is a file with the symbols of a commit, sorted so that
+// exact and prefix name queries and the symbols of a file can be looked up
+// without reading every symbol.
+//
+// The file consists of:
+//
+//   - the symbols, encoded by encodeSymbol and sorted by nameLess
+//   - the name table: the offset of each symbol in the file, in the same
+//     order, followed by the offset of the end of the symbols (8 bytes each)
+//   - the path table: the position of each symbol in the name table, sorted
+//     by path and line (4 bytes each)
+//   - the footer: the number of symbols and the offset of the path table
+//     (8 bytes each), and indexMagic
+//
+// All integers in the tables and footer are big endian.
+type symbolIndex struct {
+	f         *diskcache.File
+	count     int   // the number of symbols
+	nameTable int64 // the offset of the name table
+	pathTable int64 // the offset of the path table
+}
+
+// indexMagic ends every symbols index file.
+const indexMagic = "sgsymbols"
+
+const footerSize = 8 + 8 + len(indexMagic)
+
+func openSymbolIndex(f *diskcache.File) (*symbolIndex, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < int64(footerSize) {
+		return nil, errors.New("file too short")
+	}
+	footer := make([]byte, footerSize)
+	if _, err := f.ReadAt(footer, fi.Size()-int64(footerSize)); err != nil {
+		return nil, err
+	}
+	if string(footer[16:]) != indexMagic {
+		return nil, errors.New("bad magic")
+	}
+	count := int64(binary.BigEndian.Uint64(footer))
+	pathTable := int64(binary.BigEndian.Uint64(footer[8:]))
+	if pathTable+4*count != fi.Size()-int64(footerSize) {
+		return nil, errors.New("bad footer")
+	}
+	return &symbolIndex{
+		f:         f,
+		count:     int(count),
+		nameTable: pathTable - 8*(count+1),
+		pathTable: pathTable,
+	}, nil
+}
+
+func (x *symbolIndex) Close() error {
+	return x.f.Close()
+}
+
+// symbol returns the i'th symbol in name order.
+func (x *symbolIndex) symbol(i int) (protocol.Symbol, error) {
+	var offsets [16]byte
+	if _, err := x.f.ReadAt(offsets[:], x.nameTable+8*int64(i)); err != nil {
+		return protocol.Symbol{}, err
+	}
+	start := int64(binary.BigEndian.Uint64(offsets[:]))
+	end := int64(binary.BigEndian.Uint64(offsets[8:]))
+	r := bufio.NewReaderSize(io.NewSectionReader(x.f, start, end-start), int(end-start)+1)
+	return decodeSymbol(r)
+}
+
+// scan calls fn with each symbol in name order, starting at the i'th, until
+// it returns false or there are no more symbols.
+func (x *symbolIndex) scan(ctx context.Context, i int, fn func(protocol.Symbol) bool) error {
+	if i >= x.count {
+		return nil
+	}
+	var start [8]byte
+	if _, err := x.f.ReadAt(start[:], x.nameTable+8*int64(i)); err != nil {
+		return err
+	}
+	offset := int64(binary.BigEndian.Uint64(start[:]))
+	r := bufio.NewReader(io.NewSectionReader(x.f, offset, x.nameTable-offset))
+	for ; i < x.count; i++ {
+		if i%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 		}
-		tr.LazyPrintf("decode bytes=%d", size)
-		symbols, err = decodeSymbols(ctx, f)
-		tr.LazyPrintf("decode (done) symbols=%d", len(symbols))
+		symbol, err := decodeSymbol(r)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if !fn(symbol) {
+			return nil
 		}
 	}
+	return nil
+}
 
-	span.LogFields(otlog.String("event", "result"), otlog.Int("count", len(symbols)))
-	return symbols, nil
+// searchName returns the position in name order of the first symbol whose
+// lowercase name is at least lowerName.
+func (x *symbolIndex) searchName(lowerName string) (int, error) {
+	var err error
+	i := sort.Search(x.count, func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var symbol protocol.Symbol
+		symbol, err = x.symbol(i)
+		return strings.ToLower(symbol.Name) >= lowerName
+	})
+	return i, err
 }
 
-func encodeSymbols(symbols []protocol.Symbol) (io.ReadCloser, error) {
-	var buf bytes.Buffer
-	zw := gzip.NewWriter(&buf)
-	enc := gob.NewEncoder(zw)
-	if err := enc.Encode(symbols); err != nil {
-		return nil, err
+// fileSymbols returns the symbols in the file at path, ordered by line.
+func (x *symbolIndex) fileSymbols(path string) ([]protocol.Symbol, error) {
+	var err error
+	pathSymbol := func(i int) protocol.Symbol {
+		var pos [4]byte
+		if _, err1 := x.f.ReadAt(pos[:], x.pathTable+4*int64(i)); err1 != nil {
+			err = err1
+			return protocol.Symbol{}
+		}
+		symbol, err1 := x.symbol(int(binary.BigEndian.Uint32(pos[:])))
+		if err1 != nil {
+			err = err1
+		}
+		return symbol
 	}
-	if err := zw.Close(); err != nil {
-		return nil, err
+
+	var symbols []protocol.Symbol
+	i := sort.Search(x.count, func(i int) bool { return err != nil || pathSymbol(i).Path >= path })
+	for ; err == nil && i < x.count; i++ {
+		symbol := pathSymbol(i)
+		if symbol.Path != path {
+			break
+		}
+		symbols = append(symbols, symbol)
 	}
-	return ioutil.NopCloser(&buf), nil
+	return symbols, err
 }
 
-func decodeSymbols(ctx context.Context, r io.Reader) (symbols []protocol.Symbol, err error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "decodeSymbols")
+// nameLess reports whether a is ordered before b in a symbols index: by
+// lowercase name (so that case insensitive queries can be looked up), then
+// name, path and line.
+func nameLess(a, b *protocol.Symbol) bool {
+	if al, bl := strings.ToLower(a.Name), strings.ToLower(b.Name); al != bl {
+		return al < bl
+	}
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.Path != b.Path {
+		return a.Path < b.Path
+	}
+	return a.Line < b.Line
+}
+
+// writeSymbolIndex writes a symbols index with symbols to path (see
+// symbolIndex). The file at path must not exist.
+func writeSymbolIndex(ctx context.Context, path string, symbols []protocol.Symbol) (err error) {
+	span, _ := opentracing.StartSpanFromContext(ctx, "writeSymbolIndex")
 	defer func() {
 		if err != nil {
 			ext.Error.Set(span, true)
@@ -98,12 +261,179 @@ func decodeSymbols(ctx context.Context, r io.Reader) (symbols []protocol.Symbol,
 		span.Finish()
 	}()
 
-	zr, err := gzip.NewReader(r)
+	// byPath is the order of symbols in the path table. A stable sort keeps
+	// the symbols on a line in the order in which they were parsed.
+	byPath := make([]int, len(symbols))
+	for i := range byPath {
+		byPath[i] = i
+	}
+	sort.SliceStable(byPath, func(i, j int) bool {
+		a, b := &symbols[byPath[i]], &symbols[byPath[j]]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+	byName := make([]int, len(symbols))
+	copy(byName, byPath)
+	sort.SliceStable(byName, func(i, j int) bool { return nameLess(&symbols[byName[i]], &symbols[byName[j]]) })
+	namePos := make([]uint32, len(symbols)) // index in symbols -> position in byName
+	for pos, i := range byName {
+		namePos[i] = uint32(pos)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer zr.Close()
-	dec := gob.NewDecoder(zr)
-	err = dec.Decode(&symbols)
-	return symbols, err
+	defer func() {
+		if err1 := f.Close(); err == nil {
+			err = err1
+		}
+	}()
+	w := &countingWriter{w: bufio.NewWriter(f)}
+
+	offsets := make([]int64, 0, len(symbols)+1)
+	for _, i := range byName {
+		offsets = append(offsets, w.n)
+		encodeSymbol(w, &symbols[i])
+	}
+	offsets = append(offsets, w.n)
+
+	var b [8]byte
+	for _, offset := range offsets {
+		binary.BigEndian.PutUint64(b[:], uint64(offset))
+		w.Write(b[:])
+	}
+	pathTable := w.n
+	for _, i := range byPath {
+		binary.BigEndian.PutUint32(b[:4], namePos[i])
+		w.Write(b[:4])
+	}
+	binary.BigEndian.PutUint64(b[:], uint64(len(symbols)))
+	w.Write(b[:])
+	binary.BigEndian.PutUint64(b[:], uint64(pathTable))
+	w.Write(b[:])
+	w.Write([]byte(indexMagic))
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// updateSymbolIndex writes the symbols of base to path, except for those in
+// the files at removedPaths, and adds symbols.
+func updateSymbolIndex(ctx context.Context, path string, base *symbolIndex, removedPaths []string, symbols []protocol.Symbol) (err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "updateSymbolIndex")
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+
+	removed := make(map[string]bool, len(removedPaths))
+	for _, path := range removedPaths {
+		removed[path] = true
+	}
+	all := make([]protocol.Symbol, 0, base.count+len(symbols))
+	if err := base.scan(ctx, 0, func(symbol protocol.Symbol) bool {
+		if !removed[symbol.Path] {
+			all = append(all, symbol)
+		}
+		return true
+	}); err != nil {
+		return err
+	}
+	return writeSymbolIndex(ctx, path, append(all, symbols...))
+}
+
+// encodeSymbol writes symbol to w. Strings are written with their length
+// as a uvarint, and integers as varints.
+func encodeSymbol(w *countingWriter, symbol *protocol.Symbol) {
+	for _, s := range []string{symbol.Name, symbol.Path} {
+		w.writeString(s)
+	}
+	w.writeVarint(int64(symbol.Line))
+	for _, s := range []string{symbol.Kind, symbol.Language, symbol.Parent, symbol.ParentKind, symbol.Signature, symbol.Pattern} {
+		w.writeString(s)
+	}
+	if symbol.FileLimited {
+		w.writeVarint(1)
+	} else {
+		w.writeVarint(0)
+	}
+}
+
+// decodeSymbol reads a symbol written by encodeSymbol from r.
+func decodeSymbol(r *bufio.Reader) (symbol protocol.Symbol, err error) {
+	readString := func() string {
+		if err != nil {
+			return ""
+		}
+		var n uint64
+		if n, err = binary.ReadUvarint(r); err != nil {
+			return ""
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return string(b)
+	}
+	readVarint := func() int64 {
+		if err != nil {
+			return 0
+		}
+		var n int64
+		n, err = binary.ReadVarint(r)
+		return n
+	}
+
+	symbol.Name = readString()
+	symbol.Path = readString()
+	symbol.Line = int(readVarint())
+	symbol.Kind = readString()
+	symbol.Language = readString()
+	symbol.Parent = readString()
+	symbol.ParentKind = readString()
+	symbol.Signature = readString()
+	symbol.Pattern = readString()
+	symbol.FileLimited = readVarint() != 0
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return symbol, err
+}
+
+// countingWriter counts the bytes written to w, and records the first write
+// error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
+
+func (w *countingWriter) writeString(s string) {
+	w.writeUvarint(uint64(len(s)))
+	w.Write([]byte(s))
+}
+
+func (w *countingWriter) writeUvarint(x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutUvarint(b[:], x)])
+}
+
+func (w *countingWriter) writeVarint(x int64) {
+	var b [binary.MaxVarintLen64]byte
+	w.Write(b[:binary.PutVarint(b[:], x)])
 }
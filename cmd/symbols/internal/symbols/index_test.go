@@ -0,0 +1,87 @@
+package symbols
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/diskcache"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+func TestSymbolIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symbols_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	symbols := []protocol.Symbol{
+		{Name: "b", Path: "x.go", Line: 3, Kind: "func", Language: "Go", Parent: "T", ParentKind: "struct", Signature: "()", Pattern: "/^func b()$/", FileLimited: true},
+		{Name: "A", Path: "y.go", Line: 1, Kind: "var"},
+		{Name: "a", Path: "x.go", Line: 3, Kind: "var"},
+		{Name: "c", Path: "x.go", Line: 1, Kind: "const"},
+	}
+	index := writeTestIndex(t, filepath.Join(dir, "symbols.idx"), symbols)
+	defer index.Close()
+
+	var got []protocol.Symbol
+	if err := index.scan(context.Background(), 0, func(symbol protocol.Symbol) bool {
+		got = append(got, symbol)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []protocol.Symbol{symbols[1], symbols[2], symbols[0], symbols[3]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got symbols in name order %+v, want %+v", got, want)
+	}
+
+	if i, err := index.searchName("b"); err != nil || i != 2 {
+		t.Errorf(`got searchName("b") %d (error %v), want 2`, i, err)
+	}
+
+	got, err = index.fileSymbols("x.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []protocol.Symbol{symbols[3], symbols[0], symbols[2]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got file symbols %+v, want %+v", got, want)
+	}
+
+	// Remove x.go and add z.go.
+	updatedPath := filepath.Join(dir, "updated.idx")
+	if err := updateSymbolIndex(context.Background(), updatedPath, index, []string{"x.go"}, []protocol.Symbol{{Name: "d", Path: "z.go"}}); err != nil {
+		t.Fatal(err)
+	}
+	updated := openTestIndex(t, updatedPath)
+	defer updated.Close()
+	if updated.count != 2 {
+		t.Errorf("got %d symbols after update, want 2", updated.count)
+	}
+	if got, err := updated.fileSymbols("x.go"); err != nil || len(got) != 0 {
+		t.Errorf("got symbols %+v (error %v) in removed file", got, err)
+	}
+}
+
+// writeTestIndex writes a symbols index with symbols to path and opens it.
+func writeTestIndex(t *testing.T, path string, symbols []protocol.Symbol) *symbolIndex {
+	if err := writeSymbolIndex(context.Background(), path, symbols); err != nil {
+		t.Fatal(err)
+	}
+	return openTestIndex(t, path)
+}
+
+func openTestIndex(t *testing.T, path string) *symbolIndex {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := openSymbolIndex(&diskcache.File{File: f, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
@@ -0,0 +1,167 @@
+package symbols
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+	"golang.org/x/net/trace"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+func (s *Service) handleOutline(w http.ResponseWriter, r *http.Request) {
+	var args protocol.OutlineArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.outline(r.Context(), args)
+	if err != nil {
+		if err == context.Canceled && r.Context().Err() == context.Canceled {
+			return // client went away
+		}
+		log15.Error("Symbol outline failed", "args", args, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Service) outline(ctx context.Context, args protocol.OutlineArgs) (result *protocol.OutlineResult, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "outline")
+	span.SetTag("repo", args.Repo)
+	span.SetTag("commitID", args.CommitID)
+	span.SetTag("path", args.Path)
+	defer func() {
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+	}()
+
+	tr := trace.New("symbols.outline", fmt.Sprintf("args:%+v", args))
+	defer func() {
+		if err != nil {
+			tr.LazyPrintf("error: %v", err)
+			tr.SetError()
+		}
+		tr.Finish()
+	}()
+
+	index, err := s.symbolIndex(ctx, args.Repo, args.CommitID)
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+
+	symbols, err := index.fileSymbols(args.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &protocol.OutlineResult{Symbols: buildOutline(symbols)}, nil
+}
+
+// buildOutline nests each of symbols (which are in the same file and ordered
+// by line) in its parent, and returns the symbols that have no parent.
+//
+// ctags reports the parent of a symbol as its scope (such as "Outer.Inner"
+// in Python or "ns::Class" in C++), and the kind of the innermost symbol of
+// the scope. A symbol whose parent is not found in symbols is returned at the
+// top level.
+func buildOutline(symbols []protocol.Symbol) []*protocol.OutlineSymbol {
+	var (
+		nodes   = make([]*protocol.OutlineSymbol, len(symbols))
+		parents = make([]int, len(symbols)) // index of the parent in symbols, or -1
+		byScope = map[string][]int{}        // qualified name -> indexes in symbols
+		byName  = map[string][]int{}        // name -> indexes in symbols
+	)
+	// isDescendant reports whether j is i or is nested in i. A symbol must
+	// not be nested in one of its descendants.
+	isDescendant := func(j, i int) bool {
+		for ; j >= 0; j = parents[j] {
+			if j == i {
+				return true
+			}
+		}
+		return false
+	}
+	for i, symbol := range symbols {
+		nodes[i] = &protocol.OutlineSymbol{Symbol: symbol, Children: []*protocol.OutlineSymbol{}}
+		parents[i] = -1
+		qualified := symbol.Name
+		if symbol.Parent != "" {
+			qualified = normalizeScope(symbol.Parent) + "." + symbol.Name
+		}
+		byScope[qualified] = append(byScope[qualified], i)
+		byName[symbol.Name] = append(byName[symbol.Name], i)
+	}
+
+	for i, symbol := range symbols {
+		if symbol.Parent == "" {
+			continue
+		}
+		scope := normalizeScope(symbol.Parent)
+		candidates := byScope[scope]
+		if len(candidates) == 0 {
+			// Some parsers only report the innermost symbol of the scope.
+			candidates = byName[scope[strings.LastIndex(scope, ".")+1:]]
+		}
+
+		// Prefer the nearest preceding candidate of the right kind.
+		kindMatches := func(j int) bool { return symbol.ParentKind == "" || symbols[j].Kind == symbol.ParentKind }
+		precedes := func(j int) bool { return symbols[j].Line <= symbol.Line }
+		best := -1
+		for _, j := range candidates {
+			if isDescendant(j, i) {
+				continue
+			}
+			switch {
+			case best == -1:
+				best = j
+			case kindMatches(j) != kindMatches(best):
+				if kindMatches(j) {
+					best = j
+				}
+			case precedes(j):
+				// Candidates are ordered by line, so a preceding j is
+				// nearer than best. A following j is not.
+				best = j
+			}
+		}
+		parents[i] = best
+	}
+
+	var roots []*protocol.OutlineSymbol
+	for i, node := range nodes {
+		if parents[i] == -1 {
+			roots = append(roots, node)
+			continue
+		}
+		parent := nodes[parents[i]]
+		parent.Children = append(parent.Children, node)
+	}
+	return roots
+}
+
+// normalizeScope returns the scope reported by ctags with its components
+// separated by ".", whatever the separator of the language.
+func normalizeScope(scope string) string {
+	return strings.Replace(scope, "::", ".", -1)
+}
@@ -0,0 +1,110 @@
+package symbols
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+)
+
+func TestBuildOutline(t *testing.T) {
+	tests := map[string]struct {
+		symbols []protocol.Symbol
+		want    string
+	}{
+		"flat": {
+			symbols: []protocol.Symbol{
+				{Name: "a", Line: 1, Kind: "func"},
+				{Name: "b", Line: 2, Kind: "func"},
+			},
+			want: "a b",
+		},
+		"go methods": {
+			symbols: []protocol.Symbol{
+				{Name: "T", Line: 1, Kind: "struct"},
+				{Name: "f", Line: 2, Kind: "field", Parent: "T", ParentKind: "struct"},
+				{Name: "M", Line: 4, Kind: "method", Parent: "T", ParentKind: "struct"},
+				{Name: "F", Line: 8, Kind: "func"},
+			},
+			want: "T(f M) F",
+		},
+		"nested python classes": {
+			symbols: []protocol.Symbol{
+				{Name: "Outer", Line: 1, Kind: "class"},
+				{Name: "Inner", Line: 2, Kind: "class", Parent: "Outer", ParentKind: "class"},
+				{Name: "m", Line: 3, Kind: "member", Parent: "Outer.Inner", ParentKind: "class"},
+				{Name: "n", Line: 5, Kind: "member", Parent: "Outer", ParentKind: "class"},
+			},
+			want: "Outer(Inner(m) n)",
+		},
+		"c++ scope": {
+			symbols: []protocol.Symbol{
+				{Name: "ns", Line: 1, Kind: "namespace"},
+				{Name: "C", Line: 2, Kind: "class", Parent: "ns", ParentKind: "namespace"},
+				{Name: "m", Line: 3, Kind: "function", Parent: "ns::C", ParentKind: "class"},
+			},
+			want: "ns(C(m))",
+		},
+		"innermost scope only": {
+			symbols: []protocol.Symbol{
+				{Name: "C", Line: 1, Kind: "class", Parent: "pkg", ParentKind: "package"},
+				{Name: "m", Line: 2, Kind: "method", Parent: "pkg.Other.C", ParentKind: "class"},
+			},
+			want: "C(m)",
+		},
+		"same name, different kinds": {
+			symbols: []protocol.Symbol{
+				{Name: "A", Line: 1, Kind: "interface"},
+				{Name: "A", Line: 5, Kind: "class"},
+				{Name: "m", Line: 6, Kind: "method", Parent: "A", ParentKind: "class"},
+				{Name: "n", Line: 7, Kind: "method", Parent: "A", ParentKind: "interface"},
+			},
+			want: "A(n) A(m)",
+		},
+		"nearest preceding parent": {
+			symbols: []protocol.Symbol{
+				{Name: "A", Line: 1, Kind: "class"},
+				{Name: "m", Line: 2, Kind: "method", Parent: "A", ParentKind: "class"},
+				{Name: "A", Line: 5, Kind: "class"},
+				{Name: "n", Line: 6, Kind: "method", Parent: "A", ParentKind: "class"},
+			},
+			want: "A(m) A(n)",
+		},
+		"missing parent": {
+			symbols: []protocol.Symbol{
+				{Name: "m", Line: 2, Kind: "method", Parent: "Missing", ParentKind: "class"},
+			},
+			want: "m",
+		},
+		"no cycles": {
+			symbols: []protocol.Symbol{
+				{Name: "a", Line: 1, Kind: "x", Parent: "b"},
+				{Name: "b", Line: 2, Kind: "x", Parent: "a"},
+				{Name: "c", Line: 3, Kind: "x", Parent: "c"},
+			},
+			want: "b(a) c",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := outlineString(buildOutline(test.symbols)); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// outlineString returns a compact representation of an outline, such as
+// "A(b c) D".
+func outlineString(symbols []*protocol.OutlineSymbol) string {
+	var parts []string
+	for _, s := range symbols {
+		if len(s.Children) == 0 {
+			parts = append(parts, s.Name)
+		} else {
+			parts = append(parts, fmt.Sprintf("%s(%s)", s.Name, outlineString(s.Children)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -37,7 +37,9 @@ func (s *Service) startParsers() error {
 	return nil
 }
 
-func (s *Service) parseUncached(ctx context.Context, repo api.RepoURI, commitID api.CommitID) (symbols []protocol.Symbol, err error) {
+// parseUncached parses the symbols of the files of repo at commitID. If paths
+// is nonempty, only the files at those paths are parsed.
+func (s *Service) parseUncached(ctx context.Context, repo api.RepoURI, commitID api.CommitID, paths []string) (symbols []protocol.Symbol, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "parseUncached")
 	defer func() {
 		if err != nil {
@@ -62,7 +64,7 @@ func (s *Service) parseUncached(ctx context.Context, repo api.RepoURI, commitID
 	}()
 
 	tr.LazyPrintf("fetch")
-	parseRequests, errChan, err := s.fetchRepositoryArchive(ctx, repo, commitID)
+	parseRequests, errChan, err := s.fetchRepositoryArchive(ctx, repo, commitID, paths)
 	tr.LazyPrintf("fetch (returned chans)")
 	if err != nil {
 		return nil, err
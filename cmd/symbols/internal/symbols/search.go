@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/sourcegraph/sourcegraph/pkg/pathmatch"
@@ -21,6 +24,13 @@ import (
 // maxFileSize is the limit on file size in bytes. Only files smaller than this are processed.
 const maxFileSize = 1 << 19 // 512KB
 
+// maxRankCandidates is the maximum number of matching symbols that are
+// ranked. The first ones in name order are ranked, so that a query matching
+// most symbols of a large repository doesn't need to read and sort all of
+// them. Since exact name matches are ranked first, this only changes results
+// when fewer of them than the requested number are among the candidates.
+var maxRankCandidates = 10000
+
 func (s *Service) handleSearch(w http.ResponseWriter, r *http.Request) {
 	var args protocol.SearchArgs
 	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
@@ -72,35 +82,26 @@ func (s *Service) search(ctx context.Context, args protocol.SearchArgs) (result
 		tr.Finish()
 	}()
 
-	symbols, err := s.indexedSymbols(ctx, args.Repo, args.CommitID)
+	index, err := s.symbolIndex(ctx, args.Repo, args.CommitID)
 	if err != nil {
 		return nil, err
 	}
+	defer index.Close()
 
 	const maxFirst = 500
 	if args.First < 0 || args.First > maxFirst {
 		args.First = maxFirst
 	}
 
-	result = &protocol.SearchResult{}
-	if args.Query == "" && len(args.IncludePatterns) == 0 && args.ExcludePattern == "" {
-		// No filters were provided, save iterating the symbols and return a slice
-		if args.First != 0 && len(symbols) > args.First {
-			symbols = symbols[:args.First]
-		}
-		result.Symbols = symbols
-	} else {
-		res, err := filterSymbols(ctx, symbols, args)
-		if err != nil {
-			return nil, err
-		}
-		result.Symbols = res
+	symbols, err := filterSymbols(ctx, index, args)
+	if err != nil {
+		return nil, err
 	}
-	return result, nil
+	return &protocol.SearchResult{Symbols: symbols}, nil
 }
 
-func filterSymbols(ctx context.Context, symbols []protocol.Symbol, args protocol.SearchArgs) (res []protocol.Symbol, err error) {
-	span, _ := opentracing.StartSpanFromContext(ctx, "filterSymbols")
+func filterSymbols(ctx context.Context, index *symbolIndex, args protocol.SearchArgs) (res []protocol.Symbol, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "filterSymbols")
 	defer func() {
 		if err != nil {
 			ext.Error.Set(span, true)
@@ -108,16 +109,8 @@ func filterSymbols(ctx context.Context, symbols []protocol.Symbol, args protocol
 		}
 		span.Finish()
 	}()
-	span.SetTag("before", len(symbols))
 
-	query := args.Query
-	if !args.IsRegExp {
-		query = regexp.QuoteMeta(query)
-	}
-	if !args.IsCaseSensitive {
-		query = "(?i:" + query + ")"
-	}
-	queryRegex, err := regexp.Compile(query)
+	name, err := compileNameFilter(args)
 	if err != nil {
 		return nil, err
 	}
@@ -130,16 +123,218 @@ func filterSymbols(ctx context.Context, symbols []protocol.Symbol, args protocol
 		return nil, err
 	}
 
-	for _, symbol := range symbols {
-		if !fileFilter.MatchPath(symbol.Path) || !queryRegex.MatchString(symbol.Name) {
-			continue
+	kinds := make(map[string]bool, len(args.Kinds))
+	for _, kind := range args.Kinds {
+		kinds[strings.ToLower(kind)] = true // ctags kinds are lowercase
+	}
+	languages := make(map[string]bool, len(args.Languages))
+	for _, language := range args.Languages {
+		languages[normalizeLanguage(language)] = true
+	}
+	matches := func(symbol *protocol.Symbol) bool {
+		switch {
+		case name.match != nil && !name.match(symbol.Name),
+			len(kinds) > 0 && !kinds[symbol.Kind],
+			len(languages) > 0 && !languages[normalizeLanguage(symbol.Language)],
+			args.Parent != "" && args.IsCaseSensitive && symbol.Parent != args.Parent,
+			args.Parent != "" && !args.IsCaseSensitive && strings.ToLower(symbol.Parent) != strings.ToLower(args.Parent),
+			!fileFilter.MatchPath(symbol.Path):
+			return false
+		}
+		return true
+	}
+
+	// Symbols are only ranked if there is a query, otherwise the first
+	// matches are returned. At most maxRankCandidates matches are ranked.
+	rank := args.Query != ""
+	limit := args.First
+	if rank {
+		limit = maxRankCandidates
+	}
+
+	start := 0
+	if name.prefix != "" {
+		start, err = index.searchName(name.prefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+	span.LogFields(otlog.String("prefix", name.prefix), otlog.Int("start", start))
+	err = index.scan(ctx, start, func(symbol protocol.Symbol) bool {
+		if name.prefix != "" && !strings.HasPrefix(strings.ToLower(symbol.Name), name.prefix) {
+			return false // past the symbols with the prefix
+		}
+		if !matches(&symbol) {
+			return true
 		}
 		res = append(res, symbol)
-		if args.First > 0 && len(res) == args.First {
-			break
+		return limit <= 0 || len(res) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rank {
+		rankSymbols(res, args, name)
+		if args.First > 0 && len(res) > args.First {
+			res = res[:args.First]
 		}
 	}
 
 	span.SetTag("after", len(res))
 	return res, nil
 }
+
+// normalizeLanguage returns language in lowercase without spaces and dashes,
+// so that the names of languages used by ctags and by other tools (such as
+// "ObjectiveC" and "Objective-C") match.
+func normalizeLanguage(language string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.ToLower(language))
+}
+
+// definitionKindRanks ranks the kinds of symbols which define types and
+// functions above other symbols (such as variables and fields), since they
+// are usually what a symbol search is looking for. Kinds that are not
+// listed have the lowest rank.
+var definitionKindRanks = map[string]int{
+	"class":     0,
+	"interface": 0,
+	"struct":    0,
+	"type":      0,
+	"typedef":   0,
+	"enum":      0,
+	"trait":     0,
+	"module":    0,
+	"namespace": 0,
+	"package":   0,
+	"function":  1,
+	"func":      1,
+	"method":    1,
+}
+
+// rankSymbols sorts the symbols matching args by rank: exact name matches
+// first, then symbols with a definition kind (see definitionKindRanks), then
+// symbols in files closer to the repository root.
+func rankSymbols(symbols []protocol.Symbol, args protocol.SearchArgs, name nameFilter) {
+	exact := func(symbol *protocol.Symbol) bool {
+		if name.literal == "" {
+			return false
+		}
+		if args.IsCaseSensitive {
+			return symbol.Name == name.literal
+		}
+		return strings.ToLower(symbol.Name) == strings.ToLower(name.literal)
+	}
+	kindRank := func(symbol *protocol.Symbol) int {
+		if rank, ok := definitionKindRanks[symbol.Kind]; ok {
+			return rank
+		}
+		return len(definitionKindRanks)
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := &symbols[i], &symbols[j]
+		if ea, eb := exact(a), exact(b); ea != eb {
+			return ea
+		}
+		if ka, kb := kindRank(a), kindRank(b); ka != kb {
+			return ka < kb
+		}
+		// The depth of a path is its number of slashes.
+		if da, db := strings.Count(a.Path, "/"), strings.Count(b.Path, "/"); da != db {
+			return da < db
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+}
+
+// nameFilter is the part of a symbols query that matches symbol names.
+type nameFilter struct {
+	// prefix, if nonempty, is the lowercase prefix of the names of all
+	// matching symbols. They are looked up in the index by prefix.
+	prefix string
+
+	// match, if non-nil, reports whether a symbol name matches.
+	match func(name string) bool
+
+	// literal, if nonempty, is the name of the symbols that exactly match
+	// the query. They are ranked first.
+	literal string
+}
+
+// compileNameFilter returns the filter for the names of the symbols matching
+// args.Query.
+//
+// Regexps that are an exact name or a name prefix (such as ^foo$ or ^foo)
+// are looked up by prefix in the index. Other queries scan every symbol.
+func compileNameFilter(args protocol.SearchArgs) (nameFilter, error) {
+	fold := func(s string) string { return s }
+	if !args.IsCaseSensitive {
+		fold = strings.ToLower
+	}
+	substring := func(literal string) nameFilter {
+		folded := fold(literal)
+		return nameFilter{
+			match:   func(name string) bool { return strings.Contains(fold(name), folded) },
+			literal: literal,
+		}
+	}
+
+	if !args.IsRegExp {
+		if args.Query == "" {
+			return nameFilter{}, nil
+		}
+		return substring(args.Query), nil
+	}
+
+	re, err := syntax.Parse(args.Query, syntax.Perl)
+	if err != nil {
+		return nameFilter{}, err
+	}
+	if re.Op == syntax.OpLiteral && re.Flags&syntax.FoldCase == 0 {
+		return substring(string(re.Rune)), nil
+	}
+	prefix, complete, exact := literalPrefix(re)
+	folded := fold(prefix)
+	switch {
+	case exact:
+		return nameFilter{
+			prefix:  strings.ToLower(prefix),
+			match:   func(name string) bool { return fold(name) == folded },
+			literal: prefix,
+		}, nil
+	case complete:
+		return nameFilter{
+			prefix:  strings.ToLower(prefix),
+			match:   func(name string) bool { return strings.HasPrefix(fold(name), folded) },
+			literal: prefix,
+		}, nil
+	}
+
+	query := args.Query
+	if !args.IsCaseSensitive {
+		query = "(?i:" + query + ")"
+	}
+	nameRegexp, err := regexp.Compile(query)
+	if err != nil {
+		return nameFilter{}, err
+	}
+	return nameFilter{prefix: strings.ToLower(prefix), match: nameRegexp.MatchString, literal: prefix}, nil
+}
+
+// literalPrefix returns the literal that strings matching re must start
+// with, if re is anchored at the start. complete is true if re matches every
+// string with the prefix, and exact is true if it only matches the prefix.
+func literalPrefix(re *syntax.Regexp) (prefix string, complete, exact bool) {
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return "", false, false
+	}
+	lit := re.Sub[1]
+	if lit.Op != syntax.OpLiteral || lit.Flags&syntax.FoldCase != 0 {
+		return "", false, false
+	}
+	rest := re.Sub[2:]
+	return string(lit.Rune), len(rest) == 0, len(rest) == 1 && rest[0].Op == syntax.OpEndText
+}
@@ -3,17 +3,27 @@ package symbols
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
 	"github.com/sourcegraph/sourcegraph/pkg/testutil"
 )
 
 func BenchmarkSearch(b *testing.B) {
 	service := Service{
-		FetchTar: testutil.FetchTarFromGithub,
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			return testutil.FetchTarFromGithub(ctx, repo, commit)
+		},
 		NewParser: func() (ctags.Parser, error) {
 			return ctags.NewParser("universal-ctags")
 		},
@@ -42,3 +52,136 @@ func BenchmarkSearch(b *testing.B) {
 		})
 	}
 }
+
+func TestFilterSymbols(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symbols_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	path := filepath.Join(dir, "symbols.idx")
+	symbols := []protocol.Symbol{
+		{Name: "Foo", Path: "a.go", Kind: "func"},
+		{Name: "FooBar", Path: "b.go", Kind: "func"},
+		{Name: "foo", Path: "b.go", Kind: "var"},
+		{Name: "BarFoo", Path: "c.js", Kind: "func"},
+		{Name: "Baz", Path: "c.js", Kind: "var"},
+	}
+	index := writeTestIndex(t, path, symbols)
+	defer index.Close()
+
+	tests := []struct {
+		args protocol.SearchArgs
+		want []string
+	}{
+		{args: protocol.SearchArgs{}, want: []string{"Foo", "FooBar", "foo", "BarFoo", "Baz"}},
+		{args: protocol.SearchArgs{First: 2}, want: []string{"BarFoo", "Baz"}},
+		{args: protocol.SearchArgs{Query: "foo"}, want: []string{"Foo", "FooBar", "foo", "BarFoo"}},
+		{args: protocol.SearchArgs{Query: "foo", IsCaseSensitive: true}, want: []string{"foo"}},
+		{args: protocol.SearchArgs{Query: "^foo$", IsRegExp: true}, want: []string{"Foo", "foo"}},
+		{args: protocol.SearchArgs{Query: "^Foo$", IsRegExp: true, IsCaseSensitive: true}, want: []string{"Foo"}},
+		{args: protocol.SearchArgs{Query: "^foo", IsRegExp: true}, want: []string{"Foo", "FooBar", "foo"}},
+		{args: protocol.SearchArgs{Query: "^Foo.*r$", IsRegExp: true, IsCaseSensitive: true}, want: []string{"FooBar"}},
+		{args: protocol.SearchArgs{Query: "ba[rz]", IsRegExp: true}, want: []string{"FooBar", "BarFoo", "Baz"}},
+		{args: protocol.SearchArgs{Query: "foo", IncludePatterns: []string{"b.go"}}, want: []string{"FooBar", "foo"}},
+		{args: protocol.SearchArgs{Query: "^", IsRegExp: true, ExcludePattern: "\\.go$"}, want: []string{"BarFoo", "Baz"}},
+	}
+	for _, test := range tests {
+		res, err := filterSymbols(ctx, index, test.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, symbol := range res {
+			got = append(got, symbol.Name)
+		}
+		sort.Strings(got)
+		want := append([]string{}, test.want...)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: got %q, want %q", test.args, got, want)
+		}
+	}
+}
+
+func TestFilterSymbols_filtersAndRanking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symbols_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	path := filepath.Join(dir, "symbols.idx")
+	symbols := []protocol.Symbol{
+		{Name: "configPath", Path: "a/b/c.go", Kind: "variable", Language: "Go"},
+		{Name: "ConfigPath", Path: "a/config.go", Kind: "field", Language: "Go", Parent: "Config", ParentKind: "struct"},
+		{Name: "Config", Path: "a/b/config.go", Kind: "struct", Language: "Go"},
+		{Name: "Config", Path: "config.ts", Kind: "class", Language: "TypeScript"},
+		{Name: "loadConfig", Path: "a/load.go", Kind: "function", Language: "Go"},
+		{Name: "Load", Path: "a/config.go", Kind: "method", Language: "Go", Parent: "Config", ParentKind: "struct"},
+		{Name: "Config", Path: "x.m", Kind: "interface", Language: "ObjectiveC"},
+	}
+	index := writeTestIndex(t, path, symbols)
+	defer index.Close()
+
+	tests := []struct {
+		args protocol.SearchArgs
+		want []string // in order
+	}{
+		{
+			args: protocol.SearchArgs{Query: "config", IsRegExp: true},
+			want: []string{"Config@config.ts", "Config@x.m", "Config@a/b/config.go", "loadConfig@a/load.go", "ConfigPath@a/config.go", "configPath@a/b/c.go"},
+		},
+		{
+			args: protocol.SearchArgs{Query: "config", IsRegExp: true, First: 1, Kinds: []string{"Struct"}},
+			want: []string{"Config@a/b/config.go"},
+		},
+		{
+			args: protocol.SearchArgs{Query: "^config", IsRegExp: true, Languages: []string{"go"}},
+			want: []string{"Config@a/b/config.go", "ConfigPath@a/config.go", "configPath@a/b/c.go"},
+		},
+		{
+			args: protocol.SearchArgs{Query: "config", Languages: []string{"Objective-C", "typescript"}},
+			want: []string{"Config@config.ts", "Config@x.m"},
+		},
+		{
+			args: protocol.SearchArgs{Parent: "config"},
+			want: []string{"ConfigPath@a/config.go", "Load@a/config.go"},
+		},
+		{
+			args: protocol.SearchArgs{Parent: "config", IsCaseSensitive: true},
+		},
+	}
+	for _, test := range tests {
+		res, err := filterSymbols(ctx, index, test.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, symbol := range res {
+			got = append(got, symbol.Name+"@"+symbol.Path)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: got %q, want %q", test.args, got, test.want)
+		}
+	}
+
+	// Only the first candidates in name order are ranked, so loadConfig
+	// is not found.
+	defer func(orig int) { maxRankCandidates = orig }(maxRankCandidates)
+	maxRankCandidates = 5
+	res, err := filterSymbols(ctx, index, protocol.SearchArgs{Query: "config", IsRegExp: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, symbol := range res {
+		got = append(got, symbol.Name+"@"+symbol.Path)
+	}
+	if want := []string{"Config@config.ts", "Config@x.m", "Config@a/b/config.go", "ConfigPath@a/config.go", "configPath@a/b/c.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q with %d rank candidates, want %q", got, maxRankCandidates, want)
+	}
+}
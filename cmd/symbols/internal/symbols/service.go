@@ -12,14 +12,25 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 	"github.com/sourcegraph/sourcegraph/pkg/diskcache"
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 )
 
 // Service is the symbols service.
 type Service struct {
 	// FetchTar returns an io.ReadCloser to a tar archive of a repository at the specified Git
-	// remote URL and commit ID. If the error implements "BadRequest() bool", it will be used to
+	// remote URL and commit ID. If paths is nonempty, the archive only needs to include the files
+	// at those paths. If the error implements "BadRequest() bool", it will be used to
 	// determine if the error is a bad request (eg invalid repo).
-	FetchTar func(context.Context, gitserver.Repo, api.CommitID) (io.ReadCloser, error)
+	FetchTar func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error)
+
+	// ListAncestors returns up to n ancestors of a commit, nearest first. It is optional, see
+	// GitDiff.
+	ListAncestors func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, n int) ([]api.CommitID, error)
+
+	// GitDiff returns the paths of the files that differ between two commits. If it and
+	// ListAncestors are set, the symbols of a commit are computed from the cached symbols of
+	// a nearby ancestor by parsing only the files that changed since.
+	GitDiff func(ctx context.Context, repo gitserver.Repo, base, head api.CommitID) (*git.Changes, error)
 
 	// MaxConcurrentFetchTar is the maximum number of concurrent calls allowed
 	// to FetchTar. It defaults to 15.
@@ -79,6 +90,7 @@ func (s *Service) Handler() http.Handler {
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/outline", s.handleOutline)
 	return mux
 }
 
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/sourcegraph/sourcegraph/cmd/symbols/internal/pkg/ctags"
@@ -16,6 +17,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
 	symbolsclient "github.com/sourcegraph/sourcegraph/pkg/symbols"
 	"github.com/sourcegraph/sourcegraph/pkg/symbols/protocol"
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
 )
 
 func TestService(t *testing.T) {
@@ -27,7 +29,7 @@ func TestService(t *testing.T) {
 
 	files := map[string]string{"a.js": "var x = 1"}
 	service := Service{
-		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID) (io.ReadCloser, error) {
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
 			return createTar(files)
 		},
 		NewParser: func() (ctags.Parser, error) {
@@ -63,6 +65,86 @@ func TestService(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("outline", func(t *testing.T) {
+		result, err := client.Outline(context.Background(), protocol.OutlineArgs{}) // mockParser symbols have no path
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := protocol.OutlineResult{Symbols: []*protocol.OutlineSymbol{{Symbol: protocol.Symbol{Name: "x"}, Children: []*protocol.OutlineSymbol{}}}}
+		if !reflect.DeepEqual(*result, want) {
+			t.Errorf("got %+v, want %+v", *result, want)
+		}
+	})
+}
+
+func TestService_incremental(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { os.RemoveAll(tmpDir) }()
+
+	commits := map[api.CommitID]map[string]string{
+		"c1": {"a.go": "A", "b.go": "B", "c.go": "C"},
+		"c2": {"a.go": "A2", "c.go": "C", "d.go": "D"},
+	}
+	var fetchedPaths [][]string
+	service := Service{
+		FetchTar: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, paths []string) (io.ReadCloser, error) {
+			fetchedPaths = append(fetchedPaths, paths)
+			files := commits[commit]
+			if len(paths) > 0 {
+				files = map[string]string{}
+				for _, p := range paths {
+					files[p] = commits[commit][p]
+				}
+			}
+			return createTar(files)
+		},
+		ListAncestors: func(ctx context.Context, repo gitserver.Repo, commit api.CommitID, n int) ([]api.CommitID, error) {
+			if commit == "c2" {
+				return []api.CommitID{"c1"}, nil
+			}
+			return nil, nil
+		},
+		GitDiff: func(ctx context.Context, repo gitserver.Repo, base, head api.CommitID) (*git.Changes, error) {
+			if base != "c1" || head != "c2" {
+				t.Fatalf("unexpected diff %s..%s", base, head)
+			}
+			return &git.Changes{Added: []string{"d.go"}, Modified: []string{"a.go"}, Deleted: []string{"b.go"}}, nil
+		},
+		NewParser: func() (ctags.Parser, error) {
+			return contentParser{}, nil
+		},
+		Path: tmpDir,
+	}
+	if err := service.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	symbolNames := func(commit api.CommitID) []string {
+		result, err := service.search(context.Background(), protocol.SearchArgs{Repo: "r", CommitID: commit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, symbol := range result.Symbols {
+			names = append(names, symbol.Name+"@"+symbol.Path)
+		}
+		sort.Strings(names)
+		return names
+	}
+	if got, want := symbolNames("c1"), []string{"A@a.go", "B@b.go", "C@c.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("c1: got %q, want %q", got, want)
+	}
+	if got, want := symbolNames("c2"), []string{"A2@a.go", "C@c.go", "D@d.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("c2: got %q, want %q", got, want)
+	}
+	// Only the changed files of c2 are fetched.
+	if want := [][]string{nil, {"d.go", "a.go"}}; !reflect.DeepEqual(fetchedPaths, want) {
+		t.Errorf("got fetched paths %q, want %q", fetchedPaths, want)
+	}
 }
 
 func createTar(files map[string]string) (io.ReadCloser, error) {
@@ -100,3 +182,12 @@ func (m mockParser) Parse(name string, content []byte) ([]ctags.Entry, error) {
 }
 
 func (mockParser) Close() {}
+
+// contentParser returns a symbol for each file, named after its contents.
+type contentParser struct{}
+
+func (contentParser) Parse(name string, content []byte) ([]ctags.Entry, error) {
+	return []ctags.Entry{{Name: string(content), Path: name}}, nil
+}
+
+func (contentParser) Close() {}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/google/zoekt"
+	"github.com/google/zoekt/build"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+// maxBranches is the maximum number of branches of a repository that zoekt
+// can index.
+const maxBranches = 64
+
+// emptyCommit is the commit recorded in the index of an empty repository.
+const emptyCommit = "404aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+type indexOptions struct {
+	// Name is the repository name.
+	Name string
+
+	// Branches are the branches to index, starting with HEAD. If there are
+	// none, an empty index is written.
+	Branches []api.IndexBranch
+
+	// Build are the options for the zoekt index builder.
+	Build build.Options
+
+	// Archive returns a tar archive of the repository at commit.
+	Archive func(commit api.CommitID) (io.ReadCloser, error)
+}
+
+// document is a version of a file that is on one or more branches.
+type document struct {
+	// blobID is the Git blob ID of the file's content.
+	blobID   string
+	branches []string
+
+	// added is whether the document was added to the index.
+	added bool
+}
+
+// index writes the zoekt index of the branches of a repository, unless it is
+// already up to date. A file that is the same on several branches is indexed
+// once, for all of them.
+//
+// The archive of each branch is read twice: first to find out which branches
+// each version of a file is on, and then to add the files to the index. So
+// that we don't hold the contents of every file of every branch in memory,
+// the archives are kept in temporary files in between.
+func index(opts indexOptions) error {
+	branches := opts.Branches
+	if len(branches) > maxBranches {
+		log.Printf("%s: only indexing the first %d of %d branches", opts.Name, maxBranches, len(branches))
+		branches = branches[:maxBranches]
+	}
+
+	bopts := opts.Build
+	bopts.RepositoryDescription.Name = opts.Name
+	bopts.SetDefaults()
+	for _, b := range branches {
+		bopts.RepositoryDescription.Branches = append(bopts.RepositoryDescription.Branches, zoekt.RepositoryBranch{Name: b.Name, Version: string(b.Commit)})
+	}
+	if len(branches) == 0 {
+		bopts.RepositoryDescription.Branches = []zoekt.RepositoryBranch{{Name: "HEAD", Version: emptyCommit}}
+	}
+	if reflect.DeepEqual(bopts.IndexVersions(), bopts.RepositoryDescription.Branches) {
+		return nil // already indexed
+	}
+
+	// Branches at the same commit share an archive.
+	var commits []api.CommitID
+	commitBranches := map[api.CommitID][]string{}
+	for _, b := range branches {
+		if _, ok := commitBranches[b.Commit]; !ok {
+			commits = append(commits, b.Commit)
+		}
+		commitBranches[b.Commit] = append(commitBranches[b.Commit], b.Name)
+	}
+
+	dir, err := ioutil.TempDir("", "zoekt-sourcegraph-indexserver")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	docs := map[string][]*document{} // path -> versions
+	archives := make([]string, len(commits))
+	for i, commit := range commits {
+		archives[i] = filepath.Join(dir, fmt.Sprintf("%d.tar", i))
+		if err := scanArchive(docs, opts, commit, commitBranches[commit], bopts.SizeMax, archives[i]); err != nil {
+			return err
+		}
+	}
+
+	bopts.RepositoryDescription.Source = opts.Name
+	builder, err := build.NewBuilder(bopts)
+	if err != nil {
+		return err
+	}
+	for _, archive := range archives {
+		if err := addArchive(builder, docs, archive, bopts.SizeMax); err != nil {
+			return err
+		}
+	}
+	return builder.Finish()
+}
+
+// scanArchive records the files in the archive of the repository at commit
+// in docs, as being on branches. It writes the archive to path, for
+// addArchive.
+func scanArchive(docs map[string][]*document, opts indexOptions, commit api.CommitID, branches []string, sizeMax int, path string) error {
+	rc, err := opts.Archive(commit)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = readArchive(io.TeeReader(rc, f), sizeMax, func(name string, content []byte) error {
+		id := blobID(content)
+		for _, doc := range docs[name] {
+			if doc.blobID == id {
+				doc.branches = append(doc.branches, branches...)
+				return nil
+			}
+		}
+		docs[name] = append(docs[name], &document{blobID: id, branches: append([]string(nil), branches...)})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("reading archive of %s@%s: %v", opts.Name, commit, err)
+	}
+	return f.Close()
+}
+
+// addArchive adds the files in the archive at path that were not added yet
+// to builder, as being on the branches recorded in docs by scanArchive.
+func addArchive(builder *build.Builder, docs map[string][]*document, path string, sizeMax int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return readArchive(f, sizeMax, func(name string, content []byte) error {
+		id := blobID(content)
+		for _, doc := range docs[name] {
+			if doc.blobID == id {
+				if doc.added {
+					return nil
+				}
+				doc.added = true
+				return builder.Add(zoekt.Document{Name: name, Content: content, Branches: doc.branches})
+			}
+		}
+		return fmt.Errorf("%s was not found when the archive was first read", name)
+	})
+}
+
+// readArchive calls f with the name and content of each file in the tar
+// archive r, except for files larger than sizeMax, which we do not index.
+func readArchive(r io.Reader, sizeMax int, f func(name string, content []byte) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			// Read the rest of r, so that all of it is copied by the
+			// io.TeeReader in scanArchive.
+			_, err := io.Copy(ioutil.Discard, r)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		// We do not index large files
+		if hdr.Typeflag != tar.TypeReg || hdr.Size > int64(sizeMax) {
+			continue
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if err := f(hdr.Name, content); err != nil {
+			return err
+		}
+	}
+}
+
+// blobID returns the Git blob ID of content.
+func blobID(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
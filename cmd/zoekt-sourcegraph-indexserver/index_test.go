@@ -0,0 +1,137 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/zoekt"
+	"github.com/google/zoekt/build"
+	"github.com/google/zoekt/query"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+func tarArchive(t *testing.T, files map[string]string) io.ReadCloser {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return ioutil.NopCloser(&buf)
+}
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "indexserver_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archives := map[api.CommitID]map[string]string{
+		"c1": {"a.go": "needle head", "b.go": "needle everywhere"},
+		"c2": {"a.go": "needle dev", "b.go": "needle everywhere"},
+	}
+	var fetched []api.CommitID
+	opts := indexOptions{
+		Name: "r",
+		Branches: []api.IndexBranch{
+			{Name: "HEAD", Commit: "c1"},
+			{Name: "dev", Commit: "c2"},
+			{Name: "dev2", Commit: "c2"},
+		},
+		Build: build.Options{IndexDir: dir},
+		Archive: func(commit api.CommitID) (io.ReadCloser, error) {
+			fetched = append(fetched, commit)
+			return tarArchive(t, archives[commit]), nil
+		},
+	}
+	if err := index(opts); err != nil {
+		t.Fatal(err)
+	}
+	if want := []api.CommitID{"c1", "c2"}; !reflect.DeepEqual(fetched, want) {
+		t.Errorf("fetched archives of %v, want %v", fetched, want)
+	}
+
+	// An up to date index is not rewritten.
+	fetched = nil
+	if err := index(opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 0 {
+		t.Errorf("fetched archives of %v for an up to date index", fetched)
+	}
+
+	shards, err := filepath.Glob(filepath.Join(dir, "*.zoekt"))
+	if err != nil || len(shards) != 1 {
+		t.Fatalf("got shards %v (error %v), want 1", shards, err)
+	}
+	f, err := os.Open(shards[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexFile, err := zoekt.NewIndexFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	searcher, err := zoekt.NewSearcher(indexFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer searcher.Close()
+
+	// A file that is the same on every branch is indexed once.
+	res, err := searcher.Search(context.Background(), &query.Substring{Pattern: "everywhere"}, &zoekt.SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 1 || !reflect.DeepEqual(res.Files[0].Branches, []string{"HEAD", "dev", "dev2"}) {
+		t.Errorf("got %+v, want b.go on every branch", res.Files)
+	}
+
+	for branch, want := range map[string][]string{
+		"HEAD": {"a.go:HEAD", "b.go:HEAD"},
+		"dev2": {"a.go:dev2", "b.go:dev2"},
+	} {
+		q := query.NewAnd(&query.Substring{Pattern: "needle"}, &query.Branch{Pattern: branch})
+		res, err := searcher.Search(context.Background(), q, &zoekt.SearchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, file := range res.Files {
+			got = append(got, file.FileName+":"+strings.Join(file.Branches, ","))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("branch %s: got %v, want %v", branch, got, want)
+		}
+	}
+}
+
+func TestBlobID(t *testing.T) {
+	// The IDs that git hash-object computes.
+	for content, want := range map[string]string{
+		"":            "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		"hello world": "95d09f2b10159347eece71399a7e2e907ea3df4f",
+	} {
+		if got := blobID([]byte(content)); got != want {
+			t.Errorf("blobID(%q) = %s, want %s", content, got, want)
+		}
+	}
+}
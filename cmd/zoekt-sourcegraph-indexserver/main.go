@@ -0,0 +1,369 @@
+// Command zoekt-sourcegraph-indexserver periodically reindexes the enabled
+// repositories on Sourcegraph with zoekt.
+//
+// It is derived from the command of the same name in zoekt, which only
+// indexes the default branch of each repository. This one also indexes the
+// branches configured in the search.index.branches site configuration
+// property (as returned by the frontend's index-branches endpoint).
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/zoekt"
+	"github.com/google/zoekt/build"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"golang.org/x/net/trace"
+)
+
+// Server is the main functionality of zoekt-sourcegraph-indexserver. It
+// exists to conveniently use all the options passed in via func main.
+type Server struct {
+	// Root is the base URL for the Sourcegraph instance to index. Normally
+	// http://sourcegraph-frontend-internal or http://localhost:3090.
+	Root *url.URL
+
+	// IndexDir is the index directory to use.
+	IndexDir string
+
+	// Interval is how often we sync with Sourcegraph.
+	Interval time.Duration
+
+	// CPUCount is the amount of parallelism to use when indexing a
+	// repository.
+	CPUCount int
+
+	// Debug when true will output extra debug logs.
+	Debug bool
+}
+
+// Run the sync loop. This blocks forever.
+func (s *Server) Run() {
+	queue := &Queue{}
+
+	// Start a goroutine which updates the queue with the branches to index.
+	go func() {
+		t := time.NewTicker(s.Interval)
+		for {
+			repos, err := listRepos(s.Root)
+			if err != nil {
+				log.Println(err)
+				<-t.C
+				continue
+			}
+
+			log.Printf("updating index queue with %d repositories", len(repos))
+
+			// Listing branches is IO bound on the gitserver service. So we
+			// do it concurrently.
+			var wg sync.WaitGroup
+			sem := make(chan struct{}, 32)
+			tr := trace.New("listIndexBranches", "")
+			tr.LazyPrintf("listing branches for %d repos", len(repos))
+			for _, name := range repos {
+				wg.Add(1)
+				sem <- struct{}{}
+				go func(name string) {
+					defer func() {
+						<-sem
+						wg.Done()
+					}()
+					branches, err := listIndexBranches(s.Root, name)
+					if err != nil && !os.IsNotExist(err) {
+						tr.LazyPrintf("failed listing branches for %v: %v", name, err)
+						tr.SetError()
+						return
+					}
+					queue.AddOrUpdate(name, branches)
+				}(name)
+			}
+			wg.Wait()
+			tr.Finish()
+
+			// Remove indexes for repos which no longer exist.
+			exists := make(map[string]bool)
+			for _, name := range repos {
+				exists[name] = true
+			}
+			s.deleteStaleIndexes(exists)
+
+			<-t.C
+		}
+	}()
+
+	// In the current goroutine process the queue forever.
+	for {
+		name, branches, ok := queue.Pop()
+		if !ok {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if err := s.Index(name, branches); err != nil {
+			log.Printf("error indexing %s@%v: %s", name, branches, err)
+			continue
+		}
+		queue.SetIndexed(name, branches)
+	}
+}
+
+// Index indexes the branches of repo name. If there are no branches (because
+// the repository is empty or doesn't exist), it writes an empty index.
+func (s *Server) Index(name string, branches []api.IndexBranch) error {
+	tr := trace.New("index", name)
+	defer tr.Finish()
+
+	tr.LazyPrintf("branches: %v", branches)
+	start := time.Now()
+	err := index(indexOptions{
+		Name:     name,
+		Branches: branches,
+		Build: build.Options{
+			IndexDir:    s.IndexDir,
+			Parallelism: s.CPUCount,
+			SizeMax:     1 << 20, // 1 MB; match https://sourcegraph.sgdev.org/github.com/sourcegraph/sourcegraph/-/blob/cmd/symbols/internal/symbols/search.go#L22
+		},
+		Archive: func(commit api.CommitID) (io.ReadCloser, error) {
+			return fetchTarball(s.Root, name, commit)
+		},
+	})
+	if err != nil {
+		tr.LazyPrintf("failed: %v", err)
+		tr.SetError()
+		return err
+	}
+	tr.LazyPrintf("success")
+	if s.Debug {
+		log.Printf("indexed %s@%v in %s", name, branches, time.Since(start))
+	}
+	return nil
+}
+
+func (s *Server) deleteStaleIndexes(exists map[string]bool) {
+	expr := s.IndexDir + "/*"
+	fs, err := filepath.Glob(expr)
+	if err != nil {
+		log.Printf("Glob(%q): %v", expr, err)
+	}
+
+	for _, f := range fs {
+		if err := deleteIfStale(exists, f); err != nil {
+			log.Printf("deleteIfStale(%q): %v", f, err)
+		}
+	}
+}
+
+var repoTmpl = template.Must(template.New("name").Parse(`
+<html><body>
+<a href="debug/requests">Traces</a><br>
+{{.IndexMsg}}<br />
+<br />
+<h3>Re-index repository</h3>
+<form action="/" method="post">
+{{range .Repos}}
+<input type="submit" name="repo" value="{{ . }}" /> <br />
+{{end}}
+</form>
+</body></html>
+`))
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/debug/requests" {
+		trace.Traces(w, r)
+		return
+	}
+
+	var data struct {
+		Repos    []string
+		IndexMsg string
+	}
+
+	if r.Method == "POST" {
+		r.ParseForm()
+		name := r.Form.Get("repo")
+		index := func() error {
+			branches, err := listIndexBranches(s.Root, name)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return s.Index(name, branches)
+		}
+		if err := index(); err != nil {
+			data.IndexMsg = fmt.Sprintf("Indexing %s failed: %s", name, err)
+		} else {
+			data.IndexMsg = "Indexed " + name
+		}
+	}
+
+	var err error
+	data.Repos, err = listRepos(s.Root)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	repoTmpl.Execute(w, data)
+}
+
+func listRepos(root *url.URL) ([]string, error) {
+	u := root.ResolveReference(&url.URL{Path: "/.internal/repos/list"})
+	resp, err := http.Post(u.String(), "application/json; charset=utf8", bytes.NewReader([]byte(`{"Enabled": true, "Index": true}`)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list repositories: status %s", resp.Status)
+	}
+
+	var data []struct {
+		URI string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	repos := make([]string, len(data))
+	for i, r := range data {
+		repos[i] = r.URI
+	}
+	return repos, nil
+}
+
+// listIndexBranches returns the branches of repo to index, starting with
+// HEAD. It returns an error satisfying os.IsNotExist if the repository is
+// empty or doesn't exist.
+func listIndexBranches(root *url.URL, repo string) ([]api.IndexBranch, error) {
+	u := root.ResolveReference(&url.URL{Path: fmt.Sprintf("/.internal/git/%s/index-branches", repo)})
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, os.ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list branches to index for %s: status %s", repo, resp.Status)
+	}
+
+	var branches []api.IndexBranch
+	if err := json.NewDecoder(resp.Body).Decode(&branches); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+// fetchTarball returns a tar archive of repo at commit.
+func fetchTarball(root *url.URL, repo string, commit api.CommitID) (io.ReadCloser, error) {
+	u := root.ResolveReference(&url.URL{Path: fmt.Sprintf("/.internal/git/%s/tar/%s", repo, commit)})
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch archive of %s@%s: status %s", repo, commit, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// deleteIfStale deletes the shard if its corresponding repo name is not in
+// exists.
+func deleteIfStale(exists map[string]bool, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	ifile, err := zoekt.NewIndexFile(f)
+	if err != nil {
+		return nil
+	}
+	defer ifile.Close()
+
+	repo, _, err := zoekt.ReadMetadata(ifile)
+	if err != nil {
+		return nil
+	}
+
+	if !exists[repo.Name] {
+		log.Printf("%s no longer exists, deleting %s", repo.Name, fn)
+		return os.Remove(fn)
+	}
+
+	return nil
+}
+
+func main() {
+	root := flag.String("sourcegraph_url", "", "http://sourcegraph-frontend-internal or http://localhost:3090")
+	interval := flag.Duration("interval", 10*time.Minute, "sync with sourcegraph this often")
+	index := flag.String("index", build.DefaultDir, "set index directory to use")
+	listen := flag.String("listen", "", "listen on this address.")
+	cpuFraction := flag.Float64("cpu_fraction", 0.25,
+		"use this fraction of the cores for indexing.")
+	debug := flag.Bool("debug", false,
+		"turn on more verbose logging.")
+	flag.Parse()
+
+	if *cpuFraction <= 0.0 || *cpuFraction > 1.0 {
+		log.Fatal("cpu_fraction must be between 0.0 and 1.0")
+	}
+	if *index == "" {
+		log.Fatal("must set -index")
+	}
+	if *root == "" {
+		log.Fatal("must set -sourcegraph_url")
+	}
+	rootURL, err := url.Parse(*root)
+	if err != nil {
+		log.Fatalf("url.Parse(%v): %v", *root, err)
+	}
+
+	if _, err := os.Stat(*index); err != nil {
+		if err := os.MkdirAll(*index, 0755); err != nil {
+			log.Fatalf("MkdirAll %s: %v", *index, err)
+		}
+	}
+
+	cpuCount := int(math.Round(float64(runtime.NumCPU()) * (*cpuFraction)))
+	if cpuCount < 1 {
+		cpuCount = 1
+	}
+	s := &Server{
+		Root:     rootURL,
+		IndexDir: *index,
+		Interval: *interval,
+		CPUCount: cpuCount,
+		Debug:    *debug,
+	}
+
+	if *listen != "" {
+		go func() {
+			trace.AuthRequest = func(req *http.Request) (any, sensitive bool) {
+				return true, true
+			}
+			log.Printf("serving HTTP on %s", *listen)
+			log.Fatal(http.ListenAndServe(*listen, s))
+		}()
+	}
+
+	s.Run()
+}
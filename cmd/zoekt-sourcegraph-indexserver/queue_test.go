@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+)
+
+func branchesAt(commit int) []api.IndexBranch {
+	return []api.IndexBranch{{Name: "HEAD", Commit: api.CommitID(strconv.Itoa(commit))}}
+}
+
+func TestQueue(t *testing.T) {
+	queue := &Queue{}
+
+	for i := 0; i < 100; i++ {
+		queue.AddOrUpdate(fmt.Sprintf("item-%d", i), branchesAt(i))
+	}
+
+	// Odd numbers are already at the same commit
+	for i := 1; i < 100; i += 2 {
+		queue.SetIndexed(fmt.Sprintf("item-%d", i), branchesAt(i))
+	}
+
+	// Ensure we process all the even commits first, then odd.
+	want := 0
+	for {
+		name, branches, ok := queue.Pop()
+		if !ok {
+			break
+		}
+		got, _ := strconv.Atoi(string(branches[0].Commit))
+		if got != want {
+			t.Fatalf("got %v %v, want %v", name, branches, want)
+		}
+		want += 2
+		if want == 100 {
+			// We now switch to processing the odd numbers
+			want = 1
+		}
+		// update current, shouldn't put the job in the queue
+		queue.SetIndexed(name, branches)
+	}
+	if want != 101 {
+		t.Fatalf("only popped %d items", want)
+	}
+}
+
+func TestQueue_branchAdded(t *testing.T) {
+	queue := &Queue{}
+	queue.AddOrUpdate("a", branchesAt(1))
+	queue.AddOrUpdate("b", branchesAt(1))
+	queue.SetIndexed("a", branchesAt(1))
+	queue.SetIndexed("b", branchesAt(1))
+
+	// A configured branch makes b stale, so it is indexed first.
+	queue.AddOrUpdate("b", append(branchesAt(1), api.IndexBranch{Name: "dev", Commit: "2"}))
+	if name, _, _ := queue.Pop(); name != "b" {
+		t.Errorf("got %q, want b", name)
+	}
+}
+
+func TestQueueFIFO(t *testing.T) {
+	// Tests that the queue fallbacks to FIFO if everything has the same
+	// priority
+	queue := &Queue{}
+
+	for i := 0; i < 100; i++ {
+		queue.AddOrUpdate(fmt.Sprintf("item-%d", i), branchesAt(i))
+	}
+
+	want := 0
+	for {
+		name, branches, ok := queue.Pop()
+		if !ok {
+			break
+		}
+		got, _ := strconv.Atoi(string(branches[0].Commit))
+		if got != want {
+			t.Fatalf("got %v %v, want %v", name, branches, want)
+		}
+		queue.SetIndexed(name, branches)
+		want++
+	}
+	if want != 100 {
+		t.Fatalf("only popped %d items", want)
+	}
+}
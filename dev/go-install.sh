@@ -64,8 +64,7 @@ export GO111MODULE=on
 
 if ! go install \
 	github.com/mattn/goreman \
-	github.com/google/zoekt/cmd/zoekt-archive-index \
-	github.com/google/zoekt/cmd/zoekt-sourcegraph-indexserver \
+	github.com/sourcegraph/sourcegraph/cmd/zoekt-sourcegraph-indexserver \
 	github.com/google/zoekt/cmd/zoekt-webserver; then
 	echo >&2 "failed to install prerequisites, aborting."
 	exit 1
@@ -393,7 +393,7 @@ func (g *globalDeps) Dependencies(ctx context.Context, op db.DependenciesOptions
 	}
 
 	selectSQL := `SELECT gd.language, dep_data, repo_id, hints`
-	fromSQL := `FROM global_dep AS gd INNER JOIN repo AS r ON gd.repo_id=r.id`
+	fromSQL := `FROM global_dep AS gd INNER JOIN repo AS r ON gd.repo_id=r.id AND r.deleted_at IS NULL`
 	whereSQL := ""
 	if len(whereConds) > 0 {
 		whereSQL = `WHERE ` + strings.Join(whereConds, " AND ")
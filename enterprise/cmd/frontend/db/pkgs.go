@@ -162,7 +162,7 @@ func (p *pkgs) ListPackages(ctx context.Context, op *api.ListPackagesOp) (pks []
 	whereSQL := "(" + strings.Join(whereClauses, ") AND (") + ")"
 	sql := `
 		SELECT pkgs.*
-		FROM pkgs INNER JOIN repo ON pkgs.repo_id=repo.id
+		FROM pkgs INNER JOIN repo ON pkgs.repo_id=repo.id AND repo.deleted_at IS NULL
 		WHERE ` + whereSQL + `
 		ORDER BY repo.created_at ASC NULLS LAST, pkgs.repo_id ASC
 		LIMIT ` + arg(op.Limit)
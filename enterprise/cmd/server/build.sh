@@ -23,7 +23,6 @@ GO111MODULE=on ./.bin/godockerize build --base 'alpine:3.8' -t ${IMAGE} --go-bui
     github.com/sourcegraph/sourcegraph/cmd/repo-updater \
     github.com/sourcegraph/sourcegraph/cmd/searcher \
     github.com/sourcegraph/sourcegraph/cmd/indexer \
-    github.com/google/zoekt/cmd/zoekt-archive-index \
-    github.com/google/zoekt/cmd/zoekt-sourcegraph-indexserver \
+    github.com/sourcegraph/sourcegraph/cmd/zoekt-sourcegraph-indexserver \
     github.com/google/zoekt/cmd/zoekt-webserver \
     github.com/sourcegraph/sourcegraph/cmd/lsp-proxy $additional_images
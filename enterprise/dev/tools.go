@@ -3,8 +3,6 @@
 package main
 
 import (
-	_ "github.com/google/zoekt/cmd/zoekt-archive-index"
-	_ "github.com/google/zoekt/cmd/zoekt-sourcegraph-indexserver"
 	_ "github.com/google/zoekt/cmd/zoekt-webserver"
 	_ "github.com/kevinburke/go-bindata/go-bindata"
 	_ "github.com/mattn/goreman"
@@ -0,0 +1,8 @@
+CREATE TABLE repo_update_schedule (
+    repo_id integer PRIMARY KEY REFERENCES repo(id) ON DELETE CASCADE,
+    due timestamp with time zone NOT NULL,
+    update_interval_ns bigint NOT NULL,
+    last_update_duration_ns bigint NOT NULL,
+    last_changed timestamp with time zone,
+    updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+DROP INDEX repo_external_service;
+ALTER TABLE repo DROP COLUMN deleted_at;
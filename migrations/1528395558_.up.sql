@@ -0,0 +1,2 @@
+ALTER TABLE repo ADD COLUMN deleted_at timestamp with time zone;
+CREATE INDEX repo_external_service ON repo(external_service_type, external_service_id) WHERE deleted_at IS NULL;
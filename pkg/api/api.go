@@ -100,6 +100,25 @@ func (r *ExternalRepoSpec) String() string {
 	return fmt.Sprintf("ExternalRepoSpec{%s %s %s}", r.ServiceID, r.ServiceType, r.ID)
 }
 
+// RepoUpdateSchedule is the repo-updater scheduler's state for a repository,
+// which is saved so that it survives restarts of repo-updater.
+type RepoUpdateSchedule struct {
+	// Repo is the repository's URI.
+	Repo RepoURI
+
+	// Due is the next time the repository should be updated.
+	Due time.Time
+
+	// UpdateInterval is how often the repository is updated.
+	UpdateInterval time.Duration
+
+	// LastUpdateDuration is how long the last update of the repository took.
+	LastUpdateDuration time.Duration
+
+	// LastChanged is when the repository last changed, if known.
+	LastChanged *time.Time
+}
+
 type DependencyReferences struct {
 	References []*DependencyReference
 	Location   lspext.SymbolLocationInformation
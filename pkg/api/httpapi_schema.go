@@ -52,6 +52,15 @@ type RepoUpdateIndexRequest struct {
 	Language string `json:"language"`
 }
 
+// IndexBranch is a branch of a repository that the search indexer should
+// index.
+type IndexBranch struct {
+	// Name is the branch name (such as "release/1.0"), or "HEAD" for the
+	// default branch.
+	Name   string   `json:"name"`
+	Commit CommitID `json:"commit"`
+}
+
 type RepoUnindexedDependenciesRequest struct {
 	RepoID   `json:"repoID"`
 	Language string `json:"language"`
@@ -79,3 +88,39 @@ type PhabricatorRepoCreateRequest struct {
 	Callsign string `json:"callsign"`
 	URL      string `json:"url"`
 }
+
+// ReposListByExternalServiceRequest is a request to list the repositories of an external service, that is, the
+// repositories whose ExternalRepo has the given ServiceType and ServiceID.
+type ReposListByExternalServiceRequest struct {
+	ServiceType string `json:"serviceType"`
+	ServiceID   string `json:"serviceID"`
+}
+
+// ReposSyncRequest is a request to update the stored repositories of an external service to match the
+// repositories on it, in a single transaction.
+type ReposSyncRequest struct {
+	ServiceType string `json:"serviceType"`
+	ServiceID   string `json:"serviceID"`
+
+	// Repos are all repositories on the external service. Each must have an ExternalRepo of the external
+	// service, by which it is matched to a stored repository. Matched repositories are renamed to RepoURI if it
+	// differs, and their metadata is updated. Other repositories are created.
+	Repos []RepoCreateOrUpdateRequest `json:"repos"`
+
+	// Deleted are the IDs of stored repositories of the external service that no longer exist on it.
+	Deleted []RepoID `json:"deleted"`
+}
+
+// ReposSyncResponse is the result of a ReposSyncRequest.
+type ReposSyncResponse struct {
+	// Repos are the stored repositories after the sync, in the order of the request's Repos. An entry is nil if
+	// the repository could not be stored because another external service's repository has its URI.
+	Repos []*Repo `json:"repos"`
+
+	// Created, Updated, Renamed and Deleted are the number of repositories that were created, had their
+	// metadata updated, were renamed and were deleted.
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Renamed int `json:"renamed"`
+	Deleted int `json:"deleted"`
+}
@@ -271,6 +271,41 @@ func (c *internalClient) ReposListEnabled(ctx context.Context) ([]RepoURI, error
 	return names, err
 }
 
+// ReposListByExternalService returns the repositories of the given external service, that is, the repositories
+// whose ExternalRepo has the given service type and ID.
+func (c *internalClient) ReposListByExternalService(ctx context.Context, serviceType, serviceID string) ([]*Repo, error) {
+	var repos []*Repo
+	err := c.postInternal(ctx, "repos/list-external-service", ReposListByExternalServiceRequest{
+		ServiceType: serviceType,
+		ServiceID:   serviceID,
+	}, &repos)
+	return repos, err
+}
+
+// ReposSync updates the stored repositories of an external service to match the repositories on it, in a single
+// transaction. See ReposSyncRequest.
+func (c *internalClient) ReposSync(ctx context.Context, req ReposSyncRequest) (*ReposSyncResponse, error) {
+	var resp ReposSyncResponse
+	if err := c.postInternal(ctx, "repos/sync", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ReposListUpdateSchedules returns the saved repo-updater scheduler state of
+// all repositories.
+func (c *internalClient) ReposListUpdateSchedules(ctx context.Context) ([]*RepoUpdateSchedule, error) {
+	var schedules []*RepoUpdateSchedule
+	err := c.postInternal(ctx, "repos/list-update-schedules", nil, &schedules)
+	return schedules, err
+}
+
+// ReposSaveUpdateSchedules saves the repo-updater scheduler state of the given
+// repositories. Repositories that don't exist are ignored.
+func (c *internalClient) ReposSaveUpdateSchedules(ctx context.Context, schedules []*RepoUpdateSchedule) error {
+	return c.postInternal(ctx, "repos/save-update-schedules", schedules, nil)
+}
+
 func (c *internalClient) ReposUpdateMetadata(ctx context.Context, uri RepoURI, description string, fork bool, archived bool) error {
 	return c.postInternal(ctx, "repos/update-metadata", ReposUpdateMetadataRequest{
 		RepoURI:     uri,
@@ -95,8 +95,8 @@ func CanReadEmail() bool {
 	return Get().EmailImap != nil
 }
 
-// HasGitHubDotComToken reports whether there are any personal access tokens configured for
-// github.com.
+// HasGitHubDotComToken reports whether there are any personal access tokens (or GitHub Apps, which
+// provide installation access tokens) configured for github.com.
 func HasGitHubDotComToken() bool {
 	for _, c := range Get().Github {
 		u, err := url.Parse(c.Url)
@@ -104,7 +104,7 @@ func HasGitHubDotComToken() bool {
 			continue
 		}
 		hostname := strings.ToLower(u.Hostname())
-		if (hostname == "github.com" || hostname == "api.github.com") && c.Token != "" {
+		if (hostname == "github.com" || hostname == "api.github.com") && (c.Token != "" || c.GithubApp != nil) {
 			return true
 		}
 	}
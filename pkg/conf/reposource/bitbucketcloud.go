@@ -0,0 +1,41 @@
+package reposource
+
+import (
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+type BitbucketCloud struct {
+	*schema.BitbucketCloudConnection
+}
+
+var _ repoSource = BitbucketCloud{}
+
+func (c BitbucketCloud) cloneURLToRepoURI(cloneURL string) (repoURI api.RepoURI, err error) {
+	parsedCloneURL, baseURL, match, err := parseURLs(cloneURL, c.Url)
+	if err != nil {
+		return "", err
+	}
+	if !match {
+		return "", nil
+	}
+
+	nameWithOwner := strings.TrimPrefix(strings.TrimSuffix(parsedCloneURL.Path, ".git"), "/")
+	if strings.Count(nameWithOwner, "/") != 1 { // Not a Bitbucket Cloud clone URL
+		return "", nil
+	}
+	return BitbucketCloudRepoURI(c.RepositoryPathPattern, baseURL.Hostname(), nameWithOwner), nil
+}
+
+func BitbucketCloudRepoURI(repositoryPathPattern, host, nameWithOwner string) api.RepoURI {
+	if repositoryPathPattern == "" {
+		repositoryPathPattern = "{host}/{nameWithOwner}"
+	}
+
+	return api.RepoURI(strings.NewReplacer(
+		"{host}", host,
+		"{nameWithOwner}", nameWithOwner,
+	).Replace(repositoryPathPattern))
+}
@@ -0,0 +1,50 @@
+package reposource
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+func TestBitbucketCloud_cloneURLToRepoURI(t *testing.T) {
+	var tests = []struct {
+		conn schema.BitbucketCloudConnection
+		urls []urlURI
+	}{{
+		conn: schema.BitbucketCloudConnection{
+			Url: "https://bitbucket.org",
+		},
+		urls: []urlURI{
+			{"https://user@bitbucket.org/myteam/myrepo.git", "bitbucket.org/myteam/myrepo"},
+			{"git@bitbucket.org:myteam/myrepo.git", "bitbucket.org/myteam/myrepo"},
+			{"ssh://git@bitbucket.org/myteam/myrepo.git", "bitbucket.org/myteam/myrepo"},
+
+			{"https://bitbucket.org/myteam", ""},
+			{"https://user@asdf.org/myteam/myrepo.git", ""},
+			{"git@asdf.org:myteam/myrepo.git", ""},
+		},
+	}, {
+		conn: schema.BitbucketCloudConnection{
+			Url:                   "https://bitbucket.org",
+			RepositoryPathPattern: "bb/{nameWithOwner}",
+		},
+		urls: []urlURI{
+			{"https://user@bitbucket.org/myteam/myrepo.git", "bb/myteam/myrepo"},
+			{"git@bitbucket.org:myteam/myrepo.git", "bb/myteam/myrepo"},
+
+			{"git@asdf.org:myteam/myrepo.git", ""},
+		},
+	}}
+
+	for _, test := range tests {
+		for _, u := range test.urls {
+			repoURI, err := BitbucketCloud{&test.conn}.cloneURLToRepoURI(u.cloneURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.repoURI != string(repoURI) {
+				t.Errorf("expected %q but got %q for clone URL %q (connection: %+v)", u.repoURI, repoURI, u.cloneURL, test.conn)
+			}
+		}
+	}
+}
@@ -37,7 +37,9 @@ func CloneURLToRepoURI(cloneURL string) (repoURI api.RepoURI, err error) {
 
 	repoSources := make([]repoSource, 0, len(cfg.Github)+
 		len(cfg.Gitlab)+
+		len(cfg.Gitea)+
 		len(cfg.BitbucketServer)+
+		len(cfg.BitbucketCloud)+
 		len(cfg.AwsCodeCommit)+
 		1+ /* for repos.list */
 		len(cfg.Gitolite))
@@ -48,9 +50,15 @@ func CloneURLToRepoURI(cloneURL string) (repoURI api.RepoURI, err error) {
 	for _, c := range cfg.Gitlab {
 		repoSources = append(repoSources, GitLab{c})
 	}
+	for _, c := range cfg.Gitea {
+		repoSources = append(repoSources, Gitea{c})
+	}
 	for _, c := range cfg.BitbucketServer {
 		repoSources = append(repoSources, BitbucketServer{c})
 	}
+	for _, c := range cfg.BitbucketCloud {
+		repoSources = append(repoSources, BitbucketCloud{c})
+	}
 	for _, c := range cfg.AwsCodeCommit {
 		repoSources = append(repoSources, AWS{c})
 	}
@@ -0,0 +1,46 @@
+package reposource
+
+import (
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/schema"
+)
+
+type Gitea struct {
+	*schema.GiteaConnection
+}
+
+var _ repoSource = Gitea{}
+
+func (c Gitea) cloneURLToRepoURI(cloneURL string) (repoURI api.RepoURI, err error) {
+	parsedCloneURL, baseURL, match, err := parseURLs(cloneURL, c.Url)
+	if err != nil {
+		return "", err
+	}
+	if !match {
+		return "", nil
+	}
+
+	// Gitea can be served under a path (such as https://example.com/gitea/), which HTTP clone URLs
+	// include.
+	nameWithOwner := strings.TrimPrefix(strings.TrimSuffix(parsedCloneURL.Path, ".git"), "/")
+	if strings.HasPrefix(parsedCloneURL.Scheme, "http") {
+		nameWithOwner = strings.TrimPrefix("/"+nameWithOwner, baseURL.Path)
+	}
+	if strings.Count(nameWithOwner, "/") != 1 { // Not a Gitea clone URL
+		return "", nil
+	}
+	return GiteaRepoURI(c.RepositoryPathPattern, baseURL.Hostname(), nameWithOwner), nil
+}
+
+func GiteaRepoURI(repositoryPathPattern, host, nameWithOwner string) api.RepoURI {
+	if repositoryPathPattern == "" {
+		repositoryPathPattern = "{host}/{nameWithOwner}"
+	}
+
+	return api.RepoURI(strings.NewReplacer(
+		"{host}", host,
+		"{nameWithOwner}", nameWithOwner,
+	).Replace(repositoryPathPattern))
+}
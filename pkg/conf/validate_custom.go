@@ -66,6 +66,14 @@ func validateCustom(cfg schema.SiteConfiguration) (problems []string) {
 		}
 	}
 
+	for _, c := range cfg.Github {
+		if c.Token != "" && c.GithubApp != nil {
+			invalid("for GitHub, specify either a token or a githubApp, not both")
+		} else if c.Token == "" && c.GithubApp == nil {
+			invalid("for GitHub, you must specify either a token or a githubApp to authenticate")
+		}
+	}
+
 	for _, c := range cfg.Gitlab {
 		if strings.Contains(c.Url, "example.com") {
 			invalid(fmt.Sprintf(`invalid GitLab URL detected: %s (did you forget to remove "example.com"?)`, c.Url))
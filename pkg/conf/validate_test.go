@@ -61,6 +61,22 @@ func TestValidateCustom(t *testing.T) {
 		"bitbucketserver token": {
 			raw: `{"bitbucketServer":[{"token":"t"}]}`,
 		},
+
+		// token and githubApp are disjointly required
+		"github no auth": {
+			raw:         `{"github":[{"url":"https://github.com"}]}`,
+			wantProblem: "specify either a token or a githubApp",
+		},
+		"github token and githubApp": {
+			raw:         `{"github":[{"url":"https://github.com","token":"t","githubApp":{"appID":1,"privateKey":"k"}}]}`,
+			wantProblem: "specify either a token or a githubApp",
+		},
+		"github token": {
+			raw: `{"github":[{"url":"https://github.com","token":"t"}]}`,
+		},
+		"github githubApp": {
+			raw: `{"github":[{"url":"https://github.com","githubApp":{"appID":1,"privateKey":"k"}}]}`,
+		},
 	}
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
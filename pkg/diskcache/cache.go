@@ -35,6 +35,12 @@ type Store struct {
 	// BeforeEvict, when non-nil, is a function to call before evicting a file.
 	// It is passed the path to the file to be evicted.
 	BeforeEvict func(string)
+
+	// SidecarSuffixes are the suffixes of files that are stored next to
+	// cache items, such as indexes derived from them. The sidecar files of
+	// an item are at its path plus one of the suffixes. Evict counts their
+	// size towards the item's and removes them along with it.
+	SidecarSuffixes []string
 }
 
 // File is an os.File, but includes the Path
@@ -49,9 +55,31 @@ type File struct {
 // cache.
 type Fetcher func(context.Context) (io.ReadCloser, error)
 
+// FetcherWithPath writes a cache entry to the given file. It is used by
+// OpenWithPath if the key is not in the cache.
+type FetcherWithPath func(context.Context, string) error
+
 // Open will open a file from the local cache with key. If missing, fetcher
 // will fill the cache first. Open also performs single-flighting for fetcher.
 func (s *Store) Open(ctx context.Context, key string, fetcher Fetcher) (file *File, err error) {
+	return s.OpenWithPath(ctx, key, func(ctx context.Context, path string) error {
+		r, err := fetcher(ctx)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			r.Close()
+			return errors.Wrap(err, "failed to create temporary archive cache item")
+		}
+		return copyAndClose(f, r)
+	})
+}
+
+// OpenWithPath is like Open, but fetcher writes the cache entry to a file
+// itself. This is useful for entries which are not written sequentially,
+// such as databases.
+func (s *Store) OpenWithPath(ctx context.Context, key string, fetcher FetcherWithPath) (file *File, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Cached Fetch")
 	if s.Component != "" {
 		ext.Component.Set(span, s.Component)
@@ -73,10 +101,7 @@ func (s *Store) Open(ctx context.Context, key string, fetcher Fetcher) (file *Fi
 		return nil, errors.New("diskcache.Store.Dir must be set")
 	}
 
-	// path uses a sha256 hash of the key since we want to use it for the
-	// disk name.
-	h := sha256.Sum256([]byte(key))
-	path := filepath.Join(s.Dir, hex.EncodeToString(h[:])) + ".zip"
+	path := s.path(key)
 	span.LogKV("key", key, "path", path)
 
 	// First do a fast-path, assume already on disk
@@ -116,7 +141,29 @@ func (s *Store) Open(ctx context.Context, key string, fetcher Fetcher) (file *Fi
 	}
 }
 
-func doFetch(ctx context.Context, path string, fetcher Fetcher) (file *File, err error) {
+// OpenCached opens the file with key if it is in the cache. Unlike Open, it
+// never fetches it. If it is not cached, the error satisfies os.IsNotExist.
+func (s *Store) OpenCached(key string) (*File, error) {
+	if s.Dir == "" {
+		return nil, errors.New("diskcache.Store.Dir must be set")
+	}
+	path := s.path(key)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	touch(path)
+	return &File{File: f, Path: path}, nil
+}
+
+// path returns the path of the file with key. It uses a sha256 hash of the
+// key since we want to use it for the disk name.
+func (s *Store) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(h[:])) + ".zip"
+}
+
+func doFetch(ctx context.Context, path string, fetcher FetcherWithPath) (file *File, err error) {
 	// We have to grab the lock for this key, so we can fetch or wait for
 	// someone else to finish fetching.
 	urlMu := urlMu(path)
@@ -145,23 +192,16 @@ func doFetch(ctx context.Context, path string, fetcher Fetcher) (file *File, err
 	// We write to a temporary path to prevent another Open finding a
 	// partially written file.
 	tmpPath := path + ".part"
-	f, err = os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create temporary archive cache item")
-	}
+	// Remove what an interrupted fetch may have left behind, since fetcher
+	// may not truncate it.
+	_ = os.Remove(tmpPath)
 	defer os.Remove(tmpPath)
 
 	// We are now ready to actually fetch the file. Write it to the
 	// partial file and cleanup.
-	r, err := fetcher(ctx)
-	if err != nil {
-		f.Close()
+	if err := fetcher(ctx, tmpPath); err != nil {
 		return nil, errors.Wrap(err, "failed to fetch missing archive cache item")
 	}
-	err = copyAndClose(f, r)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to copy and close missing archive cache item")
-	}
 
 	// Put the partially written file in the correct place and open
 	err = os.Rename(tmpPath, path)
@@ -202,13 +242,25 @@ func (s *Store) Evict(maxCacheSizeBytes int64) (stats EvictStats, err error) {
 		return stats, errors.Wrapf(err, "failed to ReadDir %s", s.Dir)
 	}
 
-	// Sum up the total size of all zips
-	var size int64
+	// Sum up the total size of all zips and their sidecar files
+	itemSize := map[string]int64{} // zip name -> size including sidecars
 	for _, fi := range list {
 		if isZip(fi) {
-			size += fi.Size()
+			itemSize[fi.Name()] += fi.Size()
+		}
+	}
+	for _, fi := range list {
+		for _, suffix := range s.SidecarSuffixes {
+			name := strings.TrimSuffix(fi.Name(), suffix)
+			if _, ok := itemSize[name]; ok && name != fi.Name() {
+				itemSize[name] += fi.Size()
+			}
 		}
 	}
+	var size int64
+	for _, n := range itemSize {
+		size += n
+	}
 	stats.CacheSize = size
 
 	// Nothing to evict
@@ -237,8 +289,13 @@ func (s *Store) Evict(maxCacheSizeBytes int64) (stats EvictStats, err error) {
 			log.Printf("failed to remove %s: %s", path, err)
 			continue
 		}
+		for _, suffix := range s.SidecarSuffixes {
+			if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+				log.Printf("failed to remove %s: %s", path+suffix, err)
+			}
+		}
 		stats.Evicted++
-		size -= fi.Size()
+		size -= itemSize[fi.Name()]
 	}
 
 	return stats, nil
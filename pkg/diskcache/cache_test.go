@@ -6,7 +6,10 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestOpen(t *testing.T) {
@@ -61,3 +64,104 @@ func TestOpen(t *testing.T) {
 		t.Fatal("Item was not properly evicted")
 	}
 }
+
+func TestOpenWithPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskcache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &Store{
+		Dir:       dir,
+		Component: "test",
+	}
+
+	want := "foobar"
+	calls := 0
+	for i := 0; i < 2; i++ {
+		f, err := store.OpenWithPath(context.Background(), "key", func(ctx context.Context, path string) error {
+			calls++
+			return ioutil.WriteFile(path, []byte(want), 0600)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(f.File)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("did not return fetcher output. got %q, want %q", string(got), want)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected fetcher to be called once, got %d calls", calls)
+	}
+
+	f, err := store.OpenCached("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := store.OpenCached("missing"); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error for a missing key, got %v", err)
+	}
+}
+
+func TestEvictSidecars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskcache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var evicted []string
+	store := &Store{
+		Dir:             dir,
+		Component:       "test",
+		BeforeEvict:     func(path string) { evicted = append(evicted, filepath.Base(path)) },
+		SidecarSuffixes: []string{".idx"},
+	}
+
+	// old.zip is small, but its sidecar makes the cache too large.
+	now := time.Now()
+	for i, file := range []struct {
+		name string
+		size int
+	}{
+		{"old.zip", 10},
+		{"old.zip.idx", 100},
+		{"new.zip", 10},
+		{"new.zip.idx", 10},
+	} {
+		path := filepath.Join(dir, file.name)
+		if err := ioutil.WriteFile(path, make([]byte, file.size), 0600); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := store.Evict(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CacheSize != 130 || stats.Evicted != 1 {
+		t.Errorf("got %+v, want a cache size of 130 and 1 eviction", stats)
+	}
+	if want := []string{"old.zip"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("got evicted %v, want %v", evicted, want)
+	}
+	for _, name := range []string{"old.zip", "old.zip.idx"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.zip.idx")); err != nil {
+		t.Errorf("new.zip.idx was removed: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+// Package githubapp authenticates to the GitHub API as a GitHub App and its installations.
+//
+// See https://developer.github.com/apps/building-github-apps/authenticating-with-github-apps/.
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// mediaType is the media type of the (preview) GitHub Apps API.
+const mediaType = "application/vnd.github.machine-man-preview+json"
+
+// App authenticates as a GitHub App. It mints installation access tokens for the app's installations and
+// caches them until shortly before they expire.
+type App struct {
+	ID         int64           // the GitHub App ID
+	PrivateKey *rsa.PrivateKey // a private key of the GitHub App, for signing JWTs
+
+	// APIURL is the base URL of the GitHub REST API, such as https://api.github.com/ or
+	// https://github.example.com/api/v3/.
+	APIURL *url.URL
+
+	// HTTPClient is the client used to access the GitHub API.
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	tokens map[int64]*installationToken // installation ID -> cached token
+}
+
+// An Installation is an installation of a GitHub App on an organization or user account.
+type Installation struct {
+	ID      int64 `json:"id"`
+	Account struct {
+		Login string `json:"login"`
+	} `json:"account"`
+}
+
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// timeNow is time.Now, mocked in tests.
+var timeNow = time.Now
+
+// ParsePrivateKey parses a PEM-encoded RSA private key (as downloaded from the GitHub App's settings
+// page).
+func ParsePrivateKey(privateKeyPEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("invalid GitHub App private key: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid GitHub App private key")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid GitHub App private key: not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// JWT returns a JSON Web Token that authenticates as the GitHub App for the next few minutes. It is only
+// accepted by the endpoints that manage the app's installations.
+func (a *App) JWT() (string, error) {
+	now := timeNow()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(), // allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Installations returns all installations of the GitHub App.
+func (a *App) Installations(ctx context.Context) ([]*Installation, error) {
+	const perPage = 100
+	var all []*Installation
+	for page := 1; ; page++ {
+		var installations []*Installation
+		if err := a.do(ctx, "GET", fmt.Sprintf("app/installations?per_page=%d&page=%d", perPage, page), http.StatusOK, &installations); err != nil {
+			return nil, err
+		}
+		all = append(all, installations...)
+		if len(installations) < perPage {
+			return all, nil
+		}
+	}
+}
+
+// InstallationToken returns an access token for the installation of the GitHub App. Tokens are valid for
+// an hour. A cached token is returned until 5 minutes before it expires, so that callers can use it for
+// a few minutes, after which a new one is minted.
+func (a *App) InstallationToken(ctx context.Context, installationID int64) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if t := a.tokens[installationID]; t != nil && timeNow().Add(5*time.Minute).Before(t.ExpiresAt) {
+		return t.Token, nil
+	}
+
+	var t installationToken
+	if err := a.do(ctx, "POST", fmt.Sprintf("app/installations/%d/access_tokens", installationID), http.StatusCreated, &t); err != nil {
+		return "", errors.Wrapf(err, "creating access token for GitHub App installation %d", installationID)
+	}
+	if a.tokens == nil {
+		a.tokens = make(map[int64]*installationToken)
+	}
+	a.tokens[installationID] = &t
+	return t.Token, nil
+}
+
+// do sends a request authenticated as the GitHub App and decodes the JSON response into result.
+func (a *App) do(ctx context.Context, method, path string, wantStatus int, result interface{}) error {
+	jwt, err := a.JWT()
+	if err != nil {
+		return err
+	}
+	u, err := a.APIURL.Parse(path)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := ctxhttp.Do(ctx, a.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		return errors.WithStack(&httpError{URL: req.URL, StatusCode: resp.StatusCode})
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+type httpError struct {
+	StatusCode int
+	URL        *url.URL
+}
+
+func (e *httpError) Error() string {
+	return fmt.Sprintf("unexpected %d response from GitHub App API at %s", e.StatusCode, e.URL)
+}
+
+func (e *httpError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized
+}
+
+func (e *httpError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
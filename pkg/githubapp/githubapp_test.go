@@ -0,0 +1,170 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestApp(t *testing.T, apiURL string) *App {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &App{ID: 123, PrivateKey: key, APIURL: u, HTTPClient: http.DefaultClient}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, block := range map[string]*pem.Block{
+		"pkcs1": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"pkcs8": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParsePrivateKey(string(pem.EncodeToMemory(block)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.PublicKey, key.PublicKey) {
+				t.Error("got a different key")
+			}
+		})
+	}
+
+	if _, err := ParsePrivateKey("not a key"); err == nil {
+		t.Error("got no error for an invalid key")
+	}
+}
+
+func TestApp_JWT(t *testing.T) {
+	defer func() { timeNow = time.Now }()
+	now := time.Unix(1500000000, 0)
+	timeNow = func() time.Time { return now }
+
+	app := newTestApp(t, "https://api.github.com/")
+	jwt, err := app.JWT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("got JWT %q, want 3 parts", jwt)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&app.PrivateKey.PublicKey, crypto.SHA256, hash[:], sig); err != nil {
+		t.Errorf("invalid JWT signature: %s", err)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]int64
+	if err := json.Unmarshal(b, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int64{"iat": 1499999940, "exp": 1500000540, "iss": 123}; !reflect.DeepEqual(claims, want) {
+		t.Errorf("got claims %v, want %v", claims, want)
+	}
+}
+
+func TestApp_InstallationToken(t *testing.T) {
+	defer func() { timeNow = time.Now }()
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	var minted int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/app/installations/7/access_tokens" {
+			http.Error(w, "unexpected request "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			http.Error(w, "no JWT", http.StatusUnauthorized)
+			return
+		}
+		minted++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "t%d", "expires_at": %q}`, minted, now.Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	app := newTestApp(t, srv.URL+"/")
+	ctx := context.Background()
+	for _, want := range []string{"t1", "t1"} {
+		token, err := app.InstallationToken(ctx, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != want {
+			t.Errorf("got token %q, want %q", token, want)
+		}
+	}
+
+	// The token is refreshed shortly before it expires.
+	now = now.Add(56 * time.Minute)
+	token, err := app.InstallationToken(ctx, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "t2" {
+		t.Errorf("got token %q after expiry, want t2", token)
+	}
+
+	if _, err := app.InstallationToken(ctx, 8); err == nil {
+		t.Error("got no error for a nonexistent installation")
+	}
+}
+
+func TestApp_Installations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/app/installations" || r.URL.Query().Get("page") != "1" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `[{"id": 1, "account": {"login": "a"}}, {"id": 2, "account": {"login": "b"}}]`)
+	}))
+	defer srv.Close()
+
+	installations, err := newTestApp(t, srv.URL+"/api/v3/").Installations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, inst := range installations {
+		got = append(got, fmt.Sprintf("%d:%s", inst.ID, inst.Account.Login))
+	}
+	if want := []string{"1:a", "2:b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got installations %v, want %v", got, want)
+	}
+}
@@ -72,10 +72,16 @@ type Client struct {
 
 // addrForRepo returns the gitserver address to use for the given repo URI.
 func (c *Client) addrForRepo(repo api.RepoURI) string {
+	return AddrForRepo(repo, c.Addrs)
+}
+
+// AddrForRepo returns the address of the gitserver in addrs which stores the
+// given repo URI.
+func AddrForRepo(repo api.RepoURI, addrs []string) string {
 	repo = protocol.NormalizeRepo(repo) // in case the caller didn't already normalize it
 	sum := md5.Sum([]byte(repo))
-	serverIndex := binary.BigEndian.Uint64(sum[:]) % uint64(len(c.Addrs))
-	return c.Addrs[serverIndex]
+	serverIndex := binary.BigEndian.Uint64(sum[:]) % uint64(len(addrs))
+	return addrs[serverIndex]
 }
 
 func (c *Cmd) sendExec(ctx context.Context) (_ io.ReadCloser, _ http.Header, errRes error) {
@@ -293,6 +299,11 @@ func (c *Client) ListCloned(ctx context.Context) ([]string, error) {
 	return repos, err
 }
 
+// ListClonedOn lists the repositories cloned on the gitserver at addr.
+func (c *Client) ListClonedOn(ctx context.Context, addr string) ([]string, error) {
+	return doListOne(ctx, "?cloned", addr)
+}
+
 // GetGitolitePhabricatorMetadata returns Phabricator metadata for a
 // Gitolite repository fetched via a user-provided command.
 func (c *Client) GetGitolitePhabricatorMetadata(ctx context.Context, gitoliteHost string, repo string) (*protocol.GitolitePhabricatorMetadataResponse, error) {
@@ -436,10 +447,16 @@ func (c *Client) IsRepoCloned(ctx context.Context, repo api.RepoURI) (bool, erro
 // The repository not existing is not an error; in that case, RepoInfoResponse.Cloned will be false
 // and the error will be nil.
 func (c *Client) RepoInfo(ctx context.Context, repo api.RepoURI) (*protocol.RepoInfoResponse, error) {
+	return c.RepoInfoOn(ctx, c.addrForRepo(repo), repo)
+}
+
+// RepoInfoOn is like RepoInfo, but it retrieves information about the repository on the
+// gitserver at addr, which may not be the gitserver that stores it.
+func (c *Client) RepoInfoOn(ctx context.Context, addr string, repo api.RepoURI) (*protocol.RepoInfoResponse, error) {
 	req := &protocol.RepoInfoRequest{
 		Repo: repo,
 	}
-	resp, err := c.httpPost(ctx, repo, "repo", req)
+	resp, err := c.httpPostAddr(ctx, addr, "repo", req)
 	if err != nil {
 		return nil, err
 	}
@@ -472,6 +489,10 @@ func (c *Client) Remove(ctx context.Context, repo api.RepoURI) error {
 }
 
 func (c *Client) httpPost(ctx context.Context, repo api.RepoURI, method string, payload interface{}) (resp *http.Response, err error) {
+	return c.httpPostAddr(ctx, c.addrForRepo(repo), method, payload)
+}
+
+func (c *Client) httpPostAddr(ctx context.Context, addr string, method string, payload interface{}) (resp *http.Response, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Client.httpPost")
 	defer func() {
 		if err != nil {
@@ -486,7 +507,6 @@ func (c *Client) httpPost(ctx context.Context, repo api.RepoURI, method string,
 		return nil, err
 	}
 
-	addr := c.addrForRepo(repo)
 	req, err := http.NewRequest("POST", "http://"+addr+"/"+method, bytes.NewReader(reqBody))
 	if err != nil {
 		return nil, err
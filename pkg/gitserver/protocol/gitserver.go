@@ -96,6 +96,10 @@ type IsRepoClonedRequest struct {
 type RepoInfoRequest struct {
 	// Repo is the repository to get information about.
 	Repo api.RepoURI
+
+	// IncludeSize is whether to compute RepoInfoResponse.Size. It is
+	// expensive, since it walks the repository's files.
+	IncludeSize bool
 }
 
 // RepoDeleteRequest is a request to delete a repository clone on gitserver
@@ -111,6 +115,8 @@ type RepoInfoResponse struct {
 	CloneProgress   string     // a progress message from the running clone command.
 	Cloned          bool       // whether the repository has been cloned successfully
 	LastFetched     *time.Time // when the last `git remote update` or `git fetch` occurred
+	LastAccessed    *time.Time // when a command was last run on the repository (updated at most once a minute)
+	Size            int64      // the size of the repository on disk, in bytes (only set if RepoInfoRequest.IncludeSize is)
 
 	// CloneTime is the time the clone occurred. Note: Repositories may be
 	// recloned automatically, so this time is likely to move forward
@@ -142,6 +142,31 @@ func (c *Client) EnqueueRepoUpdate(ctx context.Context, repo gitserver.Repo) err
 	return nil
 }
 
+// MockUpdateQueue mocks (*Client).UpdateQueue for tests.
+var MockUpdateQueue func(ctx context.Context, args protocol.UpdateQueueRequest) (*protocol.UpdateQueue, error)
+
+// UpdateQueue returns the state of the repo-updater scheduler's queues.
+func (c *Client) UpdateQueue(ctx context.Context, args protocol.UpdateQueueRequest) (*protocol.UpdateQueue, error) {
+	if MockUpdateQueue != nil {
+		return MockUpdateQueue(ctx, args)
+	}
+
+	resp, err := c.httpPost(ctx, "update-queue", args)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("UpdateQueue: http status %d", resp.StatusCode)
+	}
+	var queue protocol.UpdateQueue
+	if err := json.NewDecoder(resp.Body).Decode(&queue); err != nil {
+		return nil, err
+	}
+	return &queue, nil
+}
+
 func (c *Client) httpPost(ctx context.Context, method string, payload interface{}) (resp *http.Response, err error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Client.httpPost")
 	defer func() {
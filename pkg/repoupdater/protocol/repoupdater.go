@@ -3,6 +3,7 @@ package protocol
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sourcegraph/sourcegraph/pkg/api"
 )
@@ -96,3 +97,32 @@ type RepoUpdateRequest struct {
 	// URL is the repository's Git remote URL (from which to clone or update).
 	URL string `json:"url"`
 }
+
+// UpdateQueueRequest is a request for the state of the repo-updater scheduler's queues.
+type UpdateQueueRequest struct {
+	// First is the maximum number of repositories to return from each queue. If zero, all
+	// repositories are returned.
+	First int `json:"first"`
+}
+
+// UpdateQueue is the state of the repo-updater scheduler's queues. The fields are ordered by
+// priority.
+type UpdateQueue struct {
+	HighPriority []*UpdateQueueItem // repositories requested to be updated next
+	New          []*UpdateQueueItem // repositories that are updated when there is spare capacity
+	Scheduled    []*UpdateQueueItem // repositories scheduled for periodic updates, by due time
+	Unscheduled  []*UpdateQueueItem // repositories that are not scheduled
+
+	// LockHeld is how long the scheduler's lock was held to copy its state.
+	LockHeld time.Duration
+}
+
+// UpdateQueueItem is a repository in the repo-updater scheduler's queues.
+type UpdateQueueItem struct {
+	Repo               api.RepoURI
+	Due                time.Time     // the next time the repository should be updated
+	UpdateInterval     time.Duration // how often the repository is updated
+	LastUpdateDuration time.Duration // how long the last update took
+	LastChanged        *time.Time    // when the repository last changed, if known
+	IsUpdating         bool          // whether the repository is being updated
+}
@@ -0,0 +1,42 @@
+// Package contextlines returns the lines around a search match, for the
+// context: search parameter. It is shared by searcher and by the frontend
+// (for indexed search results), so that both return the same context.
+package contextlines
+
+import (
+	"bufio"
+	"bytes"
+)
+
+// Around returns up to nBefore lines preceding and up to nAfter lines
+// following the line content[start:end]. end is the offset just after the
+// line's newline, or len(content) for a last line without one. Like
+// bufio.ScanLines, the returned lines do not include the line ending. The
+// returned strings are copies, so are safe to use after content is no longer
+// valid.
+//
+// If the line is not within content (e.g. because content is not the file the
+// line was found in), Around returns no lines.
+func Around(content []byte, start, end, nBefore, nAfter int) (before, after []string) {
+	if start < 0 || start > end || end > len(content) {
+		return nil, nil
+	}
+
+	for pos := start; len(before) < nBefore && pos > 0; {
+		// content[pos-1] is the newline terminating the previous line.
+		lineStart := bytes.LastIndexByte(content[:pos-1], '\n') + 1
+		before = append(before, string(bytes.TrimSuffix(content[lineStart:pos-1], []byte{'\r'})))
+		pos = lineStart
+	}
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+
+	rest := content[end:]
+	for len(after) < nAfter && len(rest) > 0 {
+		advance, line, _ := bufio.ScanLines(rest, true)
+		after = append(after, string(line))
+		rest = rest[advance:]
+	}
+	return before, after
+}
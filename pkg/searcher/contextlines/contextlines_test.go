@@ -0,0 +1,25 @@
+package contextlines
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAround(t *testing.T) {
+	content := []byte("a\nb\r\nfoo\nc\nd")
+	cases := []struct {
+		start, end    int
+		before, after []string
+	}{
+		{start: 5, end: 9, before: []string{"a", "b"}, after: []string{"c", "d"}},
+		{start: 0, end: 2, before: nil, after: []string{"b", "foo"}},
+		{start: 11, end: 12, before: []string{"foo", "c"}, after: nil},
+		{start: 11, end: 13, before: nil, after: nil}, // not in content
+	}
+	for _, c := range cases {
+		before, after := Around(content, c.start, c.end, 2, 2)
+		if !reflect.DeepEqual(before, c.before) || !reflect.DeepEqual(after, c.after) {
+			t.Errorf("Around(%d, %d) = %q, %q, want %q, %q", c.start, c.end, before, after, c.before, c.after)
+		}
+	}
+}
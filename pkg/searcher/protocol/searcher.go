@@ -39,6 +39,11 @@ type Request struct {
 	// The deadline for the search request.
 	// It is parsed with time.Time.UnmarshalText.
 	Deadline string
+
+	// Stream if true requests a streaming response. Instead of a single
+	// Response, the body is newline-delimited JSON of StreamEvents which are
+	// written as soon as matches are found.
+	Stream bool
 }
 
 // GitserverRepo returns the repository information necessary to perform gitserver requests.
@@ -97,6 +102,45 @@ type PatternInfo struct {
 	// PatternMatchesPath is whether a file whose path matches Pattern (but whose contents don't) should be
 	// considered a match.
 	PatternMatchesPath bool
+
+	// IsMultiline if true will match Pattern against the whole file rather
+	// than line by line, so a match may span multiple lines. Matches are
+	// returned in FileMatch.MultilineMatches instead of LineMatches.
+	IsMultiline bool
+
+	// BeforeContextLines is the number of lines preceding each matched line
+	// to return in LineMatch.BeforeContext.
+	BeforeContextLines int
+
+	// AfterContextLines is the number of lines following each matched line
+	// to return in LineMatch.AfterContext.
+	AfterContextLines int
+
+	// IsReplace if true will replace every match of Pattern with
+	// Replacement. Instead of LineMatches, each FileMatch contains a Diff of
+	// the replacement.
+	IsReplace bool
+
+	// Replacement is the template each match of Pattern is replaced with if
+	// IsReplace is true. It may refer to capture groups of the pattern, as
+	// in regexp.Regexp.Expand. eg "${1}Handler"
+	Replacement string
+
+	// AndPatterns is a list of additional patterns that must *all* match
+	// the content of the returned files, though not necessarily on the same
+	// line as Pattern. Their matches are returned along with those of
+	// Pattern. They are interpreted like Pattern (see IsRegExp, IsWordMatch
+	// and IsCaseSensitive).
+	AndPatterns []string
+
+	// NotPatterns is a list of patterns that must not match the content of
+	// the returned files. They are interpreted like Pattern.
+	NotPatterns []string
+
+	// CountOnly if true will only count the matches in each file. Instead
+	// of LineMatches, each FileMatch contains the MatchCount, which is not
+	// limited like the number of LineMatches is.
+	CountOnly bool
 }
 
 // AllIncludePatterns returns all include patterns (including the deprecated
@@ -125,12 +169,57 @@ type Response struct {
 	DeadlineHit bool
 }
 
+// StreamContentType is the Content-Type of a streaming response. Older
+// searchers ignore Request.Stream and respond with a JSON encoded Response, so
+// clients should check the Content-Type of the response.
+const StreamContentType = "application/x-ndjson"
+
+// StreamEvent is a line of a streaming response. Exactly one of its fields is
+// set. Every stream is terminated by an event with Done set.
+type StreamEvent struct {
+	// Match is a file match, sent as soon as it is found.
+	Match *FileMatch `json:",omitempty"`
+
+	// Done is the final event of the stream.
+	Done *StreamDone `json:",omitempty"`
+}
+
+// StreamDone is the final event of a streaming response. It contains the
+// same information as a Response, except for the matches which have already
+// been sent.
+type StreamDone struct {
+	// LimitHit is true if the stream may not include all FileMatches because a match limit was hit.
+	LimitHit bool
+
+	// DeadlineHit is true if the stream may not include all FileMatches because a deadline was hit.
+	DeadlineHit bool
+
+	// Error is non-empty if the search failed after the stream started. The
+	// stream may still contain some matches.
+	Error string `json:",omitempty"`
+}
+
 // FileMatch is the struct used by vscode to receive search results
 type FileMatch struct {
 	Path        string
 	LineMatches []LineMatch
 
-	// LimitHit is true if LineMatches may not include all LineMatches.
+	// MultilineMatches is set instead of LineMatches when the search was
+	// done with IsMultiline.
+	MultilineMatches []MultilineMatch `json:",omitempty"`
+
+	// Diff is set instead of LineMatches when the search was done with
+	// IsReplace. It is a unified diff of the file with every match replaced,
+	// in a format understood by git apply.
+	Diff string `json:",omitempty"`
+
+	// MatchCount is set instead of LineMatches when the search was done with
+	// CountOnly. It is the number of matching lines (or, with IsMultiline,
+	// the number of matches) in the file.
+	MatchCount int `json:",omitempty"`
+
+	// LimitHit is true if LineMatches (or MultilineMatches) may not include
+	// all matches.
 	LimitHit bool
 }
 
@@ -150,4 +239,38 @@ type LineMatch struct {
 
 	// LimitHit is true if OffsetAndLengths may not include all OffsetAndLengths.
 	LimitHit bool
+
+	// BeforeContext is up to PatternInfo.BeforeContextLines lines preceding
+	// the matched line, in file order. It is shorter if the match is near
+	// the start of the file.
+	BeforeContext []string `json:",omitempty"`
+
+	// AfterContext is up to PatternInfo.AfterContextLines lines following
+	// the matched line, in file order. It is shorter if the match is near
+	// the end of the file.
+	AfterContext []string `json:",omitempty"`
+}
+
+// MultilineMatch is a match of a multiline pattern. Unlike LineMatch it may
+// span several lines.
+type MultilineMatch struct {
+	// Preview is the full text of every line the match touches (without the
+	// trailing newline of the last line).
+	Preview string
+
+	// Start is the position of the first character of the match.
+	Start Location
+
+	// End is the position just after the last character of the match.
+	End Location
+}
+
+// Location is a position in a file.
+type Location struct {
+	// Line is the 0-based line number.
+	Line int
+
+	// Column is the 0-based offset in the line, measured in characters (not
+	// bytes).
+	Column int
 }
@@ -35,7 +35,25 @@ type SearchArgs struct {
 	// need to match to get included in the result
 	ExcludePattern string
 
+	// Kinds, if nonempty, restricts the results to symbols of these kinds
+	// (such as "function" or "class"), as reported by ctags.
+	Kinds []string
+
+	// Languages, if nonempty, restricts the results to symbols in these
+	// languages, as reported by ctags. They are matched case insensitively,
+	// ignoring spaces and dashes.
+	Languages []string
+
+	// Parent, if nonempty, restricts the results to symbols whose parent
+	// (such as the class of a method) has this name. It is matched case
+	// insensitively, unless IsCaseSensitive is true.
+	Parent string
+
 	// First indicates that only the first n symbols should be returned.
+	//
+	// When Query is nonempty, symbols whose name is an exact match are
+	// returned first, then symbols that define types and functions, then
+	// symbols in files closer to the repository root.
 	First int
 }
 
@@ -58,3 +76,28 @@ type Symbol struct {
 
 	FileLimited bool
 }
+
+// OutlineArgs are the arguments to get the outline of a file from the
+// symbols service.
+type OutlineArgs struct {
+	// Repo is the repository URI of the file.
+	Repo api.RepoURI `json:"repo"`
+
+	// CommitID is the commit of the file.
+	CommitID api.CommitID `json:"commitID"`
+
+	// Path is the path of the file, relative to the repository root.
+	Path string `json:"path"`
+}
+
+// OutlineResult is the outline of a file from the symbols service.
+type OutlineResult struct {
+	Symbols []*OutlineSymbol // the top-level symbols of the file, in order of their lines
+}
+
+// OutlineSymbol is a code symbol in the outline of a file, with the symbols
+// nested in it (such as the methods of a class).
+type OutlineSymbol struct {
+	Symbol
+	Children []*OutlineSymbol
+}
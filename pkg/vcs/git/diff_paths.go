@@ -0,0 +1,72 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/sourcegraph/pkg/api"
+	"github.com/sourcegraph/sourcegraph/pkg/gitserver"
+)
+
+// Changes are the paths of the files that differ between two commits.
+type Changes struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+// DiffPaths returns the paths of the files that differ between commits base
+// and head, as reported by `git diff --name-status`. A renamed file is
+// reported as deleted and added.
+func DiffPaths(ctx context.Context, repo gitserver.Repo, base, head api.CommitID) (*Changes, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: DiffPaths")
+	span.SetTag("Base", base)
+	span.SetTag("Head", head)
+	defer span.Finish()
+
+	if err := checkSpecArgSafety(string(base)); err != nil {
+		return nil, err
+	}
+	if err := checkSpecArgSafety(string(head)); err != nil {
+		return nil, err
+	}
+
+	cmd := gitserver.DefaultClient.Command("git", "diff", "--name-status", "--no-renames", "-z", string(base), string(head), "--")
+	cmd.Repo = repo
+	out, err := cmd.CombinedOutput(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, out))
+	}
+	return parseDiffNameStatus(out)
+}
+
+// parseDiffNameStatus parses the output of `git diff --name-status
+// --no-renames -z`, which is a status letter and a path for each file,
+// separated by NUL bytes.
+func parseDiffNameStatus(out []byte) (*Changes, error) {
+	var changes Changes
+	if len(out) == 0 {
+		return &changes, nil
+	}
+	fields := bytes.Split(bytes.TrimSuffix(out, []byte{0}), []byte{0})
+	if len(fields)%2 != 0 {
+		return nil, errors.Errorf("unexpected git diff --name-status output: %q", out)
+	}
+	for i := 0; i < len(fields); i += 2 {
+		status, path := string(fields[i]), string(fields[i+1])
+		switch status {
+		case "A":
+			changes.Added = append(changes.Added, path)
+		case "M", "T":
+			changes.Modified = append(changes.Modified, path)
+		case "D":
+			changes.Deleted = append(changes.Deleted, path)
+		default:
+			return nil, errors.Errorf("unexpected status %q for %q in git diff --name-status output", status, path)
+		}
+	}
+	return &changes, nil
+}
@@ -0,0 +1,58 @@
+package git_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/pkg/vcs/git"
+)
+
+func TestDiffPaths(t *testing.T) {
+	t.Parallel()
+
+	cmds := []string{
+		"echo a > a",
+		"echo b > b",
+		"echo c > c",
+		"git add a b c",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git tag base",
+		"echo a2 >> a",
+		"git rm b",
+		"git mv c 'd e'",
+		"echo f > f",
+		"git add a f",
+		"GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z git commit -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	repo := makeGitRepository(t, cmds...)
+
+	base, err := git.ResolveRevision(ctx, repo, nil, "base", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := git.ResolveRevision(ctx, repo, nil, "HEAD", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := git.DiffPaths(ctx, repo, base, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &git.Changes{
+		Added:    []string{"d e", "f"},
+		Modified: []string{"a"},
+		Deleted:  []string{"b", "c"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %+v, want %+v", changes, want)
+	}
+
+	changes, err = git.DiffPaths(ctx, repo, head, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, &git.Changes{}) {
+		t.Errorf("got %+v, want no changes", changes)
+	}
+}
@@ -247,6 +247,50 @@ func ListTags(ctx context.Context, repo gitserver.Repo) ([]*Tag, error) {
 	return tags, nil
 }
 
+// A Ref is a Git ref, such as a branch or a tag.
+type Ref struct {
+	// Name is the full name of the ref, such as "refs/heads/master".
+	Name string
+	// CommitID is the commit that the ref points to. Annotated tags are
+	// peeled to their commit.
+	CommitID api.CommitID
+}
+
+// ListRefs returns all refs in the repository, sorted by name.
+func ListRefs(ctx context.Context, repo gitserver.Repo) ([]*Ref, error) {
+	if Mocks.ListRefs != nil {
+		return Mocks.ListRefs()
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Git: ListRefs")
+	defer span.Finish()
+
+	cmd := gitserver.DefaultClient.Command("git", "for-each-ref", "--sort", "refname", "--format", "%(if)%(*objectname)%(then)%(*objectname)%(else)%(objectname)%(end) %(refname)")
+	cmd.Repo = repo
+	out, err := cmd.CombinedOutput(ctx)
+	if err != nil {
+		if vcs.IsRepoNotExist(err) {
+			return nil, err
+		}
+		return nil, errors.WithMessage(err, fmt.Sprintf("git command %v failed (output: %q)", cmd.Args, out))
+	}
+
+	out = bytes.TrimSuffix(out, []byte("\n")) // remove trailing newline
+	if len(out) == 0 {
+		return nil, nil // no refs
+	}
+	lines := bytes.Split(out, []byte("\n"))
+	refs := make([]*Ref, len(lines))
+	for i, line := range lines {
+		parts := bytes.SplitN(line, []byte(" "), 2)
+		if len(parts) != 2 || len(parts[0]) != 40 {
+			return nil, fmt.Errorf("invalid git for-each-ref output line: %q", line)
+		}
+		refs[i] = &Ref{Name: string(parts[1]), CommitID: api.CommitID(parts[0])}
+	}
+	return refs, nil
+}
+
 type byteSlices [][]byte
 
 func (p byteSlices) Len() int           { return len(p) }
@@ -280,3 +280,29 @@ func TestRepository_ListTags(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_ListRefs(t *testing.T) {
+	t.Parallel()
+
+	dateEnv := "GIT_COMMITTER_NAME=a GIT_COMMITTER_EMAIL=a@a.com GIT_COMMITTER_DATE=2006-01-02T15:04:05Z"
+	gitCommands := []string{
+		dateEnv + " git commit --allow-empty -m foo --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+		"git branch release/1.0",
+		dateEnv + " git tag --annotate -m foo t0",
+		dateEnv + " git commit --allow-empty -m bar --author='a <a@a.com>' --date 2006-01-02T15:04:05Z",
+	}
+	repo := makeGitRepository(t, gitCommands...)
+
+	refs, err := git.ListRefs(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*git.Ref{
+		{Name: "refs/heads/master", CommitID: "ce89acd69db9a7ebbeb6c6db31d01e0c15969b9f"},
+		{Name: "refs/heads/release/1.0", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
+		{Name: "refs/tags/t0", CommitID: "ea167fe3d76b1e5fd3ed8ca44cbd2fe3897684f8"},
+	}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("got refs == %v, want %v", asJSON(refs), asJSON(want))
+	}
+}
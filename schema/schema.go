@@ -84,16 +84,28 @@ func (v *AuthProviders) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("tagged union type must have a %q property whose value is one of %s", "type", []string{"builtin", "saml", "openidconnect", "http-header"})
 }
 
+type BitbucketCloudConnection struct {
+	AppPassword                 string   `json:"appPassword"`
+	GitURLType                  string   `json:"gitURLType,omitempty"`
+	InitialRepositoryEnablement bool     `json:"initialRepositoryEnablement,omitempty"`
+	RepositoryPathPattern       string   `json:"repositoryPathPattern,omitempty"`
+	Teams                       []string `json:"teams,omitempty"`
+	Url                         string   `json:"url"`
+	Username                    string   `json:"username"`
+}
 type BitbucketServerConnection struct {
-	Certificate                 string `json:"certificate,omitempty"`
-	ExcludePersonalRepositories bool   `json:"excludePersonalRepositories,omitempty"`
-	GitURLType                  string `json:"gitURLType,omitempty"`
-	InitialRepositoryEnablement bool   `json:"initialRepositoryEnablement,omitempty"`
-	Password                    string `json:"password,omitempty"`
-	RepositoryPathPattern       string `json:"repositoryPathPattern,omitempty"`
-	Token                       string `json:"token,omitempty"`
-	Url                         string `json:"url"`
-	Username                    string `json:"username,omitempty"`
+	Certificate                 string   `json:"certificate,omitempty"`
+	ExcludePersonalRepositories bool     `json:"excludePersonalRepositories,omitempty"`
+	GitCloneFilter              string   `json:"gitCloneFilter,omitempty"`
+	GitURLType                  string   `json:"gitURLType,omitempty"`
+	IndexedBranches             []string `json:"indexedBranches,omitempty"`
+	InitialRepositoryEnablement bool     `json:"initialRepositoryEnablement,omitempty"`
+	Password                    string   `json:"password,omitempty"`
+	RepositoryPathPattern       string   `json:"repositoryPathPattern,omitempty"`
+	Token                       string   `json:"token,omitempty"`
+	Url                         string   `json:"url"`
+	Username                    string   `json:"username,omitempty"`
+	WebhookSecret               string   `json:"webhookSecret,omitempty"`
 }
 
 // BuiltinAuthProvider description: Configures the builtin username-password authentication provider.
@@ -140,27 +152,51 @@ type Extensions struct {
 	Disabled              *bool       `json:"disabled,omitempty"`
 	RemoteRegistry        interface{} `json:"remoteRegistry,omitempty"`
 }
+
+// GitHubApp description: Authenticate as a GitHub App instead of with a personal access token, so that Sourcegraph is not limited to a user's rate limit and permissions. Sourcegraph mints (and refreshes) access tokens for the app's installations, syncs the repositories that the installations have access to, and clones them with the installation tokens. The app needs the "Repository contents" and "Repository metadata" read permissions.
+type GitHubApp struct {
+	AppID      int    `json:"appID"`
+	PrivateKey string `json:"privateKey"`
+}
 type GitHubConnection struct {
+	Certificate                 string     `json:"certificate,omitempty"`
+	GitCloneFilter              string     `json:"gitCloneFilter,omitempty"`
+	GitURLType                  string     `json:"gitURLType,omitempty"`
+	GithubApp                   *GitHubApp `json:"githubApp,omitempty"`
+	IndexedBranches             []string   `json:"indexedBranches,omitempty"`
+	InitialRepositoryEnablement bool       `json:"initialRepositoryEnablement,omitempty"`
+	Repos                       []string   `json:"repos,omitempty"`
+	RepositoryPathPattern       string     `json:"repositoryPathPattern,omitempty"`
+	RepositoryQuery             []string   `json:"repositoryQuery,omitempty"`
+	Token                       string     `json:"token,omitempty"`
+	Url                         string     `json:"url"`
+	WebhookSecret               string     `json:"webhookSecret,omitempty"`
+}
+type GitLabConnection struct {
 	Certificate                 string   `json:"certificate,omitempty"`
+	GitCloneFilter              string   `json:"gitCloneFilter,omitempty"`
 	GitURLType                  string   `json:"gitURLType,omitempty"`
+	IndexedBranches             []string `json:"indexedBranches,omitempty"`
 	InitialRepositoryEnablement bool     `json:"initialRepositoryEnablement,omitempty"`
-	Repos                       []string `json:"repos,omitempty"`
+	ProjectQuery                []string `json:"projectQuery,omitempty"`
 	RepositoryPathPattern       string   `json:"repositoryPathPattern,omitempty"`
-	RepositoryQuery             []string `json:"repositoryQuery,omitempty"`
 	Token                       string   `json:"token"`
 	Url                         string   `json:"url"`
+	WebhookSecret               string   `json:"webhookSecret,omitempty"`
 }
-type GitLabConnection struct {
+type GiteaConnection struct {
 	Certificate                 string   `json:"certificate,omitempty"`
 	GitURLType                  string   `json:"gitURLType,omitempty"`
 	InitialRepositoryEnablement bool     `json:"initialRepositoryEnablement,omitempty"`
-	ProjectQuery                []string `json:"projectQuery,omitempty"`
+	Orgs                        []string `json:"orgs,omitempty"`
 	RepositoryPathPattern       string   `json:"repositoryPathPattern,omitempty"`
 	Token                       string   `json:"token"`
 	Url                         string   `json:"url"`
+	Users                       []string `json:"users,omitempty"`
 }
 type GitoliteConnection struct {
 	Blacklist                  string `json:"blacklist,omitempty"`
+	GitCloneFilter             string `json:"gitCloneFilter,omitempty"`
 	Host                       string `json:"host"`
 	PhabricatorMetadataCommand string `json:"phabricatorMetadataCommand,omitempty"`
 	Prefix                     string `json:"prefix"`
@@ -242,10 +278,12 @@ type Repos struct {
 	Path     string `json:"path"`
 }
 type Repository struct {
-	Links *Links `json:"links,omitempty"`
-	Path  string `json:"path"`
-	Type  string `json:"type,omitempty"`
-	Url   string `json:"url"`
+	GitCloneFilter  string   `json:"gitCloneFilter,omitempty"`
+	IndexedBranches []string `json:"indexedBranches,omitempty"`
+	Links           *Links   `json:"links,omitempty"`
+	Path            string   `json:"path"`
+	Type            string   `json:"type,omitempty"`
+	Url             string   `json:"url"`
 }
 type ReviewBoard struct {
 	Url string `json:"url,omitempty"`
@@ -316,6 +354,7 @@ type SiteConfiguration struct {
 	AuthSessionExpiry                 string                       `json:"auth.sessionExpiry,omitempty"`
 	AuthUserOrgMap                    map[string][]string          `json:"auth.userOrgMap,omitempty"`
 	AwsCodeCommit                     []*AWSCodeCommitConnection   `json:"awsCodeCommit,omitempty"`
+	BitbucketCloud                    []*BitbucketCloudConnection  `json:"bitbucketCloud,omitempty"`
 	BitbucketServer                   []*BitbucketServerConnection `json:"bitbucketServer,omitempty"`
 	BlacklistGoGet                    []string                     `json:"blacklistGoGet,omitempty"`
 	CorsOrigin                        string                       `json:"corsOrigin,omitempty"`
@@ -332,10 +371,12 @@ type SiteConfiguration struct {
 	ExperimentalFeatures              *ExperimentalFeatures        `json:"experimentalFeatures,omitempty"`
 	Extensions                        *Extensions                  `json:"extensions,omitempty"`
 	GitCloneURLToRepositoryName       []*CloneURLToRepositoryName  `json:"git.cloneURLToRepositoryName,omitempty"`
+	GitCloneFilters                   map[string]string            `json:"gitCloneFilters,omitempty"`
 	GitMaxConcurrentClones            int                          `json:"gitMaxConcurrentClones,omitempty"`
 	Github                            []*GitHubConnection          `json:"github,omitempty"`
 	GithubClientID                    string                       `json:"githubClientID,omitempty"`
 	GithubClientSecret                string                       `json:"githubClientSecret,omitempty"`
+	Gitea                             []*GiteaConnection           `json:"gitea,omitempty"`
 	Gitlab                            []*GitLabConnection          `json:"gitlab,omitempty"`
 	Gitolite                          []*GitoliteConnection        `json:"gitolite,omitempty"`
 	HtmlBodyBottom                    string                       `json:"htmlBodyBottom,omitempty"`
@@ -360,6 +401,7 @@ type SiteConfiguration struct {
 	RepoListUpdateInterval            int                          `json:"repoListUpdateInterval,omitempty"`
 	ReposList                         []*Repository                `json:"repos.list,omitempty"`
 	ReviewBoard                       []*ReviewBoard               `json:"reviewBoard,omitempty"`
+	SearchIndexBranches               map[string][]string          `json:"search.index.branches,omitempty"`
 	SearchIndexEnabled                *bool                        `json:"search.index.enabled,omitempty"`
 	SiteID                            string                       `json:"siteID,omitempty"`
 	TlsLetsencrypt                    string                       `json:"tls.letsencrypt,omitempty"`
@@ -65,6 +65,16 @@ const SiteSchemaJSON = `{
       "type": "boolean",
       "!go": { "pointer": true }
     },
+    "search.index.branches": {
+      "description":
+        "Additional branches to index for search, by repository name. The default branch of each repository is always indexed. Each branch is a branch name or a glob such as \"release/*\" (where \"*\" also matches \"/\"). Searches of an indexed branch (such as ` + "`" + `repo:^github\\.com/myorg/myrepo$@release/1.0` + "`" + `) use the index instead of searching the branch unindexed.\n\nBranches can also be configured for all repositories of a code host connection, or a \"repos.list\" entry, with its \"indexedBranches\" property.",
+      "type": "object",
+      "additionalProperties": {
+        "type": "array",
+        "items": { "type": "string" }
+      },
+      "examples": [{ "github.com/myorg/myrepo": ["release/*"] }]
+    },
     "experimentalFeatures": {
       "description":
         "Experimental features to enable or disable. Features that are now enabled by default are marked as deprecated.",
@@ -211,6 +221,13 @@ const SiteSchemaJSON = `{
         "$ref": "#/definitions/GitLabConnection"
       }
     },
+    "gitea": {
+      "description": "JSON array of configuration for Gitea hosts.",
+      "type": "array",
+      "items": {
+        "$ref": "#/definitions/GiteaConnection"
+      }
+    },
     "awsCodeCommit": {
       "description": "JSON array of configuration for AWS CodeCommit endpoints.",
       "type": "array",
@@ -225,6 +242,13 @@ const SiteSchemaJSON = `{
         "$ref": "#/definitions/BitbucketServerConnection"
       }
     },
+    "bitbucketCloud": {
+      "description": "JSON array of configuration for Bitbucket Cloud (bitbucket.org).",
+      "type": "array",
+      "items": {
+        "$ref": "#/definitions/BitbucketCloudConnection"
+      }
+    },
     "gitolite": {
       "description": "JSON array of configuration for Gitolite hosts.",
       "type": "array",
@@ -237,6 +261,16 @@ const SiteSchemaJSON = `{
       "type": "integer",
       "default": 5
     },
+    "gitCloneFilters": {
+      "description":
+        "Partial clone filters of repositories, by repository name. A repository with a filter is cloned partially (as a Git partial clone), without the contents of files (\"blob:none\") or also without directories (\"tree:0\"). gitserver fetches the missing objects from the code host when a command first needs them, so the first search or view of an old commit may be slower. This saves disk space for repositories with large histories, such as ones with many large binary files. The code host must support partial clones.\n\nFilters can also be configured for all repositories of a code host connection, or a \"repos.list\" entry, with its \"gitCloneFilter\" property. Existing clones are recloned with the new filter by the next janitor run.",
+      "type": "object",
+      "additionalProperties": {
+        "type": "string",
+        "enum": ["blob:none", "tree:0"]
+      },
+      "examples": [{ "github.com/myorg/myrepo": "blob:none" }]
+    },
     "repos.list": {
       "description": "JSON array of configuration for external repositories.",
       "type": "array",
@@ -572,7 +606,7 @@ const SiteSchemaJSON = `{
     "GitHubConnection": {
       "type": "object",
       "additionalProperties": false,
-      "required": ["url", "token"],
+      "required": ["url"],
       "properties": {
         "url": {
           "description":
@@ -595,10 +629,14 @@ const SiteSchemaJSON = `{
           "default": "http"
         },
         "token": {
-          "description": "A GitHub personal access token with repo and org scope.",
+          "description":
+            "A GitHub personal access token with repo and org scope. Either a token or a githubApp is required.",
           "type": "string",
           "pattern": "^[^<>]+$"
         },
+        "githubApp": {
+          "$ref": "#/definitions/GitHubApp"
+        },
         "certificate": {
           "description": "TLS certificate of a GitHub Enterprise instance.",
           "type": "string",
@@ -612,7 +650,7 @@ const SiteSchemaJSON = `{
         },
         "repositoryQuery": {
           "description":
-            "An array of strings specifying which GitHub or GitHub Enterprise repositories to mirror on Sourcegraph. The valid values are:\n\n- ` + "`" + `public` + "`" + ` mirrors all public repositories for GitHub Enterprise and is the equivalent of ` + "`" + `none` + "`" + ` for GitHub\n\n- ` + "`" + `affiliated` + "`" + ` mirrors all repositories affiliated with the configured token's user:\n\t- Private repositories with read access\n\t- Public repositories owned by the user or their orgs\n\t- Public repositories with write access\n\n- ` + "`" + `none` + "`" + ` mirrors no repositories (except those specified in the ` + "`" + `repos` + "`" + ` configuration property or added manually)\n\nIf multiple values are provided, their results are unioned.\n\nIf you need to narrow the set of mirrored repositories further (and don't want to enumerate the set in the \"repos\" configuration property), create a new bot/machine user on GitHub or GitHub Enterprise that is only affiliated with the desired repositories.",
+            "An array of strings specifying which GitHub or GitHub Enterprise repositories to mirror on Sourcegraph. The valid values are:\n\n- ` + "`" + `public` + "`" + ` mirrors all public repositories for GitHub Enterprise and is the equivalent of ` + "`" + `none` + "`" + ` for GitHub\n\n- ` + "`" + `affiliated` + "`" + ` mirrors all repositories affiliated with the configured token's user:\n\t- Private repositories with read access\n\t- Public repositories owned by the user or their orgs\n\t- Public repositories with write access\n\n  With a ` + "`" + `githubApp` + "`" + `, it mirrors all repositories that the app's installations have access to.\n\n- ` + "`" + `none` + "`" + ` mirrors no repositories (except those specified in the ` + "`" + `repos` + "`" + ` configuration property or added manually)\n\nIf multiple values are provided, their results are unioned.\n\nIf you need to narrow the set of mirrored repositories further (and don't want to enumerate the set in the \"repos\" configuration property), create a new bot/machine user on GitHub or GitHub Enterprise that is only affiliated with the desired repositories.",
           "type": "array",
           "items": {
             "type": "string",
@@ -630,6 +668,43 @@ const SiteSchemaJSON = `{
           "description":
             "Defines whether repositories from this GitHub instance should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable GitHub repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately (subject to rate limiting by GitHub); site admins can still disable them explicitly, and they'll remain disabled.",
           "type": "boolean"
+        },
+        "indexedBranches": {
+          "description":
+            "Additional branches of repositories from this GitHub instance to index for search, such as \"release/*\". See the \"search.index.branches\" site configuration property for details.",
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "gitCloneFilter": {
+          "description":
+            "Clone the repositories from this GitHub instance partially (as a Git partial clone), without the contents of files (\"blob:none\") or also without directories (\"tree:0\"). gitserver fetches the missing objects from the code host when a command first needs them. This saves disk space for repositories with large histories, such as ones with many large binary files. The code host must support partial clones. See the \"gitCloneFilters\" site configuration property for details.",
+          "type": "string",
+          "enum": ["blob:none", "tree:0"]
+        },
+        "webhookSecret": {
+          "description":
+            "The secret of the webhooks that GitHub sends to Sourcegraph when repositories are pushed to, so that they are updated immediately instead of on the next periodic update. Add a webhook with the URL https://sourcegraph.example.com/.api/webhooks/github (using your Sourcegraph URL), Content type \"application/json\" and the \"push\" event, and this secret to the repositories or organizations. GitHub signs webhook requests with the secret, and requests without a valid secret are rejected.",
+          "type": "string"
+        }
+      }
+    },
+    "GitHubApp": {
+      "description":
+        "Authenticate as a GitHub App instead of with a personal access token, so that Sourcegraph is not limited to a user's rate limit and permissions. Sourcegraph mints (and refreshes) access tokens for the app's installations, syncs the repositories that the installations have access to, and clones them with the installation tokens. The app needs the \"Repository contents\" and \"Repository metadata\" read permissions.",
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["appID", "privateKey"],
+      "properties": {
+        "appID": {
+          "description": "The ID of the GitHub App, shown on its settings page.",
+          "type": "integer",
+          "minimum": 1
+        },
+        "privateKey": {
+          "description":
+            "A PEM-encoded private key of the GitHub App, generated on its settings page. It signs the requests for installation access tokens.",
+          "type": "string",
+          "pattern": "^-----BEGIN (RSA )?PRIVATE KEY-----\n"
         }
       }
     },
@@ -687,6 +762,82 @@ const SiteSchemaJSON = `{
           "description":
             "Defines whether repositories from this GitLab instance should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable GitLab repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately (subject to rate limiting by GitLab); site admins can still disable them explicitly, and they'll remain disabled.",
           "type": "boolean"
+        },
+        "indexedBranches": {
+          "description":
+            "Additional branches of repositories from this GitLab instance to index for search, such as \"release/*\". See the \"search.index.branches\" site configuration property for details.",
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "gitCloneFilter": {
+          "description":
+            "Clone the repositories from this GitLab instance partially (as a Git partial clone), without the contents of files (\"blob:none\") or also without directories (\"tree:0\"). gitserver fetches the missing objects from the code host when a command first needs them. This saves disk space for repositories with large histories, such as ones with many large binary files. The code host must support partial clones. See the \"gitCloneFilters\" site configuration property for details.",
+          "type": "string",
+          "enum": ["blob:none", "tree:0"]
+        },
+        "webhookSecret": {
+          "description":
+            "The secret of the webhooks that GitLab sends to Sourcegraph when repositories are pushed to, so that they are updated immediately instead of on the next periodic update. Add a webhook with the URL https://sourcegraph.example.com/.api/webhooks/gitlab (using your Sourcegraph URL), the \"Push events\" trigger, and this secret to the projects or groups. GitLab sends the secret as the webhook's secret token, and requests without a valid secret are rejected.",
+          "type": "string"
+        }
+      }
+    },
+    "GiteaConnection": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["url", "token"],
+      "properties": {
+        "url": {
+          "description": "URL of a Gitea instance, such as https://gitea.example.com.",
+          "type": "string",
+          "pattern": "^https?://",
+          "not": {
+            "type": "string",
+            "pattern": "example\\.com"
+          },
+          "format": "uri",
+          "examples": ["https://gitea.example.com"]
+        },
+        "token": {
+          "description":
+            "A Gitea access token of a user with read access to the repositories. Create one in the user's Settings > Applications page on the Gitea instance.",
+          "type": "string",
+          "pattern": "^[^<>]+$"
+        },
+        "orgs": {
+          "description":
+            "The organizations whose repositories are mirrored on Sourcegraph, such as [\"myorg\"].\n\nIf neither \"orgs\" nor \"users\" is set, all repositories that the token's user owns or has access to are mirrored.",
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "users": {
+          "description":
+            "The users whose own repositories are mirrored on Sourcegraph, such as [\"alice\"].\n\nIf neither \"orgs\" nor \"users\" is set, all repositories that the token's user owns or has access to are mirrored.",
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "gitURLType": {
+          "description":
+            "The type of Git URLs to use for cloning and fetching Git repositories on this Gitea instance.\n\nIf \"http\", Sourcegraph will access Gitea repositories using Git URLs of the form http(s)://gitea.example.com/myorg/myrepo.git (using https: if the Gitea instance uses HTTPS), authenticated with the token.\n\nIf \"ssh\", Sourcegraph will access Gitea repositories using Git URLs of the form git@gitea.example.com:myorg/myrepo.git. See the documentation for how to provide SSH private keys and known_hosts: https://about.sourcegraph.com/docs/config/repositories#repositories-that-need-https-or-ssh-authentication.",
+          "type": "string",
+          "enum": ["http", "ssh"],
+          "default": "http"
+        },
+        "certificate": {
+          "description": "TLS certificate of a Gitea instance.",
+          "type": "string",
+          "pattern": "^-----BEGIN CERTIFICATE-----\n"
+        },
+        "repositoryPathPattern": {
+          "description":
+            "The pattern used to generate the corresponding Sourcegraph repository path for a Gitea repository. In the pattern, the variable \"{host}\" is replaced with the Gitea URL's host (such as gitea.example.com), and \"{nameWithOwner}\" is replaced with the Gitea repository's \"owner/name\" (such as \"myorg/myrepo\").\n\nFor example, if your Gitea is https://gitea.example.com and your Sourcegraph is https://src.example.com, then a repositoryPathPattern of \"{host}/{nameWithOwner}\" would mean that a Gitea repository at https://gitea.example.com/myorg/myrepo is available on Sourcegraph at https://src.example.com/gitea.example.com/myorg/myrepo.",
+          "type": "string",
+          "default": "{host}/{nameWithOwner}"
+        },
+        "initialRepositoryEnablement": {
+          "description":
+            "Defines whether repositories from this Gitea instance should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable Gitea repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately; site admins can still disable them explicitly, and they'll remain disabled.",
+          "type": "boolean"
         }
       }
     },
@@ -749,6 +900,72 @@ const SiteSchemaJSON = `{
           "description":
             "Defines whether repositories from this Bitbucket Server instance should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable Bitbucket Server repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately (subject to rate limiting by Bitbucket Server); site admins can still disable them explicitly, and they'll remain disabled.",
           "type": "boolean"
+        },
+        "indexedBranches": {
+          "description":
+            "Additional branches of repositories from this Bitbucket Server instance to index for search, such as \"release/*\". See the \"search.index.branches\" site configuration property for details.",
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "gitCloneFilter": {
+          "description":
+            "Clone the repositories from this Bitbucket Server instance partially (as a Git partial clone), without the contents of files (\"blob:none\") or also without directories (\"tree:0\"). gitserver fetches the missing objects from the code host when a command first needs them. This saves disk space for repositories with large histories, such as ones with many large binary files. The code host must support partial clones. See the \"gitCloneFilters\" site configuration property for details.",
+          "type": "string",
+          "enum": ["blob:none", "tree:0"]
+        },
+        "webhookSecret": {
+          "description":
+            "The secret of the webhooks that Bitbucket Server sends to Sourcegraph when repositories are pushed to, so that they are updated immediately instead of on the next periodic update. Add a webhook with the URL https://sourcegraph.example.com/.api/webhooks/bitbucket-server (using your Sourcegraph URL), the \"Repository: Push\" event, and this secret to the projects or repositories. Bitbucket Server signs webhook requests with the secret, and requests without a valid secret are rejected.",
+          "type": "string"
+        }
+      }
+    },
+    "BitbucketCloudConnection": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["url", "username", "appPassword"],
+      "properties": {
+        "url": {
+          "description": "URL of Bitbucket Cloud, which is https://bitbucket.org unless it is accessed through a proxy.",
+          "type": "string",
+          "pattern": "^https?://",
+          "format": "uri",
+          "default": "https://bitbucket.org",
+          "examples": ["https://bitbucket.org"]
+        },
+        "username": {
+          "description": "The username to use when authenticating to Bitbucket Cloud. Also set the corresponding \"appPassword\" field.",
+          "type": "string"
+        },
+        "appPassword": {
+          "description":
+            "The app password to use when authenticating to Bitbucket Cloud. It needs the \"Repositories: Read\" permission. Create one at https://bitbucket.org/account/settings/app-passwords/.",
+          "type": "string",
+          "pattern": "^[^<>]+$"
+        },
+        "teams": {
+          "description":
+            "The teams (workspaces) whose repositories are synced to Sourcegraph, such as [\"myteam\"]. If empty, the repositories that the user (\"username\") is a member of are synced.",
+          "type": "array",
+          "items": { "type": "string", "pattern": "^[\\w-]+$" }
+        },
+        "gitURLType": {
+          "description":
+            "The type of Git URLs to use for cloning and fetching Git repositories on Bitbucket Cloud.\n\nIf \"http\", Sourcegraph will access Bitbucket Cloud repositories using Git URLs of the form https://bitbucket.org/myteam/myrepo.git, authenticated with the app password.\n\nIf \"ssh\", Sourcegraph will access Bitbucket Cloud repositories using Git URLs of the form git@bitbucket.org:myteam/myrepo.git. See the documentation for how to provide SSH private keys and known_hosts: https://about.sourcegraph.com/docs/config/repositories#repositories-that-need-https-or-ssh-authentication.",
+          "type": "string",
+          "enum": ["http", "ssh"],
+          "default": "http"
+        },
+        "repositoryPathPattern": {
+          "description":
+            "The pattern used to generate the corresponding Sourcegraph repository path for a Bitbucket Cloud repository.\n\n - \"{host}\" is replaced with the Bitbucket Cloud URL's host (such as bitbucket.org)\n - \"{nameWithOwner}\" is replaced with the Bitbucket Cloud repository's \"owner/path\" (such as \"myteam/myrepo\").\n\nFor example, if your Sourcegraph instance is at https://src.example.com, then a repositoryPathPattern of \"{host}/{nameWithOwner}\" would mean that a Bitbucket Cloud repository at https://bitbucket.org/myteam/myrepo is available on Sourcegraph at https://src.example.com/bitbucket.org/myteam/myrepo.",
+          "type": "string",
+          "default": "{host}/{nameWithOwner}"
+        },
+        "initialRepositoryEnablement": {
+          "description":
+            "Defines whether repositories from Bitbucket Cloud should be enabled and cloned when they are first seen by Sourcegraph. If false, the site admin must explicitly enable Bitbucket Cloud repositories (in the site admin area) to clone them and make them searchable on Sourcegraph. If true, they will be enabled and cloned immediately (subject to rate limiting by Bitbucket Cloud); site admins can still disable them explicitly, and they'll remain disabled.",
+          "type": "boolean"
         }
       }
     },
@@ -825,6 +1042,12 @@ const SiteSchemaJSON = `{
           "description":
             "Bash command that prints out the Phabricator callsign for a Gitolite repository. This will be run with environment variable $REPO set to the URI of the repository and used to obtain the Phabricator metadata for a Gitolite repository. (Note: this requires ` + "`" + `bash` + "`" + ` to be installed.)",
           "type": "string"
+        },
+        "gitCloneFilter": {
+          "description":
+            "Clone the repositories from this Gitolite host partially (as a Git partial clone), without the contents of files (\"blob:none\") or also without directories (\"tree:0\"). gitserver fetches the missing objects from the code host when a command first needs them. This saves disk space for repositories with large histories, such as ones with many large binary files. The code host must support partial clones. See the \"gitCloneFilters\" site configuration property for details.",
+          "type": "string",
+          "enum": ["blob:none", "tree:0"]
         }
       }
     },
@@ -867,6 +1090,18 @@ const SiteSchemaJSON = `{
           "type": "string",
           "pattern": "^[\\w_]"
         },
+        "indexedBranches": {
+          "description":
+            "Additional branches of this repository to index for search, such as \"release/*\". See the \"search.index.branches\" site configuration property for details.",
+          "type": "array",
+          "items": { "type": "string" }
+        },
+        "gitCloneFilter": {
+          "description":
+            "Clone this repository partially (as a Git partial clone), without the contents of files (\"blob:none\") or also without directories (\"tree:0\"). gitserver fetches the missing objects from the code host when a command first needs them. This saves disk space for repositories with large histories, such as ones with many large binary files. The code host must support partial clones. See the \"gitCloneFilters\" site configuration property for details.",
+          "type": "string",
+          "enum": ["blob:none", "tree:0"]
+        },
         "links": {
           "type": "object",
           "additionalProperties": false,
@@ -145,6 +145,10 @@ function serviceTypeDisplayNameAndIcon(
             return { displayName: 'GitLab' }
         case 'bitbucketserver':
             return { displayName: 'Bitbucket Server' }
+        case 'bitbucketCloud':
+            return { displayName: 'Bitbucket Cloud' }
+        case 'gitea':
+            return { displayName: 'Gitea' }
         case 'phabricator':
             return { displayName: 'Phabricator', icon: PhabricatorIcon }
         case 'awscodecommit':
@@ -3,7 +3,9 @@ import { setProperty } from '@sqs/jsonc-parser/lib/edit'
 import { SlackNotificationsConfig } from '../schema/settings.schema'
 import {
     AwsCodeCommitConnection,
+    BitbucketCloudConnection,
     BitbucketServerConnection,
+    GiteaConnection,
     GitHubConnection,
     GitLabConnection,
     OpenIdConnectAuthProvider,
@@ -62,6 +64,28 @@ const addBitbucketServer: ConfigInsertionFunction = config => {
     return { edits, selectText: tokenPlaceholder }
 }
 
+const addBitbucketCloud: ConfigInsertionFunction = config => {
+    const appPasswordPlaceholder =
+        '<app password with Repositories: Read permission (https://bitbucket.org/account/settings/app-passwords/)>'
+    const value: BitbucketCloudConnection = {
+        url: 'https://bitbucket.org',
+        username: '<username>',
+        appPassword: appPasswordPlaceholder,
+    }
+    const edits = setProperty(config, ['bitbucketCloud', -1], value, defaultFormattingOptions)
+    return { edits, selectText: appPasswordPlaceholder }
+}
+
+const addGitea: ConfigInsertionFunction = config => {
+    const tokenPlaceholder = '<access token (create one in your Gitea user settings under Applications)>'
+    const value: GiteaConnection = {
+        url: 'https://gitea.example.com',
+        token: tokenPlaceholder,
+    }
+    const edits = setProperty(config, ['gitea', -1], value, defaultFormattingOptions)
+    return { edits, selectText: tokenPlaceholder }
+}
+
 const addAWSCodeCommit: ConfigInsertionFunction = config => {
     const value: AwsCodeCommitConnection = {
         region: '' as any,
@@ -149,6 +173,8 @@ export const siteConfigActions: EditorAction[] = [
     },
     { id: 'sourcegraph.site.addGitLab', label: 'Add GitLab projects', run: addGitLab },
     { id: 'sourcegraph.site.addBitbucketServer', label: 'Add Bitbucket Server repositories', run: addBitbucketServer },
+    { id: 'sourcegraph.site.addBitbucketCloud', label: 'Add Bitbucket Cloud repositories', run: addBitbucketCloud },
+    { id: 'sourcegraph.site.addGitea', label: 'Add Gitea repositories', run: addGitea },
     { id: 'sourcegraph.site.addAWSCodeCommit', label: 'Add AWS CodeCommit repositories', run: addAWSCodeCommit },
     { id: 'sourcegraph.site.otherRepository', label: 'Add other repository', run: addOtherRepository },
     {